@@ -0,0 +1,91 @@
+// Command breach-import builds or refreshes the breached password corpus
+// used by the password policy.
+//
+//	breach-import -out breached-passwords.bin [-min-count 1] SOURCE
+//
+// SOURCE is either the sorted pwned-passwords-sha1-ordered-by-hash.txt file
+// or a directory of range files from the Pwned Passwords downloader. The
+// corpus is written next to -out and renamed into place when complete, so
+// a running server keeps its current corpus until restarted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/natchaphonbw/usermanagement/pkg/breach"
+)
+
+func main() {
+	out := flag.String("out", "breached-passwords.bin", "corpus file to write")
+	minCount := flag.Uint("min-count", 1, "skip hashes seen fewer times than this")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] SOURCE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	started := time.Now()
+	added, err := importCorpus(flag.Arg(0), *out, uint32(*minCount))
+	if err != nil {
+		log.Fatalf("Failed to import breach corpus: %v", err)
+	}
+
+	log.Printf("Imported %d hashes into %s in %s", added, *out, time.Since(started).Round(time.Second))
+}
+
+func importCorpus(source, out string, minCount uint32) (int, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp := out + ".tmp"
+	w, err := breach.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+
+	var added int
+	if info.IsDir() {
+		added, err = breach.ImportRanges(w, source, minCount)
+	} else {
+		added, err = importFile(w, source, minCount)
+	}
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+
+	// make sure the result opens before replacing the old corpus
+	corpus, err := breach.Open(tmp)
+	if err != nil {
+		return 0, err
+	}
+	corpus.Close()
+
+	return added, os.Rename(tmp, out)
+}
+
+func importFile(w *breach.Writer, source string, minCount uint32) (int, error) {
+	f, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return breach.ImportHashes(w, f, minCount)
+}
@@ -3,6 +3,10 @@ package config
 import (
 	"log"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -10,7 +14,12 @@ import (
 type Config struct {
 	FiberHost string
 	FiberPort string
+	// ShutdownTimeout bounds how long in-flight requests get to finish
+	// after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration
 
+	DBDriver   string
+	DBPath     string
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -19,6 +28,103 @@ type Config struct {
 
 	JWT_ACCESS_SECRET  string
 	JWT_REFRESH_SECRET string
+
+	AdminAPIKey string
+	// MetricsToken is the bearer token scrapers send to /metrics, which
+	// is disabled while it is empty.
+	MetricsToken string
+
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	MagicLinkURL string
+
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	ReauthMaxAge time.Duration
+
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordMaxRepeat     int
+	PasswordMinScore      int
+
+	BreachCorpusPath string
+	BreachMinCount   int
+
+	PasswordPepper     string
+	PasswordPepperID   string
+	PasswordPepperFile string
+
+	PasswordHashConcurrency  int
+	PasswordHashMaxQueue     int
+	PasswordHashQueueTimeout time.Duration
+
+	RateLimitStore string
+	RateLimits     string
+	RedisAddr      string
+	RedisPassword  string
+	RedisDB        int
+
+	OIDCIssuer         string
+	OIDCLoginURL       string
+	OIDCSigningKeyFile string
+
+	SSOProviders []SSOProvider
+
+	LDAPURL            string
+	LDAPStartTLS       bool
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string
+	LDAPEmailAttribute string
+	LDAPNameAttribute  string
+	LDAPGroupAttribute string
+	LDAPGroupRoles     string
+	LDAPTimeout        time.Duration
+
+	SAMLBaseURL   string
+	SAMLKeyFile   string
+	SAMLCertFile  string
+	SAMLProviders []SAMLProvider
+}
+
+// SSOProvider is an upstream OpenID Connect provider users can sign in
+// with, registered with RedirectURL, the login page it sends them back to.
+type SSOProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// SAMLProvider is a SAML identity provider users can sign in with, which
+// sends them back to RedirectURL, the login page, with a code.
+type SAMLProvider struct {
+	Name            string
+	IDPMetadataFile string
+	RedirectURL     string
+	Binding         string
+	EmailAttribute  string
+	NameAttribute   string
+	LocaleAttribute string
+	// TrustEmail links the provider's users to accounts with the same
+	// verified email. Only set it for providers users can't pick their
+	// email at.
+	TrustEmail bool
 }
 
 func LoadConfig() *Config {
@@ -28,15 +134,140 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		FiberHost: getEnv("FIBER_HOST", "0.0.0.0"),
-		FiberPort: getEnv("FIBER_PORT", "5000"),
+		FiberHost:       getEnv("FIBER_HOST", "0.0.0.0"),
+		FiberPort:       getEnv("FIBER_PORT", "5000"),
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
 
+		DBDriver:   getEnv("DB_DRIVER", "postgres"),
+		DBPath:     getEnv("DB_PATH", "usermanagement.db"),
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "user"),
 		DBPassword: getEnv("DB_PASSWORD", "password"),
 		DBName:     getEnv("DB_NAME", "user_db"),
+
+		AdminAPIKey:  getEnv("ADMIN_API_KEY", ""),
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailDir:      getEnv("MAIL_DIR", "tmp/mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "25"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		// the login page emailed links open, with ?token=
+		MagicLinkURL: getEnv("MAGIC_LINK_URL", "http://localhost:3000/magic-link"),
+
+		// passkeys are scoped to the RP ID domain and created on the origins
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "User Management"),
+		WebAuthnOrigins: strings.Fields(getEnv("WEBAUTHN_ORIGINS", "http://localhost:3000")),
+
+		// sensitive endpoints need a login at most this old
+		ReauthMaxAge: getEnvDuration("REAUTH_MAX_AGE", 10*time.Minute),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordMaxLength:     getEnvInt("PASSWORD_MAX_LENGTH", 128),
+		PasswordRequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+		PasswordRequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+		PasswordRequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordMaxRepeat:     getEnvInt("PASSWORD_MAX_REPEAT", 3),
+		PasswordMinScore:      getEnvInt("PASSWORD_MIN_SCORE", 3),
+
+		BreachCorpusPath: getEnv("BREACH_CORPUS_PATH", ""),
+		BreachMinCount:   getEnvInt("BREACH_MIN_COUNT", 1),
+
+		PasswordPepper:     getEnv("PASSWORD_PEPPER", ""),
+		PasswordPepperID:   getEnv("PASSWORD_PEPPER_ID", "1"),
+		PasswordPepperFile: getEnv("PASSWORD_PEPPER_FILE", ""),
+
+		PasswordHashConcurrency:  getEnvInt("PASSWORD_HASH_CONCURRENCY", runtime.GOMAXPROCS(0)),
+		PasswordHashMaxQueue:     getEnvInt("PASSWORD_HASH_MAX_QUEUE", 100),
+		PasswordHashQueueTimeout: getEnvDuration("PASSWORD_HASH_QUEUE_TIMEOUT", 2*time.Second),
+
+		// ROUTE=KEY:LIMIT/WINDOW[:ALGORITHM],...;... see ratelimit.ParsePolicies
+		RateLimitStore: getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimits:     getEnv("RATE_LIMITS", "auth.login=ip:20/1m,email:5/15m:sliding_window;auth.register=ip:5/1h;auth.magic_link=ip:20/1h,email:3/15m;users=ip:60/1m;oauth.token=ip:60/1m"),
+		RedisAddr:      getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:  getEnv("REDIS_PASSWORD", ""),
+		RedisDB:        getEnvInt("REDIS_DB", 0),
+
+		OIDCIssuer:         getEnv("OIDC_ISSUER", "http://localhost:5000"),
+		OIDCLoginURL:       getEnv("OIDC_LOGIN_URL", "http://localhost:3000/authorize"),
+		OIDCSigningKeyFile: getEnv("OIDC_SIGNING_KEY_FILE", ""),
+
+		// SSO_PROVIDERS=corp,... each set by SSO_CORP_ISSUER and so on
+		SSOProviders: getSSOProviders(),
+
+		// an empty LDAP_URL turns directory logins off
+		LDAPURL:            getEnv("LDAP_URL", ""),
+		LDAPStartTLS:       getEnvBool("LDAP_START_TLS", false),
+		LDAPBindDN:         getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:         getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(mail=%s)"),
+		LDAPEmailAttribute: getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPNameAttribute:  getEnv("LDAP_NAME_ATTRIBUTE", "displayName"),
+		LDAPGroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		// ROLE=GROUP_DN;... the first group the user is in wins
+		LDAPGroupRoles: getEnv("LDAP_GROUP_ROLES", ""),
+		LDAPTimeout:    getEnvDuration("LDAP_TIMEOUT", 5*time.Second),
+
+		// the public URL identity providers reach the service at
+		SAMLBaseURL: getEnv("SAML_BASE_URL", getEnv("OIDC_ISSUER", "http://localhost:5000")),
+		// without both a key is generated on every start
+		SAMLKeyFile:  getEnv("SAML_KEY_FILE", ""),
+		SAMLCertFile: getEnv("SAML_CERT_FILE", ""),
+		// SAML_PROVIDERS=corp,... each set by SAML_CORP_IDP_METADATA_FILE and so on
+		SAMLProviders: getSAMLProviders(),
+	}
+}
+
+func getSSOProviders() []SSOProvider {
+	var providers []SSOProvider
+	for _, name := range strings.Split(getEnv("SSO_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "SSO_" + strings.ToUpper(name) + "_"
+		providers = append(providers, SSOProvider{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		})
 	}
+	return providers
+}
+
+func getSAMLProviders() []SAMLProvider {
+	var providers []SAMLProvider
+	for _, name := range strings.Split(getEnv("SAML_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "SAML_" + strings.ToUpper(name) + "_"
+		providers = append(providers, SAMLProvider{
+			Name:            name,
+			IDPMetadataFile: getEnv(prefix+"IDP_METADATA_FILE", ""),
+			RedirectURL:     getEnv(prefix+"REDIRECT_URL", ""),
+			Binding:         getEnv(prefix+"BINDING", "redirect"),
+			EmailAttribute:  getEnv(prefix+"EMAIL_ATTRIBUTE", ""),
+			NameAttribute:   getEnv(prefix+"NAME_ATTRIBUTE", ""),
+			LocaleAttribute: getEnv(prefix+"LOCALE_ATTRIBUTE", ""),
+			TrustEmail:      getEnvBool(prefix+"TRUST_EMAIL", false),
+		})
+	}
+	return providers
 }
 
 func getEnv(key, defaultVal string) string {
@@ -45,3 +276,45 @@ func getEnv(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+func getEnvInt(key string, defaultVal int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultVal
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %d", key, value, defaultVal)
+		return defaultVal
+	}
+	return n
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultVal
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %t", key, value, defaultVal)
+		return defaultVal
+	}
+	return b
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultVal
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s", key, value, defaultVal)
+		return defaultVal
+	}
+	return d
+}
@@ -1,30 +1,90 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/natchaphonbw/usermanagement/config"
 	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/pkg/breach"
 	"github.com/natchaphonbw/usermanagement/pkg/databases"
 	"github.com/natchaphonbw/usermanagement/pkg/databases/migrations"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
 	"github.com/natchaphonbw/usermanagement/server"
 )
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves until SIGINT, SIGTERM or a listen error. It returns errors
+// rather than exiting so the deferred closes always run.
+func run() error {
 	// validate init
 	validator.Init()
 	// Load configuration
 	cfg := config.LoadConfig()
+	// Load the password pepper
+	peppers, err := utils.LoadPeppers(cfg.PasswordPepperID, cfg.PasswordPepper, cfg.PasswordPepperFile)
+	if err != nil {
+		log.Fatalf("Failed to load password pepper: %v", err)
+	}
+	utils.PasswordPeppers = peppers
+	// Bound concurrent password hashing
+	utils.PasswordHashLimiter = utils.NewHashLimiter(cfg.PasswordHashConcurrency, cfg.PasswordHashMaxQueue, cfg.PasswordHashQueueTimeout)
 	// Connect to the database
 	db := databases.Connect(cfg)
 	// Run migrations
 	migrations.Migrate(db)
 
+	// Start the mail queue
+	mailQueue, err := mailer.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up mailer: %w", err)
+	}
+	defer mailQueue.Close()
+
+	// Load the breached password corpus, built with cmd/breach-import
+	var breaches validator.BreachChecker
+	if cfg.BreachCorpusPath != "" {
+		corpus, err := breach.Open(cfg.BreachCorpusPath)
+		if err != nil {
+			return fmt.Errorf("failed to load breach corpus: %w", err)
+		}
+		defer corpus.Close()
+		log.Printf("Loaded %d breached password hashes", corpus.Len())
+		breaches = corpus
+	}
+
+	// Start background workers, they stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server.StartWorkers(ctx, db)
+
 	app := server.NewFiberApp()
 
-	server.SetupRoutes(app, db)
+	server.SetupRoutes(app, db, cfg, mailQueue, breaches)
 
 	addr := fmt.Sprintf("%s:%s", cfg.FiberHost, cfg.FiberPort)
-	app.Listen(addr)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(addr)
+	}()
 
+	// On a signal let in-flight requests finish, then the deferred closes
+	// stop the workers, flush the mail queue and release the corpus
+	select {
+	case err := <-listenErr:
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+		return app.ShutdownWithTimeout(cfg.ShutdownTimeout)
+	}
 }
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type ClientController struct {
+	clientUsecase usecases.ClientUsecase
+}
+
+func NewClientController(u usecases.ClientUsecase) *ClientController {
+	return &ClientController{
+		clientUsecase: u,
+	}
+}
+
+// CreateClient
+func (ctrl *ClientController) CreateClient(c *fiber.Ctx) error {
+	var req dtos.CreateClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.clientUsecase.CreateClient(c.Context(), req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// GetAllClients
+func (ctrl *ClientController) GetAllClients(c *fiber.Ctx) error {
+	resp, respErr := ctrl.clientUsecase.GetAllClients(c.Context())
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// GetClientByID
+func (ctrl *ClientController) GetClientByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid client ID", err))
+	}
+
+	resp, respErr := ctrl.clientUsecase.GetClientByID(c.Context(), id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// UpdateClient
+func (ctrl *ClientController) UpdateClient(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid client ID", err))
+	}
+
+	var req dtos.UpdateClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.clientUsecase.UpdateClient(c.Context(), id, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// DeleteClient
+func (ctrl *ClientController) DeleteClient(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid client ID", err))
+	}
+
+	if respErr := ctrl.clientUsecase.DeleteClient(c.Context(), id); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/usecases"
+)
+
+const (
+	grantClientCredentials = "client_credentials"
+	grantAuthorizationCode = "authorization_code"
+)
+
+type OAuthController struct {
+	tokenUsecase usecases.TokenUsecase
+}
+
+func NewOAuthController(u usecases.TokenUsecase) *OAuthController {
+	return &OAuthController{
+		tokenUsecase: u,
+	}
+}
+
+// Token is the RFC 6749 token endpoint.
+func (ctrl *OAuthController) Token(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+		return sendTokenError(c, &dtos.TokenError{Code: usecases.ErrInvalidRequest, Description: "Request must be form encoded", Status: fiber.StatusBadRequest})
+	}
+
+	var req dtos.TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendTokenError(c, &dtos.TokenError{Code: usecases.ErrInvalidRequest, Description: "Invalid request body", Status: fiber.StatusBadRequest})
+	}
+
+	clientID, clientSecret, basic, ok := clientCredentials(c, req)
+	if !ok {
+		return sendTokenError(c, &dtos.TokenError{Code: usecases.ErrInvalidRequest, Description: "Use one client authentication method", Status: fiber.StatusBadRequest})
+	}
+
+	var (
+		resp     *dtos.TokenResponse
+		tokenErr *dtos.TokenError
+	)
+	switch req.GrantType {
+	case "":
+		tokenErr = &dtos.TokenError{Code: usecases.ErrInvalidRequest, Description: "grant_type is required", Status: fiber.StatusBadRequest}
+	case grantClientCredentials:
+		if clientID == "" || clientSecret == "" {
+			tokenErr = &dtos.TokenError{Code: usecases.ErrInvalidClient, Description: "Client authentication is required", Status: fiber.StatusUnauthorized}
+			break
+		}
+		resp, tokenErr = ctrl.tokenUsecase.ClientCredentials(c.Context(), clientID, clientSecret, req.Scope)
+	case grantAuthorizationCode:
+		// public clients send only their ID, and rely on PKCE
+		if clientID == "" {
+			tokenErr = &dtos.TokenError{Code: usecases.ErrInvalidClient, Description: "Client authentication is required", Status: fiber.StatusUnauthorized}
+			break
+		}
+		resp, tokenErr = ctrl.tokenUsecase.AuthorizationCode(c.Context(), clientID, clientSecret, req.Code, req.RedirectURI, req.CodeVerifier)
+	default:
+		tokenErr = &dtos.TokenError{Code: usecases.ErrUnsupportedGrantType, Status: fiber.StatusBadRequest}
+	}
+
+	if tokenErr != nil {
+		if tokenErr.Code == usecases.ErrInvalidClient && (basic || clientID == "") {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		}
+		return sendTokenError(c, tokenErr)
+	}
+	return c.JSON(resp)
+}
+
+// clientCredentials reads the client's ID and secret from HTTP Basic
+// authentication or the request body, ok is false if both are used.
+func clientCredentials(c *fiber.Ctx, req dtos.TokenRequest) (clientID, clientSecret string, basic, ok bool) {
+	encoded, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Basic ")
+	if !found {
+		return req.ClientID, req.ClientSecret, false, true
+	}
+	if req.ClientID != "" || req.ClientSecret != "" {
+		return "", "", true, false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", true, true
+	}
+	id, secret, _ := strings.Cut(string(decoded), ":")
+
+	// RFC 6749 section 2.3.1 form encodes both before Basic encoding
+	if clientID, err = url.QueryUnescape(id); err != nil {
+		return "", "", true, true
+	}
+	if clientSecret, err = url.QueryUnescape(secret); err != nil {
+		return "", "", true, true
+	}
+	return clientID, clientSecret, true, true
+}
+
+func sendTokenError(c *fiber.Ctx, tokenErr *dtos.TokenError) error {
+	return c.Status(tokenErr.Status).JSON(tokenErr)
+}
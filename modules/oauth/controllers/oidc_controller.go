@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type OIDCController struct {
+	authorizationUsecase usecases.AuthorizationUsecase
+	provider             *usecases.Provider
+}
+
+func NewOIDCController(u usecases.AuthorizationUsecase, provider *usecases.Provider) *OIDCController {
+	return &OIDCController{
+		authorizationUsecase: u,
+		provider:             provider,
+	}
+}
+
+// Discovery
+func (ctrl *OIDCController) Discovery(c *fiber.Ctx) error {
+	return c.JSON(ctrl.provider.Discovery())
+}
+
+// JWKS
+func (ctrl *OIDCController) JWKS(c *fiber.Ctx) error {
+	return c.JSON(ctrl.provider.Key.JWKS())
+}
+
+// Authorize is the authorization endpoint. The browser is sent on to the
+// login page, or back to the client if the request is wrong.
+func (ctrl *OIDCController) Authorize(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	var req dtos.AuthorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid query", err))
+	}
+
+	location, respErr := ctrl.authorizationUsecase.Authorize(c.Context(), req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Redirect(location, fiber.StatusFound)
+}
+
+// GetAuthorizationRequest
+func (ctrl *OIDCController) GetAuthorizationRequest(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid authorization request ID", err))
+	}
+
+	resp, respErr := ctrl.authorizationUsecase.GetRequest(c.Context(), userID, id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// DecideAuthorizationRequest
+func (ctrl *OIDCController) DecideAuthorizationRequest(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid authorization request ID", err))
+	}
+
+	var req dtos.DecideAuthorizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.authorizationUsecase.DecideRequest(c.Context(), userID, id, *req.Approve)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// UserInfo is the userinfo endpoint, authenticated with an access token
+// from the token endpoint. Errors are described in WWW-Authenticate as
+// RFC 6750 requires.
+func (ctrl *OIDCController) UserInfo(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	tokenStr, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="oauth"`)
+		return app_errors.Send(c, app_errors.Unautherized("Invalid or missing Authorization header", nil))
+	}
+
+	resp, respErr := ctrl.authorizationUsecase.UserInfo(c.Context(), tokenStr)
+	if respErr != nil {
+		switch respErr.Code {
+		case fiber.StatusUnauthorized:
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="oauth", error="invalid_token"`)
+		case fiber.StatusForbidden:
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="oauth", error="insufficient_scope", scope="openid"`)
+		}
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
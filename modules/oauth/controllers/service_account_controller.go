@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type ServiceAccountController struct {
+	serviceAccountUsecase usecases.ServiceAccountUsecase
+}
+
+func NewServiceAccountController(u usecases.ServiceAccountUsecase) *ServiceAccountController {
+	return &ServiceAccountController{
+		serviceAccountUsecase: u,
+	}
+}
+
+// CreateServiceAccount
+func (ctrl *ServiceAccountController) CreateServiceAccount(c *fiber.Ctx) error {
+	var req dtos.CreateServiceAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.serviceAccountUsecase.CreateServiceAccount(c.Context(), req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// GetAllServiceAccounts
+func (ctrl *ServiceAccountController) GetAllServiceAccounts(c *fiber.Ctx) error {
+	resp, respErr := ctrl.serviceAccountUsecase.GetAllServiceAccounts(c.Context())
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// GetServiceAccountByID
+func (ctrl *ServiceAccountController) GetServiceAccountByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid service account ID", err))
+	}
+
+	resp, respErr := ctrl.serviceAccountUsecase.GetServiceAccountByID(c.Context(), id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// UpdateServiceAccount
+func (ctrl *ServiceAccountController) UpdateServiceAccount(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid service account ID", err))
+	}
+
+	var req dtos.UpdateServiceAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.serviceAccountUsecase.UpdateServiceAccount(c.Context(), id, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// DeleteServiceAccount
+func (ctrl *ServiceAccountController) DeleteServiceAccount(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid service account ID", err))
+	}
+
+	if respErr := ctrl.serviceAccountUsecase.DeleteServiceAccount(c.Context(), id); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RotateSecret
+func (ctrl *ServiceAccountController) RotateSecret(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid service account ID", err))
+	}
+
+	// the body is optional
+	var req dtos.RotateSecretRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+		}
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.serviceAccountUsecase.RotateSecret(c.Context(), id, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// DeleteSecret
+func (ctrl *ServiceAccountController) DeleteSecret(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid service account ID", err))
+	}
+
+	secretID, err := uuid.Parse(c.Params("secretID"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid secret ID", err))
+	}
+
+	if respErr := ctrl.serviceAccountUsecase.DeleteSecret(c.Context(), id, secretID); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,72 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthorizeRequest is the query of an authorization request.
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type"`
+	ClientID            string `query:"client_id"`
+	RedirectURI         string `query:"redirect_uri"`
+	Scope               string `query:"scope"`
+	State               string `query:"state"`
+	Nonce               string `query:"nonce"`
+	Prompt              string `query:"prompt"`
+	CodeChallenge       string `query:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method"`
+}
+
+// DecideAuthorizationRequest is the user's answer on the consent screen.
+type DecideAuthorizationRequest struct {
+	Approve *bool `json:"approve" validate:"required"`
+}
+
+// Response
+
+// AuthorizationRequestResponse is what the consent screen shows. If
+// ConsentRequired is false the user has already granted every scope, and
+// the screen may approve without asking.
+type AuthorizationRequestResponse struct {
+	ID              uuid.UUID `json:"id"`
+	ClientID        string    `json:"client_id"`
+	ClientName      string    `json:"client_name"`
+	Scopes          []string  `json:"scopes"`
+	Prompt          string    `json:"prompt,omitempty"`
+	ConsentRequired bool      `json:"consent_required"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// AuthorizationDecisionResponse is where to send the user's browser back
+// to the client, with a code or an error.
+type AuthorizationDecisionResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// UserInfoResponse holds the standard claims of the scopes granted.
+type UserInfoResponse struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// DiscoveryResponse is the OpenID Provider metadata.
+type DiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	AuthorizationResponseIssParameter bool     `json:"authorization_response_iss_parameter_supported"`
+}
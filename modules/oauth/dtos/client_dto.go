@@ -0,0 +1,59 @@
+package dtos
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+)
+
+type CreateClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	Public       bool     `json:"public"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,max=10,dive,required,url"`
+}
+
+type UpdateClientRequest struct {
+	Name         *string  `json:"name" validate:"omitempty,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"omitempty,min=1,max=10,dive,required,url"`
+}
+
+// Response
+
+type ClientResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	ClientID     string    `json:"client_id"`
+	Public       bool      `json:"public"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CreateClientResponse is the only response that includes the secret of a
+// confidential client.
+type CreateClientResponse struct {
+	ClientResponse
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func FromClientEntity(client *entities.Client) *ClientResponse {
+	return &ClientResponse{
+		ID:           client.ID,
+		Name:         client.Name,
+		ClientID:     client.ClientID,
+		Public:       client.Public,
+		RedirectURIs: strings.Fields(client.RedirectURIs),
+		CreatedAt:    client.Created_at,
+		UpdatedAt:    client.Updated_at,
+	}
+}
+
+func FromClientEntities(clients []entities.Client) []*ClientResponse {
+	clientResponse := make([]*ClientResponse, 0, len(clients))
+	for _, client := range clients {
+		clientResponse = append(clientResponse, FromClientEntity(&client))
+	}
+	return clientResponse
+}
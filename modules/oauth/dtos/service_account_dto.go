@@ -0,0 +1,99 @@
+package dtos
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+)
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Description string   `json:"description" validate:"omitempty,max=255"`
+	Scopes      []string `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+type UpdateServiceAccountRequest struct {
+	Name        *string  `json:"name" validate:"omitempty,max=100"`
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	Scopes      []string `json:"scopes" validate:"omitempty,min=1,dive,required"`
+	Disabled    *bool    `json:"disabled"`
+}
+
+// RotateSecretRequest sets how long the current secrets keep working
+// next to the new one, by default a day. 0 expires them at once.
+type RotateSecretRequest struct {
+	OverlapSeconds *int `json:"overlap_seconds" validate:"omitempty,min=0,max=2592000"`
+}
+
+// Response
+
+type ServiceAccountSecretResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Prefix     string     `json:"prefix"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ServiceAccountResponse struct {
+	ID          uuid.UUID                       `json:"id"`
+	Name        string                          `json:"name"`
+	Description string                          `json:"description"`
+	ClientID    string                          `json:"client_id"`
+	Scopes      []string                        `json:"scopes"`
+	Disabled    bool                            `json:"disabled"`
+	Secrets     []*ServiceAccountSecretResponse `json:"secrets"`
+	CreatedAt   time.Time                       `json:"created_at"`
+	UpdatedAt   time.Time                       `json:"updated_at"`
+}
+
+// CreateServiceAccountResponse and ClientSecretResponse are the only
+// responses that include a client secret.
+type CreateServiceAccountResponse struct {
+	ServiceAccountResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+type ClientSecretResponse struct {
+	ServiceAccountSecretResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+func FromServiceAccountSecretEntity(secret *entities.ServiceAccountSecret) *ServiceAccountSecretResponse {
+	return &ServiceAccountSecretResponse{
+		ID:         secret.ID,
+		Prefix:     secret.Prefix,
+		ExpiresAt:  secret.ExpiresAt,
+		LastUsedAt: secret.LastUsedAt,
+		CreatedAt:  secret.Created_at,
+	}
+}
+
+func FromServiceAccountEntity(account *entities.ServiceAccount) *ServiceAccountResponse {
+	secrets := make([]*ServiceAccountSecretResponse, 0, len(account.Secrets))
+	for _, secret := range account.Secrets {
+		secrets = append(secrets, FromServiceAccountSecretEntity(&secret))
+	}
+
+	return &ServiceAccountResponse{
+		ID:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		ClientID:    account.ClientID,
+		Scopes:      strings.Fields(account.Scopes),
+		Disabled:    account.Disabled,
+		Secrets:     secrets,
+		CreatedAt:   account.Created_at,
+		UpdatedAt:   account.Updated_at,
+	}
+}
+
+func FromServiceAccountEntities(accounts []entities.ServiceAccount) []*ServiceAccountResponse {
+	accountResponse := make([]*ServiceAccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		accountResponse = append(accountResponse, FromServiceAccountEntity(&account))
+	}
+	return accountResponse
+}
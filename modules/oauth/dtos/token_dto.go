@@ -0,0 +1,33 @@
+package dtos
+
+// TokenRequest is a token endpoint request, form encoded as RFC 6749
+// requires. The client may authenticate with HTTP Basic instead of
+// ClientID and ClientSecret.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+// TokenError is an RFC 6749 section 5.2 error response.
+type TokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *TokenError) Error() string {
+	return e.Code + ": " + e.Description
+}
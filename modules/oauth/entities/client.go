@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	userEntities "github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+// Prefixes of relying party credentials.
+const (
+	AppClientIDPrefix     = "app_"
+	AppClientSecretPrefix = "aps_"
+)
+
+// OpenID Connect scopes a relying party can request.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Client is a relying party, an application users sign in to through this
+// service with OpenID Connect. A public client, such as a single page app,
+// has no secret and is held to PKCE alone.
+type Client struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
+	ClientID     string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"client_id"`
+	SecretHash   string    `json:"-"`
+	Public       bool      `gorm:"not null;default:false" json:"public"`
+	RedirectURIs string    `gorm:"type:text;not null" json:"redirect_uris"` // space separated
+	Created_at   time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	Updated_at   time.Time `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+}
+
+// AllowsRedirect reports whether uri is one of the client's redirect URIs,
+// compared exactly.
+func (c *Client) AllowsRedirect(uri string) bool {
+	return slices.Contains(strings.Fields(c.RedirectURIs), uri)
+}
+
+// AuthorizationRequest is a request to /oauth/authorize waiting for the
+// user to sign in and consent. Once approved it holds the authorization
+// code, which is exchanged for tokens once and then deleted.
+type AuthorizationRequest struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ClientID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"client_id"` // Client.ID
+	RedirectURI   string     `gorm:"type:text;not null" json:"redirect_uri"`
+	Scope         string     `gorm:"type:text;not null" json:"scope"` // space separated
+	State         string     `gorm:"type:text" json:"state"`
+	Nonce         string     `gorm:"type:text" json:"nonce"`
+	Prompt        string     `gorm:"type:varchar(50)" json:"prompt"`
+	CodeChallenge string     `gorm:"type:varchar(128);not null" json:"-"`
+	CodeHash      *string    `gorm:"uniqueIndex" json:"-"`
+	UserID        *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	Created_at    time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// Consent records the scopes a user has granted a client, so they are
+// only asked again for new ones.
+type Consent struct {
+	ID         uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	User       userEntities.User `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID     uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_consent_user_client" json:"user_id"`
+	ClientID   uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_consent_user_client" json:"client_id"`
+	Scopes     string            `gorm:"type:text;not null" json:"scopes"` // space separated
+	Created_at time.Time         `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	Updated_at time.Time         `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+}
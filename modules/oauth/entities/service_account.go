@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Prefixes of service account credentials.
+const (
+	ClientIDPrefix     = "svc_"
+	ClientSecretPrefix = "sas_"
+)
+
+// Scopes a service account can be granted, checked on the admin API.
+const (
+	ScopeUsersImport   = "users:import"
+	ScopeWebhooksRead  = "webhooks:read"
+	ScopeWebhooksWrite = "webhooks:write"
+)
+
+// ServiceAccount is the identity of a backend service, which gets access
+// tokens with the client credentials grant instead of logging in.
+type ServiceAccount struct {
+	ID          uuid.UUID              `gorm:"type:uuid;primaryKey" json:"id"`
+	Name        string                 `gorm:"type:varchar(100);not null" json:"name"`
+	Description string                 `gorm:"type:varchar(255)" json:"description"`
+	ClientID    string                 `gorm:"type:varchar(64);not null;uniqueIndex" json:"client_id"`
+	Scopes      string                 `gorm:"type:text;not null" json:"scopes"` // space separated
+	Disabled    bool                   `gorm:"not null;default:false" json:"disabled"`
+	Secrets     []ServiceAccountSecret `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Created_at  time.Time              `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	Updated_at  time.Time              `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+}
+
+// ServiceAccountSecret is one of a service account's client secrets, of
+// which only the SHA-256 is stored. An account has more than one while a
+// secret is rotated: the old one keeps working until ExpiresAt.
+type ServiceAccountSecret struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ServiceAccountID uuid.UUID  `gorm:"type:uuid;not null;index" json:"service_account_id"`
+	Prefix           string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	SecretHash       string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	Created_at       time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// Active reports whether the secret can still be used at now.
+func (s *ServiceAccountSecret) Active(now time.Time) bool {
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
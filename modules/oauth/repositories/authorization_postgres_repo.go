@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type authorizationPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthorizationPostgresRepository(db *gorm.DB) AuthorizationRepository {
+	return &authorizationPostgresRepository{db: db}
+}
+
+// CreateRequest
+func (r *authorizationPostgresRepository) CreateRequest(ctx context.Context, request *entities.AuthorizationRequest) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(request).Error)
+}
+
+// GetRequest
+func (r *authorizationPostgresRepository) GetRequest(ctx context.Context, id uuid.UUID) (*entities.AuthorizationRequest, error) {
+	var request entities.AuthorizationRequest
+	err := databases.Conn(ctx, r.db).
+		Where("code_hash IS NULL AND expires_at > ?", time.Now()).
+		First(&request, "id = ?", id).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &request, nil
+}
+
+// ApproveRequest
+func (r *authorizationPostgresRepository) ApproveRequest(ctx context.Context, id, userID uuid.UUID, codeHash string, expiresAt time.Time) error {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.AuthorizationRequest{}).
+		Where("id = ? AND code_hash IS NULL AND expires_at > ?", id, time.Now()).
+		Updates(map[string]any{"user_id": userID, "code_hash": codeHash, "expires_at": expiresAt})
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	// approved by a concurrent request, or expired
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteRequest
+func (r *authorizationPostgresRepository) DeleteRequest(ctx context.Context, id uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).Delete(&entities.AuthorizationRequest{}, "id = ?", id)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ConsumeCode
+func (r *authorizationPostgresRepository) ConsumeCode(ctx context.Context, codeHash string) (*entities.AuthorizationRequest, error) {
+	var request entities.AuthorizationRequest
+	if err := databases.Conn(ctx, r.db).First(&request, "code_hash = ?", codeHash).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+
+	// only the caller that deletes the row gets the code
+	if err := r.DeleteRequest(ctx, request.ID); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetConsent
+func (r *authorizationPostgresRepository) GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*entities.Consent, error) {
+	var consent entities.Consent
+	if err := databases.Conn(ctx, r.db).First(&consent, "user_id = ? AND client_id = ?", userID, clientID).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &consent, nil
+}
+
+// SaveConsent inserts the consent, or replaces the scopes of the user's
+// existing consent to the client.
+func (r *authorizationPostgresRepository) SaveConsent(ctx context.Context, consent *entities.Consent) error {
+	err := databases.Conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent).Error
+	return databases.TranslateError(err)
+}
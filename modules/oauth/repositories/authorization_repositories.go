@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+)
+
+type AuthorizationRepository interface {
+	CreateRequest(ctx context.Context, request *entities.AuthorizationRequest) error
+	// GetRequest returns a request still waiting for the user, which is
+	// neither approved nor expired.
+	GetRequest(ctx context.Context, id uuid.UUID) (*entities.AuthorizationRequest, error)
+	// ApproveRequest attaches the user and code to a waiting request.
+	ApproveRequest(ctx context.Context, id, userID uuid.UUID, codeHash string, expiresAt time.Time) error
+	DeleteRequest(ctx context.Context, id uuid.UUID) error
+	// ConsumeCode deletes and returns the approved request with the code,
+	// which fails for all but one of concurrent callers.
+	ConsumeCode(ctx context.Context, codeHash string) (*entities.AuthorizationRequest, error)
+
+	GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*entities.Consent, error)
+	SaveConsent(ctx context.Context, consent *entities.Consent) error
+}
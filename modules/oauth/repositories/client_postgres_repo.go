@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type clientPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewClientPostgresRepository(db *gorm.DB) ClientRepository {
+	return &clientPostgresRepository{db: db}
+}
+
+// Create
+func (r *clientPostgresRepository) Create(ctx context.Context, client *entities.Client) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(client).Error)
+}
+
+// GetAll
+func (r *clientPostgresRepository) GetAll(ctx context.Context) ([]entities.Client, error) {
+	var clients []entities.Client
+	if err := databases.Conn(ctx, r.db).Order("created_at").Find(&clients).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return clients, nil
+}
+
+// GetByID
+func (r *clientPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entities.Client, error) {
+	var client entities.Client
+	if err := databases.Conn(ctx, r.db).First(&client, "id = ?", id).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &client, nil
+}
+
+// GetByClientID
+func (r *clientPostgresRepository) GetByClientID(ctx context.Context, clientID string) (*entities.Client, error) {
+	var client entities.Client
+	if err := databases.Conn(ctx, r.db).First(&client, "client_id = ?", clientID).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &client, nil
+}
+
+// Update
+func (r *clientPostgresRepository) Update(ctx context.Context, client *entities.Client) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Save(client).Error)
+}
+
+// Delete
+func (r *clientPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&entities.Client{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		// SQLite only cascades with foreign keys on
+		if err := tx.Delete(&entities.AuthorizationRequest{}, "client_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entities.Consent{}, "client_id = ?", id).Error
+	}))
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+)
+
+type ClientRepository interface {
+	Create(ctx context.Context, client *entities.Client) error
+	GetAll(ctx context.Context) ([]entities.Client, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entities.Client, error)
+	GetByClientID(ctx context.Context, clientID string) (*entities.Client, error)
+	Update(ctx context.Context, client *entities.Client) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type serviceAccountPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountPostgresRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountPostgresRepository{db: db}
+}
+
+// Create inserts the account with its secrets.
+func (r *serviceAccountPostgresRepository) Create(ctx context.Context, account *entities.ServiceAccount) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(account).Error)
+}
+
+// GetAll
+func (r *serviceAccountPostgresRepository) GetAll(ctx context.Context) ([]entities.ServiceAccount, error) {
+	var accounts []entities.ServiceAccount
+	if err := r.withSecrets(ctx).Order("created_at").Find(&accounts).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return accounts, nil
+}
+
+// GetByID
+func (r *serviceAccountPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entities.ServiceAccount, error) {
+	var account entities.ServiceAccount
+	if err := r.withSecrets(ctx).First(&account, "id = ?", id).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &account, nil
+}
+
+// GetByClientID
+func (r *serviceAccountPostgresRepository) GetByClientID(ctx context.Context, clientID string) (*entities.ServiceAccount, error) {
+	var account entities.ServiceAccount
+	if err := r.withSecrets(ctx).First(&account, "client_id = ?", clientID).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &account, nil
+}
+
+// Update saves the account's own columns, secrets are changed separately.
+func (r *serviceAccountPostgresRepository) Update(ctx context.Context, account *entities.ServiceAccount) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Omit("Secrets").Save(account).Error)
+}
+
+// Delete
+func (r *serviceAccountPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).Delete(&entities.ServiceAccount{}, "id = ?", id)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	// SQLite only cascades with foreign keys on
+	err := databases.Conn(ctx, r.db).Delete(&entities.ServiceAccountSecret{}, "service_account_id = ?", id).Error
+	return databases.TranslateError(err)
+}
+
+// AddSecret
+func (r *serviceAccountPostgresRepository) AddSecret(ctx context.Context, secret *entities.ServiceAccountSecret) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(secret).Error)
+}
+
+// ExpireSecrets
+func (r *serviceAccountPostgresRepository) ExpireSecrets(ctx context.Context, accountID uuid.UUID, expiresAt time.Time) error {
+	err := databases.Conn(ctx, r.db).
+		Model(&entities.ServiceAccountSecret{}).
+		Where("service_account_id = ? AND (expires_at IS NULL OR expires_at > ?)", accountID, expiresAt).
+		Update("expires_at", expiresAt).Error
+	return databases.TranslateError(err)
+}
+
+// DeleteSecret
+func (r *serviceAccountPostgresRepository) DeleteSecret(ctx context.Context, accountID, secretID uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).
+		Delete(&entities.ServiceAccountSecret{}, "id = ? AND service_account_id = ?", secretID, accountID)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkSecretUsed
+func (r *serviceAccountPostgresRepository) MarkSecretUsed(ctx context.Context, secretID uuid.UUID, at time.Time) error {
+	err := databases.Conn(ctx, r.db).
+		Model(&entities.ServiceAccountSecret{}).
+		Where("id = ?", secretID).
+		Update("last_used_at", at).Error
+	return databases.TranslateError(err)
+}
+
+func (r *serviceAccountPostgresRepository) withSecrets(ctx context.Context) *gorm.DB {
+	return databases.Conn(ctx, r.db).Preload("Secrets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	})
+}
@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+)
+
+type ServiceAccountRepository interface {
+	Create(ctx context.Context, account *entities.ServiceAccount) error
+	GetAll(ctx context.Context) ([]entities.ServiceAccount, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entities.ServiceAccount, error)
+	GetByClientID(ctx context.Context, clientID string) (*entities.ServiceAccount, error)
+	Update(ctx context.Context, account *entities.ServiceAccount) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	AddSecret(ctx context.Context, secret *entities.ServiceAccountSecret) error
+	// ExpireSecrets sets expiresAt on the account's secrets that would
+	// outlive it.
+	ExpireSecrets(ctx context.Context, accountID uuid.UUID, expiresAt time.Time) error
+	DeleteSecret(ctx context.Context, accountID, secretID uuid.UUID) error
+	MarkSecretUsed(ctx context.Context, secretID uuid.UUID, at time.Time) error
+}
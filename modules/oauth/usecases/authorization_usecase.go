@@ -0,0 +1,22 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+// AuthorizationUsecase implements the OpenID Connect authorization code
+// flow up to the token endpoint, and the userinfo endpoint.
+type AuthorizationUsecase interface {
+	// Authorize checks an authorization request and returns where to
+	// redirect the browser: the login page, or the client with an error.
+	// It only fails when the client or redirect URI can't be trusted.
+	Authorize(ctx context.Context, input dtos.AuthorizeRequest) (string, *app_errors.AppError)
+	GetRequest(ctx context.Context, userID, requestID uuid.UUID) (*dtos.AuthorizationRequestResponse, *app_errors.AppError)
+	DecideRequest(ctx context.Context, userID, requestID uuid.UUID, approve bool) (*dtos.AuthorizationDecisionResponse, *app_errors.AppError)
+	UserInfo(ctx context.Context, accessToken string) (*dtos.UserInfoResponse, *app_errors.AppError)
+}
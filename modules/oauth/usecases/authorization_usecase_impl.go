@@ -0,0 +1,299 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/repositories"
+	userRepositories "github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// Authorization error codes sent back to the client, RFC 6749 section
+// 4.1.2.1 and OpenID Connect Core section 3.1.2.6.
+const (
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrAccessDenied            = "access_denied"
+	ErrLoginRequired           = "login_required"
+)
+
+const (
+	// authorizationRequestTTL is how long the user has to sign in and
+	// consent
+	authorizationRequestTTL = 10 * time.Minute
+
+	// authorizationCodeTTL is how long the client has to redeem a code
+	authorizationCodeTTL = time.Minute
+
+	// s256ChallengeLength is the length of a base64url SHA-256
+	s256ChallengeLength = 43
+)
+
+type authorizationUsecaseImpl struct {
+	clientRepo        repositories.ClientRepository
+	authorizationRepo repositories.AuthorizationRepository
+	userRepo          userRepositories.UserRepository
+	txManager         databases.TxManager
+	provider          *Provider
+}
+
+func NewAuthorizationUsecase(clientRepo repositories.ClientRepository, authorizationRepo repositories.AuthorizationRepository, userRepo userRepositories.UserRepository, txManager databases.TxManager, provider *Provider) AuthorizationUsecase {
+	return &authorizationUsecaseImpl{
+		clientRepo:        clientRepo,
+		authorizationRepo: authorizationRepo,
+		userRepo:          userRepo,
+		txManager:         txManager,
+		provider:          provider,
+	}
+}
+
+// Authorize
+func (u *authorizationUsecaseImpl) Authorize(ctx context.Context, input dtos.AuthorizeRequest) (string, *app_errors.AppError) {
+	client, err := u.clientRepo.GetByClientID(ctx, input.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", app_errors.BadRequest("Unknown client_id", err)
+		}
+		return "", app_errors.FromDB(err, "Failed to get client")
+	}
+	// never redirect to a URI the client hasn't registered
+	if !client.AllowsRedirect(input.RedirectURI) {
+		return "", app_errors.BadRequest("redirect_uri is not registered for the client", nil)
+	}
+
+	fail := func(code, description string) (string, *app_errors.AppError) {
+		return u.redirect(input.RedirectURI, input.State, map[string]string{"error": code, "error_description": description}), nil
+	}
+
+	scopes := uniqueScopes(strings.Fields(input.Scope))
+	switch {
+	case input.ResponseType != "code":
+		return fail(ErrUnsupportedResponseType, "response_type must be code")
+	case input.CodeChallengeMethod != "S256" || len(input.CodeChallenge) != s256ChallengeLength:
+		return fail(ErrInvalidRequest, "PKCE with code_challenge_method S256 is required")
+	case !slices.Contains(scopes, entities.ScopeOpenID):
+		return fail(ErrInvalidScope, "The openid scope is required")
+	case slices.Contains(strings.Fields(input.Prompt), "none"):
+		// the user can only be seen signed in on the login page
+		return fail(ErrLoginRequired, "The user must sign in")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(SupportedOIDCScopes, scope) {
+			return fail(ErrInvalidScope, "Unsupported scope "+scope)
+		}
+	}
+
+	request := &entities.AuthorizationRequest{
+		ID:            uuid.New(),
+		ClientID:      client.ID,
+		RedirectURI:   input.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         input.State,
+		Nonce:         input.Nonce,
+		Prompt:        input.Prompt,
+		CodeChallenge: input.CodeChallenge,
+		ExpiresAt:     time.Now().Add(authorizationRequestTTL),
+		Created_at:    time.Now(),
+	}
+	if err := u.authorizationRepo.CreateRequest(ctx, request); err != nil {
+		return "", app_errors.FromDB(err, "Failed to save authorization request")
+	}
+
+	return withQuery(u.provider.LoginURL, map[string]string{"request_id": request.ID.String()}), nil
+}
+
+// GetRequest
+func (u *authorizationUsecaseImpl) GetRequest(ctx context.Context, userID, requestID uuid.UUID) (*dtos.AuthorizationRequestResponse, *app_errors.AppError) {
+	request, appErr := u.getRequest(ctx, requestID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	client, err := u.clientRepo.GetByID(ctx, request.ClientID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get client")
+	}
+
+	scopes := strings.Fields(request.Scope)
+	consentRequired := slices.Contains(strings.Fields(request.Prompt), "consent")
+	if !consentRequired {
+		granted, appErr := u.grantedScopes(ctx, userID, request.ClientID)
+		if appErr != nil {
+			return nil, appErr
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				consentRequired = true
+			}
+		}
+	}
+
+	return &dtos.AuthorizationRequestResponse{
+		ID:              request.ID,
+		ClientID:        client.ClientID,
+		ClientName:      client.Name,
+		Scopes:          scopes,
+		Prompt:          request.Prompt,
+		ConsentRequired: consentRequired,
+		ExpiresAt:       request.ExpiresAt,
+	}, nil
+}
+
+// DecideRequest issues the authorization code if the user approves, and
+// remembers the consent.
+func (u *authorizationUsecaseImpl) DecideRequest(ctx context.Context, userID, requestID uuid.UUID, approve bool) (*dtos.AuthorizationDecisionResponse, *app_errors.AppError) {
+	request, appErr := u.getRequest(ctx, requestID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if !approve {
+		if err := u.authorizationRepo.DeleteRequest(ctx, request.ID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, app_errors.NotFound("Authorization request not found", err)
+			}
+			return nil, app_errors.FromDB(err, "Failed to deny authorization request")
+		}
+		return &dtos.AuthorizationDecisionResponse{
+			RedirectTo: u.redirect(request.RedirectURI, request.State, map[string]string{"error": ErrAccessDenied, "error_description": "The user denied the request"}),
+		}, nil
+	}
+
+	granted, appErr := u.grantedScopes(ctx, userID, request.ClientID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	code, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to generate code", err)
+	}
+
+	err = u.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		consent := &entities.Consent{
+			ID:         uuid.New(),
+			UserID:     userID,
+			ClientID:   request.ClientID,
+			Scopes:     strings.Join(uniqueScopes(append(granted, strings.Fields(request.Scope)...)), " "),
+			Created_at: time.Now(),
+			Updated_at: time.Now(),
+		}
+		if err := u.authorizationRepo.SaveConsent(ctx, consent); err != nil {
+			return err
+		}
+		return u.authorizationRepo.ApproveRequest(ctx, request.ID, userID, utils.HashToken(code), time.Now().Add(authorizationCodeTTL))
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Authorization request not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to approve authorization request")
+	}
+
+	return &dtos.AuthorizationDecisionResponse{
+		RedirectTo: u.redirect(request.RedirectURI, request.State, map[string]string{"code": code}),
+	}, nil
+}
+
+// UserInfo
+func (u *authorizationUsecaseImpl) UserInfo(ctx context.Context, accessToken string) (*dtos.UserInfoResponse, *app_errors.AppError) {
+	claims, err := u.provider.Key.VerifyOAuthToken(accessToken, u.provider.Issuer)
+	if err != nil {
+		return nil, app_errors.Unautherized("Invalid token", err)
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, entities.ScopeOpenID) {
+		return nil, app_errors.New(http.StatusForbidden, "Token is missing the openid scope", nil)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, app_errors.Unautherized("Invalid token", err)
+	}
+	user, err := u.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.Unautherized("Invalid token", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	// the token outlives locking the user, it must not outlive their access
+	if user.LockedAt != nil {
+		return nil, app_errors.Unautherized("Invalid token", nil)
+	}
+
+	return userInfo(user, scopes), nil
+}
+
+func (u *authorizationUsecaseImpl) getRequest(ctx context.Context, id uuid.UUID) (*entities.AuthorizationRequest, *app_errors.AppError) {
+	request, err := u.authorizationRepo.GetRequest(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Authorization request not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get authorization request")
+	}
+	return request, nil
+}
+
+// grantedScopes returns the scopes the user has consented to give the
+// client.
+func (u *authorizationUsecaseImpl) grantedScopes(ctx context.Context, userID, clientID uuid.UUID) ([]string, *app_errors.AppError) {
+	consent, err := u.authorizationRepo.GetConsent(ctx, userID, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, app_errors.FromDB(err, "Failed to get consent")
+	}
+	return strings.Fields(consent.Scopes), nil
+}
+
+// redirect returns the client's redirect URI with an authorization
+// response, which carries the issuer as RFC 9207 recommends.
+func (u *authorizationUsecaseImpl) redirect(redirectURI, state string, params map[string]string) string {
+	params["state"] = state
+	params["iss"] = u.provider.Issuer
+	return withQuery(redirectURI, params)
+}
+
+// withQuery adds the non-empty params to the query of uri, which is a
+// registered or configured URI known to parse.
+func withQuery(uri string, params map[string]string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// uniqueScopes drops repeated scopes, keeping the order.
+func uniqueScopes(scopes []string) []string {
+	var unique []string
+	for _, scope := range scopes {
+		if !slices.Contains(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
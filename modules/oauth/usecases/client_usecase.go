@@ -0,0 +1,18 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type ClientUsecase interface {
+	CreateClient(ctx context.Context, input dtos.CreateClientRequest) (*dtos.CreateClientResponse, *app_errors.AppError)
+	GetAllClients(ctx context.Context) ([]*dtos.ClientResponse, *app_errors.AppError)
+	GetClientByID(ctx context.Context, id uuid.UUID) (*dtos.ClientResponse, *app_errors.AppError)
+	UpdateClient(ctx context.Context, id uuid.UUID, input dtos.UpdateClientRequest) (*dtos.ClientResponse, *app_errors.AppError)
+	DeleteClient(ctx context.Context, id uuid.UUID) *app_errors.AppError
+}
@@ -0,0 +1,157 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+type clientUsecaseImpl struct {
+	repo repositories.ClientRepository
+}
+
+func NewClientUsecase(repo repositories.ClientRepository) ClientUsecase {
+	return &clientUsecaseImpl{repo: repo}
+}
+
+// Create Client
+func (u *clientUsecaseImpl) CreateClient(ctx context.Context, input dtos.CreateClientRequest) (*dtos.CreateClientResponse, *app_errors.AppError) {
+	if appErr := validateRedirectURIs(input.RedirectURIs); appErr != nil {
+		return nil, appErr
+	}
+
+	clientID, err := utils.GenerateToken(12)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to generate client ID", err)
+	}
+
+	client := &entities.Client{
+		ID:           uuid.New(),
+		Name:         input.Name,
+		ClientID:     entities.AppClientIDPrefix + clientID,
+		Public:       input.Public,
+		RedirectURIs: strings.Join(input.RedirectURIs, " "),
+		Created_at:   time.Now(),
+		Updated_at:   time.Now(),
+	}
+
+	var plaintext string
+	if !client.Public {
+		secret, err := utils.GenerateToken(32)
+		if err != nil {
+			return nil, app_errors.InternalServer("Failed to generate secret", err)
+		}
+		plaintext = entities.AppClientSecretPrefix + secret
+		client.SecretHash = utils.HashToken(plaintext)
+	}
+
+	if err := u.repo.Create(ctx, client); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to create client")
+	}
+
+	return &dtos.CreateClientResponse{
+		ClientResponse: *dtos.FromClientEntity(client),
+		ClientSecret:   plaintext,
+	}, nil
+}
+
+// Get All Clients
+func (u *clientUsecaseImpl) GetAllClients(ctx context.Context) ([]*dtos.ClientResponse, *app_errors.AppError) {
+	clients, err := u.repo.GetAll(ctx)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get clients")
+	}
+	return dtos.FromClientEntities(clients), nil
+}
+
+// Get Client By ID
+func (u *clientUsecaseImpl) GetClientByID(ctx context.Context, id uuid.UUID) (*dtos.ClientResponse, *app_errors.AppError) {
+	client, appErr := u.getClient(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return dtos.FromClientEntity(client), nil
+}
+
+// Update Client
+func (u *clientUsecaseImpl) UpdateClient(ctx context.Context, id uuid.UUID, input dtos.UpdateClientRequest) (*dtos.ClientResponse, *app_errors.AppError) {
+	client, appErr := u.getClient(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if input.Name != nil {
+		client.Name = *input.Name
+	}
+	if input.RedirectURIs != nil {
+		if appErr := validateRedirectURIs(input.RedirectURIs); appErr != nil {
+			return nil, appErr
+		}
+		client.RedirectURIs = strings.Join(input.RedirectURIs, " ")
+	}
+	client.Updated_at = time.Now()
+
+	if err := u.repo.Update(ctx, client); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to update client")
+	}
+
+	return dtos.FromClientEntity(client), nil
+}
+
+// Delete Client
+func (u *clientUsecaseImpl) DeleteClient(ctx context.Context, id uuid.UUID) *app_errors.AppError {
+	if err := u.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Client not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to delete client")
+	}
+	return nil
+}
+
+func (u *clientUsecaseImpl) getClient(ctx context.Context, id uuid.UUID) (*entities.Client, *app_errors.AppError) {
+	client, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Client not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get client")
+	}
+	return client, nil
+}
+
+// validateRedirectURIs allows https URIs, and http only on the loopback
+// interface for apps running on the user's machine. Codes are sent to
+// these URIs, so fragments and anything else are refused.
+func validateRedirectURIs(uris []string) *app_errors.AppError {
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		switch {
+		case err != nil, strings.ContainsAny(uri, " \t\r\n"), !u.IsAbs(), u.Host == "":
+			return app_errors.BadRequest(fmt.Sprintf("Invalid redirect URI %q", uri), err)
+		case u.Fragment != "" || strings.Contains(uri, "#"):
+			return app_errors.BadRequest(fmt.Sprintf("Redirect URI %q must not have a fragment", uri), nil)
+		case u.Scheme == "https":
+		case u.Scheme == "http" && isLoopback(u.Hostname()):
+		default:
+			return app_errors.BadRequest(fmt.Sprintf("Redirect URI %q must use https", uri), nil)
+		}
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
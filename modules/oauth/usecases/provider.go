@@ -0,0 +1,61 @@
+package usecases
+
+import (
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	userEntities "github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+)
+
+// Provider is how this service presents itself to relying parties as an
+// OpenID Provider.
+type Provider struct {
+	Issuer string
+	// LoginURL is the web page that signs the user in and shows the
+	// consent screen, given the request_id of an authorization request.
+	LoginURL string
+	Key      *jwt.SigningKey
+}
+
+// SupportedOIDCScopes lists the scopes a relying party may request.
+var SupportedOIDCScopes = []string{
+	entities.ScopeOpenID,
+	entities.ScopeProfile,
+	entities.ScopeEmail,
+}
+
+// Discovery returns the provider's metadata for
+// /.well-known/openid-configuration.
+func (p *Provider) Discovery() *dtos.DiscoveryResponse {
+	return &dtos.DiscoveryResponse{
+		Issuer:                            p.Issuer,
+		AuthorizationEndpoint:             p.Issuer + "/oauth/authorize",
+		TokenEndpoint:                     p.Issuer + "/oauth/token",
+		UserInfoEndpoint:                  p.Issuer + "/oauth/userinfo",
+		JWKSURI:                           p.Issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   SupportedOIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "name", "locale", "email"},
+		AuthorizationResponseIssParameter: true,
+	}
+}
+
+// userInfo returns the claims about user that scopes grant.
+func userInfo(user *userEntities.User, scopes []string) *dtos.UserInfoResponse {
+	info := &dtos.UserInfoResponse{Subject: user.ID.String()}
+	for _, scope := range scopes {
+		switch scope {
+		case entities.ScopeProfile:
+			info.Name = user.Name
+			info.Locale = user.Locale
+		case entities.ScopeEmail:
+			info.Email = user.Email
+		}
+	}
+	return info
+}
@@ -0,0 +1,24 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type ServiceAccountUsecase interface {
+	CreateServiceAccount(ctx context.Context, input dtos.CreateServiceAccountRequest) (*dtos.CreateServiceAccountResponse, *app_errors.AppError)
+	GetAllServiceAccounts(ctx context.Context) ([]*dtos.ServiceAccountResponse, *app_errors.AppError)
+	GetServiceAccountByID(ctx context.Context, id uuid.UUID) (*dtos.ServiceAccountResponse, *app_errors.AppError)
+	UpdateServiceAccount(ctx context.Context, id uuid.UUID, input dtos.UpdateServiceAccountRequest) (*dtos.ServiceAccountResponse, *app_errors.AppError)
+	DeleteServiceAccount(ctx context.Context, id uuid.UUID) *app_errors.AppError
+	RotateSecret(ctx context.Context, id uuid.UUID, input dtos.RotateSecretRequest) (*dtos.ClientSecretResponse, *app_errors.AppError)
+	DeleteSecret(ctx context.Context, id, secretID uuid.UUID) *app_errors.AppError
+
+	// Authenticate implements middlewares.ServiceTokenVerifier, the error
+	// is an *app_errors.AppError.
+	Authenticate(ctx context.Context, id, secretID uuid.UUID) error
+}
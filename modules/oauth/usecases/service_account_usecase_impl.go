@@ -0,0 +1,248 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// defaultSecretOverlap is how long the old secrets keep working after a
+// rotation, unless the request says otherwise.
+const defaultSecretOverlap = 24 * time.Hour
+
+// secretPrefixLength is how much of a secret is kept in clear, the prefix
+// and 8 random characters.
+const secretPrefixLength = len(entities.ClientSecretPrefix) + 8
+
+// SupportedScopes lists the scopes a service account may be granted.
+var SupportedScopes = []string{
+	entities.ScopeUsersImport,
+	entities.ScopeWebhooksRead,
+	entities.ScopeWebhooksWrite,
+}
+
+type serviceAccountUsecaseImpl struct {
+	repo      repositories.ServiceAccountRepository
+	txManager databases.TxManager
+}
+
+func NewServiceAccountUsecase(repo repositories.ServiceAccountRepository, txManager databases.TxManager) ServiceAccountUsecase {
+	return &serviceAccountUsecaseImpl{
+		repo:      repo,
+		txManager: txManager,
+	}
+}
+
+// Create Service Account
+func (u *serviceAccountUsecaseImpl) CreateServiceAccount(ctx context.Context, input dtos.CreateServiceAccountRequest) (*dtos.CreateServiceAccountResponse, *app_errors.AppError) {
+	if appErr := validateScopes(input.Scopes); appErr != nil {
+		return nil, appErr
+	}
+
+	clientID, err := utils.GenerateToken(12)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to generate client ID", err)
+	}
+
+	account := &entities.ServiceAccount{
+		ID:          uuid.New(),
+		Name:        input.Name,
+		Description: input.Description,
+		ClientID:    entities.ClientIDPrefix + clientID,
+		Scopes:      strings.Join(input.Scopes, " "),
+		Created_at:  time.Now(),
+		Updated_at:  time.Now(),
+	}
+
+	plaintext, secret, appErr := newSecret(account.ID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	account.Secrets = []entities.ServiceAccountSecret{*secret}
+
+	if err := u.repo.Create(ctx, account); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to create service account")
+	}
+
+	return &dtos.CreateServiceAccountResponse{
+		ServiceAccountResponse: *dtos.FromServiceAccountEntity(account),
+		ClientSecret:           plaintext,
+	}, nil
+}
+
+// Get All Service Accounts
+func (u *serviceAccountUsecaseImpl) GetAllServiceAccounts(ctx context.Context) ([]*dtos.ServiceAccountResponse, *app_errors.AppError) {
+	accounts, err := u.repo.GetAll(ctx)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get service accounts")
+	}
+	return dtos.FromServiceAccountEntities(accounts), nil
+}
+
+// Get Service Account By ID
+func (u *serviceAccountUsecaseImpl) GetServiceAccountByID(ctx context.Context, id uuid.UUID) (*dtos.ServiceAccountResponse, *app_errors.AppError) {
+	account, appErr := u.getServiceAccount(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return dtos.FromServiceAccountEntity(account), nil
+}
+
+// Update Service Account
+func (u *serviceAccountUsecaseImpl) UpdateServiceAccount(ctx context.Context, id uuid.UUID, input dtos.UpdateServiceAccountRequest) (*dtos.ServiceAccountResponse, *app_errors.AppError) {
+	account, appErr := u.getServiceAccount(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if input.Name != nil {
+		account.Name = *input.Name
+	}
+	if input.Description != nil {
+		account.Description = *input.Description
+	}
+	if input.Scopes != nil {
+		if appErr := validateScopes(input.Scopes); appErr != nil {
+			return nil, appErr
+		}
+		account.Scopes = strings.Join(input.Scopes, " ")
+	}
+	if input.Disabled != nil {
+		account.Disabled = *input.Disabled
+	}
+	account.Updated_at = time.Now()
+
+	if err := u.repo.Update(ctx, account); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to update service account")
+	}
+
+	return dtos.FromServiceAccountEntity(account), nil
+}
+
+// Delete Service Account
+func (u *serviceAccountUsecaseImpl) DeleteServiceAccount(ctx context.Context, id uuid.UUID) *app_errors.AppError {
+	if err := u.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Service account not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to delete service account")
+	}
+	return nil
+}
+
+// RotateSecret adds a new secret and expires the current ones after the
+// overlap, so clients can switch over without downtime.
+func (u *serviceAccountUsecaseImpl) RotateSecret(ctx context.Context, id uuid.UUID, input dtos.RotateSecretRequest) (*dtos.ClientSecretResponse, *app_errors.AppError) {
+	if _, appErr := u.getServiceAccount(ctx, id); appErr != nil {
+		return nil, appErr
+	}
+
+	overlap := defaultSecretOverlap
+	if input.OverlapSeconds != nil {
+		overlap = time.Duration(*input.OverlapSeconds) * time.Second
+	}
+
+	plaintext, secret, appErr := newSecret(id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	err := u.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := u.repo.ExpireSecrets(ctx, id, time.Now().Add(overlap)); err != nil {
+			return err
+		}
+		return u.repo.AddSecret(ctx, secret)
+	})
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to rotate secret")
+	}
+
+	return &dtos.ClientSecretResponse{
+		ServiceAccountSecretResponse: *dtos.FromServiceAccountSecretEntity(secret),
+		ClientSecret:                 plaintext,
+	}, nil
+}
+
+// DeleteSecret revokes one secret at once, e.g. a leaked one.
+func (u *serviceAccountUsecaseImpl) DeleteSecret(ctx context.Context, id, secretID uuid.UUID) *app_errors.AppError {
+	if err := u.repo.DeleteSecret(ctx, id, secretID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Secret not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to delete secret")
+	}
+	return nil
+}
+
+// Authenticate
+func (u *serviceAccountUsecaseImpl) Authenticate(ctx context.Context, id, secretID uuid.UUID) error {
+	account, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.Unautherized("Invalid token", err)
+		}
+		return app_errors.FromDB(err, "Failed to get service account")
+	}
+	if account.Disabled {
+		return app_errors.Unautherized("Invalid token", nil)
+	}
+
+	now := time.Now()
+	for _, secret := range account.Secrets {
+		if secret.ID == secretID && secret.Active(now) {
+			return nil
+		}
+	}
+	return app_errors.Unautherized("Invalid token", nil)
+}
+
+func (u *serviceAccountUsecaseImpl) getServiceAccount(ctx context.Context, id uuid.UUID) (*entities.ServiceAccount, *app_errors.AppError) {
+	account, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Service account not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get service account")
+	}
+	return account, nil
+}
+
+// newSecret generates a client secret for the account, returning the
+// plaintext to show once and the entity to store.
+func newSecret(accountID uuid.UUID) (string, *entities.ServiceAccountSecret, *app_errors.AppError) {
+	random, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", nil, app_errors.InternalServer("Failed to generate secret", err)
+	}
+	plaintext := entities.ClientSecretPrefix + random
+
+	return plaintext, &entities.ServiceAccountSecret{
+		ID:               uuid.New(),
+		ServiceAccountID: accountID,
+		Prefix:           plaintext[:secretPrefixLength],
+		SecretHash:       utils.HashToken(plaintext),
+		Created_at:       time.Now(),
+	}, nil
+}
+
+func validateScopes(scopes []string) *app_errors.AppError {
+	for _, scope := range scopes {
+		if !slices.Contains(SupportedScopes, scope) {
+			return app_errors.BadRequest(fmt.Sprintf("Unsupported scope %q", scope), nil).WithDetails(SupportedScopes)
+		}
+	}
+	return nil
+}
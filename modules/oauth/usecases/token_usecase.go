@@ -0,0 +1,14 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+)
+
+// TokenUsecase implements the OAuth 2.0 token endpoint. Errors are in the
+// RFC 6749 format rather than app_errors.
+type TokenUsecase interface {
+	ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*dtos.TokenResponse, *dtos.TokenError)
+	AuthorizationCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*dtos.TokenResponse, *dtos.TokenError)
+}
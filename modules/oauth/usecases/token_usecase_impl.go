@@ -0,0 +1,198 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/oauth/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	"github.com/natchaphonbw/usermanagement/modules/oauth/repositories"
+	userRepositories "github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// RFC 6749 section 5.2 error codes.
+const (
+	ErrInvalidRequest       = "invalid_request"
+	ErrInvalidClient        = "invalid_client"
+	ErrInvalidGrant         = "invalid_grant"
+	ErrInvalidScope         = "invalid_scope"
+	ErrUnsupportedGrantType = "unsupported_grant_type"
+	ErrServerError          = "server_error"
+)
+
+type tokenUsecaseImpl struct {
+	accountRepo       repositories.ServiceAccountRepository
+	clientRepo        repositories.ClientRepository
+	authorizationRepo repositories.AuthorizationRepository
+	userRepo          userRepositories.UserRepository
+	provider          *Provider
+}
+
+func NewTokenUsecase(accountRepo repositories.ServiceAccountRepository, clientRepo repositories.ClientRepository, authorizationRepo repositories.AuthorizationRepository, userRepo userRepositories.UserRepository, provider *Provider) TokenUsecase {
+	return &tokenUsecaseImpl{
+		accountRepo:       accountRepo,
+		clientRepo:        clientRepo,
+		authorizationRepo: authorizationRepo,
+		userRepo:          userRepo,
+		provider:          provider,
+	}
+}
+
+// ClientCredentials issues a service account an access token for the
+// requested scope, or every scope it has been granted if scope is empty.
+func (u *tokenUsecaseImpl) ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*dtos.TokenResponse, *dtos.TokenError) {
+	invalidClient := &dtos.TokenError{Code: ErrInvalidClient, Description: "Client authentication failed", Status: http.StatusUnauthorized}
+
+	account, err := u.accountRepo.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidClient
+		}
+		log.Printf("Failed to get service account %q: %v", clientID, err)
+		return nil, &dtos.TokenError{Code: ErrServerError, Status: http.StatusInternalServerError}
+	}
+	if account.Disabled {
+		return nil, invalidClient
+	}
+
+	now := time.Now()
+	secretHash := utils.HashToken(clientSecret)
+	matched := -1
+	for i, secret := range account.Secrets {
+		if secret.Active(now) && subtle.ConstantTimeCompare([]byte(secretHash), []byte(secret.SecretHash)) == 1 {
+			matched = i
+		}
+	}
+	if matched < 0 {
+		return nil, invalidClient
+	}
+
+	granted := strings.Fields(account.Scopes)
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		requested = granted
+	}
+	for _, s := range requested {
+		if !slices.Contains(granted, s) {
+			return nil, &dtos.TokenError{Code: ErrInvalidScope, Description: "Scope " + s + " is not granted", Status: http.StatusBadRequest}
+		}
+	}
+	scope = strings.Join(requested, " ")
+
+	token, ttl, err := jwt.GenerateServiceToken(account.ID.String(), account.Secrets[matched].ID.String(), account.ClientID, scope)
+	if err != nil {
+		log.Printf("Failed to sign token for service account %s: %v", account.ID, err)
+		return nil, &dtos.TokenError{Code: ErrServerError, Status: http.StatusInternalServerError}
+	}
+
+	if err := u.accountRepo.MarkSecretUsed(ctx, account.Secrets[matched].ID, now); err != nil {
+		log.Printf("Failed to record use of secret %s: %v", account.Secrets[matched].ID, err)
+	}
+
+	return &dtos.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// AuthorizationCode redeems a code from the authorization endpoint for an
+// access token and, with the openid scope, an ID token. A code is deleted
+// on first use, even if the rest of the request is wrong.
+func (u *tokenUsecaseImpl) AuthorizationCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*dtos.TokenResponse, *dtos.TokenError) {
+	invalidClient := &dtos.TokenError{Code: ErrInvalidClient, Description: "Client authentication failed", Status: http.StatusUnauthorized}
+	invalidGrant := &dtos.TokenError{Code: ErrInvalidGrant, Description: "The authorization code is invalid or expired", Status: http.StatusBadRequest}
+	serverError := &dtos.TokenError{Code: ErrServerError, Status: http.StatusInternalServerError}
+
+	client, err := u.clientRepo.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidClient
+		}
+		log.Printf("Failed to get client %q: %v", clientID, err)
+		return nil, serverError
+	}
+	if !client.Public && subtle.ConstantTimeCompare([]byte(utils.HashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, invalidClient
+	}
+
+	if code == "" || redirectURI == "" || codeVerifier == "" {
+		return nil, &dtos.TokenError{Code: ErrInvalidRequest, Description: "code, redirect_uri and code_verifier are required", Status: http.StatusBadRequest}
+	}
+
+	request, err := u.authorizationRepo.ConsumeCode(ctx, utils.HashToken(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidGrant
+		}
+		log.Printf("Failed to redeem authorization code: %v", err)
+		return nil, serverError
+	}
+	if request.ClientID != client.ID || request.UserID == nil || time.Now().After(request.ExpiresAt) ||
+		request.RedirectURI != redirectURI || !verifyCodeChallenge(codeVerifier, request.CodeChallenge) {
+		return nil, invalidGrant
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, *request.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidGrant
+		}
+		log.Printf("Failed to get user %s: %v", *request.UserID, err)
+		return nil, serverError
+	}
+	// locked since they approved the request, see UserUsecase.LockUser
+	if user.LockedAt != nil {
+		return nil, invalidGrant
+	}
+
+	accessToken, ttl, err := u.provider.Key.GenerateOAuthToken(u.provider.Issuer, user.ID.String(), client.ClientID, request.Scope)
+	if err != nil {
+		log.Printf("Failed to sign token for client %s: %v", client.ClientID, err)
+		return nil, serverError
+	}
+	resp := &dtos.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       request.Scope,
+	}
+
+	scopes := strings.Fields(request.Scope)
+	if slices.Contains(scopes, entities.ScopeOpenID) {
+		info := userInfo(user, scopes)
+		resp.IDToken, err = u.provider.Key.GenerateIDToken(u.provider.Issuer, info.Subject, client.ClientID, jwt.IDTokenClaims{
+			Nonce:  request.Nonce,
+			AtHash: jwt.AtHash(accessToken),
+			Name:   info.Name,
+			Locale: info.Locale,
+			Email:  info.Email,
+		})
+		if err != nil {
+			log.Printf("Failed to sign ID token for client %s: %v", client.ClientID, err)
+			return nil, serverError
+		}
+	}
+
+	return resp, nil
+}
+
+// verifyCodeChallenge checks the PKCE verifier against the S256 challenge
+// of the authorization request.
+func verifyCodeChallenge(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type AccessTokenController struct {
+	accessTokenUseCase usecases.AccessTokenUsecase
+}
+
+func NewAccessTokenController(u usecases.AccessTokenUsecase) *AccessTokenController {
+	return &AccessTokenController{
+		accessTokenUseCase: u,
+	}
+}
+
+// CreateToken
+func (a *AccessTokenController) CreateToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req dtos.CreateAccessTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	tokenResp, respErr := a.accessTokenUseCase.CreateToken(c.Context(), userID, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tokenResp)
+}
+
+// ListTokens
+func (a *AccessTokenController) ListTokens(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	tokens, respErr := a.accessTokenUseCase.ListTokens(c.Context(), userID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(tokens)
+}
+
+// RevokeToken
+func (a *AccessTokenController) RevokeToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	tokenID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid token ID", err))
+	}
+
+	if respErr := a.accessTokenUseCase.RevokeToken(c.Context(), userID, tokenID); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
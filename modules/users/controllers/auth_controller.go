@@ -113,6 +113,67 @@ func (a *AuthController) Logout(c *fiber.Ctx) error {
 
 }
 
+// Reauthenticate returns tokens for a session authenticated just now,
+// for endpoints that require a recent login.
+func (a *AuthController) Reauthenticate(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	sessionID := c.Locals("sessionID").(uuid.UUID)
+
+	var req dtos.ReauthenticateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	loginResp, respErr := a.authUseCase.Reauthenticate(c.Context(), userID, sessionID, req, c.IP(), c.Get("User-Agent"), c.Get("X-Device-ID"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(loginResp)
+}
+
+// RequestEmailChange
+func (a *AuthController) RequestEmailChange(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req dtos.EmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	if respErr := a.authUseCase.RequestEmailChange(c.Context(), userID, req); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// ConfirmEmailChange
+func (a *AuthController) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req dtos.ConfirmEmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	if respErr := a.authUseCase.ConfirmEmailChange(c.Context(), req); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
 // LogoutAll
 func (a *AuthController) LogoutAll(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
@@ -123,3 +184,14 @@ func (a *AuthController) LogoutAll(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// DeleteAccount deletes the signed in user.
+func (a *AuthController) DeleteAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	if deleteErr := a.authUseCase.DeleteAccount(c.Context(), userID); deleteErr != nil {
+		return app_errors.Send(c, deleteErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type PasskeyController struct {
+	authUseCase usecases.AuthUsecase
+}
+
+func NewPasskeyController(u usecases.AuthUsecase) *PasskeyController {
+	return &PasskeyController{
+		authUseCase: u,
+	}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create.
+func (p *PasskeyController) BeginRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	ceremony, respErr := p.authUseCase.BeginPasskeyRegistration(c.Context(), userID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ceremony)
+}
+
+// FinishRegistration
+func (p *PasskeyController) FinishRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req dtos.FinishPasskeyRegistrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	passkey, respErr := p.authUseCase.FinishPasskeyRegistration(c.Context(), userID, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(passkey)
+}
+
+// BeginLogin returns the options for navigator.credentials.get.
+func (p *PasskeyController) BeginLogin(c *fiber.Ctx) error {
+	ceremony, respErr := p.authUseCase.BeginPasskeyLogin(c.Context())
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ceremony)
+}
+
+// FinishLogin finishes a passkey login, started here or by a password
+// login.
+func (p *PasskeyController) FinishLogin(c *fiber.Ctx) error {
+	var req dtos.FinishPasskeyLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	loginResp, respErr := p.authUseCase.FinishPasskeyLogin(c.Context(), req, c.IP(), c.Get("User-Agent"), c.Get("X-Device-ID"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(loginResp)
+}
+
+// ListPasskeys
+func (p *PasskeyController) ListPasskeys(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	passkeys, respErr := p.authUseCase.ListPasskeys(c.Context(), userID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(passkeys)
+}
+
+// RenamePasskey
+func (p *PasskeyController) RenamePasskey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	passkeyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid passkey ID", err))
+	}
+
+	var req dtos.RenamePasskeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	passkey, respErr := p.authUseCase.RenamePasskey(c.Context(), userID, passkeyID, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(passkey)
+}
+
+// DeletePasskey
+func (p *PasskeyController) DeletePasskey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	passkeyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid passkey ID", err))
+	}
+
+	if respErr := p.authUseCase.DeletePasskey(c.Context(), userID, passkeyID); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
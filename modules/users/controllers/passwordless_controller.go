@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type PasswordlessController struct {
+	passwordlessUseCase usecases.PasswordlessUsecase
+}
+
+func NewPasswordlessController(u usecases.PasswordlessUsecase) *PasswordlessController {
+	return &PasswordlessController{
+		passwordlessUseCase: u,
+	}
+}
+
+// RequestLogin emails a login link or code, bound to the X-Device-ID of
+// the request.
+func (p *PasswordlessController) RequestLogin(c *fiber.Ctx) error {
+	var req dtos.MagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	if respErr := p.passwordlessUseCase.RequestLogin(c.Context(), req, c.Get("X-Device-ID")); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// VerifyLink
+func (p *PasswordlessController) VerifyLink(c *fiber.Ctx) error {
+	var req dtos.VerifyMagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	loginResp, respErr := p.passwordlessUseCase.VerifyLink(c.Context(), req, c.IP(), c.Get("User-Agent"), c.Get("X-Device-ID"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(loginResp)
+}
+
+// VerifyCode
+func (p *PasswordlessController) VerifyCode(c *fiber.Ctx) error {
+	var req dtos.VerifyLoginCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	loginResp, respErr := p.passwordlessUseCase.VerifyCode(c.Context(), req, c.IP(), c.Get("User-Agent"), c.Get("X-Device-ID"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(loginResp)
+}
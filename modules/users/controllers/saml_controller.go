@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type SAMLController struct {
+	samlUseCase usecases.SAMLUsecase
+}
+
+func NewSAMLController(u usecases.SAMLUsecase) *SAMLController {
+	return &SAMLController{
+		samlUseCase: u,
+	}
+}
+
+// Metadata serves the service provider metadata to register with the
+// identity provider.
+func (s *SAMLController) Metadata(c *fiber.Ctx) error {
+	metadata, respErr := s.samlUseCase.Metadata(c.Context(), c.Params("provider"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/samlmetadata+xml")
+	return c.Status(fiber.StatusOK).Send(metadata)
+}
+
+// StartLogin sends the browser to the identity provider, with a redirect
+// or a form that posts itself.
+func (s *SAMLController) StartLogin(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	authnRequest, respErr := s.samlUseCase.StartLogin(c.Context(), c.Params("provider"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	if authnRequest.RedirectURL != "" {
+		return c.Redirect(authnRequest.RedirectURL, fiber.StatusFound)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).Send(authnRequest.Form)
+}
+
+// StartLink returns where to send the browser to link an identity to
+// the signed in user.
+func (s *SAMLController) StartLink(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	linkResp, respErr := s.samlUseCase.StartLink(c.Context(), c.Params("provider"), userID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(linkResp)
+}
+
+// ConsumeAssertion receives the identity provider's response and sends
+// the browser on to the login page with a code.
+func (s *SAMLController) ConsumeAssertion(c *fiber.Ctx) error {
+	var req dtos.SAMLResponseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	redirectURL, respErr := s.samlUseCase.ConsumeAssertion(c.Context(), c.Params("provider"), req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Redirect(redirectURL, fiber.StatusSeeOther)
+}
+
+// Token exchanges the code the login page was sent back with for tokens.
+func (s *SAMLController) Token(c *fiber.Ctx) error {
+	var req dtos.SAMLTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	loginResp, respErr := s.samlUseCase.ExchangeCode(c.Context(), c.Params("provider"), req, c.IP(), c.Get("User-Agent"), c.Get("X-Device-ID"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(loginResp)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type SSOController struct {
+	ssoUseCase usecases.SSOUsecase
+}
+
+func NewSSOController(u usecases.SSOUsecase) *SSOController {
+	return &SSOController{
+		ssoUseCase: u,
+	}
+}
+
+// StartLogin redirects the browser to the identity provider.
+func (s *SSOController) StartLogin(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	authURL, respErr := s.ssoUseCase.StartLogin(c.Context(), c.Params("provider"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// StartLink returns where to send the browser to link an identity to
+// the signed in user.
+func (s *SSOController) StartLink(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	linkResp, respErr := s.ssoUseCase.StartLink(c.Context(), c.Params("provider"), userID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(linkResp)
+}
+
+// Callback is called by the login page the provider redirected to, with
+// the code and state it was given.
+func (s *SSOController) Callback(c *fiber.Ctx) error {
+	var req dtos.SSOCallbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	loginResp, respErr := s.ssoUseCase.CompleteLogin(c.Context(), c.Params("provider"), req, c.IP(), c.Get("User-Agent"), c.Get("X-Device-ID"))
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(loginResp)
+}
+
+// ListIdentities
+func (s *SSOController) ListIdentities(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	identities, respErr := s.ssoUseCase.ListIdentities(c.Context(), userID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(identities)
+}
+
+// UnlinkIdentity
+func (s *SSOController) UnlinkIdentity(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	identityID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid identity ID", err))
+	}
+
+	if respErr := s.ssoUseCase.UnlinkIdentity(c.Context(), userID, identityID); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -83,6 +83,10 @@ func (ctrl *UserController) UpdateUserByID(c *fiber.Ctx) error {
 	if err != nil {
 		return app_errors.Send(c, app_errors.BadRequest("Invalid user ID", err))
 	}
+	// users only update themselves
+	if userID, _ := c.Locals("userID").(uuid.UUID); userID != id {
+		return app_errors.Send(c, app_errors.New(fiber.StatusForbidden, "You can only update your own account", nil))
+	}
 
 	// parse request body
 	var req dtos.UpdateUserRequest
@@ -105,20 +109,52 @@ func (ctrl *UserController) UpdateUserByID(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(userResp)
 }
 
-// DeleteUserByID
-func (ctrl *UserController) DeleteUserByID(c *fiber.Ctx) error {
+// ImportUsers
+func (ctrl *UserController) ImportUsers(c *fiber.Ctx) error {
+	var req dtos.ImportUsersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	// rows are validated one by one by the usecase
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	importResp, err := ctrl.userUsecase.ImportUsers(c.Context(), req)
+	if err != nil {
+		return app_errors.Send(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(importResp)
+}
+
+// LockUser
+func (ctrl *UserController) LockUser(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
-	log.Printf("Fetching user with ID: %s", id)
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid user ID", err))
+	}
+
+	userResp, respErr := ctrl.userUsecase.LockUser(c.Context(), id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(userResp)
+}
 
+// UnlockUser
+func (ctrl *UserController) UnlockUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return app_errors.Send(c, app_errors.BadRequest("Invalid user ID", err))
 	}
-	// Delete user
-	deletedUser, delErr := ctrl.userUsecase.DeleteUserByID(c.Context(), id)
-	if delErr != nil {
-		return app_errors.Send(c, delErr)
 
+	userResp, respErr := ctrl.userUsecase.UnlockUser(c.Context(), id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
 	}
 
-	return c.JSON(deletedUser)
+	return c.JSON(userResp)
 }
@@ -0,0 +1,57 @@
+package dtos
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=profile:read tokens:read"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// Response
+
+type AccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAccessTokenResponse is the only response carrying the token.
+type CreatedAccessTokenResponse struct {
+	AccessTokenResponse
+	Token string `json:"token"`
+}
+
+func FromAccessTokenEntity(token *entities.AccessToken) *AccessTokenResponse {
+	return &AccessTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Fields(token.Scopes),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		RevokedAt:  token.RevokedAt,
+		CreatedAt:  token.Created_at,
+	}
+}
+
+func FromAccessTokenEntities(tokens []entities.AccessToken) []*AccessTokenResponse {
+	tokenResponse := make([]*AccessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		tokenResponse = append(tokenResponse, FromAccessTokenEntity(&token))
+	}
+	return tokenResponse
+}
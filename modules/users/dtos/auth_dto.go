@@ -1,10 +1,13 @@
 package dtos
 
+import "encoding/json"
+
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 	Age      int    `json:"age" validate:"required,min=13"`
+	Locale   string `json:"locale" validate:"omitempty,oneof=en th"`
 }
 
 type LoginRequest struct {
@@ -12,15 +15,50 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// LoginResponse carries either tokens or, for users with passkeys, the
+// passkey login to finish for them.
 type LoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken     string                   `json:"access_token,omitempty"`
+	RefreshToken    string                   `json:"refresh_token,omitempty"`
+	PasskeyRequired *PasskeyCeremonyResponse `json:"passkey_required,omitempty"`
+}
+
+// ReauthenticateRequest proves the user is still there with their
+// password or, for users with passkeys, a passkey login started at
+// /auth/passkeys/login/begin.
+type ReauthenticateRequest struct {
+	Password   string          `json:"password" validate:"required_without=CeremonyID"`
+	CeremonyID string          `json:"ceremony_id" validate:"omitempty,uuid"`
+	Credential json.RawMessage `json:"credential" validate:"required_with=CeremonyID"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	// Method is how the login is sent, a link by default or a code.
+	Method string `json:"method" validate:"omitempty,oneof=link code"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type VerifyLoginCodeRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Code  string `json:"code" validate:"required,len=6,numeric"`
+}
+
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
@@ -0,0 +1,68 @@
+package dtos
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type FinishPasskeyRegistrationRequest struct {
+	CeremonyID string `json:"ceremony_id" validate:"required,uuid"`
+	Nickname   string `json:"nickname" validate:"omitempty,max=100"`
+	// Credential is the PublicKeyCredential from navigator.credentials.create.
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type FinishPasskeyLoginRequest struct {
+	CeremonyID string `json:"ceremony_id" validate:"required,uuid"`
+	// Credential is the PublicKeyCredential from navigator.credentials.get.
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type RenamePasskeyRequest struct {
+	Nickname string `json:"nickname" validate:"required,max=100"`
+}
+
+// Response
+
+// PasskeyCeremonyResponse carries the options to pass to
+// navigator.credentials and the ceremony to finish with its result.
+type PasskeyCeremonyResponse struct {
+	CeremonyID uuid.UUID       `json:"ceremony_id"`
+	Options    json.RawMessage `json:"options"`
+}
+
+type PasskeyResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Nickname       string     `json:"nickname"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func FromPasskeyEntity(passkey *entities.Passkey) *PasskeyResponse {
+	transports := []string{}
+	if passkey.Transports != "" {
+		transports = strings.Split(passkey.Transports, ",")
+	}
+	return &PasskeyResponse{
+		ID:             passkey.ID,
+		Nickname:       passkey.Nickname,
+		Transports:     transports,
+		BackupEligible: passkey.BackupEligible,
+		LastUsedAt:     passkey.LastUsedAt,
+		CreatedAt:      passkey.Created_at,
+	}
+}
+
+func FromPasskeyEntities(passkeys []entities.Passkey) []*PasskeyResponse {
+	passkeyResponse := make([]*PasskeyResponse, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		passkeyResponse = append(passkeyResponse, FromPasskeyEntity(&passkey))
+	}
+	return passkeyResponse
+}
@@ -0,0 +1,57 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+// SSOCallbackRequest is what the provider sent back to the login page.
+type SSOCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+// SAMLResponseRequest is what the identity provider posts to the assertion
+// consumer service.
+type SAMLResponseRequest struct {
+	SAMLResponse string `form:"SAMLResponse" validate:"required"`
+	RelayState   string `form:"RelayState" validate:"required"`
+}
+
+// SAMLTokenRequest is the code the login page was sent back with.
+type SAMLTokenRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// Response
+
+type IdentityResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// SSOLinkResponse is where to send the browser to link an identity: to
+// URL, or by showing Form, an HTML form that posts itself.
+type SSOLinkResponse struct {
+	URL  string `json:"url,omitempty"`
+	Form string `json:"form,omitempty"`
+}
+
+func FromIdentityEntities(identities []entities.Identity) []*IdentityResponse {
+	identityResponse := make([]*IdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		identityResponse = append(identityResponse, &IdentityResponse{
+			ID:          identity.ID,
+			Provider:    identity.Provider,
+			Email:       identity.Email,
+			LastLoginAt: identity.LastLoginAt,
+			CreatedAt:   identity.Created_at,
+		})
+	}
+	return identityResponse
+}
@@ -11,24 +11,43 @@ type CreateUserRequest struct {
 	Name     string `json:"name" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email"`
 	Age      int    `json:"age" validate:"required,min=13"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
+	Locale   string `json:"locale" validate:"omitempty,oneof=en th"`
 }
 
 type UpdateUserRequest struct {
-	Name  *string `json:"name" validate:"omitempty,max=100"`
-	Email *string `json:"email" validate:"omitempty,email"`
-	Age   *int    `json:"age" validate:"omitempty,min=13"`
+	Name   *string `json:"name" validate:"omitempty,max=100"`
+	Age    *int    `json:"age" validate:"omitempty,min=13"`
+	Locale *string `json:"locale" validate:"omitempty,oneof=en th"`
+}
+
+// ImportUserRequest is a user from another system, with a password hash
+// in one of the formats utils.VerifyPassword understands.
+type ImportUserRequest struct {
+	Name         string `json:"name" validate:"required,max=100"`
+	Email        string `json:"email" validate:"required,email"`
+	Age          int    `json:"age" validate:"required,min=13"`
+	Locale       string `json:"locale" validate:"omitempty,oneof=en th"`
+	PasswordHash string `json:"password_hash" validate:"required"`
+}
+
+type ImportUsersRequest struct {
+	Users []ImportUserRequest `json:"users" validate:"required,min=1,max=1000"`
 }
 
 // Response
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Age       int       `json:"age"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Age       int        `json:"age"`
+	Locale    string     `json:"locale"`
+	Role      string     `json:"role"`
+	Source    string     `json:"source"`
+	LockedAt  *time.Time `json:"locked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 func FromUserEntity(user *entities.User) *UserResponse {
@@ -37,6 +56,10 @@ func FromUserEntity(user *entities.User) *UserResponse {
 		Name:      user.Name,
 		Email:     user.Email,
 		Age:       user.Age,
+		Locale:    user.Locale,
+		Role:      user.Role,
+		Source:    user.Source,
+		LockedAt:  user.LockedAt,
 		CreatedAt: user.Created_at,
 		UpdatedAt: user.Updated_at,
 	}
@@ -49,3 +72,14 @@ func FromUserEntities(users []entities.User) []*UserResponse {
 	}
 	return userResponse
 }
+
+type ImportUserFailure struct {
+	Index  int    `json:"index"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+type ImportUsersResponse struct {
+	Imported []*UserResponse     `json:"imported"`
+	Failed   []ImportUserFailure `json:"failed"`
+}
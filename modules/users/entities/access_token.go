@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessTokenPrefix starts every personal access token, so they can be
+// told apart from JWTs and found by secret scanners.
+const AccessTokenPrefix = "pat_"
+
+// Scopes a personal access token can be granted. A session from a
+// password login has all of them, and some endpoints, like creating
+// tokens, need a session whatever the scopes.
+const (
+	ScopeProfileRead = "profile:read"
+	ScopeTokensRead  = "tokens:read"
+)
+
+// AccessToken is a personal access token, a long-lived API key a user
+// creates for scripts and CI. Only the token's SHA-256 is stored, Prefix
+// keeps its first characters so the user can recognise it in a list.
+type AccessToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	User       User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	TokenHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"not null" json:"scopes"` // space separated
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Created_at time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
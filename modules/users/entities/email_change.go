@@ -0,0 +1,18 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailChange struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	User        User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	NewEmail    string     `gorm:"type:varchar(100);not null" json:"new_email"`
+	TokenHash   string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	Created_at  time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package entities
+
+// Aggregate types and domain event types written to the outbox.
+const (
+	AggregateUser    = "user"
+	AggregateSession = "session"
+
+	EventUserCreated         = "user.created"
+	EventUserUpdated         = "user.updated"
+	EventUserDeleted         = "user.deleted"
+	EventUserLocked          = "user.locked"
+	EventUserUnlocked        = "user.unlocked"
+	EventSessionRevoked      = "session.revoked"
+	EventUserSessionsRevoked = "user.sessions_revoked"
+)
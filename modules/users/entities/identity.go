@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links a user to their account at an upstream identity
+// provider, by the provider's name and its subject for the user.
+type Identity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	User        User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email       string     `gorm:"type:varchar(100)" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	Created_at  time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// SSOLoginState is a login started with an upstream provider, kept until
+// the browser comes back with the code. Only the state's SHA-256 is
+// stored. LinkUserID is set when a signed in user started it to link the
+// identity to their account.
+type SSOLoginState struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Provider     string     `gorm:"type:varchar(50);not null" json:"provider"`
+	StateHash    string     `gorm:"not null;uniqueIndex" json:"-"`
+	Nonce        string     `gorm:"not null" json:"-"`
+	CodeVerifier string     `gorm:"not null" json:"-"`
+	LinkUserID   *uuid.UUID `gorm:"type:uuid" json:"link_user_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	Created_at   time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// SAMLLogin is a login started with a SAML identity provider. The relay
+// state is cleared when the response comes back, and the user it signed
+// in is then held for the login page under a one-time code. Only the
+// SHA-256s of the relay state and code are stored. LinkUserID is set as
+// for SSOLoginState.
+type SAMLLogin struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Provider       string     `gorm:"type:varchar(50);not null" json:"provider"`
+	RelayStateHash *string    `gorm:"uniqueIndex" json:"-"`
+	RequestID      string     `gorm:"not null" json:"-"`
+	CodeHash       *string    `gorm:"uniqueIndex" json:"-"`
+	LinkUserID     *uuid.UUID `gorm:"type:uuid" json:"link_user_id"`
+	UserID         *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	Created_at     time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
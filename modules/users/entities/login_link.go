@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ways a passwordless login is emailed.
+const (
+	LoginMethodLink = "link"
+	LoginMethodCode = "code"
+)
+
+// LoginLink is a passwordless login emailed to a user, as a link or a
+// one-time code, which only the device that asked for it can complete.
+// Only the SHA-256 of the link's token or of the code is stored.
+type LoginLink struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	User       User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Method     string    `gorm:"type:varchar(10);not null" json:"method"`
+	SecretHash string    `gorm:"not null;index" json:"-"`
+	DeviceID   string    `gorm:"not null" json:"device_id"`
+	Attempts   int       `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt  time.Time `gorm:"not null" json:"expires_at"`
+	Created_at time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
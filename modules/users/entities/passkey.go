@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of a passkey ceremony.
+const (
+	PasskeyCeremonyRegister = "register"
+	PasskeyCeremonyLogin    = "login"
+)
+
+// Passkey is a WebAuthn credential registered by a user.
+type Passkey struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	User            User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	CredentialID    []byte    `gorm:"not null;uniqueIndex" json:"-"`
+	PublicKey       []byte    `gorm:"not null" json:"-"`
+	AttestationType string    `gorm:"type:varchar(50)" json:"attestation_type"`
+	AAGUID          []byte    `json:"-"`
+	SignCount       uint32    `gorm:"not null;default:0" json:"sign_count"`
+	// Transports is comma-separated, e.g. "internal,hybrid".
+	Transports     string     `gorm:"type:varchar(100)" json:"transports"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState    bool       `gorm:"not null;default:false" json:"backup_state"`
+	Nickname       string     `gorm:"type:varchar(100);not null" json:"nickname"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	Created_at     time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// PasskeyCeremony is the server side of a registration or login started
+// but not yet finished. Login ceremonies without a user accept any
+// discoverable credential, those with one are a second factor.
+type PasskeyCeremony struct {
+	ID      uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID  *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	Purpose string     `gorm:"type:varchar(10);not null" json:"purpose"`
+	// AMR is how the user logged in before the second factor,
+	// space-separated.
+	AMR        string    `gorm:"type:varchar(50)" json:"amr"`
+	Session    []byte    `gorm:"not null" json:"-"`
+	ExpiresAt  time.Time `gorm:"not null" json:"expires_at"`
+	Created_at time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
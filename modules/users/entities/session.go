@@ -19,4 +19,8 @@ type Session struct {
 	IssuedAt     time.Time `gorm:"not null" json:"issued_at" validate:"required"`
 	ExpiresAt    time.Time `gorm:"not null" json:"expires_at" validate:"required"`
 	Revoked      bool      `gorm:"default:false" json:"revoked"`
+
+	// when and how the user last authenticated, kept across refreshes
+	AuthTime     *time.Time `json:"auth_time"`
+	AMR          string     `gorm:"type:varchar(50)" json:"amr"` // space separated
 }
\ No newline at end of file
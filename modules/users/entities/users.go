@@ -6,13 +6,34 @@ import (
 	"github.com/google/uuid"
 )
 
+// RoleUser is the role of users no directory group maps to another role.
+// Roles are recorded for clients to act on; this service does not enforce
+// them, access to its admin API is by admin key or service token.
+const RoleUser = "user"
+
+// Where a user was provisioned from, see User.Source.
+const (
+	SourceLocal = "local"
+	SourceLDAP  = "ldap"
+)
+
 type User struct {
 	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
 	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
 	Email        string    `gorm:"type:varchar(100);unique;not null" json:"email"`
 	PasswordHash string    `gorm:"not null" json:"-"`
-	Salt         string    `gorm:"not null" json:"-"`
 	Age          int       `gorm:"type:int;not null" json:"age"`
-	Created_at   time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
-	Updated_at   time.Time `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+	Locale       string    `gorm:"type:varchar(10);not null;default:'en'" json:"locale"`
+	Role         string    `gorm:"type:varchar(50);not null;default:'user'" json:"role"`
+	// Source is what provisioned the user and owns its profile: this
+	// service, or the LDAP directory that syncs it on every login.
+	Source string `gorm:"type:varchar(20);not null;default:'local'" json:"source"`
+	// EmailVerifiedAt is when the user proved they own Email, by a link or
+	// code sent to it or an identity provider that verified it.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// LockedAt is when an admin locked the user out, nil while they may
+	// log in.
+	LockedAt   *time.Time `json:"locked_at"`
+	Created_at time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	Updated_at time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
 }
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type accessTokenPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenPostgresRepository(db *gorm.DB) AccessTokenRepository {
+	return &accessTokenPostgresRepository{db: db}
+}
+
+// Insert
+func (r *accessTokenPostgresRepository) Insert(ctx context.Context, token *entities.AccessToken) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(token).Error)
+}
+
+// GetByTokenHash
+func (r *accessTokenPostgresRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*entities.AccessToken, error) {
+	var token entities.AccessToken
+	err := databases.Conn(ctx, r.db).First(&token, "token_hash = ?", tokenHash).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &token, nil
+}
+
+// ListByUserID returns the user's tokens, newest first.
+func (r *accessTokenPostgresRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.AccessToken, error) {
+	var tokens []entities.AccessToken
+	err := databases.Conn(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, databases.TranslateError(err)
+}
+
+// Revoke revokes one of the user's tokens, gorm.ErrRecordNotFound if the
+// user has no such token or it is already revoked.
+func (r *accessTokenPostgresRepository) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.AccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkUsed
+func (r *accessTokenPostgresRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, ip string) error {
+	err := databases.Conn(ctx, r.db).
+		Model(&entities.AccessToken{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": at, "last_used_ip": ip}).Error
+	return databases.TranslateError(err)
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type AccessTokenRepository interface {
+	Insert(ctx context.Context, token *entities.AccessToken) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*entities.AccessToken, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.AccessToken, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID) error
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, ip string) error
+}
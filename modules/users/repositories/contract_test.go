@@ -0,0 +1,396 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/config"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/databases/migrations"
+)
+
+// backend builds fresh user and session repositories sharing one store,
+// and the transaction manager they take part in.
+type backend func(t *testing.T) (repositories.UserRepository, repositories.SessionRepository, databases.TxManager)
+
+func backends() map[string]backend {
+	return map[string]backend{
+		"memory": func(t *testing.T) (repositories.UserRepository, repositories.SessionRepository, databases.TxManager) {
+			db := databases.Connect(&config.Config{DBDriver: databases.DriverMemory})
+			return repositories.NewUserMemoryRepository(), repositories.NewSessionMemoryRepository(), databases.NewTxManager(db)
+		},
+		"sqlite": func(t *testing.T) (repositories.UserRepository, repositories.SessionRepository, databases.TxManager) {
+			db := databases.Connect(&config.Config{DBDriver: databases.DriverSQLite, DBPath: ":memory:"})
+			migrations.Migrate(db)
+			return repositories.NewUserSQLiteRepository(db), repositories.NewSessionSQLiteRepository(db), databases.NewTxManager(db)
+		},
+		"postgres": func(t *testing.T) (repositories.UserRepository, repositories.SessionRepository, databases.TxManager) {
+			dsn := os.Getenv("TEST_POSTGRES_DSN")
+			if dsn == "" {
+				t.Skip("TEST_POSTGRES_DSN not set")
+			}
+			db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+			if err != nil {
+				t.Fatalf("connect postgres: %v", err)
+			}
+			migrations.Migrate(db)
+			return repositories.NewUserPostgresRepository(db), repositories.NewSessionPostgresRepository(db), databases.NewTxManager(db)
+		},
+	}
+}
+
+func TestUserRepositoryContract(t *testing.T) {
+	for name, newBackend := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			users, _, _ := newBackend(t)
+
+			user := newUser()
+			if err := users.CreateUser(ctx, user); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+
+			t.Run("get by id", func(t *testing.T) {
+				got, err := users.GetUserByID(ctx, user.ID)
+				if err != nil {
+					t.Fatalf("GetUserByID: %v", err)
+				}
+				if got.Email != user.Email || got.Name != user.Name || got.Age != user.Age {
+					t.Errorf("got %+v, want %+v", got, user)
+				}
+			})
+
+			t.Run("update password hash", func(t *testing.T) {
+				if err := users.UpdatePasswordHash(ctx, user.ID, "new-hash"); err != nil {
+					t.Fatalf("UpdatePasswordHash: %v", err)
+				}
+				got, err := users.GetUserByID(ctx, user.ID)
+				if err != nil {
+					t.Fatalf("GetUserByID: %v", err)
+				}
+				if got.PasswordHash != "new-hash" {
+					t.Errorf("password hash = %q, want new-hash", got.PasswordHash)
+				}
+
+				if err := users.UpdatePasswordHash(ctx, uuid.New(), "new-hash"); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("UpdatePasswordHash(unknown) = %v, want ErrRecordNotFound", err)
+				}
+			})
+
+			t.Run("mark email verified", func(t *testing.T) {
+				at := time.Now().Truncate(time.Second)
+				if err := users.MarkEmailVerified(ctx, user.ID, at); err != nil {
+					t.Fatalf("MarkEmailVerified: %v", err)
+				}
+				got, err := users.GetUserByID(ctx, user.ID)
+				if err != nil {
+					t.Fatalf("GetUserByID: %v", err)
+				}
+				if got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(at) {
+					t.Errorf("email verified at = %v, want %v", got.EmailVerifiedAt, at)
+				}
+
+				if err := users.MarkEmailVerified(ctx, uuid.New(), at); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("MarkEmailVerified(unknown) = %v, want ErrRecordNotFound", err)
+				}
+			})
+
+			t.Run("set locked at", func(t *testing.T) {
+				at := time.Now().Truncate(time.Second)
+				if err := users.SetLockedAt(ctx, user.ID, &at); err != nil {
+					t.Fatalf("SetLockedAt: %v", err)
+				}
+				got, err := users.GetUserByID(ctx, user.ID)
+				if err != nil {
+					t.Fatalf("GetUserByID: %v", err)
+				}
+				if got.LockedAt == nil || !got.LockedAt.Equal(at) {
+					t.Errorf("locked at = %v, want %v", got.LockedAt, at)
+				}
+
+				if err := users.SetLockedAt(ctx, user.ID, nil); err != nil {
+					t.Fatalf("SetLockedAt(nil): %v", err)
+				}
+				if got, _ := users.GetUserByID(ctx, user.ID); got.LockedAt != nil {
+					t.Errorf("locked at = %v after unlock, want nil", got.LockedAt)
+				}
+
+				if err := users.SetLockedAt(ctx, uuid.New(), &at); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("SetLockedAt(unknown) = %v, want ErrRecordNotFound", err)
+				}
+			})
+
+			t.Run("get by email ignores case", func(t *testing.T) {
+				got, err := users.GetUserByEmail(ctx, strings.ToUpper(user.Email))
+				if err != nil {
+					t.Fatalf("GetUserByEmail: %v", err)
+				}
+				if got.ID != user.ID {
+					t.Errorf("got user %s, want %s", got.ID, user.ID)
+				}
+			})
+
+			t.Run("duplicate email", func(t *testing.T) {
+				dup := newUser()
+				dup.Email = user.Email
+				err := users.CreateUser(ctx, dup)
+				if !errors.Is(err, databases.ErrDuplicateKey) {
+					t.Errorf("got %v, want ErrDuplicateKey", err)
+				}
+			})
+
+			t.Run("get all", func(t *testing.T) {
+				all, err := users.GetAllUsers(ctx)
+				if err != nil {
+					t.Fatalf("GetAllUsers: %v", err)
+				}
+				if !containsUser(all, user.ID) {
+					t.Errorf("user %s missing from GetAllUsers", user.ID)
+				}
+			})
+
+			t.Run("update", func(t *testing.T) {
+				changed := *user
+				changed.Name = "Renamed"
+				if _, err := users.UpdateUserByID(ctx, user.ID, &changed); err != nil {
+					t.Fatalf("UpdateUserByID: %v", err)
+				}
+				got, err := users.GetUserByID(ctx, user.ID)
+				if err != nil {
+					t.Fatalf("GetUserByID: %v", err)
+				}
+				if got.Name != "Renamed" {
+					t.Errorf("name = %q, want Renamed", got.Name)
+				}
+			})
+
+			t.Run("missing user", func(t *testing.T) {
+				if _, err := users.GetUserByID(ctx, uuid.New()); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("GetUserByID: got %v, want ErrRecordNotFound", err)
+				}
+				if _, err := users.UpdateUserByID(ctx, uuid.New(), newUser()); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("UpdateUserByID: got %v, want ErrRecordNotFound", err)
+				}
+				if _, err := users.GetUserByEmail(ctx, "missing-"+user.Email); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("GetUserByEmail: got %v, want ErrRecordNotFound", err)
+				}
+			})
+
+			t.Run("delete", func(t *testing.T) {
+				if _, err := users.DeleteUserByID(ctx, user.ID); err != nil {
+					t.Fatalf("DeleteUserByID: %v", err)
+				}
+				if _, err := users.DeleteUserByID(ctx, user.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("second delete: got %v, want ErrRecordNotFound", err)
+				}
+			})
+		})
+	}
+}
+
+func TestSessionRepositoryContract(t *testing.T) {
+	for name, newBackend := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			users, sessions, _ := newBackend(t)
+
+			owner, other := newUser(), newUser()
+			for _, u := range []*entities.User{owner, other} {
+				if err := users.CreateUser(ctx, u); err != nil {
+					t.Fatalf("CreateUser: %v", err)
+				}
+			}
+
+			first, second, foreign := newSession(owner.ID), newSession(owner.ID), newSession(other.ID)
+			for _, s := range []*entities.Session{first, second, foreign} {
+				if err := sessions.Insert(ctx, s); err != nil {
+					t.Fatalf("Insert: %v", err)
+				}
+			}
+
+			t.Run("get by id", func(t *testing.T) {
+				got, err := sessions.GetByID(ctx, first.ID)
+				if err != nil {
+					t.Fatalf("GetByID: %v", err)
+				}
+				if got.UserID != owner.ID || got.DeviceID != first.DeviceID || got.Revoked {
+					t.Errorf("got %+v, want %+v", got, first)
+				}
+				if _, err := sessions.GetByID(ctx, uuid.New()); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("missing session: got %v, want ErrRecordNotFound", err)
+				}
+			})
+
+			t.Run("mark revoked", func(t *testing.T) {
+				if err := sessions.MarkRevoked(ctx, first.ID); err != nil {
+					t.Fatalf("MarkRevoked: %v", err)
+				}
+				assertRevoked(t, sessions, first.ID, true)
+
+				if err := sessions.MarkRevoked(ctx, first.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("second MarkRevoked: got %v, want ErrRecordNotFound", err)
+				}
+			})
+
+			t.Run("mark revoked by user", func(t *testing.T) {
+				if err := sessions.MarkRevokedByUserID(ctx, owner.ID); err != nil {
+					t.Fatalf("MarkRevokedByUserID: %v", err)
+				}
+				assertRevoked(t, sessions, second.ID, true)
+				assertRevoked(t, sessions, foreign.ID, false)
+			})
+		})
+	}
+}
+
+func TestRepositoriesRollBack(t *testing.T) {
+	for name, newBackend := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			users, sessions, tx := newBackend(t)
+
+			kept := newUser()
+			if err := users.CreateUser(ctx, kept); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			session := newSession(kept.ID)
+			if err := sessions.Insert(ctx, session); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+
+			added := newUser()
+			rollback := errors.New("roll back")
+			err := tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				if err := users.CreateUser(ctx, added); err != nil {
+					return err
+				}
+				changed := *kept
+				changed.Name = "Renamed"
+				if _, err := users.UpdateUserByID(ctx, kept.ID, &changed); err != nil {
+					return err
+				}
+				if err := sessions.MarkRevokedByUserID(ctx, kept.ID); err != nil {
+					return err
+				}
+				return rollback
+			})
+			if !errors.Is(err, rollback) {
+				t.Fatalf("WithinTransaction = %v, want %v", err, rollback)
+			}
+
+			if _, err := users.GetUserByID(ctx, added.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("user created in the rolled back transaction: got %v, want ErrRecordNotFound", err)
+			}
+			if got, _ := users.GetUserByID(ctx, kept.ID); got.Name != kept.Name {
+				t.Errorf("name = %q after rollback, want %q", got.Name, kept.Name)
+			}
+			assertRevoked(t, sessions, session.ID, false)
+		})
+	}
+}
+
+func newUser() *entities.User {
+	id := uuid.New()
+	return &entities.User{
+		ID:           id,
+		Name:         "Test User",
+		Email:        "user-" + id.String() + "@example.com",
+		PasswordHash: "hash",
+		Age:          30,
+		Locale:       "en",
+		Created_at:   time.Now(),
+		Updated_at:   time.Now(),
+	}
+}
+
+func newSession(userID uuid.UUID) *entities.Session {
+	return &entities.Session{
+		ID:          uuid.New(),
+		UserID:      userID,
+		HashedToken: "hashed",
+		DeviceID:    "device-" + uuid.NewString(),
+		DeviceUA:    "test-agent",
+		DeviceIP:    "127.0.0.1",
+		IssuedAt:    time.Now(),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+}
+
+func containsUser(users []entities.User, id uuid.UUID) bool {
+	for _, u := range users {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func assertRevoked(t *testing.T, sessions repositories.SessionRepository, id uuid.UUID, want bool) {
+	t.Helper()
+
+	got, err := sessions.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Revoked != want {
+		t.Errorf("session %s revoked = %v, want %v", id, got.Revoked, want)
+	}
+}
+
+func TestLoginLinkClaimAttempt(t *testing.T) {
+	ctx := context.Background()
+	db := databases.Connect(&config.Config{DBDriver: databases.DriverSQLite, DBPath: ":memory:"})
+	migrations.Migrate(db)
+	users := repositories.NewUserPostgresRepository(db)
+	links := repositories.NewLoginLinkPostgresRepository(db)
+
+	user := newUser()
+	if err := users.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	link := &entities.LoginLink{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		Method:     entities.LoginMethodCode,
+		SecretHash: "hashed",
+		DeviceID:   "device",
+		ExpiresAt:  time.Now().Add(time.Minute),
+		Created_at: time.Now(),
+	}
+	if err := links.Insert(ctx, link); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var claimed atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := links.ClaimAttempt(ctx, link.ID, 5)
+			switch {
+			case err == nil:
+				claimed.Add(1)
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				t.Errorf("ClaimAttempt: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := claimed.Load(); got != 5 {
+		t.Errorf("claimed %d attempts, want 5", got)
+	}
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type emailChangePostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailChangePostgresRepository(db *gorm.DB) EmailChangeRepository {
+	return &emailChangePostgresRepository{db: db}
+}
+
+// Insert
+func (r *emailChangePostgresRepository) Insert(ctx context.Context, change *entities.EmailChange) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(change).Error)
+}
+
+// GetByTokenHash
+func (r *emailChangePostgresRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*entities.EmailChange, error) {
+	var change entities.EmailChange
+	err := databases.Conn(ctx, r.db).First(&change, "token_hash = ?", tokenHash).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &change, nil
+}
+
+// MarkConfirmed
+func (r *emailChangePostgresRepository) MarkConfirmed(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.EmailChange{}).
+		Where("id = ? AND confirmed_at IS NULL AND expires_at > ?", id, now).
+		Update("confirmed_at", now)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	// already confirmed by a concurrent request, expired, or missing
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeletePendingByUserID
+func (r *emailChangePostgresRepository) DeletePendingByUserID(ctx context.Context, userID uuid.UUID) error {
+	err := databases.Conn(ctx, r.db).
+		Where("user_id = ? AND confirmed_at IS NULL", userID).
+		Delete(&entities.EmailChange{}).Error
+	return databases.TranslateError(err)
+}
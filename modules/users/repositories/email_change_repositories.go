@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type EmailChangeRepository interface {
+	Insert(ctx context.Context, change *entities.EmailChange) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*entities.EmailChange, error)
+	// MarkConfirmed returns gorm.ErrRecordNotFound unless the change is
+	// still pending and unexpired.
+	MarkConfirmed(ctx context.Context, id uuid.UUID) error
+	DeletePendingByUserID(ctx context.Context, userID uuid.UUID) error
+}
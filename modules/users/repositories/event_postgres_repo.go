@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/outbox"
+	"gorm.io/gorm"
+)
+
+type eventPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewEventPostgresRepository(db *gorm.DB) EventRepository {
+	return &eventPostgresRepository{db: db}
+}
+
+// Append
+func (r *eventPostgresRepository) Append(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) error {
+	err := outbox.Append(databases.Conn(ctx, r.db), aggregateType, aggregateID, eventType, payload)
+	return databases.TranslateError(err)
+}
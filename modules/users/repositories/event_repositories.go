@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// EventRepository writes domain events to the outbox. Use cases append
+// them in the transaction of the change they describe, so both commit or
+// roll back together.
+type EventRepository interface {
+	Append(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) error
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type identityPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityPostgresRepository(db *gorm.DB) IdentityRepository {
+	return &identityPostgresRepository{db: db}
+}
+
+// Insert
+func (r *identityPostgresRepository) Insert(ctx context.Context, identity *entities.Identity) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(identity).Error)
+}
+
+// GetByProviderSubject
+func (r *identityPostgresRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*entities.Identity, error) {
+	var identity entities.Identity
+	err := databases.Conn(ctx, r.db).First(&identity, "provider = ? AND subject = ?", provider, subject).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &identity, nil
+}
+
+// ListByUserID
+func (r *identityPostgresRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.Identity, error) {
+	var identities []entities.Identity
+	err := databases.Conn(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&identities).Error
+	return identities, databases.TranslateError(err)
+}
+
+// Delete
+func (r *identityPostgresRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).Delete(&entities.Identity{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkLogin records a login with the identity, and the email the
+// provider has for it now.
+func (r *identityPostgresRepository) MarkLogin(ctx context.Context, id uuid.UUID, at time.Time, email string) error {
+	err := databases.Conn(ctx, r.db).
+		Model(&entities.Identity{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"last_login_at": at, "email": email}).Error
+	return databases.TranslateError(err)
+}
+
+// InsertLoginState
+func (r *identityPostgresRepository) InsertLoginState(ctx context.Context, state *entities.SSOLoginState) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(state).Error)
+}
+
+// ConsumeLoginState
+func (r *identityPostgresRepository) ConsumeLoginState(ctx context.Context, stateHash string) (*entities.SSOLoginState, error) {
+	var state entities.SSOLoginState
+	if err := databases.Conn(ctx, r.db).First(&state, "state_hash = ?", stateHash).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+
+	// only the caller that deletes the row gets the login
+	result := databases.Conn(ctx, r.db).Delete(&entities.SSOLoginState{}, "id = ?", state.ID)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &state, nil
+}
@@ -0,0 +1,23 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type IdentityRepository interface {
+	Insert(ctx context.Context, identity *entities.Identity) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*entities.Identity, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.Identity, error)
+	// Delete deletes the identity if it is linked to userID.
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+	MarkLogin(ctx context.Context, id uuid.UUID, at time.Time, email string) error
+
+	InsertLoginState(ctx context.Context, state *entities.SSOLoginState) error
+	// ConsumeLoginState deletes and returns the login with the state, which
+	// fails for all but one of concurrent callers.
+	ConsumeLoginState(ctx context.Context, stateHash string) (*entities.SSOLoginState, error)
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type loginLinkPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginLinkPostgresRepository(db *gorm.DB) LoginLinkRepository {
+	return &loginLinkPostgresRepository{db: db}
+}
+
+// Insert
+func (r *loginLinkPostgresRepository) Insert(ctx context.Context, link *entities.LoginLink) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(link).Error)
+}
+
+// GetBySecretHash
+func (r *loginLinkPostgresRepository) GetBySecretHash(ctx context.Context, method, secretHash string) (*entities.LoginLink, error) {
+	var link entities.LoginLink
+	err := databases.Conn(ctx, r.db).First(&link, "method = ? AND secret_hash = ?", method, secretHash).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &link, nil
+}
+
+// GetLatestByUserID
+func (r *loginLinkPostgresRepository) GetLatestByUserID(ctx context.Context, userID uuid.UUID, method string) (*entities.LoginLink, error) {
+	var link entities.LoginLink
+	err := databases.Conn(ctx, r.db).
+		Where("user_id = ? AND method = ?", userID, method).
+		Order("created_at DESC").
+		First(&link).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &link, nil
+}
+
+// ClaimAttempt
+func (r *loginLinkPostgresRepository) ClaimAttempt(ctx context.Context, id uuid.UUID, max int) error {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.LoginLink{}).
+		Where("id = ? AND attempts < ?", id, max).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete
+func (r *loginLinkPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).Delete(&entities.LoginLink{}, "id = ?", id)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteByUserID
+func (r *loginLinkPostgresRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	err := databases.Conn(ctx, r.db).Delete(&entities.LoginLink{}, "user_id = ?", userID).Error
+	return databases.TranslateError(err)
+}
@@ -0,0 +1,23 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type LoginLinkRepository interface {
+	Insert(ctx context.Context, link *entities.LoginLink) error
+	GetBySecretHash(ctx context.Context, method, secretHash string) (*entities.LoginLink, error)
+	// GetLatestByUserID returns the user's most recent login sent with method.
+	GetLatestByUserID(ctx context.Context, userID uuid.UUID, method string) (*entities.LoginLink, error)
+	// ClaimAttempt counts an attempt at the login, failing with
+	// gorm.ErrRecordNotFound once it has had max; concurrent callers can't
+	// claim more than max between them.
+	ClaimAttempt(ctx context.Context, id uuid.UUID, max int) error
+	// Delete fails with gorm.ErrRecordNotFound for all but one of
+	// concurrent callers, so a login deleted this way is used once.
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteByUserID(ctx context.Context, userID uuid.UUID) error
+}
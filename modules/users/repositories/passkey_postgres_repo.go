@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type passkeyPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewPasskeyPostgresRepository(db *gorm.DB) PasskeyRepository {
+	return &passkeyPostgresRepository{db: db}
+}
+
+// Insert
+func (r *passkeyPostgresRepository) Insert(ctx context.Context, passkey *entities.Passkey) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(passkey).Error)
+}
+
+// ListByUserID
+func (r *passkeyPostgresRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.Passkey, error) {
+	var passkeys []entities.Passkey
+	err := databases.Conn(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&passkeys).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return passkeys, nil
+}
+
+// MarkUsed
+func (r *passkeyPostgresRepository) MarkUsed(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time) error {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.Passkey{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"sign_count": signCount, "backup_state": backupState, "last_used_at": usedAt})
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Rename
+func (r *passkeyPostgresRepository) Rename(ctx context.Context, id, userID uuid.UUID, nickname string) (*entities.Passkey, error) {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.Passkey{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("nickname", nickname)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var passkey entities.Passkey
+	if err := databases.Conn(ctx, r.db).First(&passkey, "id = ?", id).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &passkey, nil
+}
+
+// Delete
+func (r *passkeyPostgresRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).Delete(&entities.Passkey{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// InsertCeremony
+func (r *passkeyPostgresRepository) InsertCeremony(ctx context.Context, ceremony *entities.PasskeyCeremony) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(ceremony).Error)
+}
+
+// ConsumeCeremony
+func (r *passkeyPostgresRepository) ConsumeCeremony(ctx context.Context, id uuid.UUID) (*entities.PasskeyCeremony, error) {
+	var ceremony entities.PasskeyCeremony
+	if err := databases.Conn(ctx, r.db).First(&ceremony, "id = ?", id).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+
+	result := databases.Conn(ctx, r.db).Delete(&entities.PasskeyCeremony{}, "id = ?", ceremony.ID)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &ceremony, nil
+}
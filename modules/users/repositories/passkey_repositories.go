@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type PasskeyRepository interface {
+	Insert(ctx context.Context, passkey *entities.Passkey) error
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.Passkey, error)
+	// MarkUsed records a login with the passkey and its new sign count.
+	MarkUsed(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time) error
+	// Rename and Delete only touch the user's own passkeys.
+	Rename(ctx context.Context, id, userID uuid.UUID, nickname string) (*entities.Passkey, error)
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+
+	InsertCeremony(ctx context.Context, ceremony *entities.PasskeyCeremony) error
+	// ConsumeCeremony deletes and returns the ceremony, so it can be
+	// finished once.
+	ConsumeCeremony(ctx context.Context, id uuid.UUID) (*entities.PasskeyCeremony, error)
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type samlLoginPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewSAMLLoginPostgresRepository(db *gorm.DB) SAMLLoginRepository {
+	return &samlLoginPostgresRepository{db: db}
+}
+
+// Insert
+func (r *samlLoginPostgresRepository) Insert(ctx context.Context, login *entities.SAMLLogin) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(login).Error)
+}
+
+// ConsumeRelayState
+func (r *samlLoginPostgresRepository) ConsumeRelayState(ctx context.Context, relayStateHash string) (*entities.SAMLLogin, error) {
+	var login entities.SAMLLogin
+	if err := databases.Conn(ctx, r.db).First(&login, "relay_state_hash = ?", relayStateHash).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+
+	// only the caller that clears the relay state gets the login
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.SAMLLogin{}).
+		Where("id = ? AND relay_state_hash = ?", login.ID, relayStateHash).
+		Update("relay_state_hash", nil)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	login.RelayStateHash = nil
+	return &login, nil
+}
+
+// SetCode
+func (r *samlLoginPostgresRepository) SetCode(ctx context.Context, id uuid.UUID, codeHash string, userID uuid.UUID, expiresAt time.Time) error {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.SAMLLogin{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"code_hash": codeHash, "user_id": userID, "expires_at": expiresAt})
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ConsumeCode
+func (r *samlLoginPostgresRepository) ConsumeCode(ctx context.Context, codeHash string) (*entities.SAMLLogin, error) {
+	var login entities.SAMLLogin
+	if err := databases.Conn(ctx, r.db).First(&login, "code_hash = ?", codeHash).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+
+	result := databases.Conn(ctx, r.db).Delete(&entities.SAMLLogin{}, "id = ?", login.ID)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &login, nil
+}
@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+)
+
+type SAMLLoginRepository interface {
+	Insert(ctx context.Context, login *entities.SAMLLogin) error
+	// ConsumeRelayState clears and returns the login with the relay state,
+	// which fails for all but one of concurrent callers.
+	ConsumeRelayState(ctx context.Context, relayStateHash string) (*entities.SAMLLogin, error)
+	SetCode(ctx context.Context, id uuid.UUID, codeHash string, userID uuid.UUID, expiresAt time.Time) error
+	// ConsumeCode deletes and returns the login with the code, which fails
+	// for all but one of concurrent callers.
+	ConsumeCode(ctx context.Context, codeHash string) (*entities.SAMLLogin, error)
+}
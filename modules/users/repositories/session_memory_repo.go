@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+// sessionMemoryRepository keeps sessions in a map, see userMemoryRepository.
+type sessionMemoryRepository struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]entities.Session
+}
+
+func NewSessionMemoryRepository() SessionRepository {
+	return &sessionMemoryRepository{
+		sessions: make(map[uuid.UUID]entities.Session),
+	}
+}
+
+// Insert
+func (r *sessionMemoryRepository) Insert(ctx context.Context, session *entities.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; ok {
+		return fmt.Errorf("session %s: %w", session.ID, databases.ErrDuplicateKey)
+	}
+
+	// fiber hands out header values backed by its request buffer,
+	// copy them before the request ends
+	stored := *session
+	stored.DeviceIP = strings.Clone(session.DeviceIP)
+	stored.DeviceUA = strings.Clone(session.DeviceUA)
+	stored.DeviceID = strings.Clone(session.DeviceID)
+
+	r.restoreOnRollback(ctx, session.ID)
+	r.sessions[session.ID] = stored
+	return nil
+}
+
+// GetByID
+func (r *sessionMemoryRepository) GetByID(ctx context.Context, sessionID uuid.UUID) (*entities.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &session, nil
+}
+
+// MarkRevoked
+func (r *sessionMemoryRepository) MarkRevoked(ctx context.Context, sessionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok || session.Revoked {
+		return gorm.ErrRecordNotFound
+	}
+
+	r.restoreOnRollback(ctx, sessionID)
+	session.Revoked = true
+	r.sessions[sessionID] = session
+	return nil
+}
+
+// MarkRevokedByUserID
+func (r *sessionMemoryRepository) MarkRevokedByUserID(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.UserID == userID && !session.Revoked {
+			r.restoreOnRollback(ctx, id)
+			session.Revoked = true
+			r.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// restoreOnRollback puts the session back as it is now if the transaction
+// in ctx rolls back; callers hold mu.
+func (r *sessionMemoryRepository) restoreOnRollback(ctx context.Context, id uuid.UUID) {
+	prev, existed := r.sessions[id]
+	databases.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if existed {
+			r.sessions[id] = prev
+		} else {
+			delete(r.sessions, id)
+		}
+	})
+}
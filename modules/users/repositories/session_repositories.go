@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
 )
 
 type sessionPostgresRepository struct {
@@ -20,15 +21,15 @@ func NewSessionPostgresRepository(db *gorm.DB) SessionRepository {
 
 // Insert
 func (r *sessionPostgresRepository) Insert(ctx context.Context, session *entities.Session) error {
-	return r.db.WithContext(ctx).Create(session).Error
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(session).Error)
 }
 
 // GetByID
 func (r *sessionPostgresRepository) GetByID(ctx context.Context, sessionID uuid.UUID) (*entities.Session, error) {
 	var session entities.Session
-	err := r.db.WithContext(ctx).First(&session, "id = ?", sessionID).Error
+	err := databases.Conn(ctx, r.db).First(&session, "id = ?", sessionID).Error
 	if err != nil {
-		return nil, err
+		return nil, databases.TranslateError(err)
 	}
 	return &session, nil
 }
@@ -36,10 +37,10 @@ func (r *sessionPostgresRepository) GetByID(ctx context.Context, sessionID uuid.
 // GetAllByUserID
 func (r *sessionPostgresRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]*entities.Session, error) {
 	var sessions []*entities.Session
-	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&sessions)
+	result := databases.Conn(ctx, r.db).Where("user_id = ?", userID).Find(&sessions)
 
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, databases.TranslateError(result.Error)
 	}
 
 	return sessions, nil
@@ -47,16 +48,25 @@ func (r *sessionPostgresRepository) GetAllByUserID(ctx context.Context, userID u
 
 // MarkRevoked
 func (r *sessionPostgresRepository) MarkRevoked(ctx context.Context, sessionID uuid.UUID) error {
-	return r.db.WithContext(ctx).
+	result := databases.Conn(ctx, r.db).
 		Model(&entities.Session{}).
-		Where("id = ?", sessionID).
-		Update("revoked", true).Error
+		Where("id = ? AND revoked = ?", sessionID, false).
+		Update("revoked", true)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	// already revoked by a concurrent request, or missing
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 // MarkAllRevokedByUserID
 func (r *sessionPostgresRepository) MarkRevokedByUserID(ctx context.Context, userID uuid.UUID) error {
-	return r.db.WithContext(ctx).
+	err := databases.Conn(ctx, r.db).
 		Model(&entities.Session{}).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND revoked = ?", userID, false).
 		Update("revoked", true).Error
+	return databases.TranslateError(err)
 }
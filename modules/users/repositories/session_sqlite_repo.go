@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+// sessionSQLiteRepository stores sessions in SQLite.
+type sessionSQLiteRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionSQLiteRepository(db *gorm.DB) SessionRepository {
+	return &sessionSQLiteRepository{db: db}
+}
+
+// Insert
+func (r *sessionSQLiteRepository) Insert(ctx context.Context, session *entities.Session) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(session).Error)
+}
+
+// GetByID
+func (r *sessionSQLiteRepository) GetByID(ctx context.Context, sessionID uuid.UUID) (*entities.Session, error) {
+	var session entities.Session
+	err := databases.Conn(ctx, r.db).First(&session, "id = ?", sessionID).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &session, nil
+}
+
+// MarkRevoked
+func (r *sessionSQLiteRepository) MarkRevoked(ctx context.Context, sessionID uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).
+		Model(&entities.Session{}).
+		Where("id = ? AND revoked = ?", sessionID, false).
+		Update("revoked", true)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	// already revoked by a concurrent request, or missing
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkRevokedByUserID
+func (r *sessionSQLiteRepository) MarkRevokedByUserID(ctx context.Context, userID uuid.UUID) error {
+	err := databases.Conn(ctx, r.db).
+		Model(&entities.Session{}).
+		Where("user_id = ? AND revoked = ?", userID, false).
+		Update("revoked", true).Error
+	return databases.TranslateError(err)
+}
@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+// userMemoryRepository keeps users in a map. It is meant for unit tests,
+// DB_DRIVER=memory uses the SQLite repositories. Its writes are undone when the transaction they were
+// made in rolls back, see databases.OnRollback, but other callers see them
+// before the commit.
+type userMemoryRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]entities.User
+}
+
+func NewUserMemoryRepository() UserRepository {
+	return &userMemoryRepository{
+		users: make(map[uuid.UUID]entities.User),
+	}
+}
+
+// Create a new user
+func (r *userMemoryRepository) CreateUser(ctx context.Context, user *entities.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return fmt.Errorf("user %s: %w", user.ID, databases.ErrDuplicateKey)
+	}
+	if r.emailTaken(user.Email, user.ID) {
+		return fmt.Errorf("email %s: %w", user.Email, databases.ErrDuplicateKey)
+	}
+
+	r.restoreOnRollback(ctx, user.ID)
+	r.users[user.ID] = *user
+	return nil
+}
+
+// Get all users
+func (r *userMemoryRepository) GetAllUsers(ctx context.Context) ([]entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]entities.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Created_at.Before(users[j].Created_at)
+	})
+	return users, nil
+}
+
+// Get user by ID
+func (r *userMemoryRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// Update user by ID
+func (r *userMemoryRepository) UpdateUserByID(ctx context.Context, id uuid.UUID, data *entities.User) (*entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if r.emailTaken(data.Email, id) {
+		return nil, fmt.Errorf("email %s: %w", data.Email, databases.ErrDuplicateKey)
+	}
+
+	user := *data
+	user.ID = id
+	r.restoreOnRollback(ctx, id)
+	r.users[id] = user
+	return &user, nil
+}
+
+// Delete user by ID
+func (r *userMemoryRepository) DeleteUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	r.restoreOnRollback(ctx, id)
+	delete(r.users, id)
+	return &user, nil
+}
+
+// Get by email
+func (r *userMemoryRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Update password hash
+func (r *userMemoryRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	r.restoreOnRollback(ctx, id)
+	user.PasswordHash = passwordHash
+	r.users[id] = user
+	return nil
+}
+
+// Mark email verified
+func (r *userMemoryRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	r.restoreOnRollback(ctx, id)
+	user.EmailVerifiedAt = &at
+	r.users[id] = user
+	return nil
+}
+
+// Set locked at
+func (r *userMemoryRepository) SetLockedAt(ctx context.Context, id uuid.UUID, at *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	r.restoreOnRollback(ctx, id)
+	user.LockedAt = at
+	user.Updated_at = time.Now()
+	r.users[id] = user
+	return nil
+}
+
+// restoreOnRollback puts the user back as it is now if the transaction in
+// ctx rolls back; callers hold mu.
+func (r *userMemoryRepository) restoreOnRollback(ctx context.Context, id uuid.UUID) {
+	prev, existed := r.users[id]
+	databases.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if existed {
+			r.users[id] = prev
+		} else {
+			delete(r.users, id)
+		}
+	})
+}
+
+// emailTaken reports whether another user has the email; callers hold mu.
+func (r *userMemoryRepository) emailTaken(email string, except uuid.UUID) bool {
+	for id, user := range r.users {
+		if id != except && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
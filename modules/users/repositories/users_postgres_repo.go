@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
 	"gorm.io/gorm"
 )
 
@@ -21,10 +23,10 @@ func NewUserPostgresRepository(db *gorm.DB) UserRepository {
 
 // Create a new user
 func (r *userPostgresRepository) CreateUser(ctx context.Context, user *entities.User) error {
-	result := r.db.WithContext(ctx).Create(user)
+	result := databases.Conn(ctx, r.db).Create(user)
 	if result.Error != nil {
 		log.Printf("Error creating user: %v", result.Error)
-		return result.Error
+		return databases.TranslateError(result.Error)
 	}
 	return nil
 }
@@ -33,10 +35,10 @@ func (r *userPostgresRepository) CreateUser(ctx context.Context, user *entities.
 func (r *userPostgresRepository) GetAllUsers(ctx context.Context) ([]entities.User, error) {
 
 	var users []entities.User
-	result := r.db.WithContext(ctx).Find(&users)
+	result := databases.Conn(ctx, r.db).Find(&users)
 	if result.Error != nil {
 		log.Printf("Error getting users: %v", result.Error)
-		return nil, result.Error
+		return nil, databases.TranslateError(result.Error)
 	}
 	return users, nil
 
@@ -45,10 +47,10 @@ func (r *userPostgresRepository) GetAllUsers(ctx context.Context) ([]entities.Us
 // Get user by ID
 func (r *userPostgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
 	var user entities.User
-	result := r.db.WithContext(ctx).First(&user, "id = ?", id)
+	result := databases.Conn(ctx, r.db).First(&user, "id = ?", id)
 
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, databases.TranslateError(result.Error)
 	}
 
 	return &user, nil
@@ -59,22 +61,22 @@ func (r *userPostgresRepository) GetUserByID(ctx context.Context, id uuid.UUID)
 func (r *userPostgresRepository) UpdateUserByID(ctx context.Context, id uuid.UUID, data *entities.User) (*entities.User, error) {
 	var user entities.User
 	// Find user by ID
-	findResult := r.db.WithContext(ctx).First(&user, "id = ?", id)
+	findResult := databases.Conn(ctx, r.db).First(&user, "id = ?", id)
 
 	if findResult.Error != nil {
-		return nil, findResult.Error
+		return nil, databases.TranslateError(findResult.Error)
 	}
 
 	// Check if there are any changes
-	if user.Name == data.Name && user.Email == data.Email && user.Age == data.Age {
+	if user.Name == data.Name && user.Email == data.Email && user.Age == data.Age && user.Locale == data.Locale && user.Role == data.Role {
 		return &user, nil
 	}
 
 	// update user
-	UpdateResult := r.db.WithContext(ctx).Model(&user).Updates(data)
+	UpdateResult := databases.Conn(ctx, r.db).Model(&user).Updates(data)
 	if UpdateResult.Error != nil {
 		log.Printf("Error updating user: %v", UpdateResult.Error)
-		return nil, UpdateResult.Error
+		return nil, databases.TranslateError(UpdateResult.Error)
 	}
 
 	return &user, nil
@@ -84,17 +86,17 @@ func (r *userPostgresRepository) UpdateUserByID(ctx context.Context, id uuid.UUI
 // Delete user by ID
 func (r *userPostgresRepository) DeleteUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
 	var user entities.User
-	findResult := r.db.WithContext(ctx).First(&user, "id = ?", id)
+	findResult := databases.Conn(ctx, r.db).First(&user, "id = ?", id)
 
 	if findResult.Error != nil {
-		return nil, findResult.Error
+		return nil, databases.TranslateError(findResult.Error)
 	}
 
 	// delete
-	DeleteResult := r.db.WithContext(ctx).Delete(&user)
+	DeleteResult := databases.Conn(ctx, r.db).Delete(&user)
 	if DeleteResult.Error != nil {
 		log.Printf("Error deleting user: %v", DeleteResult.Error)
-		return nil, DeleteResult.Error
+		return nil, databases.TranslateError(DeleteResult.Error)
 	}
 
 	return &user, nil
@@ -104,12 +106,51 @@ func (r *userPostgresRepository) DeleteUserByID(ctx context.Context, id uuid.UUI
 // Get by email
 func (r *userPostgresRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
 	var user entities.User
-	result := r.db.WithContext(ctx).First(&user, "email = ?", email)
+	result := databases.Conn(ctx, r.db).First(&user, "lower(email) = lower(?)", email)
 
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, databases.TranslateError(result.Error)
 	}
 
 	return &user, nil
 
 }
+
+// Update password hash, without touching updated_at or emitting an event
+func (r *userPostgresRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	result := databases.Conn(ctx, r.db).Model(&entities.User{}).Where("id = ?", id).UpdateColumn("password_hash", passwordHash)
+	if result.Error != nil {
+		log.Printf("Error updating password hash: %v", result.Error)
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Mark email verified, without touching updated_at or emitting an event
+func (r *userPostgresRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := databases.Conn(ctx, r.db).Model(&entities.User{}).Where("id = ?", id).UpdateColumn("email_verified_at", at)
+	if result.Error != nil {
+		log.Printf("Error marking email verified: %v", result.Error)
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Set locked at, nil unlocks
+func (r *userPostgresRepository) SetLockedAt(ctx context.Context, id uuid.UUID, at *time.Time) error {
+	result := databases.Conn(ctx, r.db).Model(&entities.User{}).Where("id = ?", id).Updates(map[string]any{"locked_at": at, "updated_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Error setting user lock: %v", result.Error)
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
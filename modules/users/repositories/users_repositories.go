@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/natchaphonbw/usermanagement/modules/users/entities"
@@ -14,4 +15,7 @@ type UserRepository interface {
 	UpdateUserByID(ctx context.Context, id uuid.UUID, user *entities.User) (*entities.User, error)
 	DeleteUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*entities.User, error)
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error
+	SetLockedAt(ctx context.Context, id uuid.UUID, at *time.Time) error
 }
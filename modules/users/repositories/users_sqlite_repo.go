@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// userSQLiteRepository stores users in SQLite. SQLite has a single writer,
+// so updates and deletes are one statement each, returning the row,
+// rather than a read followed by a write.
+type userSQLiteRepository struct {
+	db *gorm.DB
+}
+
+func NewUserSQLiteRepository(db *gorm.DB) UserRepository {
+	return &userSQLiteRepository{
+		db: db,
+	}
+}
+
+// Create a new user
+func (r *userSQLiteRepository) CreateUser(ctx context.Context, user *entities.User) error {
+	result := databases.Conn(ctx, r.db).Create(user)
+	if result.Error != nil {
+		log.Printf("Error creating user: %v", result.Error)
+		return databases.TranslateError(result.Error)
+	}
+	return nil
+}
+
+// Get all users
+func (r *userSQLiteRepository) GetAllUsers(ctx context.Context) ([]entities.User, error) {
+	var users []entities.User
+	result := databases.Conn(ctx, r.db).Order("created_at").Find(&users)
+	if result.Error != nil {
+		log.Printf("Error getting users: %v", result.Error)
+		return nil, databases.TranslateError(result.Error)
+	}
+	return users, nil
+}
+
+// Get user by ID
+func (r *userSQLiteRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	var user entities.User
+	result := databases.Conn(ctx, r.db).First(&user, "id = ?", id)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	return &user, nil
+}
+
+// Update user by ID
+func (r *userSQLiteRepository) UpdateUserByID(ctx context.Context, id uuid.UUID, data *entities.User) (*entities.User, error) {
+	var user entities.User
+	result := databases.Conn(ctx, r.db).Model(&user).Clauses(clause.Returning{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"name":       data.Name,
+			"email":      data.Email,
+			"age":        data.Age,
+			"locale":     data.Locale,
+			"role":       data.Role,
+			"updated_at": data.Updated_at,
+		})
+	if result.Error != nil {
+		log.Printf("Error updating user: %v", result.Error)
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// Delete user by ID
+func (r *userSQLiteRepository) DeleteUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	var user entities.User
+	result := databases.Conn(ctx, r.db).Clauses(clause.Returning{}).Where("id = ?", id).Delete(&user)
+	if result.Error != nil {
+		log.Printf("Error deleting user: %v", result.Error)
+		return nil, databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// Get by email
+func (r *userSQLiteRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	var user entities.User
+	result := databases.Conn(ctx, r.db).First(&user, "email = ? COLLATE NOCASE", email)
+	if result.Error != nil {
+		return nil, databases.TranslateError(result.Error)
+	}
+	return &user, nil
+}
+
+// Update password hash, without touching updated_at
+func (r *userSQLiteRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	return r.updateColumns(ctx, id, map[string]any{"password_hash": passwordHash})
+}
+
+// Mark email verified, without touching updated_at
+func (r *userSQLiteRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.updateColumns(ctx, id, map[string]any{"email_verified_at": at})
+}
+
+// Set locked at, nil unlocks
+func (r *userSQLiteRepository) SetLockedAt(ctx context.Context, id uuid.UUID, at *time.Time) error {
+	return r.updateColumns(ctx, id, map[string]any{"locked_at": at, "updated_at": time.Now()})
+}
+
+// updateColumns sets columns of one user, gorm.ErrRecordNotFound if there
+// is no such user.
+func (r *userSQLiteRepository) updateColumns(ctx context.Context, id uuid.UUID, columns map[string]any) error {
+	result := databases.Conn(ctx, r.db).Model(&entities.User{}).Where("id = ?", id).UpdateColumns(columns)
+	if result.Error != nil {
+		log.Printf("Error updating user: %v", result.Error)
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
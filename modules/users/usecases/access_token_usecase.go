@@ -0,0 +1,20 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type AccessTokenUsecase interface {
+	CreateToken(ctx context.Context, userID uuid.UUID, input dtos.CreateAccessTokenRequest) (*dtos.CreatedAccessTokenResponse, *app_errors.AppError)
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]*dtos.AccessTokenResponse, *app_errors.AppError)
+	RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) *app_errors.AppError
+
+	// Authenticate implements middlewares.AccessTokenVerifier, the error
+	// is an *app_errors.AppError.
+	Authenticate(ctx context.Context, token, ip string) (uuid.UUID, []string, error)
+}
@@ -0,0 +1,140 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+const (
+	accessTokenDefaultTTL = 90 * 24 * time.Hour
+
+	// accessTokenPrefixLength is how much of the token is kept in clear,
+	// "pat_" and 8 random characters
+	accessTokenPrefixLength = len(entities.AccessTokenPrefix) + 8
+
+	// accessTokenUseInterval limits how often last use is written, so a
+	// busy script doesn't cost a write per request
+	accessTokenUseInterval = time.Minute
+)
+
+type accessTokenUsecaseImpl struct {
+	repo     repositories.AccessTokenRepository
+	userRepo repositories.UserRepository
+}
+
+func NewAccessTokenUsecase(repo repositories.AccessTokenRepository, userRepo repositories.UserRepository) AccessTokenUsecase {
+	return &accessTokenUsecaseImpl{repo: repo, userRepo: userRepo}
+}
+
+// Create token
+func (u *accessTokenUsecaseImpl) CreateToken(ctx context.Context, userID uuid.UUID, input dtos.CreateAccessTokenRequest) (*dtos.CreatedAccessTokenResponse, *app_errors.AppError) {
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to generate token", err)
+	}
+	plaintext := entities.AccessTokenPrefix + secret
+
+	ttl := accessTokenDefaultTTL
+	if input.ExpiresInDays > 0 {
+		ttl = time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	}
+
+	now := time.Now()
+	token := &entities.AccessToken{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Name:       input.Name,
+		Prefix:     plaintext[:accessTokenPrefixLength],
+		TokenHash:  utils.HashToken(plaintext),
+		Scopes:     strings.Join(uniqueScopes(input.Scopes), " "),
+		ExpiresAt:  now.Add(ttl),
+		Created_at: now,
+	}
+	if err := u.repo.Insert(ctx, token); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to save access token")
+	}
+
+	return &dtos.CreatedAccessTokenResponse{
+		AccessTokenResponse: *dtos.FromAccessTokenEntity(token),
+		Token:               plaintext,
+	}, nil
+}
+
+// List tokens
+func (u *accessTokenUsecaseImpl) ListTokens(ctx context.Context, userID uuid.UUID) ([]*dtos.AccessTokenResponse, *app_errors.AppError) {
+	tokens, err := u.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get access tokens")
+	}
+	return dtos.FromAccessTokenEntities(tokens), nil
+}
+
+// Revoke token
+func (u *accessTokenUsecaseImpl) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) *app_errors.AppError {
+	if err := u.repo.Revoke(ctx, tokenID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Access token not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to revoke access token")
+	}
+	return nil
+}
+
+// Authenticate
+func (u *accessTokenUsecaseImpl) Authenticate(ctx context.Context, plaintext, ip string) (uuid.UUID, []string, error) {
+	token, err := u.repo.GetByTokenHash(ctx, utils.HashToken(plaintext))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, nil, app_errors.Unautherized("Invalid token", err)
+		}
+		return uuid.Nil, nil, app_errors.FromDB(err, "Failed to get access token")
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil || now.After(token.ExpiresAt) {
+		return uuid.Nil, nil, app_errors.Unautherized("Invalid token", nil)
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, nil, app_errors.Unautherized("Invalid token", err)
+		}
+		return uuid.Nil, nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	if appErr := checkNotLocked(user); appErr != nil {
+		return uuid.Nil, nil, appErr
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= accessTokenUseInterval || token.LastUsedIP != ip {
+		if err := u.repo.MarkUsed(ctx, token.ID, now, ip); err != nil {
+			log.Printf("Failed to record use of access token %s: %v", token.ID, err)
+		}
+	}
+
+	return token.UserID, strings.Fields(token.Scopes), nil
+}
+
+// uniqueScopes drops repeated scopes, keeping the order.
+func uniqueScopes(scopes []string) []string {
+	var unique []string
+	for _, scope := range scopes {
+		if !slices.Contains(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
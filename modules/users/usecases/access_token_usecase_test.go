@@ -0,0 +1,96 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+func TestAccessTokens(t *testing.T) {
+	repo := newFakeAccessTokenRepo()
+	users := newFakeUserRepo()
+	uc := usecases.NewAccessTokenUsecase(repo, users)
+	ctx := context.Background()
+	userID := uuid.New()
+	if err := users.CreateUser(ctx, &entities.User{ID: userID, Email: "bob@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	created, appErr := uc.CreateToken(ctx, userID, dtos.CreateAccessTokenRequest{
+		Name:   "ci",
+		Scopes: []string{entities.ScopeProfileRead, entities.ScopeProfileRead},
+	})
+	if appErr != nil {
+		t.Fatalf("CreateToken: %v", appErr)
+	}
+	if !strings.HasPrefix(created.Token, entities.AccessTokenPrefix) || !strings.HasPrefix(created.Token, created.Prefix) || len(created.Prefix) >= len(created.Token) {
+		t.Errorf("token %q with prefix %q", created.Token, created.Prefix)
+	}
+	if len(created.Scopes) != 1 || created.Scopes[0] != entities.ScopeProfileRead {
+		t.Errorf("scopes = %v, want [%s]", created.Scopes, entities.ScopeProfileRead)
+	}
+	for _, stored := range repo.tokens {
+		if strings.Contains(stored.TokenHash, created.Token) {
+			t.Error("plaintext token stored")
+		}
+	}
+
+	gotUser, scopes, err := uc.Authenticate(ctx, created.Token, "10.0.0.1")
+	if err != nil || gotUser != userID || len(scopes) != 1 {
+		t.Fatalf("Authenticate = %s, %v, %v", gotUser, scopes, err)
+	}
+
+	// use is recorded at most once a minute per IP
+	uc.Authenticate(ctx, created.Token, "10.0.0.1")
+	uc.Authenticate(ctx, created.Token, "10.0.0.2")
+	if repo.markCalls != 2 {
+		t.Errorf("MarkUsed called %d times, want 2", repo.markCalls)
+	}
+
+	tokens, appErr := uc.ListTokens(ctx, userID)
+	if appErr != nil || len(tokens) != 1 || tokens[0].LastUsedIP != "10.0.0.2" {
+		t.Errorf("ListTokens = %+v, %v", tokens, appErr)
+	}
+
+	if _, _, err := uc.Authenticate(ctx, created.Token+"x", "10.0.0.1"); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("Authenticate(unknown) = %v, want 401", err)
+	}
+
+	now := time.Now()
+	users.SetLockedAt(ctx, userID, &now)
+	if _, _, err := uc.Authenticate(ctx, created.Token, "10.0.0.1"); !isStatus(err, http.StatusForbidden) {
+		t.Errorf("Authenticate(locked user) = %v, want 403", err)
+	}
+	users.SetLockedAt(ctx, userID, nil)
+
+	if appErr := uc.RevokeToken(ctx, uuid.New(), created.ID); appErr == nil || appErr.Code != http.StatusNotFound {
+		t.Errorf("RevokeToken by another user = %v, want 404", appErr)
+	}
+	if appErr := uc.RevokeToken(ctx, userID, created.ID); appErr != nil {
+		t.Fatalf("RevokeToken: %v", appErr)
+	}
+	if _, _, err := uc.Authenticate(ctx, created.Token, "10.0.0.1"); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("Authenticate(revoked) = %v, want 401", err)
+	}
+
+	expiring, _ := uc.CreateToken(ctx, userID, dtos.CreateAccessTokenRequest{Name: "old", Scopes: []string{entities.ScopeTokensRead}, ExpiresInDays: 1})
+	repo.expireAll()
+	if _, _, err := uc.Authenticate(ctx, expiring.Token, "10.0.0.1"); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("Authenticate(expired) = %v, want 401", err)
+	}
+}
+
+func isStatus(err error, code int) bool {
+	var appErr *app_errors.AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
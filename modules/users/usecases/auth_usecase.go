@@ -7,12 +7,39 @@ import (
 
 	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
 	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey"
 )
 
+// PasskeyRelyingParty runs WebAuthn ceremonies, see passkey.RelyingParty.
+type PasskeyRelyingParty interface {
+	BeginRegistration(user passkey.User) (*passkey.Ceremony, error)
+	FinishRegistration(user passkey.User, session, response []byte) (*passkey.Credential, error)
+	BeginLogin(user *passkey.User) (*passkey.Ceremony, error)
+	FinishLogin(session, response []byte, lookup func(handle []byte) (*passkey.User, error)) (*passkey.User, *passkey.Credential, error)
+}
+
 type AuthUsecase interface {
 	RegisterUser(ctx context.Context, input dtos.RegisterRequest) (*dtos.UserResponse, *app_errors.AppError)
 	Login(ctx context.Context, input dtos.LoginRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
 	GetProfile(ctx context.Context, userID uuid.UUID) (*dtos.UserResponse, *app_errors.AppError)
 	Logout(ctx context.Context, sessionID uuid.UUID, deviceID, deviceUA string) *app_errors.AppError
 	LogoutAll(ctx context.Context, userID uuid.UUID) *app_errors.AppError
+	// DeleteAccount deletes the user, ending all their sessions.
+	DeleteAccount(ctx context.Context, userID uuid.UUID) *app_errors.AppError
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, input dtos.EmailChangeRequest) *app_errors.AppError
+	ConfirmEmailChange(ctx context.Context, input dtos.ConfirmEmailChangeRequest) *app_errors.AppError
+	// Reauthenticate checks the user's password, or passkey if they have
+	// any, and replaces the session with one authenticated just now.
+	Reauthenticate(ctx context.Context, userID, sessionID uuid.UUID, input dtos.ReauthenticateRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
+
+	BeginPasskeyRegistration(ctx context.Context, userID uuid.UUID) (*dtos.PasskeyCeremonyResponse, *app_errors.AppError)
+	FinishPasskeyRegistration(ctx context.Context, userID uuid.UUID, input dtos.FinishPasskeyRegistrationRequest) (*dtos.PasskeyResponse, *app_errors.AppError)
+	// BeginPasskeyLogin starts a login with any of the user's discoverable
+	// passkeys. Every other login starts one with the user's passkeys when
+	// they have any, as a second factor.
+	BeginPasskeyLogin(ctx context.Context) (*dtos.PasskeyCeremonyResponse, *app_errors.AppError)
+	FinishPasskeyLogin(ctx context.Context, input dtos.FinishPasskeyLoginRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
+	ListPasskeys(ctx context.Context, userID uuid.UUID) ([]*dtos.PasskeyResponse, *app_errors.AppError)
+	RenamePasskey(ctx context.Context, userID, passkeyID uuid.UUID, input dtos.RenamePasskeyRequest) (*dtos.PasskeyResponse, *app_errors.AppError)
+	DeletePasskey(ctx context.Context, userID, passkeyID uuid.UUID) *app_errors.AppError
 }
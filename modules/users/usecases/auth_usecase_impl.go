@@ -1,50 +1,80 @@
 package usecases
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/natchaphonbw/usermanagement/pkg/utils"
 	"gorm.io/gorm"
 
 	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
 	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
-	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
 	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey"
+)
+
+const (
+	emailChangeTTL = 24 * time.Hour
+	// passkeyCeremonyTTL is how long the browser has to answer a ceremony.
+	passkeyCeremonyTTL = 5 * time.Minute
 )
 
 type AuthUsecaseImpl struct {
-	userUsecase    UserUsecase
-	sessionUsecase SessionUsecase
+	secondFactor
+	userUsecase UserUsecase
+
+	userRepo        repositories.UserRepository
+	sessionRepo     repositories.SessionRepository
+	emailChangeRepo repositories.EmailChangeRepository
+	eventRepo       repositories.EventRepository
+
+	txManager databases.TxManager
+	mailer    mailer.Mailer
 
-	userRepo    repositories.UserRepository
-	sessionRepo repositories.SessionRepository
+	verifiers []CredentialVerifier
 }
 
-func NewAuthUseCase(userUsecase UserUsecase, sessionUsecase SessionUsecase, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository) AuthUsecase {
+func NewAuthUseCase(userUsecase UserUsecase, sessionUsecase SessionUsecase, userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository, emailChangeRepo repositories.EmailChangeRepository, passkeyRepo repositories.PasskeyRepository, eventRepo repositories.EventRepository, txManager databases.TxManager, m mailer.Mailer, relyingParty PasskeyRelyingParty, verifiers ...CredentialVerifier) AuthUsecase {
 	return &AuthUsecaseImpl{
-		userUsecase:    userUsecase,
-		sessionUsecase: sessionUsecase,
+		secondFactor: secondFactor{
+			passkeyRepo:    passkeyRepo,
+			sessionUsecase: sessionUsecase,
+			relyingParty:   relyingParty,
+		},
+		userUsecase: userUsecase,
 
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		emailChangeRepo: emailChangeRepo,
+		eventRepo:       eventRepo,
+
+		txManager: txManager,
+		mailer:    m,
+
+		// stored passwords first, then e.g. the directory
+		verifiers: append([]CredentialVerifier{NewPasswordVerifier(userRepo)}, verifiers...),
 	}
 }
 
 // register
 func (a *AuthUsecaseImpl) RegisterUser(ctx context.Context, input dtos.RegisterRequest) (*dtos.UserResponse, *app_errors.AppError) {
-	// validate pwd
-	if err := validator.ValidatePassword(input.Password); err != nil {
-		return nil, app_errors.BadRequest("Invalid password", err)
-	}
-
+	// the password policy is applied by CreateUser
 	createReq := dtos.CreateUserRequest{
 		Name:     input.Name,
 		Email:    input.Email,
 		Password: input.Password,
 		Age:      input.Age,
+		Locale:   input.Locale,
 	}
 
 	userResp, err := a.userUsecase.CreateUser(ctx, createReq)
@@ -57,24 +87,49 @@ func (a *AuthUsecaseImpl) RegisterUser(ctx context.Context, input dtos.RegisterR
 
 // login
 func (a *AuthUsecaseImpl) Login(ctx context.Context, req dtos.LoginRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
-	user, err := a.userRepo.GetUserByEmail(ctx, req.Email)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, app_errors.Unautherized("Invalid credentials", err)
-		}
-		return nil, app_errors.InternalServer("Failed to get user", err)
+	user, appErr := a.verifyPassword(ctx, req.Email, req.Password)
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	// verify pwd
-	match, err := utils.VerifyPassword(req.Password, user.PasswordHash, user.Salt, &utils.DefaultArgon2Config)
-	if err != nil || !match {
-		return nil, app_errors.Unautherized("Invalid credentials", fmt.Errorf("password mismatch"))
+	return a.completeLogin(ctx, user, []string{jwt.AMRPassword}, deviceIP, deviceUA, deviceID)
+}
+
+// Reauthenticate
+func (a *AuthUsecaseImpl) Reauthenticate(ctx context.Context, userID, sessionID uuid.UUID, input dtos.ReauthenticateRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	user, passkeys, appErr := a.getPasskeyUser(ctx, userID)
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	// gen jwt token
-	tokenPair, pairErr := a.sessionUsecase.IssueTokenPair(ctx, user.ID, deviceIP, deviceUA, deviceID)
+	var amr []string
+	switch {
+	case input.CeremonyID != "":
+		verified, _, appErr := a.verifyPasskey(ctx, dtos.FinishPasskeyLoginRequest{CeremonyID: input.CeremonyID, Credential: input.Credential})
+		if appErr != nil {
+			return nil, appErr
+		}
+		if verified.ID != user.ID {
+			return nil, app_errors.Unautherized("Invalid passkey", nil)
+		}
+		amr = []string{jwt.AMRHardwareKey}
+	case len(passkeys) > 0:
+		// the password alone isn't enough to log in either
+		return nil, app_errors.BadRequest("Reauthenticate with a passkey", nil)
+	default:
+		verified, appErr := a.verifyPassword(ctx, user.Email, input.Password)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if verified.ID != user.ID {
+			return nil, app_errors.Unautherized("Invalid credentials", nil)
+		}
+		amr = []string{jwt.AMRPassword}
+	}
+
+	tokenPair, pairErr := a.sessionUsecase.Reauthenticate(ctx, sessionID, amr, deviceIP, deviceUA, deviceID)
 	if pairErr != nil {
-		return nil, app_errors.InternalServer("Failed to issue token pair", pairErr).WithDetails(pairErr.Details)
+		return nil, pairErr
 	}
 	return &dtos.LoginResponse{
 		AccessToken:  tokenPair.AccessToken,
@@ -91,7 +146,7 @@ func (a *AuthUsecaseImpl) Logout(ctx context.Context, sessionID uuid.UUID, devic
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return app_errors.NotFound("Session not found", err)
 		}
-		return app_errors.InternalServer("Failed to get session", err)
+		return app_errors.FromDB(err, "Failed to get session")
 	}
 
 	// check revoked
@@ -105,28 +160,53 @@ func (a *AuthUsecaseImpl) Logout(ctx context.Context, sessionID uuid.UUID, devic
 	}
 
 	// revoke
-	if revokeErr := a.sessionRepo.MarkRevoked(ctx, sessionID); revokeErr != nil {
-		if errors.Is(revokeErr, gorm.ErrRecordNotFound) {
-			return app_errors.NotFound("session not found", revokeErr)
+	return inTransaction(ctx, a.txManager, func(ctx context.Context) *app_errors.AppError {
+		if revokeErr := a.sessionRepo.MarkRevoked(ctx, sessionID); revokeErr != nil {
+			if errors.Is(revokeErr, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("session not found", revokeErr)
+			}
+			return app_errors.FromDB(revokeErr, "Failed to revoke sessions")
 		}
-		return app_errors.InternalServer("Failed to revoke sessions", revokeErr)
-	}
 
-	return nil
+		payload := map[string]any{"session_id": sessionID, "user_id": session.UserID}
+		return raiseEvent(ctx, a.eventRepo, entities.AggregateSession, sessionID, entities.EventSessionRevoked, payload)
+	})
 }
 
 // Log out all devices
 func (a *AuthUsecaseImpl) LogoutAll(ctx context.Context, userID uuid.UUID) *app_errors.AppError {
 
 	// revoke
-	if revokeErr := a.sessionRepo.MarkRevokedByUserID(ctx, userID); revokeErr != nil {
-		if errors.Is(revokeErr, gorm.ErrRecordNotFound) {
-			return app_errors.NotFound("session not found", revokeErr)
+	return inTransaction(ctx, a.txManager, func(ctx context.Context) *app_errors.AppError {
+		if revokeErr := a.sessionRepo.MarkRevokedByUserID(ctx, userID); revokeErr != nil {
+			if errors.Is(revokeErr, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("session not found", revokeErr)
+			}
+			return app_errors.FromDB(revokeErr, "Failed to revoke sessions for user")
 		}
-		return app_errors.InternalServer("Failed to revoke sessions for user", revokeErr)
-	}
+		return raiseSessionsRevoked(ctx, a.eventRepo, userID)
+	})
+}
 
-	return nil
+// Delete account
+func (a *AuthUsecaseImpl) DeleteAccount(ctx context.Context, userID uuid.UUID) *app_errors.AppError {
+	return inTransaction(ctx, a.txManager, func(ctx context.Context) *app_errors.AppError {
+		if err := a.sessionRepo.MarkRevokedByUserID(ctx, userID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.FromDB(err, "Failed to revoke sessions for user")
+		}
+		if appErr := raiseSessionsRevoked(ctx, a.eventRepo, userID); appErr != nil {
+			return appErr
+		}
+
+		user, err := a.userRepo.DeleteUserByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("User not found", err)
+			}
+			return app_errors.FromDB(err, "Failed to delete user")
+		}
+		return raiseUserEvent(ctx, a.eventRepo, entities.EventUserDeleted, user)
+	})
 }
 
 // Get Profile
@@ -136,8 +216,440 @@ func (a *AuthUsecaseImpl) GetProfile(ctx context.Context, userID uuid.UUID) (*dt
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, app_errors.NotFound("User not found", err)
 		}
-		return nil, app_errors.InternalServer("Failed to get user", err)
+		return nil, app_errors.FromDB(err, "Failed to get user")
 	}
 
 	return dtos.FromUserEntity(user), nil
 }
+
+// Request email change
+func (a *AuthUsecaseImpl) RequestEmailChange(ctx context.Context, userID uuid.UUID, input dtos.EmailChangeRequest) *app_errors.AppError {
+	user, err := a.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("User not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to get user")
+	}
+
+	newEmail := utils.NormalizeEmail(input.NewEmail)
+	if newEmail == user.Email {
+		return app_errors.BadRequest("New email is the same as the current email", nil)
+	}
+
+	if appErr := a.ensureEmailAvailable(ctx, newEmail, userID); appErr != nil {
+		return appErr
+	}
+
+	// only the latest request can be confirmed
+	if err := a.emailChangeRepo.DeletePendingByUserID(ctx, userID); err != nil {
+		return app_errors.FromDB(err, "Failed to clear pending email changes")
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return app_errors.InternalServer("Failed to generate confirmation token", err)
+	}
+
+	change := &entities.EmailChange{
+		ID:         uuid.New(),
+		UserID:     userID,
+		NewEmail:   newEmail,
+		TokenHash:  utils.HashToken(token),
+		ExpiresAt:  time.Now().Add(emailChangeTTL),
+		Created_at: time.Now(),
+	}
+	if err := a.emailChangeRepo.Insert(ctx, change); err != nil {
+		return app_errors.FromDB(err, "Failed to save email change")
+	}
+
+	// confirmation to the new address
+	confirmData := map[string]any{
+		"Name":      user.Name,
+		"Token":     token,
+		"ExpiresAt": change.ExpiresAt.Format(time.RFC1123),
+	}
+	if err := a.sendMail(ctx, user.Locale, "email_change_confirm", newEmail, confirmData); err != nil {
+		return app_errors.InternalServer("Failed to send confirmation email", err)
+	}
+
+	// notice to the old address
+	noticeData := map[string]any{"Name": user.Name, "NewEmail": newEmail}
+	if err := a.sendMail(ctx, user.Locale, "email_change_requested", user.Email, noticeData); err != nil {
+		log.Printf("Failed to send email change notice to %s: %v", user.Email, err)
+	}
+
+	return nil
+}
+
+// Confirm email change
+func (a *AuthUsecaseImpl) ConfirmEmailChange(ctx context.Context, input dtos.ConfirmEmailChangeRequest) *app_errors.AppError {
+	change, err := a.emailChangeRepo.GetByTokenHash(ctx, utils.HashToken(input.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.BadRequest("Invalid or expired token", err)
+		}
+		return app_errors.FromDB(err, "Failed to get email change")
+	}
+
+	if change.ConfirmedAt != nil || time.Now().After(change.ExpiresAt) {
+		return app_errors.BadRequest("Invalid or expired token", nil)
+	}
+
+	user, err := a.userRepo.GetUserByID(ctx, change.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("User not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to get user")
+	}
+
+	if appErr := a.ensureEmailAvailable(ctx, change.NewEmail, user.ID); appErr != nil {
+		return appErr
+	}
+
+	oldEmail := user.Email
+	user.Email = change.NewEmail
+	user.Updated_at = time.Now()
+
+	txErr := inTransaction(ctx, a.txManager, func(ctx context.Context) *app_errors.AppError {
+		// claims the token first, so it can only be used once
+		if err := a.emailChangeRepo.MarkConfirmed(ctx, change.ID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.BadRequest("Invalid or expired token", err)
+			}
+			return app_errors.FromDB(err, "Failed to confirm email change")
+		}
+
+		if _, err := a.userRepo.UpdateUserByID(ctx, user.ID, user); err != nil {
+			if errors.Is(err, databases.ErrDuplicateKey) {
+				return app_errors.Conflict("Email already in use", err)
+			}
+			return app_errors.FromDB(err, "Failed to update email")
+		}
+		if appErr := raiseUserEvent(ctx, a.eventRepo, entities.EventUserUpdated, user); appErr != nil {
+			return appErr
+		}
+
+		if err := a.userRepo.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+			return app_errors.FromDB(err, "Failed to verify email")
+		}
+
+		// sessions issued before the swap must log in again
+		if err := a.sessionRepo.MarkRevokedByUserID(ctx, user.ID); err != nil {
+			return app_errors.FromDB(err, "Failed to revoke sessions for user")
+		}
+		return raiseSessionsRevoked(ctx, a.eventRepo, user.ID)
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	noticeData := map[string]any{"Name": user.Name, "NewEmail": change.NewEmail}
+	if err := a.sendMail(ctx, user.Locale, "email_changed", oldEmail, noticeData); err != nil {
+		log.Printf("Failed to send email changed notice to %s: %v", oldEmail, err)
+	}
+
+	return nil
+}
+
+// Begin passkey registration
+func (a *AuthUsecaseImpl) BeginPasskeyRegistration(ctx context.Context, userID uuid.UUID) (*dtos.PasskeyCeremonyResponse, *app_errors.AppError) {
+	user, passkeys, appErr := a.getPasskeyUser(ctx, userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	passkeyUser := toPasskeyUser(user, passkeys)
+	return a.beginPasskeyCeremony(ctx, entities.PasskeyCeremonyRegister, &user.ID, nil, func() (*passkey.Ceremony, error) {
+		return a.relyingParty.BeginRegistration(passkeyUser)
+	})
+}
+
+// Finish passkey registration
+func (a *AuthUsecaseImpl) FinishPasskeyRegistration(ctx context.Context, userID uuid.UUID, input dtos.FinishPasskeyRegistrationRequest) (*dtos.PasskeyResponse, *app_errors.AppError) {
+	ceremony, appErr := a.consumePasskeyCeremony(ctx, input.CeremonyID, entities.PasskeyCeremonyRegister)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if ceremony.UserID == nil || *ceremony.UserID != userID {
+		return nil, app_errors.BadRequest("Invalid or expired ceremony", nil)
+	}
+
+	user, passkeys, appErr := a.getPasskeyUser(ctx, userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	cred, err := a.relyingParty.FinishRegistration(toPasskeyUser(user, passkeys), ceremony.Session, input.Credential)
+	if err != nil {
+		if errors.Is(err, passkey.ErrInvalidResponse) {
+			return nil, app_errors.BadRequest("Invalid passkey", err)
+		}
+		return nil, app_errors.InternalServer("Failed to register passkey", err)
+	}
+
+	nickname := strings.TrimSpace(input.Nickname)
+	if nickname == "" {
+		nickname = "Passkey"
+	}
+	record := &entities.Passkey{
+		ID:              uuid.New(),
+		UserID:          userID,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		AAGUID:          cred.AAGUID,
+		SignCount:       cred.SignCount,
+		Transports:      strings.Join(cred.Transports, ","),
+		BackupEligible:  cred.BackupEligible,
+		BackupState:     cred.BackupState,
+		Nickname:        nickname,
+		Created_at:      time.Now(),
+	}
+	if err := a.passkeyRepo.Insert(ctx, record); err != nil {
+		if errors.Is(err, databases.ErrDuplicateKey) {
+			return nil, app_errors.Conflict("Passkey already registered", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to save passkey")
+	}
+
+	return dtos.FromPasskeyEntity(record), nil
+}
+
+// Begin passkey login
+func (a *AuthUsecaseImpl) BeginPasskeyLogin(ctx context.Context) (*dtos.PasskeyCeremonyResponse, *app_errors.AppError) {
+	return a.beginPasskeyCeremony(ctx, entities.PasskeyCeremonyLogin, nil, nil, func() (*passkey.Ceremony, error) {
+		return a.relyingParty.BeginLogin(nil)
+	})
+}
+
+// Finish passkey login
+func (a *AuthUsecaseImpl) FinishPasskeyLogin(ctx context.Context, input dtos.FinishPasskeyLoginRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	user, ceremony, appErr := a.verifyPasskey(ctx, input)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if appErr := checkNotLocked(user); appErr != nil {
+		return nil, appErr
+	}
+
+	// ceremonies for a user are the second factor of another login
+	amr := []string{jwt.AMRHardwareKey}
+	if ceremony.UserID != nil {
+		amr = append(strings.Fields(ceremony.AMR), jwt.AMRHardwareKey, jwt.AMRMultiFactor)
+	}
+
+	tokenPair, pairErr := a.sessionUsecase.IssueTokenPair(ctx, user.ID, amr, deviceIP, deviceUA, deviceID)
+	if pairErr != nil {
+		return nil, app_errors.InternalServer("Failed to issue token pair", pairErr).WithDetails(pairErr.Details)
+	}
+	return &dtos.LoginResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+	}, nil
+}
+
+// List passkeys
+func (a *AuthUsecaseImpl) ListPasskeys(ctx context.Context, userID uuid.UUID) ([]*dtos.PasskeyResponse, *app_errors.AppError) {
+	passkeys, err := a.passkeyRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get passkeys")
+	}
+	return dtos.FromPasskeyEntities(passkeys), nil
+}
+
+// Rename passkey
+func (a *AuthUsecaseImpl) RenamePasskey(ctx context.Context, userID, passkeyID uuid.UUID, input dtos.RenamePasskeyRequest) (*dtos.PasskeyResponse, *app_errors.AppError) {
+	nickname := strings.TrimSpace(input.Nickname)
+	if nickname == "" {
+		return nil, app_errors.BadRequest("Nickname is required", nil)
+	}
+
+	renamed, err := a.passkeyRepo.Rename(ctx, passkeyID, userID, nickname)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Passkey not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to rename passkey")
+	}
+	return dtos.FromPasskeyEntity(renamed), nil
+}
+
+// Delete passkey
+func (a *AuthUsecaseImpl) DeletePasskey(ctx context.Context, userID, passkeyID uuid.UUID) *app_errors.AppError {
+	if err := a.passkeyRepo.Delete(ctx, passkeyID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Passkey not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to delete passkey")
+	}
+	return nil
+}
+
+// verifyPassword checks the password with each verifier in turn, until
+// one knows the user.
+func (a *AuthUsecaseImpl) verifyPassword(ctx context.Context, email, password string) (*entities.User, *app_errors.AppError) {
+	email = utils.NormalizeEmail(email)
+
+	var user *entities.User
+	var appErr *app_errors.AppError
+	for _, verifier := range a.verifiers {
+		user, appErr = verifier.Verify(ctx, email, password)
+		if appErr == nil || appErr.Code != http.StatusUnauthorized {
+			break
+		}
+	}
+	if appErr != nil {
+		return nil, appErr
+	}
+	return user, nil
+}
+
+// verifyPasskey finishes a passkey login ceremony and records the use of
+// the passkey.
+func (a *AuthUsecaseImpl) verifyPasskey(ctx context.Context, input dtos.FinishPasskeyLoginRequest) (*entities.User, *entities.PasskeyCeremony, *app_errors.AppError) {
+	ceremony, appErr := a.consumePasskeyCeremony(ctx, input.CeremonyID, entities.PasskeyCeremonyLogin)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	var (
+		user     *entities.User
+		passkeys []entities.Passkey
+	)
+	lookup := func(handle []byte) (*passkey.User, error) {
+		userID, err := uuid.FromBytes(handle)
+		if err != nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		var appErr *app_errors.AppError
+		if user, passkeys, appErr = a.getPasskeyUser(ctx, userID); appErr != nil {
+			return nil, appErr
+		}
+		passkeyUser := toPasskeyUser(user, passkeys)
+		return &passkeyUser, nil
+	}
+
+	_, cred, err := a.relyingParty.FinishLogin(ceremony.Session, input.Credential, lookup)
+	if err != nil {
+		var lookupErr *app_errors.AppError
+		if errors.As(err, &lookupErr) {
+			if lookupErr.Code == http.StatusNotFound {
+				return nil, nil, app_errors.Unautherized("Invalid passkey", err)
+			}
+			return nil, nil, lookupErr
+		}
+		if errors.Is(err, passkey.ErrInvalidResponse) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, app_errors.Unautherized("Invalid passkey", err)
+		}
+		return nil, nil, app_errors.InternalServer("Failed to verify passkey", err)
+	}
+
+	var used *entities.Passkey
+	for i := range passkeys {
+		if bytes.Equal(passkeys[i].CredentialID, cred.ID) {
+			used = &passkeys[i]
+		}
+	}
+	if used == nil {
+		return nil, nil, app_errors.Unautherized("Invalid passkey", nil)
+	}
+	if cred.CloneWarning {
+		log.Printf("Passkey %s of user %s may be cloned, sign count went from %d to %d", used.ID, user.ID, used.SignCount, cred.SignCount)
+		return nil, nil, app_errors.Unautherized("Invalid passkey", nil)
+	}
+
+	if err := a.passkeyRepo.MarkUsed(ctx, used.ID, cred.SignCount, cred.BackupState, time.Now()); err != nil {
+		return nil, nil, app_errors.FromDB(err, "Failed to update passkey")
+	}
+
+	return user, ceremony, nil
+}
+
+// getPasskeyUser loads a user and their passkeys.
+func (a *AuthUsecaseImpl) getPasskeyUser(ctx context.Context, userID uuid.UUID) (*entities.User, []entities.Passkey, *app_errors.AppError) {
+	user, err := a.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, app_errors.NotFound("User not found", err)
+		}
+		return nil, nil, app_errors.FromDB(err, "Failed to get user")
+	}
+
+	passkeys, err := a.passkeyRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, app_errors.FromDB(err, "Failed to get passkeys")
+	}
+	return user, passkeys, nil
+}
+
+// consumePasskeyCeremony uses up an unexpired ceremony for purpose.
+func (a *AuthUsecaseImpl) consumePasskeyCeremony(ctx context.Context, ceremonyID, purpose string) (*entities.PasskeyCeremony, *app_errors.AppError) {
+	invalid := app_errors.BadRequest("Invalid or expired ceremony", nil)
+
+	id, err := uuid.Parse(ceremonyID)
+	if err != nil {
+		return nil, invalid
+	}
+
+	ceremony, err := a.passkeyRepo.ConsumeCeremony(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get passkey ceremony")
+	}
+	if ceremony.Purpose != purpose || time.Now().After(ceremony.ExpiresAt) {
+		return nil, invalid
+	}
+	return ceremony, nil
+}
+
+// toPasskeyUser identifies the user to authenticators by their ID.
+func toPasskeyUser(user *entities.User, passkeys []entities.Passkey) passkey.User {
+	creds := make([]passkey.Credential, 0, len(passkeys))
+	for _, p := range passkeys {
+		var transports []string
+		if p.Transports != "" {
+			transports = strings.Split(p.Transports, ",")
+		}
+		creds = append(creds, passkey.Credential{
+			ID:              p.CredentialID,
+			PublicKey:       p.PublicKey,
+			AttestationType: p.AttestationType,
+			AAGUID:          p.AAGUID,
+			SignCount:       p.SignCount,
+			Transports:      transports,
+			BackupEligible:  p.BackupEligible,
+			BackupState:     p.BackupState,
+		})
+	}
+	return passkey.User{
+		Handle:      user.ID[:],
+		Name:        user.Email,
+		DisplayName: displayName(user.Name, user.Email),
+		Credentials: creds,
+	}
+}
+
+// sendMail renders a mail template and hands it to the mailer.
+func (a *AuthUsecaseImpl) sendMail(ctx context.Context, locale, template, to string, data any) error {
+	msg, err := mailer.Render(locale, template, to, data)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, msg)
+}
+
+// ensureEmailAvailable returns a conflict if the email belongs to another user.
+func (a *AuthUsecaseImpl) ensureEmailAvailable(ctx context.Context, email string, userID uuid.UUID) *app_errors.AppError {
+	existing, err := a.userRepo.GetUserByEmail(ctx, email)
+	if err == nil && existing.ID != userID {
+		return app_errors.Conflict("Email already in use", nil)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return app_errors.FromDB(err, "Failed to check email")
+	}
+	return nil
+}
@@ -0,0 +1,731 @@
+package usecases_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey/passkeytest"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+const testOrigin = "https://app.example.com"
+
+type authFixture struct {
+	users        *fakeUserRepo
+	sessions     *fakeSessionRepo
+	emailChanges *fakeEmailChangeRepo
+	passkeys     *fakePasskeyRepo
+	events       *fakeEventRepo
+	mailer       *mailer.MemoryMailer
+	rp           usecases.PasskeyRelyingParty
+	session      usecases.SessionUsecase
+	auth         usecases.AuthUsecase
+}
+
+func newAuthFixture() *authFixture {
+	f := &authFixture{
+		users:        newFakeUserRepo(),
+		sessions:     newFakeSessionRepo(),
+		emailChanges: newFakeEmailChangeRepo(),
+		passkeys:     newFakePasskeyRepo(),
+		events:       &fakeEventRepo{},
+		mailer:       mailer.NewMemoryMailer(),
+	}
+
+	rp, err := passkey.New(passkey.Config{RPID: "app.example.com", RPName: "Example", Origins: []string{testOrigin}})
+	if err != nil {
+		panic(err)
+	}
+	f.rp = rp
+
+	tx := &fakeTxManager{}
+	userUsecase := usecases.NewUserUseCase(f.users, f.sessions, f.events, tx, testPasswordPolicy)
+	f.session = usecases.NewSessionUsecase(f.sessions, f.users, tx)
+	f.auth = usecases.NewAuthUseCase(userUsecase, f.session, f.users, f.sessions, f.emailChanges, f.passkeys, f.events, tx, f.mailer, f.rp)
+	return f
+}
+
+func (f *authFixture) register(t *testing.T, email string) *dtos.UserResponse {
+	t.Helper()
+
+	user, appErr := f.auth.RegisterUser(context.Background(), dtos.RegisterRequest{
+		Name:     "Bob",
+		Email:    email,
+		Password: "Correct-Orbit-42",
+		Age:      25,
+	})
+	if appErr != nil {
+		t.Fatalf("RegisterUser: %v", appErr)
+	}
+	return user
+}
+
+func TestRegisterUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantCode int
+	}{
+		{name: "valid", password: "Correct-Orbit-42"},
+		{name: "no upper case", password: "correct-orbit-42", wantCode: http.StatusBadRequest},
+		{name: "no lower case", password: "CORRECT-ORBIT-42", wantCode: http.StatusBadRequest},
+		{name: "too common", password: "Password123!", wantCode: http.StatusBadRequest},
+		{name: "contains name", password: "Bob-Orbit-4217", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture()
+			_, appErr := f.auth.RegisterUser(context.Background(), dtos.RegisterRequest{
+				Name:     "Bob",
+				Email:    "bob@example.com",
+				Password: tt.password,
+				Age:      25,
+			})
+			if tt.wantCode == 0 && appErr != nil {
+				t.Fatalf("RegisterUser: %v", appErr)
+			}
+			if tt.wantCode != 0 && (appErr == nil || appErr.Code != tt.wantCode) {
+				t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	f := newAuthFixture()
+	f.register(t, "bob@example.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode int
+	}{
+		{name: "valid", email: "bob@example.com", password: "Correct-Orbit-42"},
+		{name: "email in other case", email: "BOB@Example.com", password: "Correct-Orbit-42"},
+		{name: "wrong password", email: "bob@example.com", password: "Wrong1234", wantCode: http.StatusUnauthorized},
+		{name: "unknown email", email: "nobody@example.com", password: "Correct-Orbit-42", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, appErr := f.auth.Login(context.Background(), dtos.LoginRequest{Email: tt.email, Password: tt.password}, testIP, testUA, testDeviceID)
+			if tt.wantCode != 0 {
+				if appErr == nil || appErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("Login: %v", appErr)
+			}
+			if resp.AccessToken == "" || resp.RefreshToken == "" {
+				t.Error("empty tokens")
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, appErr := f.auth.Login(ctx, dtos.LoginRequest{Email: "bob@example.com", Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID)
+		if appErr == nil || appErr.Code != app_errors.StatusClientClosedRequest {
+			t.Errorf("got %v, want status %d", appErr, app_errors.StatusClientClosedRequest)
+		}
+	})
+}
+
+func TestLoginRehashesOutdatedPassword(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture()
+	user := f.register(t, "bob@example.com")
+
+	// a hash made with other parameters, e.g. before they were raised
+	outdated := utils.DefaultArgon2Config
+	outdated.Memory *= 2
+	oldHash, err := utils.GeneratePasswordHash(context.Background(), "Correct-Orbit-42", &outdated)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+	if err := f.users.UpdatePasswordHash(ctx, user.ID, oldHash); err != nil {
+		t.Fatalf("UpdatePasswordHash: %v", err)
+	}
+
+	if _, appErr := f.auth.Login(ctx, dtos.LoginRequest{Email: "bob@example.com", Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID); appErr != nil {
+		t.Fatalf("Login with outdated hash: %v", appErr)
+	}
+
+	stored, err := f.users.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if stored.PasswordHash == oldHash || utils.PasswordNeedsRehash(stored.PasswordHash, &utils.DefaultArgon2Config) {
+		t.Errorf("hash %q was not upgraded", stored.PasswordHash)
+	}
+
+	if _, appErr := f.auth.Login(ctx, dtos.LoginRequest{Email: "bob@example.com", Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID); appErr != nil {
+		t.Fatalf("Login with upgraded hash: %v", appErr)
+	}
+}
+
+func TestLoginUpgradesImportedHash(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture()
+	userUsecase := usecases.NewUserUseCase(f.users, f.sessions, f.events, &fakeTxManager{}, testPasswordPolicy)
+
+	resp, appErr := userUsecase.ImportUsers(ctx, dtos.ImportUsersRequest{Users: []dtos.ImportUserRequest{{
+		Name:         "Dave",
+		Email:        "dave@example.com",
+		Age:          40,
+		PasswordHash: "pbkdf2_sha256$1000$seasalt2024$2hbxnK/8Tuey3RX6zyz5Jh0+6lq2xrmBz1ClO1X6+PQ=",
+	}}})
+	if appErr != nil || len(resp.Imported) != 1 {
+		t.Fatalf("ImportUsers = %+v, %v", resp, appErr)
+	}
+
+	login := dtos.LoginRequest{Email: "dave@example.com", Password: "Correct-Orbit-42"}
+	if _, appErr := f.auth.Login(ctx, login, testIP, testUA, testDeviceID); appErr != nil {
+		t.Fatalf("Login with PBKDF2 hash: %v", appErr)
+	}
+
+	stored, err := f.users.GetUserByID(ctx, resp.Imported[0].ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !strings.HasPrefix(stored.PasswordHash, "$argon2id$") {
+		t.Errorf("hash %q was not upgraded to argon2id", stored.PasswordHash)
+	}
+
+	if _, appErr := f.auth.Login(ctx, login, testIP, testUA, testDeviceID); appErr != nil {
+		t.Fatalf("Login with upgraded hash: %v", appErr)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name     string
+		revoked  bool
+		missing  bool
+		deviceUA string
+		wantCode int
+	}{
+		{name: "valid"},
+		{name: "already revoked", revoked: true, wantCode: http.StatusUnauthorized},
+		{name: "unknown session", missing: true, wantCode: http.StatusNotFound},
+		{name: "device mismatch", deviceUA: "other-agent", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuthFixture()
+			user := f.register(t, "bob@example.com")
+			_, sessionID := issueSession(t, f.session, user.ID)
+
+			if tt.revoked {
+				_ = f.sessions.MarkRevoked(ctx, sessionID)
+			}
+			if tt.missing {
+				sessionID = uuid.New()
+			}
+			deviceUA := testUA
+			if tt.deviceUA != "" {
+				deviceUA = tt.deviceUA
+			}
+
+			appErr := f.auth.Logout(ctx, sessionID, testDeviceID, deviceUA)
+			if tt.wantCode != 0 {
+				if appErr == nil || appErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("Logout: %v", appErr)
+			}
+			session, _ := f.sessions.GetByID(ctx, sessionID)
+			if !session.Revoked {
+				t.Error("session not revoked")
+			}
+		})
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture()
+	user := f.register(t, "bob@example.com")
+	_, first := issueSession(t, f.session, user.ID)
+	_, second := issueSession(t, f.session, user.ID)
+
+	if appErr := f.auth.LogoutAll(ctx, user.ID); appErr != nil {
+		t.Fatalf("LogoutAll: %v", appErr)
+	}
+
+	for _, id := range []uuid.UUID{first, second} {
+		session, _ := f.sessions.GetByID(ctx, id)
+		if !session.Revoked {
+			t.Errorf("session %s not revoked", id)
+		}
+	}
+}
+
+func TestDomainEvents(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture()
+	user := f.register(t, "bob@example.com")
+	_, sessionID := issueSession(t, f.session, user.ID)
+
+	if appErr := f.auth.Logout(ctx, sessionID, testDeviceID, testUA); appErr != nil {
+		t.Fatalf("Logout: %v", appErr)
+	}
+	if appErr := f.auth.LogoutAll(ctx, user.ID); appErr != nil {
+		t.Fatalf("LogoutAll: %v", appErr)
+	}
+	if appErr := f.auth.DeleteAccount(ctx, user.ID); appErr != nil {
+		t.Fatalf("DeleteAccount: %v", appErr)
+	}
+
+	want := []string{
+		entities.EventUserCreated,
+		entities.EventSessionRevoked,
+		entities.EventUserSessionsRevoked,
+		entities.EventUserSessionsRevoked,
+		entities.EventUserDeleted,
+	}
+	if got := f.events.types(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestLockUser(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture()
+	userUsecase := usecases.NewUserUseCase(f.users, f.sessions, f.events, &fakeTxManager{}, testPasswordPolicy)
+	user := f.register(t, "bob@example.com")
+	_, sessionID := issueSession(t, f.session, user.ID)
+	login := func() *app_errors.AppError {
+		_, appErr := f.auth.Login(ctx, dtos.LoginRequest{Email: "bob@example.com", Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID)
+		return appErr
+	}
+
+	for range 2 {
+		locked, appErr := userUsecase.LockUser(ctx, user.ID)
+		if appErr != nil || locked.LockedAt == nil {
+			t.Fatalf("LockUser = %+v, %v", locked, appErr)
+		}
+	}
+	if session, _ := f.sessions.GetByID(ctx, sessionID); !session.Revoked {
+		t.Error("session not revoked")
+	}
+	if appErr := login(); appErr == nil || appErr.Code != http.StatusForbidden {
+		t.Errorf("Login while locked: err = %v, want 403", appErr)
+	}
+
+	if unlocked, appErr := userUsecase.UnlockUser(ctx, user.ID); appErr != nil || unlocked.LockedAt != nil {
+		t.Fatalf("UnlockUser = %+v, %v", unlocked, appErr)
+	}
+	if appErr := login(); appErr != nil {
+		t.Errorf("Login after unlock: %v", appErr)
+	}
+
+	want := []string{
+		entities.EventUserCreated,
+		entities.EventUserSessionsRevoked,
+		entities.EventUserLocked,
+		entities.EventUserUnlocked,
+	}
+	if got := f.events.types(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	if _, appErr := userUsecase.LockUser(ctx, uuid.New()); appErr == nil || appErr.Code != http.StatusNotFound {
+		t.Errorf("LockUser(unknown) = %v, want 404", appErr)
+	}
+}
+
+var tokenLine = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`)
+
+// lastToken returns the confirmation token from the last mail sent to addr.
+func (f *authFixture) lastToken(t *testing.T, addr string) string {
+	t.Helper()
+
+	messages := f.mailer.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To == addr {
+			if token := tokenLine.FindString(messages[i].Text); token != "" {
+				return token
+			}
+		}
+	}
+	t.Fatalf("no token mailed to %s", addr)
+	return ""
+}
+
+func TestEmailChange(t *testing.T) {
+	t.Run("confirm swaps email and revokes sessions", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		_, sessionID := issueSession(t, f.session, user.ID)
+
+		if appErr := f.auth.RequestEmailChange(ctx, user.ID, dtos.EmailChangeRequest{NewEmail: "Robert@Example.com"}); appErr != nil {
+			t.Fatalf("RequestEmailChange: %v", appErr)
+		}
+		token := f.lastToken(t, "robert@example.com")
+
+		// the old address is notified
+		if msgs := f.mailer.Messages(); len(msgs) != 2 || msgs[1].To != "bob@example.com" {
+			t.Fatalf("unexpected mails %+v", msgs)
+		}
+
+		if appErr := f.auth.ConfirmEmailChange(ctx, dtos.ConfirmEmailChangeRequest{Token: token}); appErr != nil {
+			t.Fatalf("ConfirmEmailChange: %v", appErr)
+		}
+
+		updated, _ := f.users.GetUserByID(ctx, user.ID)
+		if updated.Email != "robert@example.com" || updated.EmailVerifiedAt == nil {
+			t.Errorf("email = %q, verified at %v, want robert@example.com verified", updated.Email, updated.EmailVerifiedAt)
+		}
+		session, _ := f.sessions.GetByID(ctx, sessionID)
+		if !session.Revoked {
+			t.Error("session not revoked after email change")
+		}
+
+		// tokens are single use
+		if appErr := f.auth.ConfirmEmailChange(ctx, dtos.ConfirmEmailChangeRequest{Token: token}); appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("reused token: got %v, want 400", appErr)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+
+		if appErr := f.auth.RequestEmailChange(ctx, user.ID, dtos.EmailChangeRequest{NewEmail: "robert@example.com"}); appErr != nil {
+			t.Fatalf("RequestEmailChange: %v", appErr)
+		}
+		token := f.lastToken(t, "robert@example.com")
+		f.emailChanges.expireAll()
+
+		if appErr := f.auth.ConfirmEmailChange(ctx, dtos.ConfirmEmailChangeRequest{Token: token}); appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("got %v, want 400", appErr)
+		}
+	})
+
+	t.Run("concurrent confirms", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+
+		if appErr := f.auth.RequestEmailChange(ctx, user.ID, dtos.EmailChangeRequest{NewEmail: "robert@example.com"}); appErr != nil {
+			t.Fatalf("RequestEmailChange: %v", appErr)
+		}
+		token := f.lastToken(t, "robert@example.com")
+
+		errs := make(chan *app_errors.AppError, 2)
+		for range 2 {
+			go func() { errs <- f.auth.ConfirmEmailChange(ctx, dtos.ConfirmEmailChangeRequest{Token: token}) }()
+		}
+		first, second := <-errs, <-errs
+		if (first == nil) == (second == nil) {
+			t.Fatalf("got %v and %v, want exactly one confirm", first, second)
+		}
+		if first == nil {
+			first = second
+		}
+		if first.Code != http.StatusBadRequest {
+			t.Errorf("losing confirm: got %v, want 400", first)
+		}
+	})
+
+	t.Run("email taken", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		f.register(t, "alice@example.com")
+
+		appErr := f.auth.RequestEmailChange(ctx, user.ID, dtos.EmailChangeRequest{NewEmail: "ALICE@example.com"})
+		if appErr == nil || appErr.Code != http.StatusConflict {
+			t.Errorf("got %v, want 409", appErr)
+		}
+	})
+}
+
+// registerPasskey registers a passkey on authn for the user.
+func (f *authFixture) registerPasskey(t *testing.T, authn *passkeytest.Authenticator, userID uuid.UUID, nickname string) *dtos.PasskeyResponse {
+	t.Helper()
+
+	ctx := context.Background()
+	ceremony, appErr := f.auth.BeginPasskeyRegistration(ctx, userID)
+	if appErr != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", appErr)
+	}
+	created, appErr := f.auth.FinishPasskeyRegistration(ctx, userID, dtos.FinishPasskeyRegistrationRequest{
+		CeremonyID: ceremony.CeremonyID.String(),
+		Nickname:   nickname,
+		Credential: authn.Register(t, ceremony.Options),
+	})
+	if appErr != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", appErr)
+	}
+	return created
+}
+
+// finishPasskeyLogin answers a passkey login with authn.
+func (f *authFixture) finishPasskeyLogin(t *testing.T, authn *passkeytest.Authenticator, ceremony *dtos.PasskeyCeremonyResponse) (*dtos.LoginResponse, *app_errors.AppError) {
+	t.Helper()
+
+	return f.auth.FinishPasskeyLogin(context.Background(), dtos.FinishPasskeyLoginRequest{
+		CeremonyID: ceremony.CeremonyID.String(),
+		Credential: authn.Login(t, ceremony.Options),
+	}, testIP, testUA, testDeviceID)
+}
+
+func TestPasskeys(t *testing.T) {
+	password := dtos.LoginRequest{Email: "bob@example.com", Password: "Correct-Orbit-42"}
+
+	t.Run("second factor after the password", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		authn := passkeytest.NewAuthenticator(testOrigin)
+
+		created := f.registerPasskey(t, authn, user.ID, "")
+		if created.Nickname != "Passkey" || len(created.Transports) != 1 {
+			t.Errorf("registered %+v", created)
+		}
+
+		resp, appErr := f.auth.Login(ctx, password, testIP, testUA, testDeviceID)
+		if appErr != nil {
+			t.Fatalf("Login: %v", appErr)
+		}
+		if resp.AccessToken != "" || resp.PasskeyRequired == nil {
+			t.Fatalf("Login = %+v, want a passkey ceremony", resp)
+		}
+
+		loginResp, appErr := f.finishPasskeyLogin(t, authn, resp.PasskeyRequired)
+		if appErr != nil {
+			t.Fatalf("FinishPasskeyLogin: %v", appErr)
+		}
+		if loginResp.AccessToken == "" || loginResp.RefreshToken == "" {
+			t.Fatalf("FinishPasskeyLogin = %+v, want tokens", loginResp)
+		}
+		if _, amr := authClaims(t, loginResp.AccessToken); len(amr) != 3 || amr[2] != jwt.AMRMultiFactor {
+			t.Errorf("amr = %v, want [pwd hwk mfa]", amr)
+		}
+
+		// ceremonies are single use
+		if _, appErr := f.finishPasskeyLogin(t, authn, resp.PasskeyRequired); appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("reused ceremony: got %v, want 400", appErr)
+		}
+	})
+
+	t.Run("passwordless", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		authn := passkeytest.NewAuthenticator(testOrigin)
+		f.registerPasskey(t, authn, user.ID, "Laptop")
+
+		ceremony, appErr := f.auth.BeginPasskeyLogin(ctx)
+		if appErr != nil {
+			t.Fatalf("BeginPasskeyLogin: %v", appErr)
+		}
+		if _, appErr := f.finishPasskeyLogin(t, authn, ceremony); appErr != nil {
+			t.Fatalf("FinishPasskeyLogin: %v", appErr)
+		}
+
+		passkeys, _ := f.auth.ListPasskeys(ctx, user.ID)
+		if len(passkeys) != 1 || passkeys[0].LastUsedAt == nil {
+			t.Errorf("ListPasskeys = %+v, want last use recorded", passkeys)
+		}
+		stored, _ := f.passkeys.ListByUserID(ctx, user.ID)
+		if stored[0].SignCount != 1 {
+			t.Errorf("sign count = %d, want 1", stored[0].SignCount)
+		}
+	})
+
+	t.Run("unregistered authenticator", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		other := passkeytest.NewAuthenticator(testOrigin)
+		f.registerPasskey(t, other, user.ID, "Laptop")
+		if appErr := f.auth.DeletePasskey(ctx, user.ID, mustListPasskeys(t, f, user.ID)[0].ID); appErr != nil {
+			t.Fatalf("DeletePasskey: %v", appErr)
+		}
+
+		ceremony, _ := f.auth.BeginPasskeyLogin(ctx)
+		if _, appErr := f.finishPasskeyLogin(t, other, ceremony); appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Errorf("got %v, want 401", appErr)
+		}
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		authn := passkeytest.NewAuthenticator(testOrigin)
+		f.registerPasskey(t, authn, user.ID, "Laptop")
+		f.passkeys.setSignCount(100)
+
+		ceremony, _ := f.auth.BeginPasskeyLogin(ctx)
+		if _, appErr := f.finishPasskeyLogin(t, authn, ceremony); appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Errorf("got %v, want 401", appErr)
+		}
+	})
+
+	t.Run("registration ceremony of another user", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		bob := f.register(t, "bob@example.com")
+		alice := f.register(t, "alice@example.com")
+
+		ceremony, _ := f.auth.BeginPasskeyRegistration(ctx, bob.ID)
+		_, appErr := f.auth.FinishPasskeyRegistration(ctx, alice.ID, dtos.FinishPasskeyRegistrationRequest{
+			CeremonyID: ceremony.CeremonyID.String(),
+			Credential: passkeytest.NewAuthenticator(testOrigin).Register(t, ceremony.Options),
+		})
+		if appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("got %v, want 400", appErr)
+		}
+	})
+
+	t.Run("rename and delete", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		alice := f.register(t, "alice@example.com")
+		created := f.registerPasskey(t, passkeytest.NewAuthenticator(testOrigin), user.ID, "Laptop")
+
+		renamed, appErr := f.auth.RenamePasskey(ctx, user.ID, created.ID, dtos.RenamePasskeyRequest{Nickname: " Work laptop "})
+		if appErr != nil {
+			t.Fatalf("RenamePasskey: %v", appErr)
+		}
+		if renamed.Nickname != "Work laptop" {
+			t.Errorf("Nickname = %q, want Work laptop", renamed.Nickname)
+		}
+
+		// only the owner can touch a passkey
+		if _, appErr := f.auth.RenamePasskey(ctx, alice.ID, created.ID, dtos.RenamePasskeyRequest{Nickname: "Mine"}); appErr == nil || appErr.Code != http.StatusNotFound {
+			t.Errorf("rename by another user: got %v, want 404", appErr)
+		}
+		if appErr := f.auth.DeletePasskey(ctx, alice.ID, created.ID); appErr == nil || appErr.Code != http.StatusNotFound {
+			t.Errorf("delete by another user: got %v, want 404", appErr)
+		}
+
+		if appErr := f.auth.DeletePasskey(ctx, user.ID, created.ID); appErr != nil {
+			t.Fatalf("DeletePasskey: %v", appErr)
+		}
+		if passkeys := mustListPasskeys(t, f, user.ID); len(passkeys) != 0 {
+			t.Errorf("ListPasskeys = %+v, want none", passkeys)
+		}
+
+		// without passkeys the password is enough again
+		resp, appErr := f.auth.Login(ctx, password, testIP, testUA, testDeviceID)
+		if appErr != nil || resp.AccessToken == "" {
+			t.Errorf("Login = %+v, %v, want tokens", resp, appErr)
+		}
+	})
+}
+
+func mustListPasskeys(t *testing.T, f *authFixture, userID uuid.UUID) []*dtos.PasskeyResponse {
+	t.Helper()
+
+	passkeys, appErr := f.auth.ListPasskeys(context.Background(), userID)
+	if appErr != nil {
+		t.Fatalf("ListPasskeys: %v", appErr)
+	}
+	return passkeys
+}
+
+// loginSession logs in with the password and returns the session ID.
+func (f *authFixture) loginSession(t *testing.T, email string) uuid.UUID {
+	t.Helper()
+
+	resp, appErr := f.auth.Login(context.Background(), dtos.LoginRequest{Email: email, Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID)
+	if appErr != nil {
+		t.Fatalf("Login: %v", appErr)
+	}
+	claims, err := jwt.VerifyAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	return uuid.MustParse(claims.SessionID)
+}
+
+func TestReauthenticate(t *testing.T) {
+	t.Run("password", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		sessionID := f.loginSession(t, "bob@example.com")
+
+		_, appErr := f.auth.Reauthenticate(ctx, user.ID, sessionID, dtos.ReauthenticateRequest{Password: "Wrong-Orbit-42"}, testIP, testUA, testDeviceID)
+		if appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Errorf("wrong password: got %v, want 401", appErr)
+		}
+
+		resp, appErr := f.auth.Reauthenticate(ctx, user.ID, sessionID, dtos.ReauthenticateRequest{Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID)
+		if appErr != nil {
+			t.Fatalf("Reauthenticate: %v", appErr)
+		}
+		if _, amr := authClaims(t, resp.AccessToken); len(amr) != 1 || amr[0] != jwt.AMRPassword {
+			t.Errorf("amr = %v, want [pwd]", amr)
+		}
+	})
+
+	t.Run("passkey", func(t *testing.T) {
+		ctx := context.Background()
+		f := newAuthFixture()
+		user := f.register(t, "bob@example.com")
+		alice := f.register(t, "alice@example.com")
+		sessionID := f.loginSession(t, "bob@example.com")
+		authn := passkeytest.NewAuthenticator(testOrigin)
+		f.registerPasskey(t, authn, user.ID, "Laptop")
+		aliceAuthn := passkeytest.NewAuthenticator(testOrigin)
+		f.registerPasskey(t, aliceAuthn, alice.ID, "Phone")
+
+		// with passkeys the password is not enough
+		_, appErr := f.auth.Reauthenticate(ctx, user.ID, sessionID, dtos.ReauthenticateRequest{Password: "Correct-Orbit-42"}, testIP, testUA, testDeviceID)
+		if appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("password: got %v, want 400", appErr)
+		}
+
+		answer := func(authn *passkeytest.Authenticator) dtos.ReauthenticateRequest {
+			ceremony, appErr := f.auth.BeginPasskeyLogin(ctx)
+			if appErr != nil {
+				t.Fatalf("BeginPasskeyLogin: %v", appErr)
+			}
+			return dtos.ReauthenticateRequest{CeremonyID: ceremony.CeremonyID.String(), Credential: authn.Login(t, ceremony.Options)}
+		}
+
+		_, appErr = f.auth.Reauthenticate(ctx, user.ID, sessionID, answer(aliceAuthn), testIP, testUA, testDeviceID)
+		if appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Errorf("another user's passkey: got %v, want 401", appErr)
+		}
+
+		resp, appErr := f.auth.Reauthenticate(ctx, user.ID, sessionID, answer(authn), testIP, testUA, testDeviceID)
+		if appErr != nil {
+			t.Fatalf("Reauthenticate: %v", appErr)
+		}
+		if _, amr := authClaims(t, resp.AccessToken); len(amr) != 1 || amr[0] != jwt.AMRHardwareKey {
+			t.Errorf("amr = %v, want [hwk]", amr)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// CredentialVerifier checks the email and password of a login and returns
+// the user they belong to. A 401 means the verifier can't vouch for them
+// and Login tries the next one; any other error ends the login.
+type CredentialVerifier interface {
+	Verify(ctx context.Context, email, password string) (*entities.User, *app_errors.AppError)
+}
+
+// passwordVerifier checks the argon2 (or imported) password hashes stored
+// with users.
+type passwordVerifier struct {
+	userRepo repositories.UserRepository
+}
+
+func NewPasswordVerifier(userRepo repositories.UserRepository) CredentialVerifier {
+	return &passwordVerifier{userRepo: userRepo}
+}
+
+// Verify
+func (v *passwordVerifier) Verify(ctx context.Context, email, password string) (*entities.User, *app_errors.AppError) {
+	user, err := v.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.Unautherized("Invalid credentials", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	// users from a directory or identity provider have no password here
+	if user.PasswordHash == "" {
+		return nil, app_errors.Unautherized("Invalid credentials", fmt.Errorf("no password"))
+	}
+
+	match, err := utils.VerifyPassword(ctx, password, user.PasswordHash)
+	if errors.Is(err, utils.ErrHashingBusy) {
+		return nil, hashingBusy(err)
+	}
+	// the client went away, that's not the server failing
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, app_errors.ClientClosedRequest("Login was cancelled", ctxErr)
+	}
+	if err != nil {
+		// a broken hash or missing pepper, not a wrong password
+		log.Printf("Failed to verify password for user %s: %v", user.ID, err)
+	}
+	if err != nil || !match {
+		return nil, app_errors.Unautherized("Invalid credentials", fmt.Errorf("password mismatch"))
+	}
+
+	// upgrade hashes made with older parameters while we know the password
+	if utils.PasswordNeedsRehash(user.PasswordHash, &utils.DefaultArgon2Config) {
+		v.rehashPassword(ctx, user, password)
+	}
+	return user, nil
+}
+
+// hashingBusyRetryAfter is how long clients turned away by
+// utils.PasswordHashLimiter are asked to wait before retrying.
+const hashingBusyRetryAfter = time.Second
+
+// hashingBusy is the error for a password hash the limiter turned away.
+func hashingBusy(err error) *app_errors.AppError {
+	return app_errors.ServiceUnavailable("Server is busy, please retry", err).WithRetryAfter(hashingBusyRetryAfter)
+}
+
+// rehashPassword stores a new hash of password for the user. Failing to
+// do so only means trying again on the next login, so errors are logged.
+func (v *passwordVerifier) rehashPassword(ctx context.Context, user *entities.User, password string) {
+	hash, err := utils.GeneratePasswordHash(ctx, password, &utils.DefaultArgon2Config)
+	if err != nil {
+		log.Printf("Failed to rehash password for user %s: %v", user.ID, err)
+		return
+	}
+
+	if err := v.userRepo.UpdatePasswordHash(ctx, user.ID, hash); err != nil {
+		log.Printf("Failed to store rehashed password for user %s: %v", user.ID, err)
+	}
+}
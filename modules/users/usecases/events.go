@@ -0,0 +1,31 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+// raiseEvent records a domain event for the outbox. Call it inside the
+// transaction of the change it describes, see inTransaction.
+func raiseEvent(ctx context.Context, eventRepo repositories.EventRepository, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) *app_errors.AppError {
+	if err := eventRepo.Append(ctx, aggregateType, aggregateID, eventType, payload); err != nil {
+		return app_errors.FromDB(err, "Failed to record event")
+	}
+	return nil
+}
+
+// raiseUserEvent records eventType for user, with the user as payload.
+func raiseUserEvent(ctx context.Context, eventRepo repositories.EventRepository, eventType string, user *entities.User) *app_errors.AppError {
+	return raiseEvent(ctx, eventRepo, entities.AggregateUser, user.ID, eventType, user)
+}
+
+// raiseSessionsRevoked records that all of a user's sessions were revoked.
+func raiseSessionsRevoked(ctx context.Context, eventRepo repositories.EventRepository, userID uuid.UUID) *app_errors.AppError {
+	payload := map[string]any{"user_id": userID}
+	return raiseEvent(ctx, eventRepo, entities.AggregateUser, userID, entities.EventUserSessionsRevoked, payload)
+}
@@ -0,0 +1,479 @@
+package usecases_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+func TestMain(m *testing.M) {
+	validator.Init()
+
+	// cheap hashing keeps the suite fast
+	utils.DefaultArgon2Config.Memory = 1024
+	utils.DefaultArgon2Config.Time = 1
+
+	os.Exit(m.Run())
+}
+
+var testPasswordPolicy = &validator.PasswordPolicy{
+	MinLength:    10,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	MaxRepeat:    3,
+	MinScore:     3,
+}
+
+// fakeUserRepo wraps the memory repository and lets tests inject errors.
+type fakeUserRepo struct {
+	repositories.UserRepository
+
+	createErr error
+	getErr    error
+	updateErr error
+	deleteErr error
+}
+
+func newFakeUserRepo() *fakeUserRepo {
+	return &fakeUserRepo{UserRepository: repositories.NewUserMemoryRepository()}
+}
+
+func (f *fakeUserRepo) CreateUser(ctx context.Context, user *entities.User) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	return f.UserRepository.CreateUser(ctx, user)
+}
+
+func (f *fakeUserRepo) GetUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	return f.UserRepository.GetUserByID(ctx, id)
+}
+
+func (f *fakeUserRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	return f.UserRepository.GetUserByEmail(ctx, email)
+}
+
+func (f *fakeUserRepo) UpdateUserByID(ctx context.Context, id uuid.UUID, user *entities.User) (*entities.User, error) {
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	return f.UserRepository.UpdateUserByID(ctx, id, user)
+}
+
+func (f *fakeUserRepo) DeleteUserByID(ctx context.Context, id uuid.UUID) (*entities.User, error) {
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	return f.UserRepository.DeleteUserByID(ctx, id)
+}
+
+// fakeSessionRepo wraps the memory repository and lets tests inject errors.
+type fakeSessionRepo struct {
+	repositories.SessionRepository
+
+	insertErr error
+	revokeErr error
+	mutate    func(session *entities.Session)
+}
+
+func newFakeSessionRepo() *fakeSessionRepo {
+	return &fakeSessionRepo{SessionRepository: repositories.NewSessionMemoryRepository()}
+}
+
+func (f *fakeSessionRepo) Insert(ctx context.Context, session *entities.Session) error {
+	if f.insertErr != nil {
+		return f.insertErr
+	}
+	return f.SessionRepository.Insert(ctx, session)
+}
+
+func (f *fakeSessionRepo) GetByID(ctx context.Context, sessionID uuid.UUID) (*entities.Session, error) {
+	session, err := f.SessionRepository.GetByID(ctx, sessionID)
+	if err == nil && f.mutate != nil {
+		f.mutate(session)
+	}
+	return session, err
+}
+
+func (f *fakeSessionRepo) MarkRevoked(ctx context.Context, sessionID uuid.UUID) error {
+	if f.revokeErr != nil {
+		return f.revokeErr
+	}
+	return f.SessionRepository.MarkRevoked(ctx, sessionID)
+}
+
+// fakeEmailChangeRepo is a map-based EmailChangeRepository.
+type fakeEmailChangeRepo struct {
+	mu      sync.Mutex
+	changes map[uuid.UUID]entities.EmailChange
+}
+
+func newFakeEmailChangeRepo() *fakeEmailChangeRepo {
+	return &fakeEmailChangeRepo{changes: make(map[uuid.UUID]entities.EmailChange)}
+}
+
+func (f *fakeEmailChangeRepo) Insert(ctx context.Context, change *entities.EmailChange) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.changes[change.ID] = *change
+	return nil
+}
+
+func (f *fakeEmailChangeRepo) GetByTokenHash(ctx context.Context, tokenHash string) (*entities.EmailChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, change := range f.changes {
+		if change.TokenHash == tokenHash {
+			return &change, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeEmailChangeRepo) MarkConfirmed(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	change, ok := f.changes[id]
+	if !ok || change.ConfirmedAt != nil || !now.Before(change.ExpiresAt) {
+		return gorm.ErrRecordNotFound
+	}
+	change.ConfirmedAt = &now
+	f.changes[id] = change
+	return nil
+}
+
+func (f *fakeEmailChangeRepo) DeletePendingByUserID(ctx context.Context, userID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, change := range f.changes {
+		if change.UserID == userID && change.ConfirmedAt == nil {
+			delete(f.changes, id)
+		}
+	}
+	return nil
+}
+
+// expireAll moves every pending change into the past.
+func (f *fakeEmailChangeRepo) expireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, change := range f.changes {
+		change.ExpiresAt = change.Created_at.Add(-1)
+		f.changes[id] = change
+	}
+}
+
+// fakeEventRepo records the events raised.
+type fakeEventRepo struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (f *fakeEventRepo) Append(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, eventType)
+	return nil
+}
+
+// types returns the types of the events raised, oldest first.
+func (f *fakeEventRepo) types() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.events...)
+}
+
+// fakeTxManager runs the function without a real transaction.
+type fakeTxManager struct {
+	calls int
+}
+
+func (f *fakeTxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	f.calls++
+	return fn(ctx)
+}
+
+// fakeAccessTokenRepo is a map-based AccessTokenRepository.
+type fakeAccessTokenRepo struct {
+	mu        sync.Mutex
+	tokens    map[uuid.UUID]entities.AccessToken
+	markCalls int
+}
+
+func newFakeAccessTokenRepo() *fakeAccessTokenRepo {
+	return &fakeAccessTokenRepo{tokens: make(map[uuid.UUID]entities.AccessToken)}
+}
+
+func (f *fakeAccessTokenRepo) Insert(ctx context.Context, token *entities.AccessToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token.ID] = *token
+	return nil
+}
+
+func (f *fakeAccessTokenRepo) GetByTokenHash(ctx context.Context, tokenHash string) (*entities.AccessToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, token := range f.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAccessTokenRepo) ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.AccessToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tokens []entities.AccessToken
+	for _, token := range f.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (f *fakeAccessTokenRepo) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[id]
+	if !ok || token.UserID != userID || token.RevokedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	f.tokens[id] = token
+	return nil
+}
+
+func (f *fakeAccessTokenRepo) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, ip string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.markCalls++
+	token := f.tokens[id]
+	token.LastUsedAt = &at
+	token.LastUsedIP = ip
+	f.tokens[id] = token
+	return nil
+}
+
+// expireAll moves every token's expiry into the past.
+func (f *fakeAccessTokenRepo) expireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, token := range f.tokens {
+		token.ExpiresAt = token.Created_at.Add(-1)
+		f.tokens[id] = token
+	}
+}
+
+// fakeLoginLinkRepo is a map-based LoginLinkRepository.
+type fakeLoginLinkRepo struct {
+	mu    sync.Mutex
+	links map[uuid.UUID]entities.LoginLink
+}
+
+func newFakeLoginLinkRepo() *fakeLoginLinkRepo {
+	return &fakeLoginLinkRepo{links: make(map[uuid.UUID]entities.LoginLink)}
+}
+
+func (f *fakeLoginLinkRepo) Insert(ctx context.Context, link *entities.LoginLink) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.links[link.ID] = *link
+	return nil
+}
+
+func (f *fakeLoginLinkRepo) GetBySecretHash(ctx context.Context, method, secretHash string) (*entities.LoginLink, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, link := range f.links {
+		if link.Method == method && link.SecretHash == secretHash {
+			return &link, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeLoginLinkRepo) GetLatestByUserID(ctx context.Context, userID uuid.UUID, method string) (*entities.LoginLink, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var latest *entities.LoginLink
+	for _, link := range f.links {
+		if link.UserID == userID && link.Method == method && (latest == nil || link.Created_at.After(latest.Created_at)) {
+			latest = &link
+		}
+	}
+	if latest == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return latest, nil
+}
+
+func (f *fakeLoginLinkRepo) ClaimAttempt(ctx context.Context, id uuid.UUID, max int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	link, ok := f.links[id]
+	if !ok || link.Attempts >= max {
+		return gorm.ErrRecordNotFound
+	}
+	link.Attempts++
+	f.links[id] = link
+	return nil
+}
+
+func (f *fakeLoginLinkRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.links[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(f.links, id)
+	return nil
+}
+
+func (f *fakeLoginLinkRepo) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, link := range f.links {
+		if link.UserID == userID {
+			delete(f.links, id)
+		}
+	}
+	return nil
+}
+
+// expireAll moves every login's expiry into the past.
+func (f *fakeLoginLinkRepo) expireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, link := range f.links {
+		link.ExpiresAt = link.Created_at.Add(-1)
+		f.links[id] = link
+	}
+}
+
+// fakePasskeyRepo is a map-based PasskeyRepository.
+type fakePasskeyRepo struct {
+	mu         sync.Mutex
+	passkeys   map[uuid.UUID]entities.Passkey
+	ceremonies map[uuid.UUID]entities.PasskeyCeremony
+}
+
+func newFakePasskeyRepo() *fakePasskeyRepo {
+	return &fakePasskeyRepo{
+		passkeys:   make(map[uuid.UUID]entities.Passkey),
+		ceremonies: make(map[uuid.UUID]entities.PasskeyCeremony),
+	}
+}
+
+func (f *fakePasskeyRepo) Insert(ctx context.Context, passkey *entities.Passkey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.passkeys {
+		if bytes.Equal(existing.CredentialID, passkey.CredentialID) {
+			return databases.ErrDuplicateKey
+		}
+	}
+	f.passkeys[passkey.ID] = *passkey
+	return nil
+}
+
+func (f *fakePasskeyRepo) ListByUserID(ctx context.Context, userID uuid.UUID) ([]entities.Passkey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var passkeys []entities.Passkey
+	for _, passkey := range f.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool { return passkeys[i].Created_at.Before(passkeys[j].Created_at) })
+	return passkeys, nil
+}
+
+func (f *fakePasskeyRepo) MarkUsed(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	passkey, ok := f.passkeys[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	passkey.SignCount = signCount
+	passkey.BackupState = backupState
+	passkey.LastUsedAt = &usedAt
+	f.passkeys[id] = passkey
+	return nil
+}
+
+func (f *fakePasskeyRepo) Rename(ctx context.Context, id, userID uuid.UUID, nickname string) (*entities.Passkey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	passkey, ok := f.passkeys[id]
+	if !ok || passkey.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	passkey.Nickname = nickname
+	f.passkeys[id] = passkey
+	return &passkey, nil
+}
+
+func (f *fakePasskeyRepo) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if passkey, ok := f.passkeys[id]; !ok || passkey.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(f.passkeys, id)
+	return nil
+}
+
+func (f *fakePasskeyRepo) InsertCeremony(ctx context.Context, ceremony *entities.PasskeyCeremony) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ceremonies[ceremony.ID] = *ceremony
+	return nil
+}
+
+func (f *fakePasskeyRepo) ConsumeCeremony(ctx context.Context, id uuid.UUID) (*entities.PasskeyCeremony, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ceremony, ok := f.ceremonies[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(f.ceremonies, id)
+	return &ceremony, nil
+}
+
+// setSignCount overwrites the stored sign count of every passkey.
+func (f *fakePasskeyRepo) setSignCount(count uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, passkey := range f.passkeys {
+		passkey.SignCount = count
+		f.passkeys[id] = passkey
+	}
+}
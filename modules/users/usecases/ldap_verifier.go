@@ -0,0 +1,136 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/ldapauth"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// Directory checks passwords against an LDAP directory, see
+// ldapauth.Directory.
+type Directory interface {
+	Authenticate(ctx context.Context, login, password string) (*ldapauth.Entry, error)
+}
+
+// GroupRole gives members of a directory group a role. The role is only
+// recorded on the user for clients, nothing in this service enforces it.
+type GroupRole struct {
+	Group string // DN
+	Role  string
+}
+
+// ldapVerifier signs in users of a directory. The user is created on the
+// first login, with its source set to LDAP; the directory owns the name
+// and role of such users, which are copied to them on every login.
+// Accounts made any other way are never taken over, even when their email
+// is the entry's.
+type ldapVerifier struct {
+	directory  Directory
+	groupRoles []GroupRole
+	userRepo   repositories.UserRepository
+	eventRepo  repositories.EventRepository
+	txManager  databases.TxManager
+}
+
+func NewLDAPVerifier(directory Directory, groupRoles []GroupRole, userRepo repositories.UserRepository, eventRepo repositories.EventRepository, txManager databases.TxManager) CredentialVerifier {
+	return &ldapVerifier{
+		directory:  directory,
+		groupRoles: groupRoles,
+		userRepo:   userRepo,
+		eventRepo:  eventRepo,
+		txManager:  txManager,
+	}
+}
+
+// Verify
+func (v *ldapVerifier) Verify(ctx context.Context, email, password string) (*entities.User, *app_errors.AppError) {
+	entry, err := v.directory.Authenticate(ctx, email, password)
+	if errors.Is(err, ldapauth.ErrInvalidCredentials) {
+		return nil, app_errors.Unautherized("Invalid credentials", err)
+	}
+	if err != nil {
+		log.Printf("LDAP login for %s failed: %v", email, err)
+		return nil, app_errors.ServiceUnavailable("Directory is unavailable", err)
+	}
+
+	// the entry's mail is the only email the directory vouches for
+	if entry.Email == "" {
+		return nil, app_errors.New(http.StatusForbidden, "Your directory account has no email", nil)
+	}
+	email = utils.NormalizeEmail(entry.Email)
+	name := displayName(entry.Name, email)
+	role := v.roleFor(entry.Groups)
+	now := time.Now()
+
+	user, err := v.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = &entities.User{
+			ID:         uuid.New(),
+			Name:       name,
+			Email:      email,
+			Locale:     localeOrDefault(""),
+			Role:       role,
+			Source:     entities.SourceLDAP,
+			Created_at: now,
+			Updated_at: now,
+		}
+		appErr := inTransaction(ctx, v.txManager, func(ctx context.Context) *app_errors.AppError {
+			if err := v.userRepo.CreateUser(ctx, user); err != nil {
+				return app_errors.FromDB(err, "Failed to create user")
+			}
+			return raiseUserEvent(ctx, v.eventRepo, entities.EventUserCreated, user)
+		})
+		if appErr != nil {
+			return nil, appErr
+		}
+		return user, nil
+	}
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	if user.Source != entities.SourceLDAP || user.PasswordHash != "" {
+		return nil, app_errors.Conflict("An account with this email already exists, sign in to it the way it was created", nil)
+	}
+
+	if user.Name != name || user.Role != role {
+		user.Name = name
+		user.Role = role
+		user.Updated_at = now
+		appErr := inTransaction(ctx, v.txManager, func(ctx context.Context) *app_errors.AppError {
+			if user, err = v.userRepo.UpdateUserByID(ctx, user.ID, user); err != nil {
+				return app_errors.FromDB(err, "Failed to update user")
+			}
+			return raiseUserEvent(ctx, v.eventRepo, entities.EventUserUpdated, user)
+		})
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+	return user, nil
+}
+
+// roleFor returns the role of the first group mapping the user is a
+// member of.
+func (v *ldapVerifier) roleFor(groups []string) string {
+	for _, gr := range v.groupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(group, gr.Group) {
+				return gr.Role
+			}
+		}
+	}
+	return entities.RoleUser
+}
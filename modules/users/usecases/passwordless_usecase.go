@@ -0,0 +1,17 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+// PasswordlessUsecase signs users in with a link or a one-time code sent
+// to their email. Asking for one answers the same whether or not the
+// email has an account, so it can't be used to find out which do.
+type PasswordlessUsecase interface {
+	RequestLogin(ctx context.Context, input dtos.MagicLinkRequest, deviceID string) *app_errors.AppError
+	VerifyLink(ctx context.Context, input dtos.VerifyMagicLinkRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
+	VerifyCode(ctx context.Context, input dtos.VerifyLoginCodeRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
+}
@@ -0,0 +1,211 @@
+package usecases
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+const (
+	loginLinkTTL = 15 * time.Minute
+	loginCodeTTL = 10 * time.Minute
+
+	// loginCodeAttempts is how many guesses a code allows.
+	loginCodeAttempts = 5
+)
+
+type passwordlessUsecaseImpl struct {
+	secondFactor
+	userRepo      repositories.UserRepository
+	loginLinkRepo repositories.LoginLinkRepository
+	mailer        mailer.Mailer
+	linkURL       string
+}
+
+// NewPasswordlessUsecase sends links to linkURL, the login page, with the
+// token in the query.
+func NewPasswordlessUsecase(userRepo repositories.UserRepository, loginLinkRepo repositories.LoginLinkRepository, passkeyRepo repositories.PasskeyRepository, sessionUsecase SessionUsecase, relyingParty PasskeyRelyingParty, m mailer.Mailer, linkURL string) PasswordlessUsecase {
+	return &passwordlessUsecaseImpl{
+		secondFactor: secondFactor{
+			passkeyRepo:    passkeyRepo,
+			sessionUsecase: sessionUsecase,
+			relyingParty:   relyingParty,
+		},
+		userRepo:      userRepo,
+		loginLinkRepo: loginLinkRepo,
+		mailer:        m,
+		linkURL:       linkURL,
+	}
+}
+
+// Request login
+func (u *passwordlessUsecaseImpl) RequestLogin(ctx context.Context, input dtos.MagicLinkRequest, deviceID string) *app_errors.AppError {
+	if deviceID == "" {
+		return app_errors.BadRequest("X-Device-ID header is required", nil)
+	}
+
+	user, err := u.userRepo.GetUserByEmail(ctx, utils.NormalizeEmail(input.Email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return app_errors.FromDB(err, "Failed to get user")
+	}
+
+	method := input.Method
+	if method == "" {
+		method = entities.LoginMethodLink
+	}
+
+	var secret, template string
+	ttl := loginLinkTTL
+	if method == entities.LoginMethodCode {
+		secret, err = utils.GenerateCode(6)
+		template, ttl = "login_code", loginCodeTTL
+	} else {
+		secret, err = utils.GenerateToken(32)
+		template = "magic_link"
+	}
+	if err != nil {
+		return app_errors.InternalServer("Failed to generate login", err)
+	}
+
+	// only the latest login can be used
+	if err := u.loginLinkRepo.DeleteByUserID(ctx, user.ID); err != nil {
+		return app_errors.FromDB(err, "Failed to clear pending logins")
+	}
+	link := &entities.LoginLink{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		Method:     method,
+		SecretHash: utils.HashToken(secret),
+		DeviceID:   deviceID,
+		ExpiresAt:  time.Now().Add(ttl),
+		Created_at: time.Now(),
+	}
+	if err := u.loginLinkRepo.Insert(ctx, link); err != nil {
+		return app_errors.FromDB(err, "Failed to save login")
+	}
+
+	data := map[string]any{
+		"Name":      user.Name,
+		"ExpiresAt": link.ExpiresAt.Format(time.RFC1123),
+	}
+	if method == entities.LoginMethodCode {
+		data["Code"] = secret
+	} else {
+		linkURL, err := url.Parse(u.linkURL)
+		if err != nil {
+			return app_errors.InternalServer("Invalid login link URL", err)
+		}
+		query := linkURL.Query()
+		query.Set("token", secret)
+		linkURL.RawQuery = query.Encode()
+		data["URL"] = linkURL.String()
+	}
+
+	// failing here would tell the caller the email has an account
+	msg, err := mailer.Render(user.Locale, template, user.Email, data)
+	if err == nil {
+		err = u.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("Failed to send %s to %s: %v", template, user.Email, err)
+	}
+	return nil
+}
+
+// Verify link
+func (u *passwordlessUsecaseImpl) VerifyLink(ctx context.Context, input dtos.VerifyMagicLinkRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	link, err := u.loginLinkRepo.GetBySecretHash(ctx, entities.LoginMethodLink, utils.HashToken(input.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.BadRequest("Invalid or expired link", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get login")
+	}
+	if time.Now().After(link.ExpiresAt) {
+		return nil, app_errors.BadRequest("Invalid or expired link", nil)
+	}
+	if subtle.ConstantTimeCompare([]byte(link.DeviceID), []byte(deviceID)) != 1 {
+		return nil, app_errors.Unautherized("Open the link on the device that asked for it", nil)
+	}
+
+	return u.complete(ctx, link, deviceIP, deviceUA, deviceID)
+}
+
+// Verify code
+func (u *passwordlessUsecaseImpl) VerifyCode(ctx context.Context, input dtos.VerifyLoginCodeRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	invalid := app_errors.BadRequest("Invalid or expired code", nil)
+
+	user, err := u.userRepo.GetUserByEmail(ctx, utils.NormalizeEmail(input.Email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+
+	link, err := u.loginLinkRepo.GetLatestByUserID(ctx, user.ID, entities.LoginMethodCode)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get login")
+	}
+	if time.Now().After(link.ExpiresAt) {
+		return nil, invalid
+	}
+	// every guess uses up an attempt before it is checked, so concurrent
+	// guesses can't get past the limit
+	if err := u.loginLinkRepo.ClaimAttempt(ctx, link.ID, loginCodeAttempts); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalid
+		}
+		return nil, app_errors.FromDB(err, "Failed to record attempt")
+	}
+
+	match := subtle.ConstantTimeCompare([]byte(link.SecretHash), []byte(utils.HashToken(input.Code))) == 1
+	sameDevice := subtle.ConstantTimeCompare([]byte(link.DeviceID), []byte(deviceID)) == 1
+	if !match || !sameDevice {
+		return nil, invalid
+	}
+
+	return u.complete(ctx, link, deviceIP, deviceUA, deviceID)
+}
+
+// complete uses up the login and starts a session for its user.
+func (u *passwordlessUsecaseImpl) complete(ctx context.Context, link *entities.LoginLink, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	if err := u.loginLinkRepo.Delete(ctx, link.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.BadRequest("Login already used", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to use login")
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, link.UserID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	// the link or code came to the user's email
+	if user.EmailVerifiedAt == nil {
+		if err := u.userRepo.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+			return nil, app_errors.FromDB(err, "Failed to verify email")
+		}
+	}
+	return u.completeLogin(ctx, user, []string{jwt.AMROTP}, deviceIP, deviceUA, deviceID)
+}
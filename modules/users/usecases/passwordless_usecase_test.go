@@ -0,0 +1,188 @@
+package usecases_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey/passkeytest"
+)
+
+var (
+	linkToken = regexp.MustCompile(`token=([A-Za-z0-9_-]{43})`)
+	codeLine  = regexp.MustCompile(`(?m)^[0-9]{6}$`)
+)
+
+func newPasswordlessFixture(t *testing.T) (*authFixture, *fakeLoginLinkRepo, usecases.PasswordlessUsecase) {
+	t.Helper()
+
+	f := newAuthFixture()
+	f.register(t, "bob@example.com")
+	links := newFakeLoginLinkRepo()
+	return f, links, usecases.NewPasswordlessUsecase(f.users, links, f.passkeys, f.session, f.rp, f.mailer, "https://app.example.com/magic-link")
+}
+
+// lastMatch returns the first submatch of re, or the match if it has no
+// groups, in the last mail sent.
+func lastMatch(t *testing.T, f *authFixture, re *regexp.Regexp) string {
+	t.Helper()
+
+	messages := f.mailer.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no mail sent")
+	}
+	match := re.FindStringSubmatch(messages[len(messages)-1].Text)
+	if match == nil {
+		t.Fatalf("no %s in %q", re, messages[len(messages)-1].Text)
+	}
+	return match[len(match)-1]
+}
+
+func TestMagicLink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("link signs in once on the device that asked", func(t *testing.T) {
+		f, _, uc := newPasswordlessFixture(t)
+		if appErr := uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "Bob@Example.com"}, testDeviceID); appErr != nil {
+			t.Fatalf("RequestLogin: %v", appErr)
+		}
+		token := lastMatch(t, f, linkToken)
+
+		_, appErr := uc.VerifyLink(ctx, dtos.VerifyMagicLinkRequest{Token: token}, testIP, testUA, "other-device")
+		if appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Fatalf("other device: err = %v, want 401", appErr)
+		}
+		if _, appErr := uc.VerifyLink(ctx, dtos.VerifyMagicLinkRequest{Token: token}, testIP, testUA, testDeviceID); appErr != nil {
+			t.Fatalf("VerifyLink: %v", appErr)
+		}
+		if user, _ := f.users.GetUserByEmail(ctx, "bob@example.com"); user.EmailVerifiedAt == nil {
+			t.Error("email not verified by the link")
+		}
+		_, appErr = uc.VerifyLink(ctx, dtos.VerifyMagicLinkRequest{Token: token}, testIP, testUA, testDeviceID)
+		if appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("reused link: err = %v, want 400", appErr)
+		}
+	})
+
+	t.Run("unknown email looks the same", func(t *testing.T) {
+		f, _, uc := newPasswordlessFixture(t)
+		if appErr := uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "nobody@example.com"}, testDeviceID); appErr != nil {
+			t.Fatalf("RequestLogin: %v", appErr)
+		}
+		if msgs := f.mailer.Messages(); len(msgs) != 0 {
+			t.Errorf("mailed %+v", msgs)
+		}
+	})
+
+	t.Run("device is required", func(t *testing.T) {
+		_, _, uc := newPasswordlessFixture(t)
+		appErr := uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com"}, "")
+		if appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("err = %v, want 400", appErr)
+		}
+	})
+
+	t.Run("expired and superseded links are refused", func(t *testing.T) {
+		f, links, uc := newPasswordlessFixture(t)
+		uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com"}, testDeviceID)
+		first := lastMatch(t, f, linkToken)
+		uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com"}, testDeviceID)
+		second := lastMatch(t, f, linkToken)
+		links.expireAll()
+
+		for name, token := range map[string]string{"superseded": first, "expired": second} {
+			_, appErr := uc.VerifyLink(ctx, dtos.VerifyMagicLinkRequest{Token: token}, testIP, testUA, testDeviceID)
+			if appErr == nil || appErr.Code != http.StatusBadRequest {
+				t.Errorf("%s: err = %v, want 400", name, appErr)
+			}
+		}
+	})
+}
+
+func TestLoginCode(t *testing.T) {
+	ctx := context.Background()
+	verify := func(uc usecases.PasswordlessUsecase, code, deviceID string) (*dtos.LoginResponse, int) {
+		resp, appErr := uc.VerifyCode(ctx, dtos.VerifyLoginCodeRequest{Email: "bob@example.com", Code: code}, testIP, testUA, deviceID)
+		if appErr != nil {
+			return nil, appErr.Code
+		}
+		return resp, 0
+	}
+	wrong := func(code string) string {
+		if code == "000000" {
+			return "000001"
+		}
+		return "000000"
+	}
+
+	t.Run("code signs in once", func(t *testing.T) {
+		f, _, uc := newPasswordlessFixture(t)
+		if appErr := uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com", Method: "code"}, testDeviceID); appErr != nil {
+			t.Fatalf("RequestLogin: %v", appErr)
+		}
+		code := lastMatch(t, f, codeLine)
+
+		if _, status := verify(uc, code, "other-device"); status != http.StatusBadRequest {
+			t.Errorf("other device: status = %d, want 400", status)
+		}
+		if resp, status := verify(uc, code, testDeviceID); status != 0 || resp.AccessToken == "" {
+			t.Fatalf("VerifyCode: status = %d", status)
+		}
+		if _, status := verify(uc, code, testDeviceID); status != http.StatusBadRequest {
+			t.Errorf("reused code: status = %d, want 400", status)
+		}
+	})
+
+	t.Run("wrong guesses burn the code", func(t *testing.T) {
+		f, _, uc := newPasswordlessFixture(t)
+		uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com", Method: "code"}, testDeviceID)
+		code := lastMatch(t, f, codeLine)
+
+		for range 5 {
+			if _, status := verify(uc, wrong(code), testDeviceID); status != http.StatusBadRequest {
+				t.Fatalf("wrong code: status = %d, want 400", status)
+			}
+		}
+		if _, status := verify(uc, code, testDeviceID); status != http.StatusBadRequest {
+			t.Errorf("right code after 5 wrong: status = %d, want 400", status)
+		}
+	})
+
+	t.Run("passkey users finish with a passkey", func(t *testing.T) {
+		f, _, uc := newPasswordlessFixture(t)
+		user, _ := f.users.GetUserByEmail(ctx, "bob@example.com")
+		authn := passkeytest.NewAuthenticator(testOrigin)
+		f.registerPasskey(t, authn, user.ID, "Laptop")
+
+		uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com", Method: "code"}, testDeviceID)
+		resp, status := verify(uc, lastMatch(t, f, codeLine), testDeviceID)
+		if status != 0 || resp.AccessToken != "" || resp.PasskeyRequired == nil {
+			t.Fatalf("VerifyCode = %+v, %d, want a passkey ceremony", resp, status)
+		}
+
+		loginResp, appErr := f.finishPasskeyLogin(t, authn, resp.PasskeyRequired)
+		if appErr != nil {
+			t.Fatalf("FinishPasskeyLogin: %v", appErr)
+		}
+		_, amr := authClaims(t, loginResp.AccessToken)
+		if want := []string{jwt.AMROTP, jwt.AMRHardwareKey, jwt.AMRMultiFactor}; strings.Join(amr, " ") != strings.Join(want, " ") {
+			t.Errorf("amr = %v, want %v", amr, want)
+		}
+	})
+
+	t.Run("expired code is refused", func(t *testing.T) {
+		f, links, uc := newPasswordlessFixture(t)
+		uc.RequestLogin(ctx, dtos.MagicLinkRequest{Email: "bob@example.com", Method: "code"}, testDeviceID)
+		code := lastMatch(t, f, codeLine)
+		links.expireAll()
+
+		if _, status := verify(uc, code, testDeviceID); status != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", status)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth"
+)
+
+// SAMLServiceProvider is this service as a SAML identity provider knows
+// it, see samlauth.Provider.
+type SAMLServiceProvider interface {
+	Metadata() ([]byte, error)
+	NewAuthnRequest(relayState string) (*samlauth.AuthnRequest, error)
+	ParseResponse(samlResponse, requestID string) (*samlauth.Profile, error)
+}
+
+// SAMLProvider is a SAML identity provider users can sign in with, and
+// the login page they are sent back to with a code for their tokens.
+type SAMLProvider struct {
+	ServiceProvider SAMLServiceProvider
+	RedirectURL     string
+	// TrustEmail is whether the provider only asserts emails its users
+	// own. SAML has no way to say, so it is set per provider.
+	TrustEmail bool
+}
+
+// SAMLUsecase signs users in with SAML identity providers. Identities are
+// linked to accounts the way SSOUsecase links them, and the name and
+// locale the provider asserts are copied to the user on every login.
+type SAMLUsecase interface {
+	Metadata(ctx context.Context, provider string) ([]byte, *app_errors.AppError)
+	StartLogin(ctx context.Context, provider string) (*samlauth.AuthnRequest, *app_errors.AppError)
+	// StartLink starts a login that links the identity to the signed in
+	// user, as SSOUsecase.StartLink.
+	StartLink(ctx context.Context, provider string, userID uuid.UUID) (*dtos.SSOLinkResponse, *app_errors.AppError)
+	// ConsumeAssertion verifies the provider's response and returns the
+	// login page URL, with the code to exchange for tokens.
+	ConsumeAssertion(ctx context.Context, provider string, input dtos.SAMLResponseRequest) (string, *app_errors.AppError)
+	ExchangeCode(ctx context.Context, provider string, input dtos.SAMLTokenRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
+}
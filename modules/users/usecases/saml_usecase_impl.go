@@ -0,0 +1,235 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth"
+	"github.com/natchaphonbw/usermanagement/pkg/sso"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// samlCodeTTL is how long the login page has to exchange the code.
+const samlCodeTTL = time.Minute
+
+type samlUsecaseImpl struct {
+	identityLinker
+	secondFactor
+	providers     map[string]SAMLProvider
+	samlLoginRepo repositories.SAMLLoginRepository
+}
+
+func NewSAMLUsecase(providers map[string]SAMLProvider, samlLoginRepo repositories.SAMLLoginRepository, identityRepo repositories.IdentityRepository, userRepo repositories.UserRepository, eventRepo repositories.EventRepository, passkeyRepo repositories.PasskeyRepository, sessionUsecase SessionUsecase, relyingParty PasskeyRelyingParty, txManager databases.TxManager) SAMLUsecase {
+	return &samlUsecaseImpl{
+		identityLinker: identityLinker{
+			identityRepo: identityRepo,
+			userRepo:     userRepo,
+			eventRepo:    eventRepo,
+			txManager:    txManager,
+		},
+		secondFactor: secondFactor{
+			passkeyRepo:    passkeyRepo,
+			sessionUsecase: sessionUsecase,
+			relyingParty:   relyingParty,
+		},
+		providers:     providers,
+		samlLoginRepo: samlLoginRepo,
+	}
+}
+
+// Metadata
+func (u *samlUsecaseImpl) Metadata(ctx context.Context, provider string) ([]byte, *app_errors.AppError) {
+	idp, ok := u.providers[provider]
+	if !ok {
+		return nil, app_errors.NotFound("Unknown identity provider", nil)
+	}
+
+	metadata, err := idp.ServiceProvider.Metadata()
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to build metadata", err)
+	}
+	return metadata, nil
+}
+
+// Start login
+func (u *samlUsecaseImpl) StartLogin(ctx context.Context, provider string) (*samlauth.AuthnRequest, *app_errors.AppError) {
+	return u.startLogin(ctx, provider, nil)
+}
+
+// Start link
+func (u *samlUsecaseImpl) StartLink(ctx context.Context, provider string, userID uuid.UUID) (*dtos.SSOLinkResponse, *app_errors.AppError) {
+	authnRequest, appErr := u.startLogin(ctx, provider, &userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return &dtos.SSOLinkResponse{URL: authnRequest.RedirectURL, Form: string(authnRequest.Form)}, nil
+}
+
+// startLogin saves the login state and builds the request to the
+// provider.
+func (u *samlUsecaseImpl) startLogin(ctx context.Context, provider string, linkUserID *uuid.UUID) (*samlauth.AuthnRequest, *app_errors.AppError) {
+	idp, ok := u.providers[provider]
+	if !ok {
+		return nil, app_errors.NotFound("Unknown identity provider", nil)
+	}
+
+	relayState, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to generate relay state", err)
+	}
+	authnRequest, err := idp.ServiceProvider.NewAuthnRequest(relayState)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to build authentication request", err)
+	}
+
+	relayStateHash := utils.HashToken(relayState)
+	login := &entities.SAMLLogin{
+		ID:             uuid.New(),
+		Provider:       provider,
+		RelayStateHash: &relayStateHash,
+		RequestID:      authnRequest.ID,
+		LinkUserID:     linkUserID,
+		ExpiresAt:      time.Now().Add(ssoLoginTTL),
+		Created_at:     time.Now(),
+	}
+	if err := u.samlLoginRepo.Insert(ctx, login); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to save login state")
+	}
+
+	return authnRequest, nil
+}
+
+// Consume assertion
+func (u *samlUsecaseImpl) ConsumeAssertion(ctx context.Context, provider string, input dtos.SAMLResponseRequest) (string, *app_errors.AppError) {
+	idp, ok := u.providers[provider]
+	if !ok {
+		return "", app_errors.NotFound("Unknown identity provider", nil)
+	}
+
+	login, err := u.samlLoginRepo.ConsumeRelayState(ctx, utils.HashToken(input.RelayState))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", app_errors.BadRequest("Invalid or expired login", err)
+		}
+		return "", app_errors.FromDB(err, "Failed to get login state")
+	}
+	if login.Provider != provider || time.Now().After(login.ExpiresAt) {
+		return "", app_errors.BadRequest("Invalid or expired login", nil)
+	}
+
+	profile, err := idp.ServiceProvider.ParseResponse(input.SAMLResponse, login.RequestID)
+	if err != nil {
+		log.Printf("Sign in with %s failed: %v", provider, err)
+		return "", app_errors.Unautherized("Sign in with the identity provider failed", err)
+	}
+
+	identity := &sso.Identity{
+		Subject:       profile.Subject,
+		Email:         profile.Email,
+		EmailVerified: idp.TrustEmail,
+		Name:          profile.Name,
+	}
+	var user *entities.User
+	var appErr *app_errors.AppError
+	if login.LinkUserID != nil {
+		user, appErr = u.linkIdentity(ctx, provider, identity, *login.LinkUserID)
+	} else {
+		user, appErr = u.resolveUser(ctx, provider, identity)
+	}
+	if appErr != nil {
+		return "", appErr
+	}
+	if appErr := u.applyProfile(ctx, user, profile); appErr != nil {
+		return "", appErr
+	}
+
+	code, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", app_errors.InternalServer("Failed to generate code", err)
+	}
+	if err := u.samlLoginRepo.SetCode(ctx, login.ID, utils.HashToken(code), user.ID, time.Now().Add(samlCodeTTL)); err != nil {
+		return "", app_errors.FromDB(err, "Failed to save login state")
+	}
+
+	redirect, err := url.Parse(idp.RedirectURL)
+	if err != nil {
+		return "", app_errors.InternalServer("Invalid redirect URL", err)
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	redirect.RawQuery = query.Encode()
+	return redirect.String(), nil
+}
+
+// Exchange code
+func (u *samlUsecaseImpl) ExchangeCode(ctx context.Context, provider string, input dtos.SAMLTokenRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	if _, ok := u.providers[provider]; !ok {
+		return nil, app_errors.NotFound("Unknown identity provider", nil)
+	}
+
+	login, err := u.samlLoginRepo.ConsumeCode(ctx, utils.HashToken(input.Code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.BadRequest("Invalid or expired code", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get login state")
+	}
+	if login.Provider != provider || login.UserID == nil || time.Now().After(login.ExpiresAt) {
+		return nil, app_errors.BadRequest("Invalid or expired code", nil)
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, *login.UserID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	return u.completeLogin(ctx, user, []string{jwt.AMRFederated}, deviceIP, deviceUA, deviceID)
+}
+
+// applyProfile copies the name and locale the provider asserted to the
+// user, leaving those it didn't assert alone.
+func (u *samlUsecaseImpl) applyProfile(ctx context.Context, user *entities.User, profile *samlauth.Profile) *app_errors.AppError {
+	changed := false
+	if profile.Name != "" {
+		if name := displayName(profile.Name, user.Email); name != user.Name {
+			user.Name, changed = name, true
+		}
+	}
+	if locale := supportedLocale(profile.Locale); locale != "" && locale != user.Locale {
+		user.Locale, changed = locale, true
+	}
+	if !changed {
+		return nil
+	}
+
+	user.Updated_at = time.Now()
+	return inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		if _, err := u.userRepo.UpdateUserByID(ctx, user.ID, user); err != nil {
+			return app_errors.FromDB(err, "Failed to update user")
+		}
+		return raiseUserEvent(ctx, u.eventRepo, entities.EventUserUpdated, user)
+	})
+}
+
+// supportedLocale returns the locale of a language tag such as th-TH if
+// emails can be sent in it, or "".
+func supportedLocale(tag string) string {
+	language, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	switch language = strings.ToLower(strings.TrimSpace(language)); language {
+	case "en", "th":
+		return language
+	}
+	return ""
+}
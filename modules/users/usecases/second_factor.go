@@ -0,0 +1,91 @@
+package usecases
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey"
+)
+
+// secondFactor ends every login. Users with passkeys must also use one
+// before they get a session, however they logged in: otherwise anyone
+// with their mailbox or upstream account could log in and remove them.
+type secondFactor struct {
+	passkeyRepo    repositories.PasskeyRepository
+	sessionUsecase SessionUsecase
+	relyingParty   PasskeyRelyingParty
+}
+
+// completeLogin issues tokens for a user who logged in with amr, or
+// returns the passkey ceremony they have to finish first.
+func (s *secondFactor) completeLogin(ctx context.Context, user *entities.User, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	if appErr := checkNotLocked(user); appErr != nil {
+		return nil, appErr
+	}
+
+	passkeys, err := s.passkeyRepo.ListByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get passkeys")
+	}
+	if len(passkeys) > 0 {
+		passkeyUser := toPasskeyUser(user, passkeys)
+		ceremony, appErr := s.beginPasskeyCeremony(ctx, entities.PasskeyCeremonyLogin, &user.ID, amr, func() (*passkey.Ceremony, error) {
+			return s.relyingParty.BeginLogin(&passkeyUser)
+		})
+		if appErr != nil {
+			return nil, appErr
+		}
+		return &dtos.LoginResponse{PasskeyRequired: ceremony}, nil
+	}
+
+	tokenPair, pairErr := s.sessionUsecase.IssueTokenPair(ctx, user.ID, amr, deviceIP, deviceUA, deviceID)
+	if pairErr != nil {
+		return nil, app_errors.InternalServer("Failed to issue token pair", pairErr).WithDetails(pairErr.Details)
+	}
+	return &dtos.LoginResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+	}, nil
+}
+
+// checkNotLocked refuses users an admin has locked, see
+// UserUsecase.LockUser.
+func checkNotLocked(user *entities.User) *app_errors.AppError {
+	if user.LockedAt != nil {
+		return app_errors.New(http.StatusForbidden, "Account is locked", nil)
+	}
+	return nil
+}
+
+// beginPasskeyCeremony runs begin and saves the ceremony's session until
+// the browser answers. amr is how the user logged in before a second
+// factor ceremony.
+func (s *secondFactor) beginPasskeyCeremony(ctx context.Context, purpose string, userID *uuid.UUID, amr []string, begin func() (*passkey.Ceremony, error)) (*dtos.PasskeyCeremonyResponse, *app_errors.AppError) {
+	started, err := begin()
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to start passkey ceremony", err)
+	}
+
+	ceremony := &entities.PasskeyCeremony{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Purpose:    purpose,
+		AMR:        strings.Join(amr, " "),
+		Session:    started.Session,
+		ExpiresAt:  time.Now().Add(passkeyCeremonyTTL),
+		Created_at: time.Now(),
+	}
+	if err := s.passkeyRepo.InsertCeremony(ctx, ceremony); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to save passkey ceremony")
+	}
+
+	return &dtos.PasskeyCeremonyResponse{CeremonyID: ceremony.ID, Options: started.Options}, nil
+}
@@ -10,6 +10,15 @@ import (
 )
 
 type SessionUsecase interface {
-	IssueTokenPair(ctx context.Context, userID uuid.UUID, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError)
+	// IssueTokenPair starts a session for a user who just authenticated
+	// with the amr methods.
+	IssueTokenPair(ctx context.Context, userID uuid.UUID, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError)
 	Refresh(ctx context.Context, refreshToken, deviceIP, deviceUA, deviceID string, sessionID uuid.UUID) (*dtos.TokenPair, *app_errors.AppError)	
+	// Reauthenticate replaces the session with one whose user has just
+	// authenticated again.
+	Reauthenticate(ctx context.Context, sessionID uuid.UUID, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError)
+
+	// VerifySession implements middlewares.SessionVerifier, the error is
+	// an *app_errors.AppError.
+	VerifySession(ctx context.Context, userID, sessionID uuid.UUID) error
 }
@@ -3,6 +3,7 @@ package usecases
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,27 +11,37 @@ import (
 	"github.com/natchaphonbw/usermanagement/modules/users/entities"
 	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
 	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
 	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
 	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
 	"gorm.io/gorm"
 )
 
 type SessionUsecaseImpl struct {
-	repo repositories.SessionRepository
+	repo      repositories.SessionRepository
+	userRepo  repositories.UserRepository
+	txManager databases.TxManager
 }
 
-func NewSessionUsecase(repo repositories.SessionRepository) SessionUsecase {
+func NewSessionUsecase(repo repositories.SessionRepository, userRepo repositories.UserRepository, txManager databases.TxManager) SessionUsecase {
 	return &SessionUsecaseImpl{
-		repo: repo,
+		repo:      repo,
+		userRepo:  userRepo,
+		txManager: txManager,
 	}
 }
 
-func (u *SessionUsecaseImpl) IssueTokenPair(ctx context.Context, userID uuid.UUID, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError) {
+func (u *SessionUsecaseImpl) IssueTokenPair(ctx context.Context, userID uuid.UUID, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError) {
+	return u.issueTokenPair(ctx, userID, time.Now(), amr, deviceIP, deviceUA, deviceID)
+}
+
+func (u *SessionUsecaseImpl) issueTokenPair(ctx context.Context, userID uuid.UUID, authTime time.Time, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError) {
 	// gen sessionID
 	sessionID := uuid.New()
 
 	// gen tokens
-	accessToken, err := jwt.GenerateAccessToken(userID.String(), sessionID.String())
+	accessToken, err := jwt.GenerateAccessToken(userID.String(), sessionID.String(), authTime, amr)
 	if err != nil {
 		return nil, app_errors.InternalServer("Failed to generate token", err)
 	}
@@ -56,6 +67,8 @@ func (u *SessionUsecaseImpl) IssueTokenPair(ctx context.Context, userID uuid.UUI
 		IssuedAt:    issuedAt,
 		ExpiresAt:   expiresAt,
 		Revoked:     false,
+		AuthTime:    &authTime,
+		AMR:         strings.Join(amr, " "),
 	}
 
 	// validate
@@ -64,7 +77,7 @@ func (u *SessionUsecaseImpl) IssueTokenPair(ctx context.Context, userID uuid.UUI
 	}
 	// save session
 	if err := u.repo.Insert(ctx, session); err != nil {
-		return nil, app_errors.InternalServer("Failed to save refresh token", err)
+		return nil, app_errors.FromDB(err, "Failed to save refresh token")
 	}
 
 	return &dtos.TokenPair{
@@ -77,7 +90,10 @@ func (u *SessionUsecaseImpl) Refresh(ctx context.Context, refreshToken, deviceIP
 	// get from db
 	session, err := u.repo.GetByID(ctx, sessionID)
 	if err != nil {
-		return nil, app_errors.Unautherized("Refresh token not found", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.Unautherized("Refresh token not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get refresh token")
 	}
 
 	// check revoked or expired
@@ -89,7 +105,10 @@ func (u *SessionUsecaseImpl) Refresh(ctx context.Context, refreshToken, deviceIP
 	}
 
 	// compare
-	match, verifyErr := jwt.VerifyRefreshTokenHash(refreshToken, session.HashedToken)
+	match, verifyErr := jwt.VerifyRefreshTokenHash(ctx, refreshToken, session.HashedToken)
+	if errors.Is(verifyErr, utils.ErrHashingBusy) {
+		return nil, hashingBusy(verifyErr)
+	}
 	if verifyErr != nil || !match {
 		return nil, app_errors.Unautherized("Refresh token hash mismatch", verifyErr)
 	}
@@ -99,15 +118,81 @@ func (u *SessionUsecaseImpl) Refresh(ctx context.Context, refreshToken, deviceIP
 		return nil, app_errors.Unautherized("Device info mismatch", nil)
 	}
 
-	// revoke old
-	if revokeErr := u.repo.MarkRevoked(ctx, sessionID); revokeErr != nil {
-		if errors.Is(revokeErr, gorm.ErrRecordNotFound) {
-			return nil, app_errors.NotFound("Refresh token not found", revokeErr)
+	// the user hasn't authenticated again, the new session keeps when
+	// they last did
+	authTime := session.IssuedAt
+	if session.AuthTime != nil {
+		authTime = *session.AuthTime
+	}
+	return u.replace(ctx, session, authTime, strings.Fields(session.AMR), deviceIP, deviceUA, deviceID)
+}
+
+func (u *SessionUsecaseImpl) Reauthenticate(ctx context.Context, sessionID uuid.UUID, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError) {
+	session, err := u.repo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.Unautherized("Session not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get session")
+	}
+
+	if session.Revoked {
+		return nil, app_errors.Unautherized("Session has been revoked", nil)
+	}
+	if session.DeviceID != deviceID || session.DeviceUA != deviceUA {
+		return nil, app_errors.Unautherized("Device info mismatch", nil)
+	}
+
+	return u.replace(ctx, session, time.Now(), amr, deviceIP, deviceUA, deviceID)
+}
+
+// VerifySession
+// A JWT outlives logout and locking until it expires, so every request
+// checks its session is still live and its user isn't locked.
+func (u *SessionUsecaseImpl) VerifySession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	session, err := u.repo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.Unautherized("Session not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to get session")
+	}
+	if session.UserID != userID || session.Revoked || time.Now().After(session.ExpiresAt) {
+		return app_errors.Unautherized("Session has been revoked", nil)
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.Unautherized("User not found", err)
 		}
-		return nil, app_errors.InternalServer("Failed to revoke old refresh token", revokeErr)
+		return app_errors.FromDB(err, "Failed to get user")
+	}
+	if appErr := checkNotLocked(user); appErr != nil {
+		return appErr
 	}
+	return nil
+}
+
+// replace revokes the session and issues a new one atomically, so a
+// failure keeps the old session.
+func (u *SessionUsecaseImpl) replace(ctx context.Context, session *entities.Session, authTime time.Time, amr []string, deviceIP, deviceUA, deviceID string) (*dtos.TokenPair, *app_errors.AppError) {
+	var tokenPair *dtos.TokenPair
+	txErr := inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		if revokeErr := u.repo.MarkRevoked(ctx, session.ID); revokeErr != nil {
+			if errors.Is(revokeErr, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("Refresh token not found", revokeErr)
+			}
+			return app_errors.FromDB(revokeErr, "Failed to revoke old refresh token")
+		}
 
-	// gen new
-	return u.IssueTokenPair(ctx, session.UserID, deviceIP, deviceUA, deviceID)
+		var issueErr *app_errors.AppError
+		tokenPair, issueErr = u.issueTokenPair(ctx, session.UserID, authTime, amr, deviceIP, deviceUA, deviceID)
+		return issueErr
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
 
+	return tokenPair, nil
 }
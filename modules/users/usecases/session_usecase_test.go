@@ -0,0 +1,240 @@
+package usecases_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+)
+
+const (
+	testIP       = "127.0.0.1"
+	testUA       = "test-agent"
+	testDeviceID = "device-1"
+)
+
+// issueSession issues a token pair and returns it with its session ID.
+func issueSession(t *testing.T, uc usecases.SessionUsecase, userID uuid.UUID) (string, uuid.UUID) {
+	t.Helper()
+
+	pair, appErr := uc.IssueTokenPair(context.Background(), userID, []string{jwt.AMRPassword}, testIP, testUA, testDeviceID)
+	if appErr != nil {
+		t.Fatalf("IssueTokenPair: %v", appErr)
+	}
+
+	claims, err := jwt.VerifyRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("VerifyRefreshToken: %v", err)
+	}
+
+	return pair.RefreshToken, uuid.MustParse(claims.SessionID)
+}
+
+func TestIssueTokenPair(t *testing.T) {
+	repo := newFakeSessionRepo()
+	uc := usecases.NewSessionUsecase(repo, newFakeUserRepo(), &fakeTxManager{})
+	userID := uuid.New()
+
+	_, sessionID := issueSession(t, uc, userID)
+
+	session, err := repo.GetByID(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if session.UserID != userID || session.DeviceID != testDeviceID || session.Revoked {
+		t.Errorf("unexpected session %+v", session)
+	}
+
+	repo.insertErr = context.DeadlineExceeded
+	if _, appErr := uc.IssueTokenPair(context.Background(), userID, []string{jwt.AMRPassword}, testIP, testUA, testDeviceID); appErr == nil || appErr.Code != http.StatusInternalServerError {
+		t.Errorf("insert failure: got %v, want 500", appErr)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(repo *fakeSessionRepo, sessionID uuid.UUID)
+		token    func(refreshToken string) string
+		deviceID string
+		wantCode int
+	}{
+		{name: "rotates session"},
+		{
+			name: "revoked",
+			setup: func(repo *fakeSessionRepo, sessionID uuid.UUID) {
+				_ = repo.MarkRevoked(context.Background(), sessionID)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "expired",
+			setup: func(repo *fakeSessionRepo, sessionID uuid.UUID) {
+				repo.mutate = func(s *entities.Session) { s.ExpiresAt = time.Now().Add(-time.Minute) }
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "hash mismatch",
+			token:    func(string) string { return "not-the-token" },
+			wantCode: http.StatusUnauthorized,
+		},
+		{name: "device mismatch", deviceID: "other-device", wantCode: http.StatusUnauthorized},
+		{
+			name: "concurrent refresh",
+			setup: func(repo *fakeSessionRepo, sessionID uuid.UUID) {
+				repo.revokeErr = gorm.ErrRecordNotFound
+			},
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeSessionRepo()
+			tx := &fakeTxManager{}
+			uc := usecases.NewSessionUsecase(repo, newFakeUserRepo(), tx)
+
+			refreshToken, sessionID := issueSession(t, uc, uuid.New())
+			if tt.setup != nil {
+				tt.setup(repo, sessionID)
+			}
+			if tt.token != nil {
+				refreshToken = tt.token(refreshToken)
+			}
+			deviceID := testDeviceID
+			if tt.deviceID != "" {
+				deviceID = tt.deviceID
+			}
+
+			pair, appErr := uc.Refresh(ctx, refreshToken, testIP, testUA, deviceID, sessionID)
+			if tt.wantCode != 0 {
+				if appErr == nil || appErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("Refresh: %v", appErr)
+			}
+			if pair.AccessToken == "" || pair.RefreshToken == "" {
+				t.Fatal("empty token pair")
+			}
+			if tx.calls != 1 {
+				t.Errorf("transaction calls = %d, want 1", tx.calls)
+			}
+
+			old, _ := repo.GetByID(ctx, sessionID)
+			if !old.Revoked {
+				t.Error("old session not revoked")
+			}
+		})
+	}
+}
+
+// authClaims returns the auth_time and amr claims of an access token.
+func authClaims(t *testing.T, accessToken string) (time.Time, []string) {
+	t.Helper()
+
+	claims, err := jwt.VerifyAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	if claims.AuthTime == nil {
+		t.Fatal("no auth_time claim")
+	}
+	return claims.AuthTime.Time, claims.AMR
+}
+
+func TestAuthTime(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeSessionRepo()
+	uc := usecases.NewSessionUsecase(repo, newFakeUserRepo(), &fakeTxManager{})
+
+	refreshToken, sessionID := issueSession(t, uc, uuid.New())
+
+	// a login an hour ago
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	repo.mutate = func(s *entities.Session) { s.AuthTime = &authTime }
+
+	t.Run("kept by refresh", func(t *testing.T) {
+		pair, appErr := uc.Refresh(ctx, refreshToken, testIP, testUA, testDeviceID, sessionID)
+		if appErr != nil {
+			t.Fatalf("Refresh: %v", appErr)
+		}
+		got, amr := authClaims(t, pair.AccessToken)
+		if !got.Equal(authTime) || len(amr) != 1 || amr[0] != jwt.AMRPassword {
+			t.Errorf("auth_time = %v, amr = %v, want %v, [pwd]", got, amr, authTime)
+		}
+
+		claims, _ := jwt.VerifyRefreshToken(pair.RefreshToken)
+		sessionID = uuid.MustParse(claims.SessionID)
+	})
+
+	t.Run("reset by reauthentication", func(t *testing.T) {
+		repo.mutate = nil
+
+		if _, appErr := uc.Reauthenticate(ctx, sessionID, []string{jwt.AMRHardwareKey}, testIP, testUA, "other-device"); appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Errorf("device mismatch: got %v, want 401", appErr)
+		}
+
+		pair, appErr := uc.Reauthenticate(ctx, sessionID, []string{jwt.AMRHardwareKey}, testIP, testUA, testDeviceID)
+		if appErr != nil {
+			t.Fatalf("Reauthenticate: %v", appErr)
+		}
+		got, amr := authClaims(t, pair.AccessToken)
+		if time.Since(got) > time.Minute || len(amr) != 1 || amr[0] != jwt.AMRHardwareKey {
+			t.Errorf("auth_time = %v, amr = %v, want now, [hwk]", got, amr)
+		}
+
+		old, _ := repo.GetByID(ctx, sessionID)
+		if !old.Revoked {
+			t.Error("old session not revoked")
+		}
+		if _, appErr := uc.Reauthenticate(ctx, sessionID, []string{jwt.AMRPassword}, testIP, testUA, testDeviceID); appErr == nil || appErr.Code != http.StatusUnauthorized {
+			t.Errorf("revoked session: got %v, want 401", appErr)
+		}
+	})
+}
+
+func TestVerifySession(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeSessionRepo()
+	users := newFakeUserRepo()
+	uc := usecases.NewSessionUsecase(repo, users, &fakeTxManager{})
+	userID := uuid.New()
+	if err := users.CreateUser(ctx, &entities.User{ID: userID, Email: "bob@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	_, sessionID := issueSession(t, uc, userID)
+
+	if err := uc.VerifySession(ctx, userID, sessionID); err != nil {
+		t.Fatalf("VerifySession: %v", err)
+	}
+	if err := uc.VerifySession(ctx, uuid.New(), sessionID); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("VerifySession(another user) = %v, want 401", err)
+	}
+	if err := uc.VerifySession(ctx, userID, uuid.New()); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("VerifySession(unknown session) = %v, want 401", err)
+	}
+
+	now := time.Now()
+	users.SetLockedAt(ctx, userID, &now)
+	if err := uc.VerifySession(ctx, userID, sessionID); !isStatus(err, http.StatusForbidden) {
+		t.Errorf("VerifySession(locked user) = %v, want 403", err)
+	}
+	users.SetLockedAt(ctx, userID, nil)
+
+	_ = repo.MarkRevoked(ctx, sessionID)
+	if err := uc.VerifySession(ctx, userID, sessionID); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("VerifySession(revoked) = %v, want 401", err)
+	}
+}
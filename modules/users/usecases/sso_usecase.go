@@ -0,0 +1,32 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/sso"
+)
+
+// IdentityProvider is an upstream OpenID Connect provider, see
+// sso.Provider.
+type IdentityProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (*sso.Identity, error)
+}
+
+// SSOUsecase signs users in with upstream identity providers. A first
+// login links the identity to the account with the same email, if both
+// the provider and the account have verified it, or creates the account.
+type SSOUsecase interface {
+	// StartLogin returns the provider's URL to send the browser to.
+	StartLogin(ctx context.Context, provider string) (string, *app_errors.AppError)
+	// StartLink starts a login that links the identity to the signed in
+	// user, whatever its email.
+	StartLink(ctx context.Context, provider string, userID uuid.UUID) (*dtos.SSOLinkResponse, *app_errors.AppError)
+	CompleteLogin(ctx context.Context, provider string, input dtos.SSOCallbackRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]*dtos.IdentityResponse, *app_errors.AppError)
+	UnlinkIdentity(ctx context.Context, userID, identityID uuid.UUID) *app_errors.AppError
+}
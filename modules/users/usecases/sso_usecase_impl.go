@@ -0,0 +1,310 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/sso"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// ssoLoginTTL is how long the user has to sign in at the provider.
+const ssoLoginTTL = 10 * time.Minute
+
+type ssoUsecaseImpl struct {
+	identityLinker
+	secondFactor
+	providers map[string]IdentityProvider
+}
+
+func NewSSOUsecase(providers map[string]IdentityProvider, identityRepo repositories.IdentityRepository, userRepo repositories.UserRepository, eventRepo repositories.EventRepository, passkeyRepo repositories.PasskeyRepository, sessionUsecase SessionUsecase, relyingParty PasskeyRelyingParty, txManager databases.TxManager) SSOUsecase {
+	return &ssoUsecaseImpl{
+		identityLinker: identityLinker{
+			identityRepo: identityRepo,
+			userRepo:     userRepo,
+			eventRepo:    eventRepo,
+			txManager:    txManager,
+		},
+		secondFactor: secondFactor{
+			passkeyRepo:    passkeyRepo,
+			sessionUsecase: sessionUsecase,
+			relyingParty:   relyingParty,
+		},
+		providers: providers,
+	}
+}
+
+// identityLinker signs in users with the identities of upstream
+// providers, OpenID Connect and SAML alike.
+type identityLinker struct {
+	identityRepo repositories.IdentityRepository
+	userRepo     repositories.UserRepository
+	eventRepo    repositories.EventRepository
+	txManager    databases.TxManager
+}
+
+// Start login
+func (u *ssoUsecaseImpl) StartLogin(ctx context.Context, provider string) (string, *app_errors.AppError) {
+	return u.startLogin(ctx, provider, nil)
+}
+
+// Start link
+func (u *ssoUsecaseImpl) StartLink(ctx context.Context, provider string, userID uuid.UUID) (*dtos.SSOLinkResponse, *app_errors.AppError) {
+	authURL, appErr := u.startLogin(ctx, provider, &userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return &dtos.SSOLinkResponse{URL: authURL}, nil
+}
+
+// startLogin saves the login state and returns the provider's URL.
+func (u *ssoUsecaseImpl) startLogin(ctx context.Context, provider string, linkUserID *uuid.UUID) (string, *app_errors.AppError) {
+	idp, ok := u.providers[provider]
+	if !ok {
+		return "", app_errors.NotFound("Unknown identity provider", nil)
+	}
+
+	state, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", app_errors.InternalServer("Failed to generate state", err)
+	}
+	nonce, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", app_errors.InternalServer("Failed to generate nonce", err)
+	}
+	login := &entities.SSOLoginState{
+		ID:           uuid.New(),
+		Provider:     provider,
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(ssoLoginTTL),
+		Created_at:   time.Now(),
+	}
+
+	authURL, err := idp.AuthCodeURL(ctx, state, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		return "", app_errors.ServiceUnavailable("Identity provider is unavailable", err)
+	}
+	if err := u.identityRepo.InsertLoginState(ctx, login); err != nil {
+		return "", app_errors.FromDB(err, "Failed to save login state")
+	}
+
+	return authURL, nil
+}
+
+// Complete login
+func (u *ssoUsecaseImpl) CompleteLogin(ctx context.Context, provider string, input dtos.SSOCallbackRequest, deviceIP, deviceUA, deviceID string) (*dtos.LoginResponse, *app_errors.AppError) {
+	idp, ok := u.providers[provider]
+	if !ok {
+		return nil, app_errors.NotFound("Unknown identity provider", nil)
+	}
+
+	login, err := u.identityRepo.ConsumeLoginState(ctx, utils.HashToken(input.State))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.BadRequest("Invalid or expired login", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get login state")
+	}
+	if login.Provider != provider || time.Now().After(login.ExpiresAt) {
+		return nil, app_errors.BadRequest("Invalid or expired login", nil)
+	}
+
+	identity, err := idp.Exchange(ctx, input.Code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("Sign in with %s failed: %v", provider, err)
+		return nil, app_errors.Unautherized("Sign in with the identity provider failed", err)
+	}
+
+	var user *entities.User
+	var appErr *app_errors.AppError
+	if login.LinkUserID != nil {
+		user, appErr = u.linkIdentity(ctx, provider, identity, *login.LinkUserID)
+	} else {
+		user, appErr = u.resolveUser(ctx, provider, identity)
+	}
+	if appErr != nil {
+		return nil, appErr
+	}
+	return u.completeLogin(ctx, user, []string{jwt.AMRFederated}, deviceIP, deviceUA, deviceID)
+}
+
+// List identities
+func (u *ssoUsecaseImpl) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*dtos.IdentityResponse, *app_errors.AppError) {
+	identities, err := u.identityRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get identities")
+	}
+	return dtos.FromIdentityEntities(identities), nil
+}
+
+// Unlink identity
+func (u *ssoUsecaseImpl) UnlinkIdentity(ctx context.Context, userID, identityID uuid.UUID) *app_errors.AppError {
+	user, err := u.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return app_errors.FromDB(err, "Failed to get user")
+	}
+	// an account created by a provider has no password to fall back on
+	if user.PasswordHash == "" {
+		identities, err := u.identityRepo.ListByUserID(ctx, userID)
+		if err != nil {
+			return app_errors.FromDB(err, "Failed to get identities")
+		}
+		if len(identities) == 1 && identities[0].ID == identityID {
+			return app_errors.Conflict("Cannot unlink the only way to sign in", nil)
+		}
+	}
+
+	if err := u.identityRepo.Delete(ctx, identityID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Identity not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to unlink identity")
+	}
+	return nil
+}
+
+// resolveUser returns the user the identity signs in as. An identity seen
+// before signs in as its user. A new one creates a user, or is linked to
+// the user with its email if both the provider and the user have verified
+// it: otherwise whoever registered the email first, or claimed it at the
+// provider, could take over the other's account. Unverified users have to
+// sign in and link the identity themselves, see linkIdentity.
+func (u *identityLinker) resolveUser(ctx context.Context, provider string, identity *sso.Identity) (*entities.User, *app_errors.AppError) {
+	email := utils.NormalizeEmail(identity.Email)
+	now := time.Now()
+
+	linked, err := u.identityRepo.GetByProviderSubject(ctx, provider, identity.Subject)
+	if err == nil {
+		if err := u.identityRepo.MarkLogin(ctx, linked.ID, now, email); err != nil {
+			log.Printf("Failed to record login with identity %s: %v", linked.ID, err)
+		}
+		user, err := u.userRepo.GetUserByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, app_errors.FromDB(err, "Failed to get user")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, app_errors.FromDB(err, "Failed to get identity")
+	}
+
+	if email == "" || !identity.EmailVerified {
+		return nil, app_errors.New(http.StatusForbidden, "The identity provider has not verified your email", nil)
+	}
+
+	var user *entities.User
+	appErr := inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		var err error
+		user, err = u.userRepo.GetUserByEmail(ctx, email)
+		switch {
+		case err == nil:
+			if user.EmailVerifiedAt == nil {
+				return app_errors.Conflict("An account with this email already exists, sign in to it to link the identity provider", nil)
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = &entities.User{
+				ID:              uuid.New(),
+				Name:            displayName(identity.Name, email),
+				Email:           email,
+				Locale:          localeOrDefault(""),
+				Role:            entities.RoleUser,
+				Source:          entities.SourceLocal,
+				EmailVerifiedAt: &now,
+				Created_at:      now,
+				Updated_at:      now,
+			}
+			if err := u.userRepo.CreateUser(ctx, user); err != nil {
+				return app_errors.FromDB(err, "Failed to create user")
+			}
+			if appErr := raiseUserEvent(ctx, u.eventRepo, entities.EventUserCreated, user); appErr != nil {
+				return appErr
+			}
+		default:
+			return app_errors.FromDB(err, "Failed to get user")
+		}
+
+		return u.insertIdentity(ctx, provider, identity, user.ID, now)
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+	return user, nil
+}
+
+// linkIdentity links the identity to the signed in user who started the
+// login, whatever its email.
+func (u *identityLinker) linkIdentity(ctx context.Context, provider string, identity *sso.Identity, userID uuid.UUID) (*entities.User, *app_errors.AppError) {
+	now := time.Now()
+
+	linked, err := u.identityRepo.GetByProviderSubject(ctx, provider, identity.Subject)
+	switch {
+	case err == nil && linked.UserID != userID:
+		return nil, app_errors.Conflict("The identity is linked to another account", nil)
+	case err == nil:
+		if err := u.identityRepo.MarkLogin(ctx, linked.ID, now, utils.NormalizeEmail(identity.Email)); err != nil {
+			log.Printf("Failed to record login with identity %s: %v", linked.ID, err)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if appErr := u.insertIdentity(ctx, provider, identity, userID, now); appErr != nil {
+			return nil, appErr
+		}
+	default:
+		return nil, app_errors.FromDB(err, "Failed to get identity")
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get user")
+	}
+	return user, nil
+}
+
+// insertIdentity links the identity to the user.
+func (u *identityLinker) insertIdentity(ctx context.Context, provider string, identity *sso.Identity, userID uuid.UUID, now time.Time) *app_errors.AppError {
+	err := u.identityRepo.Insert(ctx, &entities.Identity{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Provider:    provider,
+		Subject:     identity.Subject,
+		Email:       utils.NormalizeEmail(identity.Email),
+		LastLoginAt: &now,
+		Created_at:  now,
+	})
+	if err != nil {
+		if errors.Is(err, databases.ErrDuplicateKey) {
+			return app_errors.Conflict("The identity is linked to another account", err)
+		}
+		return app_errors.FromDB(err, "Failed to link identity")
+	}
+	return nil
+}
+
+// displayName is the provider's name for the user, or the start of their
+// email if it has none.
+func displayName(name, email string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	if len([]rune(name)) > 100 {
+		name = string([]rune(name)[:100])
+	}
+	return name
+}
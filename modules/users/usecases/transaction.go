@@ -0,0 +1,29 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+// inTransaction runs fn inside a transaction that repositories join through
+// ctx. The transaction is rolled back when fn returns an AppError.
+func inTransaction(ctx context.Context, txManager databases.TxManager, fn func(ctx context.Context) *app_errors.AppError) *app_errors.AppError {
+	err := txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if appErr := fn(ctx); appErr != nil {
+			return appErr
+		}
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	var appErr *app_errors.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return app_errors.FromDB(err, "Failed to commit transaction")
+}
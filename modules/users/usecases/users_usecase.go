@@ -15,4 +15,7 @@ type UserUsecase interface {
 	GetUserByID(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError)
 	UpdateUserByID(ctx context.Context, id uuid.UUID, input dtos.UpdateUserRequest) (*dtos.UserResponse, *app_errors.AppError)
 	DeleteUserByID(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError)
+	ImportUsers(ctx context.Context, input dtos.ImportUsersRequest) (*dtos.ImportUsersResponse, *app_errors.AppError)
+	LockUser(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError)
+	UnlockUser(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError)
 }
@@ -0,0 +1,231 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+)
+
+func strPtr(s string) *string { return &s }
+func intPtr(i int) *int       { return &i }
+
+func validCreateRequest() dtos.CreateUserRequest {
+	return dtos.CreateUserRequest{
+		Name:     "Alice",
+		Email:    "  Alice@Example.COM ",
+		Age:      30,
+		Password: "Correct-Orbit-42",
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		repoErr   error
+		seed      bool
+		password  string
+		wantCode  int
+		wantEmail string
+	}{
+		{name: "normalises email", wantEmail: "alice@example.com"},
+		{name: "duplicate email", seed: true, wantCode: http.StatusConflict},
+		{name: "weak password", password: "alice12345", wantCode: http.StatusBadRequest},
+		{name: "database unavailable", repoErr: databases.TranslateError(&pgconn.PgError{Code: "08006"}), wantCode: http.StatusServiceUnavailable},
+		{name: "unknown error", repoErr: errors.New("boom"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newFakeUserRepo()
+			uc := usecases.NewUserUseCase(repo, newFakeSessionRepo(), &fakeEventRepo{}, &fakeTxManager{}, testPasswordPolicy)
+
+			if tt.seed {
+				if _, err := uc.CreateUser(ctx, validCreateRequest()); err != nil {
+					t.Fatalf("seed: %v", err)
+				}
+			}
+			repo.createErr = tt.repoErr
+
+			req := validCreateRequest()
+			if tt.password != "" {
+				req.Password = tt.password
+			}
+
+			resp, appErr := uc.CreateUser(ctx, req)
+			if tt.wantCode != 0 {
+				if appErr == nil || appErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("CreateUser: %v", appErr)
+			}
+			if resp.Email != tt.wantEmail {
+				t.Errorf("email = %q, want %q", resp.Email, tt.wantEmail)
+			}
+			if resp.Locale != "en" {
+				t.Errorf("locale = %q, want en", resp.Locale)
+			}
+		})
+	}
+}
+
+func TestGetUserByID(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepo()
+	uc := usecases.NewUserUseCase(repo, newFakeSessionRepo(), &fakeEventRepo{}, &fakeTxManager{}, testPasswordPolicy)
+
+	created, appErr := uc.CreateUser(ctx, validCreateRequest())
+	if appErr != nil {
+		t.Fatalf("CreateUser: %v", appErr)
+	}
+
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		repoErr  error
+		wantCode int
+	}{
+		{name: "found", id: created.ID},
+		{name: "not found", id: uuid.New(), wantCode: http.StatusNotFound},
+		{name: "timeout", id: created.ID, repoErr: databases.TranslateError(context.DeadlineExceeded), wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.getErr = tt.repoErr
+			defer func() { repo.getErr = nil }()
+
+			resp, appErr := uc.GetUserByID(ctx, tt.id)
+			if tt.wantCode != 0 {
+				if appErr == nil || appErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("GetUserByID: %v", appErr)
+			}
+			if resp.ID != tt.id {
+				t.Errorf("id = %s, want %s", resp.ID, tt.id)
+			}
+		})
+	}
+}
+
+func TestUpdateUserByID(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepo()
+	uc := usecases.NewUserUseCase(repo, newFakeSessionRepo(), &fakeEventRepo{}, &fakeTxManager{}, testPasswordPolicy)
+
+	created, appErr := uc.CreateUser(ctx, validCreateRequest())
+	if appErr != nil {
+		t.Fatalf("CreateUser: %v", appErr)
+	}
+
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		input    dtos.UpdateUserRequest
+		wantName string
+		wantAge  int
+		wantCode int
+	}{
+		{name: "no changes", id: created.ID, wantName: "Alice", wantAge: 30},
+		{name: "rename", id: created.ID, input: dtos.UpdateUserRequest{Name: strPtr("Alicia")}, wantName: "Alicia", wantAge: 30},
+		{name: "age", id: created.ID, input: dtos.UpdateUserRequest{Age: intPtr(31)}, wantName: "Alicia", wantAge: 31},
+		{name: "not found", id: uuid.New(), input: dtos.UpdateUserRequest{Name: strPtr("x")}, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, appErr := uc.UpdateUserByID(ctx, tt.id, tt.input)
+			if tt.wantCode != 0 {
+				if appErr == nil || appErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want status %d", appErr, tt.wantCode)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("UpdateUserByID: %v", appErr)
+			}
+			if resp.Name != tt.wantName || resp.Age != tt.wantAge {
+				t.Errorf("got name %q age %d, want %q %d", resp.Name, resp.Age, tt.wantName, tt.wantAge)
+			}
+		})
+	}
+}
+
+func TestDeleteUserByID(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepo()
+	uc := usecases.NewUserUseCase(repo, newFakeSessionRepo(), &fakeEventRepo{}, &fakeTxManager{}, testPasswordPolicy)
+
+	created, appErr := uc.CreateUser(ctx, validCreateRequest())
+	if appErr != nil {
+		t.Fatalf("CreateUser: %v", appErr)
+	}
+
+	if _, appErr := uc.DeleteUserByID(ctx, created.ID); appErr != nil {
+		t.Fatalf("DeleteUserByID: %v", appErr)
+	}
+	if _, appErr := uc.DeleteUserByID(ctx, created.ID); appErr == nil || appErr.Code != http.StatusNotFound {
+		t.Errorf("second delete: got %v, want 404", appErr)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeUserRepo()
+	uc := usecases.NewUserUseCase(repo, newFakeSessionRepo(), &fakeEventRepo{}, &fakeTxManager{}, testPasswordPolicy)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("Legacy-Pass-1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	row := func(email, hash string) dtos.ImportUserRequest {
+		return dtos.ImportUserRequest{Name: "Imported", Email: email, Age: 40, PasswordHash: hash}
+	}
+
+	resp, appErr := uc.ImportUsers(ctx, dtos.ImportUsersRequest{Users: []dtos.ImportUserRequest{
+		row("Carol@Example.com", string(bcryptHash)),
+		row("dave@example.com", "pbkdf2_sha256$1000$seasalt2024$2hbxnK/8Tuey3RX6zyz5Jh0+6lq2xrmBz1ClO1X6+PQ="),
+		row("carol@example.com", string(bcryptHash)),
+		row("eve@example.com", "5f4dcc3b5aa765d61d8327deb882cf99"),
+		row("not-an-email", string(bcryptHash)),
+	}})
+	if appErr != nil {
+		t.Fatalf("ImportUsers: %v", appErr)
+	}
+
+	if len(resp.Imported) != 2 || resp.Imported[0].Email != "carol@example.com" {
+		t.Errorf("imported = %+v, want carol and dave", resp.Imported)
+	}
+
+	wantFailed := map[int]string{2: "already in use", 3: "invalid password hash", 4: "email"}
+	if len(resp.Failed) != len(wantFailed) {
+		t.Fatalf("failed = %+v, want %d rows", resp.Failed, len(wantFailed))
+	}
+	for _, f := range resp.Failed {
+		if !strings.Contains(f.Reason, wantFailed[f.Index]) {
+			t.Errorf("row %d failed with %q, want %q", f.Index, f.Reason, wantFailed[f.Index])
+		}
+	}
+
+	repo.createErr = databases.TranslateError(&pgconn.PgError{Code: "08006"})
+	if _, appErr := uc.ImportUsers(ctx, dtos.ImportUsersRequest{Users: []dtos.ImportUserRequest{row("frank@example.com", string(bcryptHash))}}); appErr == nil || appErr.Code != http.StatusServiceUnavailable {
+		t.Errorf("database down: got %v, want 503", appErr)
+	}
+}
@@ -3,6 +3,7 @@ package usecases
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,23 +12,41 @@ import (
 	"github.com/natchaphonbw/usermanagement/modules/users/dtos"
 	"github.com/natchaphonbw/usermanagement/modules/users/entities"
 	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
 	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
 	"github.com/natchaphonbw/usermanagement/pkg/utils"
 )
 
 type userUsecaseImpl struct {
-	userRepo repositories.UserRepository
+	userRepo       repositories.UserRepository
+	sessionRepo    repositories.SessionRepository
+	eventRepo      repositories.EventRepository
+	txManager      databases.TxManager
+	passwordPolicy *validator.PasswordPolicy
 }
 
-func NewUserUseCase(userRepo repositories.UserRepository) UserUsecase {
+func NewUserUseCase(userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository, eventRepo repositories.EventRepository, txManager databases.TxManager, passwordPolicy *validator.PasswordPolicy) UserUsecase {
 	return &userUsecaseImpl{
-		userRepo: userRepo,
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		eventRepo:      eventRepo,
+		txManager:      txManager,
+		passwordPolicy: passwordPolicy,
 	}
 }
 
 // Create User
 func (u *userUsecaseImpl) CreateUser(ctx context.Context, input dtos.CreateUserRequest) (*dtos.UserResponse, *app_errors.AppError) {
-	hash, salt, err := utils.GeneratePasswordHash(input.Password, &utils.DefaultArgon2Config)
+	if errs := u.passwordPolicy.Validate(input.Password, input.Name, input.Email); len(errs) > 0 {
+		return nil, app_errors.BadRequest("Invalid password", nil).WithDetails(errs)
+	}
+
+	hash, err := utils.GeneratePasswordHash(ctx, input.Password, &utils.DefaultArgon2Config)
+	if errors.Is(err, utils.ErrHashingBusy) {
+		return nil, hashingBusy(err)
+	}
 	if err != nil {
 		return nil, app_errors.InternalServer("Failed to hash password", err)
 	}
@@ -35,16 +54,18 @@ func (u *userUsecaseImpl) CreateUser(ctx context.Context, input dtos.CreateUserR
 	user := &entities.User{
 		ID:           uuid.New(),
 		Name:         input.Name,
-		Email:        input.Email,
+		Email:        utils.NormalizeEmail(input.Email),
 		Age:          input.Age,
+		Locale:       localeOrDefault(input.Locale),
+		Role:         entities.RoleUser,
+		Source:       entities.SourceLocal,
 		PasswordHash: hash,
-		Salt:         salt,
 		Created_at:   time.Now(),
 		Updated_at:   time.Now(),
 	}
 
-	if err := u.userRepo.CreateUser(ctx, user); err != nil {
-		return nil, app_errors.InternalServer("Failed to create user", err)
+	if appErr := u.createUser(ctx, user); appErr != nil {
+		return nil, appErr
 	}
 
 	return dtos.FromUserEntity(user), nil
@@ -54,7 +75,7 @@ func (u *userUsecaseImpl) CreateUser(ctx context.Context, input dtos.CreateUserR
 func (u *userUsecaseImpl) GetAllUsers(ctx context.Context) ([]*dtos.UserResponse, *app_errors.AppError) {
 	users, err := u.userRepo.GetAllUsers(ctx)
 	if err != nil {
-		return nil, app_errors.InternalServer("Failed to get users", err)
+		return nil, app_errors.FromDB(err, "Failed to get users")
 	}
 
 	return dtos.FromUserEntities(users), nil
@@ -67,7 +88,7 @@ func (u *userUsecaseImpl) GetUserByID(ctx context.Context, id uuid.UUID) (*dtos.
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, app_errors.NotFound("User not found", err)
 		}
-		return nil, app_errors.InternalServer("Failed to get user", err)
+		return nil, app_errors.FromDB(err, "Failed to get user")
 	}
 	return dtos.FromUserEntity(user), nil
 }
@@ -80,7 +101,7 @@ func (u *userUsecaseImpl) UpdateUserByID(ctx context.Context, id uuid.UUID, inpu
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, app_errors.NotFound("User not found", err)
 		}
-		return nil, app_errors.InternalServer("Failed to get user for update", err)
+		return nil, app_errors.FromDB(err, "Failed to get user for update")
 	}
 
 	updated := false
@@ -90,13 +111,13 @@ func (u *userUsecaseImpl) UpdateUserByID(ctx context.Context, id uuid.UUID, inpu
 		updated = true
 	}
 
-	if input.Email != nil && user.Email != *input.Email {
-		user.Email = *input.Email
+	if input.Age != nil && user.Age != *input.Age {
+		user.Age = *input.Age
 		updated = true
 	}
 
-	if input.Age != nil && user.Age != *input.Age {
-		user.Age = *input.Age
+	if input.Locale != nil && user.Locale != *input.Locale {
+		user.Locale = *input.Locale
 		updated = true
 	}
 
@@ -107,9 +128,17 @@ func (u *userUsecaseImpl) UpdateUserByID(ctx context.Context, id uuid.UUID, inpu
 	user.Updated_at = time.Now()
 
 	// Update user in userRepository
-	user, err = u.userRepo.UpdateUserByID(ctx, id, user)
-	if err != nil {
-		return nil, app_errors.InternalServer("Failed to update user", err)
+	appErr := inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		if user, err = u.userRepo.UpdateUserByID(ctx, id, user); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("User not found", err)
+			}
+			return app_errors.FromDB(err, "Failed to update user")
+		}
+		return raiseUserEvent(ctx, u.eventRepo, entities.EventUserUpdated, user)
+	})
+	if appErr != nil {
+		return nil, appErr
 	}
 
 	return dtos.FromUserEntity(user), nil
@@ -118,14 +147,154 @@ func (u *userUsecaseImpl) UpdateUserByID(ctx context.Context, id uuid.UUID, inpu
 
 // Delete User By ID
 func (u *userUsecaseImpl) DeleteUserByID(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError) {
-	user, err := u.userRepo.DeleteUserByID(ctx, id)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, app_errors.NotFound("User not found", err)
+	var user *entities.User
+	appErr := inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		var err error
+		if user, err = u.userRepo.DeleteUserByID(ctx, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("User not found", err)
+			}
+			return app_errors.FromDB(err, "Failed to delete user")
 		}
-		return nil, app_errors.InternalServer("Failed to delete user", err)
+		return raiseUserEvent(ctx, u.eventRepo, entities.EventUserDeleted, user)
+	})
+	if appErr != nil {
+		return nil, appErr
 	}
 
 	return dtos.FromUserEntity(user), nil
 
 }
+
+// Import Users
+// Rows are created one by one; rows that are invalid or clash with an
+// existing email are reported and skipped, any other error stops the import.
+func (u *userUsecaseImpl) ImportUsers(ctx context.Context, input dtos.ImportUsersRequest) (*dtos.ImportUsersResponse, *app_errors.AppError) {
+	resp := &dtos.ImportUsersResponse{
+		Imported: []*dtos.UserResponse{},
+		Failed:   []dtos.ImportUserFailure{},
+	}
+
+	for i, row := range input.Users {
+		fail := func(reason string) {
+			resp.Failed = append(resp.Failed, dtos.ImportUserFailure{Index: i, Email: row.Email, Reason: reason})
+		}
+
+		if errs := validator.ValidateStruct(row); len(errs) > 0 {
+			reasons := make([]string, len(errs))
+			for j, e := range errs {
+				reasons[j] = e.Reason
+			}
+			fail(strings.Join(reasons, "; "))
+			continue
+		}
+		if err := utils.ValidatePasswordHash(row.PasswordHash); err != nil {
+			fail(err.Error())
+			continue
+		}
+
+		user := &entities.User{
+			ID:           uuid.New(),
+			Name:         row.Name,
+			Email:        utils.NormalizeEmail(row.Email),
+			Age:          row.Age,
+			Locale:       localeOrDefault(row.Locale),
+			Role:         entities.RoleUser,
+			Source:       entities.SourceLocal,
+			PasswordHash: row.PasswordHash,
+			Created_at:   time.Now(),
+			Updated_at:   time.Now(),
+		}
+
+		if appErr := u.createUser(ctx, user); appErr != nil {
+			if errors.Is(appErr, databases.ErrDuplicateKey) {
+				fail("email already in use")
+				continue
+			}
+			return nil, appErr
+		}
+
+		resp.Imported = append(resp.Imported, dtos.FromUserEntity(user))
+	}
+
+	return resp, nil
+}
+
+// Lock User
+// A locked user can't log in and loses their sessions; locking them again
+// changes nothing.
+func (u *userUsecaseImpl) LockUser(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError) {
+	return u.setLocked(ctx, id, true)
+}
+
+// Unlock User
+func (u *userUsecaseImpl) UnlockUser(ctx context.Context, id uuid.UUID) (*dtos.UserResponse, *app_errors.AppError) {
+	return u.setLocked(ctx, id, false)
+}
+
+// setLocked locks or unlocks the user and raises user.locked or
+// user.unlocked, unless they already were.
+func (u *userUsecaseImpl) setLocked(ctx context.Context, id uuid.UUID, locked bool) (*dtos.UserResponse, *app_errors.AppError) {
+	var user *entities.User
+	appErr := inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		var err error
+		if user, err = u.userRepo.GetUserByID(ctx, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("User not found", err)
+			}
+			return app_errors.FromDB(err, "Failed to get user")
+		}
+		if (user.LockedAt != nil) == locked {
+			return nil
+		}
+
+		var at *time.Time
+		eventType := entities.EventUserUnlocked
+		if locked {
+			now := time.Now()
+			at, eventType = &now, entities.EventUserLocked
+		}
+		if err := u.userRepo.SetLockedAt(ctx, id, at); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.NotFound("User not found", err)
+			}
+			return app_errors.FromDB(err, "Failed to lock user")
+		}
+		user.LockedAt = at
+
+		if locked {
+			if err := u.sessionRepo.MarkRevokedByUserID(ctx, id); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return app_errors.FromDB(err, "Failed to revoke sessions for user")
+			}
+			if appErr := raiseSessionsRevoked(ctx, u.eventRepo, id); appErr != nil {
+				return appErr
+			}
+		}
+		return raiseUserEvent(ctx, u.eventRepo, eventType, user)
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return dtos.FromUserEntity(user), nil
+}
+
+// createUser stores a new user and raises user.created.
+func (u *userUsecaseImpl) createUser(ctx context.Context, user *entities.User) *app_errors.AppError {
+	return inTransaction(ctx, u.txManager, func(ctx context.Context) *app_errors.AppError {
+		if err := u.userRepo.CreateUser(ctx, user); err != nil {
+			if errors.Is(err, databases.ErrDuplicateKey) {
+				return app_errors.Conflict("Email already in use", err)
+			}
+			return app_errors.FromDB(err, "Failed to create user")
+		}
+		return raiseUserEvent(ctx, u.eventRepo, entities.EventUserCreated, user)
+	})
+}
+
+func localeOrDefault(locale string) string {
+	if locale == "" {
+		return mailer.DefaultLocale
+	}
+	return locale
+}
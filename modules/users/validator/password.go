@@ -1,29 +1,174 @@
 package validator
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/natchaphonbw/usermanagement/config"
 )
 
-func ValidatePassword(password string) error {
-	var (
-		hasUpper = false
-		hasLower = false
-	)
+const passwordField = "password"
+
+// PasswordPolicy describes what a new password has to satisfy.
+// Zero values disable a rule.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// MaxRepeat is the longest allowed run of one character.
+	MaxRepeat int
+
+	// MinScore is the lowest accepted strength score, 0 (weakest) to 4.
+	MinScore int
+
+	// Breaches, if set, rejects passwords seen BreachMinCount or more
+	// times in known breaches.
+	Breaches       BreachChecker
+	BreachMinCount int
+}
+
+// BreachChecker reports how often a password was seen in known breaches.
+type BreachChecker interface {
+	Count(password string) int
+}
+
+// NewPasswordPolicy builds the policy from cfg, breaches may be nil.
+func NewPasswordPolicy(cfg *config.Config, breaches BreachChecker) *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		MaxLength:     cfg.PasswordMaxLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		MaxRepeat:     cfg.PasswordMaxRepeat,
+		MinScore:      cfg.PasswordMinScore,
 
+		Breaches:       breaches,
+		BreachMinCount: cfg.BreachMinCount,
+	}
+}
+
+// Validate checks password against the policy and returns every violation.
+// userInputs are the user's own details (name, email) which must not appear
+// in the password.
+func (p *PasswordPolicy) Validate(password string, userInputs ...string) []InvalidField {
+	var errs []InvalidField
+	fail := func(format string, args ...any) {
+		errs = append(errs, InvalidField{Field: passwordField, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		fail("password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		// don't spend time scoring absurdly long input
+		fail("password must be at most %d characters long", p.MaxLength)
+		return errs
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
 	for _, char := range password {
 		switch {
 		case unicode.IsUpper(char):
 			hasUpper = true
 		case unicode.IsLower(char):
 			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case unicode.IsPunct(char), unicode.IsSymbol(char), unicode.IsSpace(char):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		fail("password must contain an upper case letter")
+	}
+	if p.RequireLower && !hasLower {
+		fail("password must contain a lower case letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		fail("password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		fail("password must contain a symbol")
+	}
+
+	if p.MaxRepeat > 0 && longestRun(password) > p.MaxRepeat {
+		fail("password must not repeat a character more than %d times in a row", p.MaxRepeat)
+	}
+
+	words := personalWords(userInputs)
+	lower := strings.ToLower(password)
+	for _, word := range words {
+		if strings.Contains(lower, word) {
+			fail("password must not contain your name or email")
+			break
 		}
 	}
 
-	if !hasUpper || !hasLower {
-		return errors.New("password must contain at least one upper and lower case")
+	if isCommonPassword(password) {
+		fail("password is too common")
+	} else if p.Breaches != nil && p.Breaches.Count(password) >= max(p.BreachMinCount, 1) {
+		fail("password has appeared in a data breach")
+	} else if score := PasswordScore(password, words...); score < p.MinScore {
+		fail("password is too easy to guess (strength %d of 4, need %d)", score, p.MinScore)
 	}
 
-	return nil
+	return errs
+}
 
+// longestRun returns the length of the longest run of one repeated character.
+func longestRun(s string) int {
+	var (
+		longest, current int
+		prev             rune = -1
+	)
+	for _, char := range s {
+		if char == prev {
+			current++
+		} else {
+			current = 1
+			prev = char
+		}
+		longest = max(longest, current)
+	}
+	return longest
+}
+
+// personalWords splits the user's details into lower-cased words worth
+// checking for, e.g. "John Smith", "j.smith@example.com" gives
+// john, smith, j.smith, smith and j.smith@example.com.
+func personalWords(userInputs []string) []string {
+	var words []string
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input == "" {
+			continue
+		}
+
+		candidates := []string{input}
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			candidates = append(candidates, local)
+			input = local
+		}
+		candidates = append(candidates, strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+
+		for _, word := range candidates {
+			// short fragments like initials would reject too much
+			if utf8.RuneCountInString(word) >= 3 {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
 }
@@ -0,0 +1,285 @@
+package validator
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords maps each common password to its 1-based frequency rank.
+var commonPasswords, longestCommonPassword = loadCommonPasswords(commonPasswordsFile)
+
+func loadCommonPasswords(file string) (map[string]int, int) {
+	ranks := make(map[string]int)
+	longest := 0
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, ok := ranks[line]; !ok {
+			ranks[line] = len(ranks) + 1
+			longest = max(longest, len([]rune(line)))
+		}
+	}
+	return ranks, longest
+}
+
+// leet undoes the usual character substitutions, e.g. p@55w0rd -> password.
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '9': 'g',
+	'1': 'i', '!': 'i', '|': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func unleet(r rune) rune {
+	if plain, ok := leet[r]; ok {
+		return plain
+	}
+	return r
+}
+
+// isCommonPassword reports whether password is on the common list, also
+// after dropping a short suffix like "1" or "2024!" and undoing leet.
+func isCommonPassword(password string) bool {
+	lower := strings.ToLower(password)
+
+	for _, base := range []string{lower, trimSuffix(lower)} {
+		for _, candidate := range []string{base, strings.Map(unleet, base)} {
+			if _, ok := commonPasswords[candidate]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func trimSuffix(s string) string {
+	runes := []rune(s)
+	end := len(runes)
+	for end > 0 && len(runes)-end < 5 && !unicode.IsLetter(runes[end-1]) {
+		end--
+	}
+	return string(runes[:end])
+}
+
+// PasswordScore rates how hard password is to guess from 0 (trivial) to 4
+// (very hard), with the guess thresholds used by zxcvbn. userInputs are
+// treated as the most likely dictionary words.
+func PasswordScore(password string, userInputs ...string) int {
+	bits := guessBits(password, userInputs)
+	switch {
+	case bits < math.Log2(1e3):
+		return 0
+	case bits < math.Log2(1e6):
+		return 1
+	case bits < math.Log2(1e8):
+		return 2
+	case bits < math.Log2(1e10):
+		return 3
+	}
+	return 4
+}
+
+// guessBits estimates log2 of the guesses needed for password. Like zxcvbn it
+// splits the password into the cheapest sequence of patterns (dictionary
+// words, years, repeats, sequences, keyboard runs and single characters)
+// and sums their cost.
+func guessBits(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	lower := make([]rune, n)
+	plain := make([]rune, n)
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		plain[i] = unleet(lower[i])
+	}
+
+	words := make(map[string]int, len(userInputs))
+	longestWord := longestCommonPassword
+	for _, word := range userInputs {
+		words[word] = 1
+		longestWord = max(longestWord, len([]rune(word)))
+	}
+	rank := func(word string) (int, bool) {
+		if r, ok := words[word]; ok {
+			return r, true
+		}
+		r, ok := commonPasswords[word]
+		return r, ok
+	}
+
+	charBits := math.Log2(float64(cardinality(runes)))
+	repeats, sequences, keyboard := runLengths(lower)
+
+	// best[i] is the cheapest cost of the first i characters
+	best := make([]float64, n+1)
+	for end := 1; end <= n; end++ {
+		best[end] = best[end-1] + charBits
+
+		for start := 0; start <= end-3; start++ {
+			length := end - start
+			cost := math.Inf(1)
+
+			if length <= longestWord {
+				if r, ok := rank(string(lower[start:end])); ok {
+					cost = math.Log2(float64(r+1)) + caseBits(runes[start:end])
+				} else if r, ok := rank(string(plain[start:end])); ok {
+					cost = math.Log2(float64(r+1)) + caseBits(runes[start:end]) + 1
+				}
+			}
+
+			if length == 4 && isYear(runes[start:end]) {
+				cost = min(cost, math.Log2(yearSpan))
+			}
+
+			if repeats[end-1] >= length {
+				cost = min(cost, math.Log2(float64(cardinality(runes[start:start+1])*length)))
+			}
+			if sequences[end-1] >= length {
+				cost = min(cost, sequenceBits(lower[start], lower[start+1], length))
+			}
+			if keyboard[end-1] >= length {
+				cost = min(cost, math.Log2(float64(len(keyboardRows)*4*length)))
+			}
+
+			best[end] = min(best[end], best[start]+cost)
+		}
+	}
+
+	return best[n]
+}
+
+// cardinality is the size of the character space a brute force attack on s
+// would have to cover.
+func cardinality(s []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// caseBits is the extra cost of capitalisation in a dictionary word:
+// nothing for all lower, one bit for the usual Title or UPPER case and
+// a bit per upper case letter otherwise.
+func caseBits(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(word), upper == 1 && unicode.IsUpper(word[0]):
+		return 1
+	}
+	return float64(upper)
+}
+
+func sequenceBits(first, second rune, length int) float64 {
+	base := 26.0
+	switch {
+	case strings.ContainsRune("az019", first):
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	}
+	if second < first {
+		base *= 2
+	}
+	return math.Log2(base * float64(length))
+}
+
+// yearSpan is the number of years an attacker would try, 1900 to 2099.
+const yearSpan = 200
+
+func isYear(s []rune) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	prefix := string(s[:2])
+	return prefix == "19" || prefix == "20"
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+func keyboardNeighbours(a, b rune) bool {
+	for _, row := range keyboardRows {
+		i, j := strings.IndexRune(row, a), strings.IndexRune(row, b)
+		if i >= 0 && j >= 0 && (i-j == 1 || j-i == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// runLengths returns, for every position, the length of the repeat,
+// sequence (abc, 975) and keyboard (qwer, lkj) runs ending there.
+func runLengths(s []rune) (repeats, sequences, keyboard []int) {
+	n := len(s)
+	repeats = make([]int, n)
+	sequences = make([]int, n)
+	keyboard = make([]int, n)
+
+	for i := range s {
+		repeats[i], sequences[i], keyboard[i] = 1, 1, 1
+		if i == 0 {
+			continue
+		}
+
+		if s[i] == s[i-1] {
+			repeats[i] = repeats[i-1] + 1
+		}
+
+		if delta := s[i] - s[i-1]; delta == 1 || delta == -1 {
+			sequences[i] = 2
+			if i > 1 && s[i-1]-s[i-2] == delta {
+				sequences[i] = sequences[i-1] + 1
+			}
+		}
+
+		if keyboardNeighbours(s[i-1], s[i]) {
+			keyboard[i] = keyboard[i-1] + 1
+		}
+	}
+	return repeats, sequences, keyboard
+}
@@ -0,0 +1,108 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var testPolicy = &PasswordPolicy{
+	MinLength:    10,
+	MaxLength:    128,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	MaxRepeat:    3,
+	MinScore:     3,
+}
+
+type fakeBreaches map[string]int
+
+func (f fakeBreaches) Count(password string) int { return f[password] }
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *PasswordPolicy
+		password string
+		inputs   []string
+		reasons  []string
+	}{
+		{name: "strong", policy: testPolicy, password: "Correct-Orbit-42"},
+		{name: "strong with unicode", policy: testPolicy, password: "Ünïcode-Kätze-7x"},
+		{name: "too short", policy: testPolicy, password: "Kx7#mPq2", reasons: []string{"at least 10 characters"}},
+		{name: "too long", policy: testPolicy, password: strings.Repeat("Ab1", 50), reasons: []string{"at most 128 characters"}},
+		{name: "no upper case", policy: testPolicy, password: "correct-orbit-42", reasons: []string{"upper case"}},
+		{name: "no lower case", policy: testPolicy, password: "CORRECT-ORBIT-42", reasons: []string{"lower case"}},
+		{name: "no digit", policy: testPolicy, password: "Correct-Orbit-xy", reasons: []string{"digit"}},
+		{name: "symbol required", policy: &PasswordPolicy{RequireSymbol: true}, password: "CorrectOrbit42", reasons: []string{"symbol"}},
+		{name: "repeated characters", policy: testPolicy, password: "Correct-Orbiiiit-42", reasons: []string{"more than 3 times"}},
+		{name: "contains name", policy: testPolicy, password: "Bobby-Tables-1984", inputs: []string{"Bobby Tables", "rt@example.com"}, reasons: []string{"name or email", "too easy to guess"}},
+		{name: "contains email", policy: testPolicy, password: "Xq-J.Smith-Orbit9", inputs: []string{"John", "j.smith@example.com"}, reasons: []string{"name or email"}},
+		{name: "common", policy: testPolicy, password: "Password123", reasons: []string{"too common"}},
+		{name: "common with leet", policy: testPolicy, password: "P@ssw0rd2024!", reasons: []string{"too common"}},
+		{name: "keyboard walk", policy: testPolicy, password: "Poiuytrewq1", reasons: []string{"too easy to guess"}},
+		{name: "sequence", policy: testPolicy, password: "Abcdefghij123", reasons: []string{"too easy to guess"}},
+		{name: "breached", policy: &PasswordPolicy{Breaches: fakeBreaches{"Correct-Orbit-42": 3}}, password: "Correct-Orbit-42", reasons: []string{"data breach"}},
+		{name: "breached less than min count", policy: &PasswordPolicy{Breaches: fakeBreaches{"Correct-Orbit-42": 3}, BreachMinCount: 5}, password: "Correct-Orbit-42"},
+		{name: "zero policy accepts anything", policy: &PasswordPolicy{}, password: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.policy.Validate(tt.password, tt.inputs...)
+			if len(errs) != len(tt.reasons) {
+				t.Fatalf("Validate(%q) = %v, want %d violations", tt.password, errs, len(tt.reasons))
+			}
+			for i, reason := range tt.reasons {
+				if errs[i].Field != "password" || !strings.Contains(errs[i].Reason, reason) {
+					t.Errorf("violation %d = %+v, want reason containing %q", i, errs[i], reason)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordScore(t *testing.T) {
+	tests := []struct {
+		password string
+		score    int
+	}{
+		{"", 0},
+		{"password", 0},
+		{"aaaaaaaaaaaa", 0},
+		{"qwertyuiop", 0},
+		{"Summer1987", 1},
+		{"Correct-Orbit-42", 4},
+		{"Kx7#mPq2vLw9", 4},
+	}
+
+	for _, tt := range tests {
+		if score := PasswordScore(tt.password); score != tt.score {
+			t.Errorf("PasswordScore(%q) = %d, want %d", tt.password, score, tt.score)
+		}
+	}
+}
+
+func FuzzPasswordPolicy(f *testing.F) {
+	for _, seed := range []string{"Correct-Orbit-42", "secret", "SECRET", "", "ǅ", "\xff", "p@55w0rd", "aaaa1111"} {
+		f.Add(seed, "Bob Smith")
+	}
+
+	f.Fuzz(func(t *testing.T, password, name string) {
+		errs := testPolicy.Validate(password, name)
+
+		if utf8.RuneCountInString(password) < testPolicy.MinLength && len(errs) == 0 {
+			t.Fatalf("Validate(%q) accepted a password shorter than %d", password, testPolicy.MinLength)
+		}
+		for _, err := range errs {
+			if err.Field != "password" || err.Reason == "" {
+				t.Fatalf("Validate(%q) returned malformed violation %+v", password, err)
+			}
+		}
+
+		if score := PasswordScore(password, name); score < 0 || score > 4 {
+			t.Fatalf("PasswordScore(%q) = %d, want 0..4", password, score)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/usecases"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type WebhookController struct {
+	webhookUsecase usecases.WebhookUsecase
+}
+
+func NewWebhookController(u usecases.WebhookUsecase) *WebhookController {
+	return &WebhookController{
+		webhookUsecase: u,
+	}
+}
+
+// CreateSubscription
+func (ctrl *WebhookController) CreateSubscription(c *fiber.Ctx) error {
+	var req dtos.CreateSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.webhookUsecase.CreateSubscription(c.Context(), req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// GetAllSubscriptions
+func (ctrl *WebhookController) GetAllSubscriptions(c *fiber.Ctx) error {
+	resp, respErr := ctrl.webhookUsecase.GetAllSubscriptions(c.Context())
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// GetSubscriptionByID
+func (ctrl *WebhookController) GetSubscriptionByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid subscription ID", err))
+	}
+
+	resp, respErr := ctrl.webhookUsecase.GetSubscriptionByID(c.Context(), id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// UpdateSubscription
+func (ctrl *WebhookController) UpdateSubscription(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid subscription ID", err))
+	}
+
+	var req dtos.UpdateSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid request body", err))
+	}
+
+	if errs := validator.ValidateStruct(req); len(errs) > 0 {
+		return app_errors.Send(c, app_errors.BadRequest("Validation failed", nil).WithDetails(errs))
+	}
+
+	resp, respErr := ctrl.webhookUsecase.UpdateSubscription(c.Context(), id, req)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// DeleteSubscription
+func (ctrl *WebhookController) DeleteSubscription(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid subscription ID", err))
+	}
+
+	if respErr := ctrl.webhookUsecase.DeleteSubscription(c.Context(), id); respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetDeliveries
+func (ctrl *WebhookController) GetDeliveries(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid subscription ID", err))
+	}
+
+	resp, respErr := ctrl.webhookUsecase.GetDeliveries(c.Context(), id)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.JSON(resp)
+}
+
+// Redeliver
+func (ctrl *WebhookController) Redeliver(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid subscription ID", err))
+	}
+
+	deliveryID, err := uuid.Parse(c.Params("deliveryID"))
+	if err != nil {
+		return app_errors.Send(c, app_errors.BadRequest("Invalid delivery ID", err))
+	}
+
+	resp, respErr := ctrl.webhookUsecase.Redeliver(c.Context(), id, deliveryID)
+	if respErr != nil {
+		return app_errors.Send(c, respErr)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
@@ -0,0 +1,96 @@
+package dtos
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+)
+
+type CreateSubscriptionRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Events      []string `json:"events" validate:"required,min=1,dive,required"`
+	Description string   `json:"description" validate:"omitempty,max=255"`
+}
+
+type UpdateSubscriptionRequest struct {
+	URL         *string  `json:"url" validate:"omitempty,url"`
+	Events      []string `json:"events" validate:"omitempty,min=1,dive,required"`
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active"`
+}
+
+// Response
+
+type SubscriptionResponse struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateSubscriptionResponse is the only response that includes the signing secret.
+type CreateSubscriptionResponse struct {
+	SubscriptionResponse
+	Secret string `json:"secret"`
+}
+
+type DeliveryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func FromSubscriptionEntity(sub *entities.Subscription) *SubscriptionResponse {
+	return &SubscriptionResponse{
+		ID:          sub.ID,
+		URL:         sub.URL,
+		Events:      strings.Split(sub.Events, ","),
+		Description: sub.Description,
+		Active:      sub.Active,
+		CreatedAt:   sub.Created_at,
+		UpdatedAt:   sub.Updated_at,
+	}
+}
+
+func FromSubscriptionEntities(subs []entities.Subscription) []*SubscriptionResponse {
+	resp := make([]*SubscriptionResponse, 0, len(subs))
+	for i := range subs {
+		resp = append(resp, FromSubscriptionEntity(&subs[i]))
+	}
+	return resp
+}
+
+func FromDeliveryEntity(d *entities.Delivery) *DeliveryResponse {
+	return &DeliveryResponse{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		ResponseStatus: d.ResponseStatus,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.Created_at,
+	}
+}
+
+func FromDeliveryEntities(deliveries []entities.Delivery) []*DeliveryResponse {
+	resp := make([]*DeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		resp = append(resp, FromDeliveryEntity(&deliveries[i]))
+	}
+	return resp
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+	// DeliveryCancelled deliveries were due while their subscription was
+	// inactive; only a manual redelivery sends them.
+	DeliveryCancelled = "cancelled"
+)
+
+type Delivery struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	Subscription   Subscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	SubscriptionID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_delivery_subscription_event" json:"subscription_id"`
+	EventID        uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_delivery_subscription_event" json:"event_id"`
+	EventType      string       `gorm:"type:varchar(100);not null" json:"event_type"`
+	Payload        string       `gorm:"type:text;not null" json:"payload"`
+	Status         string       `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts       int          `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time    `gorm:"not null;index" json:"next_attempt_at"`
+	ResponseStatus int          `json:"response_status"`
+	LastError      string       `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time   `json:"delivered_at"`
+	Created_at     time.Time    `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	Updated_at     time.Time    `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventWildcard subscribes to every event type.
+const EventWildcard = "*"
+
+type Subscription struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	URL         string    `gorm:"type:text;not null" json:"url"`
+	Secret      string    `gorm:"not null" json:"-"`
+	Events      string    `gorm:"type:text;not null" json:"events"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	Created_at  time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	Updated_at  time.Time `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type deliveryPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryPostgresRepository(db *gorm.DB) DeliveryRepository {
+	return &deliveryPostgresRepository{db: db}
+}
+
+// Insert
+func (r *deliveryPostgresRepository) Insert(ctx context.Context, delivery *entities.Delivery) error {
+	err := databases.Conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(delivery).Error
+	return databases.TranslateError(err)
+}
+
+// GetByID
+func (r *deliveryPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entities.Delivery, error) {
+	var delivery entities.Delivery
+	if err := databases.Conn(ctx, r.db).First(&delivery, "id = ?", id).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &delivery, nil
+}
+
+// GetBySubscriptionID
+func (r *deliveryPostgresRepository) GetBySubscriptionID(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]entities.Delivery, error) {
+	var deliveries []entities.Delivery
+	err := databases.Conn(ctx, r.db).
+		Where("subscription_id = ?", subscriptionID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return deliveries, nil
+}
+
+// GetDue
+func (r *deliveryPostgresRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]entities.Delivery, error) {
+	var deliveries []entities.Delivery
+	err := databases.Conn(ctx, r.db).
+		Where("status = ? AND next_attempt_at <= ?", entities.DeliveryPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return deliveries, nil
+}
+
+// Update
+func (r *deliveryPostgresRepository) Update(ctx context.Context, delivery *entities.Delivery) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Save(delivery).Error)
+}
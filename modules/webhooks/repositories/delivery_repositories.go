@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+)
+
+type DeliveryRepository interface {
+	// Insert ignores deliveries that already exist for the same subscription and event.
+	Insert(ctx context.Context, delivery *entities.Delivery) error
+	GetByID(ctx context.Context, id uuid.UUID) (*entities.Delivery, error)
+	GetBySubscriptionID(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]entities.Delivery, error)
+	GetDue(ctx context.Context, now time.Time, limit int) ([]entities.Delivery, error)
+	Update(ctx context.Context, delivery *entities.Delivery) error
+}
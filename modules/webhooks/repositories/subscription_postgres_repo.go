@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"gorm.io/gorm"
+)
+
+type subscriptionPostgresRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionPostgresRepository(db *gorm.DB) SubscriptionRepository {
+	return &subscriptionPostgresRepository{db: db}
+}
+
+// Create
+func (r *subscriptionPostgresRepository) Create(ctx context.Context, sub *entities.Subscription) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Create(sub).Error)
+}
+
+// GetAll
+func (r *subscriptionPostgresRepository) GetAll(ctx context.Context) ([]entities.Subscription, error) {
+	var subs []entities.Subscription
+	if err := databases.Conn(ctx, r.db).Order("created_at").Find(&subs).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return subs, nil
+}
+
+// GetActive
+func (r *subscriptionPostgresRepository) GetActive(ctx context.Context) ([]entities.Subscription, error) {
+	var subs []entities.Subscription
+	if err := databases.Conn(ctx, r.db).Where("active = ?", true).Find(&subs).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return subs, nil
+}
+
+// GetByID
+func (r *subscriptionPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entities.Subscription, error) {
+	var sub entities.Subscription
+	if err := databases.Conn(ctx, r.db).First(&sub, "id = ?", id).Error; err != nil {
+		return nil, databases.TranslateError(err)
+	}
+	return &sub, nil
+}
+
+// Update
+func (r *subscriptionPostgresRepository) Update(ctx context.Context, sub *entities.Subscription) error {
+	return databases.TranslateError(databases.Conn(ctx, r.db).Save(sub).Error)
+}
+
+// Delete
+func (r *subscriptionPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := databases.Conn(ctx, r.db).Delete(&entities.Subscription{}, "id = ?", id)
+	if result.Error != nil {
+		return databases.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+)
+
+type SubscriptionRepository interface {
+	Create(ctx context.Context, sub *entities.Subscription) error
+	GetAll(ctx context.Context) ([]entities.Subscription, error)
+	GetActive(ctx context.Context) ([]entities.Subscription, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*entities.Subscription, error)
+	Update(ctx context.Context, sub *entities.Subscription) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
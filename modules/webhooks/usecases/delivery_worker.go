@@ -0,0 +1,158 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/repositories"
+)
+
+type DeliveryWorkerConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	Timeout      time.Duration
+	// AllowPrivateURLs lets the worker post over plain http and to
+	// private addresses, for tests against a local server.
+	AllowPrivateURLs bool
+}
+
+var DefaultDeliveryWorkerConfig = DeliveryWorkerConfig{
+	BatchSize:    50,
+	PollInterval: 5 * time.Second,
+	MaxAttempts:  8,
+	BaseBackoff:  30 * time.Second,
+	Timeout:      10 * time.Second,
+}
+
+// DeliveryWorker posts pending deliveries to subscribers. Failed attempts
+// are retried with exponential backoff until MaxAttempts, after which the
+// delivery is marked dead and only a manual redelivery sends it again.
+// Deliveries for an inactive subscription are cancelled without an
+// attempt.
+type DeliveryWorker struct {
+	subRepo      repositories.SubscriptionRepository
+	deliveryRepo repositories.DeliveryRepository
+	client       *http.Client
+	config       DeliveryWorkerConfig
+}
+
+func NewDeliveryWorker(subRepo repositories.SubscriptionRepository, deliveryRepo repositories.DeliveryRepository, config DeliveryWorkerConfig) *DeliveryWorker {
+	return &DeliveryWorker{
+		subRepo:      subRepo,
+		deliveryRepo: deliveryRepo,
+		client:       newDeliveryClient(config.Timeout, config.AllowPrivateURLs),
+		config:       config,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (w *DeliveryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.DeliverDue(ctx); err != nil {
+			log.Printf("Webhook delivery failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue attempts every delivery whose next attempt is due.
+func (w *DeliveryWorker) DeliverDue(ctx context.Context) error {
+	deliveries, err := w.deliveryRepo.GetDue(ctx, time.Now(), w.config.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		sub, err := w.subRepo.GetByID(ctx, delivery.SubscriptionID)
+		if err != nil {
+			return err
+		}
+
+		if !sub.Active {
+			delivery.Status = entities.DeliveryCancelled
+			delivery.LastError = "subscription is inactive"
+			delivery.Updated_at = time.Now()
+			if err := w.deliveryRepo.Update(ctx, delivery); err != nil {
+				return err
+			}
+			continue
+		}
+
+		status, sendErr := w.send(ctx, sub, delivery)
+
+		delivery.Attempts++
+		delivery.ResponseStatus = status
+		delivery.Updated_at = time.Now()
+
+		switch {
+		case sendErr == nil:
+			now := time.Now()
+			delivery.Status = entities.DeliverySucceeded
+			delivery.DeliveredAt = &now
+			delivery.LastError = ""
+		case delivery.Attempts >= w.config.MaxAttempts:
+			delivery.Status = entities.DeliveryDead
+			delivery.LastError = sendErr.Error()
+		default:
+			delivery.LastError = sendErr.Error()
+			delivery.NextAttemptAt = time.Now().Add(w.config.BaseBackoff << (delivery.Attempts - 1))
+		}
+
+		if err := w.deliveryRepo.Update(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *DeliveryWorker) send(ctx context.Context, sub *entities.Subscription, delivery *entities.Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if req.URL.Scheme != "https" && !w.config.AllowPrivateURLs {
+		return 0, fmt.Errorf("webhook URL scheme %q is not https", req.URL.Scheme)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "usermanagement-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.EventID.String())
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(sub.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,238 @@
+package usecases_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/config"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/repositories"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/usecases"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/databases/migrations"
+)
+
+// workerFixture is a delivery worker on a fresh SQLite database, posting
+// to a subscriber that answers with status.
+type workerFixture struct {
+	subs       repositories.SubscriptionRepository
+	deliveries repositories.DeliveryRepository
+	worker     *usecases.DeliveryWorker
+	server     *httptest.Server
+	status     atomic.Int32
+	requests   atomic.Int32
+	lastHeader atomic.Pointer[http.Header]
+}
+
+var testWorkerConfig = usecases.DeliveryWorkerConfig{
+	BatchSize:   10,
+	MaxAttempts: 3,
+	BaseBackoff: time.Minute,
+	Timeout:     time.Second,
+	// httptest serves plain http on loopback
+	AllowPrivateURLs: true,
+}
+
+func newWorkerFixture(t *testing.T) *workerFixture {
+	t.Helper()
+
+	db := databases.Connect(&config.Config{DBDriver: databases.DriverSQLite, DBPath: ":memory:"})
+	migrations.Migrate(db)
+
+	f := &workerFixture{
+		subs:       repositories.NewSubscriptionPostgresRepository(db),
+		deliveries: repositories.NewDeliveryPostgresRepository(db),
+	}
+	f.status.Store(http.StatusOK)
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests.Add(1)
+		header := r.Header.Clone()
+		f.lastHeader.Store(&header)
+		w.WriteHeader(int(f.status.Load()))
+	}))
+	t.Cleanup(f.server.Close)
+	f.worker = usecases.NewDeliveryWorker(f.subs, f.deliveries, testWorkerConfig)
+	return f
+}
+
+// queue stores a subscription and a delivery due now for it.
+func (f *workerFixture) queue(t *testing.T, active bool) *entities.Delivery {
+	t.Helper()
+	ctx := context.Background()
+
+	sub := &entities.Subscription{
+		ID:         uuid.New(),
+		URL:        f.server.URL,
+		Secret:     "secret",
+		Events:     entities.EventWildcard,
+		Active:     true,
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	if err := f.subs.Create(ctx, sub); err != nil {
+		t.Fatalf("Create subscription: %v", err)
+	}
+	if !active {
+		// the column defaults to true, so a false Active is only saved by an update
+		sub.Active = false
+		if err := f.subs.Update(ctx, sub); err != nil {
+			t.Fatalf("Update subscription: %v", err)
+		}
+	}
+
+	delivery := &entities.Delivery{
+		ID:             uuid.New(),
+		SubscriptionID: sub.ID,
+		EventID:        uuid.New(),
+		EventType:      "user.created",
+		Payload:        `{"type":"user.created"}`,
+		Status:         entities.DeliveryPending,
+		NextAttemptAt:  time.Now(),
+		Created_at:     time.Now(),
+		Updated_at:     time.Now(),
+	}
+	if err := f.deliveries.Insert(ctx, delivery); err != nil {
+		t.Fatalf("Insert delivery: %v", err)
+	}
+	return delivery
+}
+
+// deliverDue runs the worker once and returns the delivery afterwards.
+func (f *workerFixture) deliverDue(t *testing.T, id uuid.UUID) *entities.Delivery {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := f.worker.DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	delivery, err := f.deliveries.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	return delivery
+}
+
+func TestDeliveryWorkerInactiveSubscription(t *testing.T) {
+	f := newWorkerFixture(t)
+	queued := f.queue(t, false)
+
+	got := f.deliverDue(t, queued.ID)
+	if got.Status != entities.DeliveryCancelled || got.Attempts != 0 {
+		t.Errorf("delivery = %s after %d attempts, want cancelled after 0", got.Status, got.Attempts)
+	}
+	if n := f.requests.Load(); n != 0 {
+		t.Errorf("subscriber got %d requests, want 0", n)
+	}
+}
+
+func TestDeliveryWorkerSuccess(t *testing.T) {
+	f := newWorkerFixture(t)
+	queued := f.queue(t, true)
+
+	got := f.deliverDue(t, queued.ID)
+	if got.Status != entities.DeliverySucceeded || got.Attempts != 1 || got.DeliveredAt == nil || got.ResponseStatus != http.StatusOK {
+		t.Fatalf("delivery = %+v, want succeeded after 1 attempt", got)
+	}
+
+	header := *f.lastHeader.Load()
+	timestamp, err := strconv.ParseInt(header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Webhook-Timestamp %q: %v", header.Get("X-Webhook-Timestamp"), err)
+	}
+	if want := usecases.Sign("secret", timestamp, []byte(queued.Payload)); header.Get("X-Webhook-Signature") != want {
+		t.Errorf("X-Webhook-Signature = %q, want %q", header.Get("X-Webhook-Signature"), want)
+	}
+	if header.Get("X-Webhook-Id") != queued.EventID.String() || header.Get("X-Webhook-Event") != queued.EventType {
+		t.Errorf("headers = %v", header)
+	}
+}
+
+func TestDeliveryWorkerRetries(t *testing.T) {
+	ctx := context.Background()
+	f := newWorkerFixture(t)
+	f.status.Store(http.StatusInternalServerError)
+	queued := f.queue(t, true)
+
+	// each failure doubles the wait before the next attempt
+	for attempt := 1; attempt < testWorkerConfig.MaxAttempts; attempt++ {
+		before := time.Now()
+		got := f.deliverDue(t, queued.ID)
+		if got.Status != entities.DeliveryPending || got.Attempts != attempt || got.ResponseStatus != http.StatusInternalServerError || got.LastError == "" {
+			t.Fatalf("attempt %d: delivery = %+v, want pending", attempt, got)
+		}
+		backoff := testWorkerConfig.BaseBackoff << (attempt - 1)
+		if wait := got.NextAttemptAt.Sub(before); wait < backoff || wait > backoff+time.Second {
+			t.Errorf("attempt %d: next attempt in %s, want %s", attempt, wait, backoff)
+		}
+
+		// not due yet
+		f.deliverDue(t, queued.ID)
+		if n := f.requests.Load(); n != int32(attempt) {
+			t.Fatalf("attempt %d: subscriber got %d requests, want %d", attempt, n, attempt)
+		}
+
+		got.NextAttemptAt = time.Now()
+		if err := f.deliveries.Update(ctx, got); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	// the last attempt dead-letters the delivery
+	got := f.deliverDue(t, queued.ID)
+	if got.Status != entities.DeliveryDead || got.Attempts != testWorkerConfig.MaxAttempts {
+		t.Fatalf("delivery = %s after %d attempts, want dead after %d", got.Status, got.Attempts, testWorkerConfig.MaxAttempts)
+	}
+	got.NextAttemptAt = time.Now().Add(-time.Hour)
+	if err := f.deliveries.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	f.deliverDue(t, queued.ID)
+	if n := f.requests.Load(); n != int32(testWorkerConfig.MaxAttempts) {
+		t.Errorf("subscriber got %d requests, want %d: dead deliveries must not be retried", n, testWorkerConfig.MaxAttempts)
+	}
+}
+
+func TestDeliveryWorkerRefusesPrivateURLs(t *testing.T) {
+	f := newWorkerFixture(t)
+	config := testWorkerConfig
+	config.AllowPrivateURLs = false
+	f.worker = usecases.NewDeliveryWorker(f.subs, f.deliveries, config)
+
+	plain := f.queue(t, true)
+	// https, but still on loopback
+	f.server = httptest.NewTLSServer(f.server.Config.Handler)
+	t.Cleanup(f.server.Close)
+	loopback := f.queue(t, true)
+
+	for _, queued := range []*entities.Delivery{plain, loopback} {
+		got := f.deliverDue(t, queued.ID)
+		if got.Status != entities.DeliveryPending || got.Attempts != 1 || got.LastError == "" {
+			t.Errorf("delivery = %+v, want a failed attempt", got)
+		}
+	}
+	if n := f.requests.Load(); n != 0 {
+		t.Errorf("subscriber got %d requests, want 0", n)
+	}
+}
+
+func TestDeliveryWorkerDoesNotFollowRedirects(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.server = httptest.NewServer(http.RedirectHandler(f.server.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(f.server.Close)
+	queued := f.queue(t, true)
+
+	got := f.deliverDue(t, queued.ID)
+	if got.Status != entities.DeliveryPending || got.ResponseStatus != http.StatusTemporaryRedirect {
+		t.Errorf("delivery = %s with status %d, want pending with 307", got.Status, got.ResponseStatus)
+	}
+	if n := f.requests.Load(); n != 0 {
+		t.Errorf("redirect target got %d requests, want 0", n)
+	}
+}
@@ -0,0 +1,22 @@
+package usecases_test
+
+import (
+	"testing"
+
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/usecases"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	want := "v1=11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5"
+
+	if got := usecases.Sign("whsec_test", 1700000000, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if got := usecases.Sign("other", 1700000000, body); got == want {
+		t.Error("signature does not depend on the secret")
+	}
+	if got := usecases.Sign("whsec_test", 1700000001, body); got == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
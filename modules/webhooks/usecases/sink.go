@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/repositories"
+	"github.com/natchaphonbw/usermanagement/pkg/outbox"
+)
+
+// envelope is the JSON body posted to subscribers.
+type envelope struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type webhookSink struct {
+	subRepo      repositories.SubscriptionRepository
+	deliveryRepo repositories.DeliveryRepository
+}
+
+// NewWebhookSink returns an outbox sink that fans events out into one
+// pending delivery per matching subscription.
+func NewWebhookSink(subRepo repositories.SubscriptionRepository, deliveryRepo repositories.DeliveryRepository) outbox.Sink {
+	return &webhookSink{
+		subRepo:      subRepo,
+		deliveryRepo: deliveryRepo,
+	}
+}
+
+func (s *webhookSink) Deliver(ctx context.Context, event outbox.Event) error {
+	subs, err := s.subRepo.GetActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(envelope{
+		ID:         event.EventID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data:       json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		events := strings.Split(sub.Events, ",")
+		if !slices.Contains(events, entities.EventWildcard) && !slices.Contains(events, event.Type) {
+			continue
+		}
+
+		delivery := &entities.Delivery{
+			ID:             uuid.New(),
+			SubscriptionID: sub.ID,
+			EventID:        event.EventID,
+			EventType:      event.Type,
+			Payload:        string(body),
+			Status:         entities.DeliveryPending,
+			NextAttemptAt:  time.Now(),
+			Created_at:     time.Now(),
+			Updated_at:     time.Now(),
+		}
+		if err := s.deliveryRepo.Insert(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+// Webhook URLs are chosen by whoever holds the admin API, and the worker
+// posts to them from inside our network. They must be https and reach
+// public addresses only, or a subscription could probe internal services
+// and cloud metadata endpoints.
+
+// isPublicAddr reports whether addr may receive webhooks.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsUnspecified() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast()
+}
+
+// checkSubscriptionURL refuses URLs that aren't https or whose host
+// resolves to an address that isn't public. The worker checks the address
+// again when it connects, as DNS may have changed since.
+func checkSubscriptionURL(ctx context.Context, rawURL string) *app_errors.AppError {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return app_errors.BadRequest("Invalid webhook URL", err)
+	}
+	if u.Scheme != "https" {
+		return app_errors.BadRequest("Webhook URL must use https", nil)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return app_errors.BadRequest("Webhook URL host does not resolve", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return app_errors.BadRequest("Webhook URL must not point to a private network", nil)
+		}
+	}
+	return nil
+}
+
+// newDeliveryClient returns the client that posts webhooks. It doesn't
+// follow redirects, and unless allowPrivate it only speaks https and only
+// connects to public addresses, whatever the DNS answered.
+func newDeliveryClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		// a proxy would connect on our behalf, past the address check
+		transport.Proxy = nil
+		dialer := &net.Dialer{Timeout: timeout, Control: dialPublicOnly}
+		transport.DialContext = dialer.DialContext
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// subscribers answer themselves, a redirect could lead anywhere
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublicOnly is a net.Dialer Control that refuses addresses that
+// aren't public.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is not public", addrPort.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/dtos"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+type WebhookUsecase interface {
+	CreateSubscription(ctx context.Context, input dtos.CreateSubscriptionRequest) (*dtos.CreateSubscriptionResponse, *app_errors.AppError)
+	GetAllSubscriptions(ctx context.Context) ([]*dtos.SubscriptionResponse, *app_errors.AppError)
+	GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*dtos.SubscriptionResponse, *app_errors.AppError)
+	UpdateSubscription(ctx context.Context, id uuid.UUID, input dtos.UpdateSubscriptionRequest) (*dtos.SubscriptionResponse, *app_errors.AppError)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) *app_errors.AppError
+	GetDeliveries(ctx context.Context, subscriptionID uuid.UUID) ([]*dtos.DeliveryResponse, *app_errors.AppError)
+	Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*dtos.DeliveryResponse, *app_errors.AppError)
+}
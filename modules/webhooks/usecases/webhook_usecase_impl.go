@@ -0,0 +1,207 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	userEntities "github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/repositories"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+const deliveryHistoryLimit = 100
+
+// SupportedEvents lists the event types a subscription may ask for.
+var SupportedEvents = []string{
+	entities.EventWildcard,
+	userEntities.EventUserCreated,
+	userEntities.EventUserUpdated,
+	userEntities.EventUserDeleted,
+	userEntities.EventUserLocked,
+	userEntities.EventUserUnlocked,
+	userEntities.EventSessionRevoked,
+	userEntities.EventUserSessionsRevoked,
+}
+
+type webhookUsecaseImpl struct {
+	subRepo      repositories.SubscriptionRepository
+	deliveryRepo repositories.DeliveryRepository
+}
+
+func NewWebhookUsecase(subRepo repositories.SubscriptionRepository, deliveryRepo repositories.DeliveryRepository) WebhookUsecase {
+	return &webhookUsecaseImpl{
+		subRepo:      subRepo,
+		deliveryRepo: deliveryRepo,
+	}
+}
+
+// Create Subscription
+func (u *webhookUsecaseImpl) CreateSubscription(ctx context.Context, input dtos.CreateSubscriptionRequest) (*dtos.CreateSubscriptionResponse, *app_errors.AppError) {
+	if appErr := validateEvents(input.Events); appErr != nil {
+		return nil, appErr
+	}
+	if appErr := checkSubscriptionURL(ctx, input.URL); appErr != nil {
+		return nil, appErr
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, app_errors.InternalServer("Failed to generate secret", err)
+	}
+
+	sub := &entities.Subscription{
+		ID:          uuid.New(),
+		URL:         input.URL,
+		Secret:      "whsec_" + secret,
+		Events:      strings.Join(input.Events, ","),
+		Description: input.Description,
+		Active:      true,
+		Created_at:  time.Now(),
+		Updated_at:  time.Now(),
+	}
+
+	if err := u.subRepo.Create(ctx, sub); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to create subscription")
+	}
+
+	return &dtos.CreateSubscriptionResponse{
+		SubscriptionResponse: *dtos.FromSubscriptionEntity(sub),
+		Secret:               sub.Secret,
+	}, nil
+}
+
+// Get All Subscriptions
+func (u *webhookUsecaseImpl) GetAllSubscriptions(ctx context.Context) ([]*dtos.SubscriptionResponse, *app_errors.AppError) {
+	subs, err := u.subRepo.GetAll(ctx)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get subscriptions")
+	}
+	return dtos.FromSubscriptionEntities(subs), nil
+}
+
+// Get Subscription By ID
+func (u *webhookUsecaseImpl) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*dtos.SubscriptionResponse, *app_errors.AppError) {
+	sub, appErr := u.getSubscription(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return dtos.FromSubscriptionEntity(sub), nil
+}
+
+// Update Subscription
+func (u *webhookUsecaseImpl) UpdateSubscription(ctx context.Context, id uuid.UUID, input dtos.UpdateSubscriptionRequest) (*dtos.SubscriptionResponse, *app_errors.AppError) {
+	sub, appErr := u.getSubscription(ctx, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if input.URL != nil {
+		if appErr := checkSubscriptionURL(ctx, *input.URL); appErr != nil {
+			return nil, appErr
+		}
+		sub.URL = *input.URL
+	}
+	if input.Events != nil {
+		if appErr := validateEvents(input.Events); appErr != nil {
+			return nil, appErr
+		}
+		sub.Events = strings.Join(input.Events, ",")
+	}
+	if input.Description != nil {
+		sub.Description = *input.Description
+	}
+	if input.Active != nil {
+		sub.Active = *input.Active
+	}
+	sub.Updated_at = time.Now()
+
+	if err := u.subRepo.Update(ctx, sub); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to update subscription")
+	}
+
+	return dtos.FromSubscriptionEntity(sub), nil
+}
+
+// Delete Subscription
+func (u *webhookUsecaseImpl) DeleteSubscription(ctx context.Context, id uuid.UUID) *app_errors.AppError {
+	if err := u.subRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return app_errors.NotFound("Subscription not found", err)
+		}
+		return app_errors.FromDB(err, "Failed to delete subscription")
+	}
+	return nil
+}
+
+// Get Deliveries
+func (u *webhookUsecaseImpl) GetDeliveries(ctx context.Context, subscriptionID uuid.UUID) ([]*dtos.DeliveryResponse, *app_errors.AppError) {
+	if _, appErr := u.getSubscription(ctx, subscriptionID); appErr != nil {
+		return nil, appErr
+	}
+
+	deliveries, err := u.deliveryRepo.GetBySubscriptionID(ctx, subscriptionID, deliveryHistoryLimit)
+	if err != nil {
+		return nil, app_errors.FromDB(err, "Failed to get deliveries")
+	}
+	return dtos.FromDeliveryEntities(deliveries), nil
+}
+
+// Redeliver queues a delivery again with a fresh retry budget.
+func (u *webhookUsecaseImpl) Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*dtos.DeliveryResponse, *app_errors.AppError) {
+	sub, appErr := u.getSubscription(ctx, subscriptionID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !sub.Active {
+		return nil, app_errors.Conflict("Subscription is inactive", nil)
+	}
+
+	delivery, err := u.deliveryRepo.GetByID(ctx, deliveryID)
+	if err != nil || delivery.SubscriptionID != subscriptionID {
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Delivery not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get delivery")
+	}
+
+	delivery.Status = entities.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.Updated_at = time.Now()
+
+	if err := u.deliveryRepo.Update(ctx, delivery); err != nil {
+		return nil, app_errors.FromDB(err, "Failed to queue delivery")
+	}
+
+	return dtos.FromDeliveryEntity(delivery), nil
+}
+
+func (u *webhookUsecaseImpl) getSubscription(ctx context.Context, id uuid.UUID) (*entities.Subscription, *app_errors.AppError) {
+	sub, err := u.subRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.NotFound("Subscription not found", err)
+		}
+		return nil, app_errors.FromDB(err, "Failed to get subscription")
+	}
+	return sub, nil
+}
+
+func validateEvents(events []string) *app_errors.AppError {
+	for _, event := range events {
+		if !slices.Contains(SupportedEvents, event) {
+			return app_errors.BadRequest(fmt.Sprintf("Unsupported event %q", event), nil).WithDetails(SupportedEvents)
+		}
+	}
+	return nil
+}
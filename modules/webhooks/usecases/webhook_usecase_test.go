@@ -0,0 +1,42 @@
+package usecases_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/dtos"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/modules/webhooks/usecases"
+)
+
+func TestSubscriptionURLMustBePublicHTTPS(t *testing.T) {
+	ctx := context.Background()
+	f := newWorkerFixture(t)
+	uc := usecases.NewWebhookUsecase(f.subs, f.deliveries)
+	events := []string{entities.EventWildcard}
+
+	created, appErr := uc.CreateSubscription(ctx, dtos.CreateSubscriptionRequest{URL: "https://93.184.215.14/hooks", Events: events})
+	if appErr != nil {
+		t.Fatalf("CreateSubscription(public https): %v", appErr)
+	}
+
+	for _, rawURL := range []string{
+		"http://93.184.215.14/hooks",
+		"https://127.0.0.1/hooks",
+		"https://localhost/hooks",
+		"https://[::1]/hooks",
+		"https://[::ffff:10.0.0.1]/hooks",
+		"https://10.1.2.3/hooks",
+		"https://192.168.0.10/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://0.0.0.0/hooks",
+	} {
+		if _, appErr := uc.CreateSubscription(ctx, dtos.CreateSubscriptionRequest{URL: rawURL, Events: events}); appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("CreateSubscription(%s) = %v, want 400", rawURL, appErr)
+		}
+		if _, appErr := uc.UpdateSubscription(ctx, created.ID, dtos.UpdateSubscriptionRequest{URL: &rawURL}); appErr == nil || appErr.Code != http.StatusBadRequest {
+			t.Errorf("UpdateSubscription(%s) = %v, want 400", rawURL, appErr)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package breach looks passwords up in a local copy of the Have I Been Pwned
+// password corpus, so no password or hash prefix ever leaves the server.
+//
+// The corpus file is built by cmd/breach-import and laid out as
+//
+//	magic      8 bytes  "HIBPSHA1"
+//	offsets    (1<<20 + 1) little-endian uint64, the index of the first
+//	           record for every 5 hex digit (20 bit) hash prefix
+//	records    24 bytes each: SHA-1 (20) + little-endian uint32 count,
+//	           sorted by hash
+//
+// which mirrors the k-anonymity range API: a lookup reads the prefix's
+// range and binary searches it. The file is memory-mapped where supported.
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const (
+	magic = "HIBPSHA1"
+
+	prefixBits  = 20
+	prefixCount = 1 << prefixBits
+
+	headerSize = len(magic) + (prefixCount+1)*8
+	recordSize = sha1.Size + 4
+)
+
+var ErrInvalidCorpus = errors.New("invalid breach corpus")
+
+// Corpus is an opened corpus file, safe for concurrent use.
+type Corpus struct {
+	data    []byte
+	records []byte
+	unmap   func() error
+}
+
+// Open maps the corpus at path and checks its layout.
+func Open(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size < int64(headerSize) || (size-int64(headerSize))%recordSize != 0 {
+		return nil, fmt.Errorf("%w: %s has unexpected size %d", ErrInvalidCorpus, path, size)
+	}
+
+	data, unmap, err := mapFile(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("map %s: %w", path, err)
+	}
+
+	c := &Corpus{data: data, records: data[headerSize:], unmap: unmap}
+	if err := c.check(); err != nil {
+		_ = unmap()
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCorpus, path, err)
+	}
+	return c, nil
+}
+
+func (c *Corpus) check() error {
+	if string(c.data[:len(magic)]) != magic {
+		return errors.New("bad magic")
+	}
+	if c.offset(0) != 0 || c.offset(prefixCount) != c.Len() {
+		return errors.New("offsets do not cover the records")
+	}
+	return nil
+}
+
+// Close unmaps the corpus, it must not be used afterwards.
+func (c *Corpus) Close() error {
+	return c.unmap()
+}
+
+// Len is the number of hashes in the corpus.
+func (c *Corpus) Len() int {
+	return len(c.records) / recordSize
+}
+
+// Count returns how often password was seen in breaches, 0 if never.
+func (c *Corpus) Count(password string) int {
+	return int(c.CountHash(sha1.Sum([]byte(password))))
+}
+
+// CountHash returns the breach count for a SHA-1 hash.
+func (c *Corpus) CountHash(hash [sha1.Size]byte) uint32 {
+	prefix := hashPrefix(hash)
+	lo, hi := c.offset(prefix), c.offset(prefix+1)
+	if lo > hi || hi > c.Len() {
+		return 0
+	}
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(c.record(lo + i)[:sha1.Size], hash[:]) >= 0
+	})
+	if i == hi {
+		return 0
+	}
+
+	record := c.record(i)
+	if !bytes.Equal(record[:sha1.Size], hash[:]) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(record[sha1.Size:])
+}
+
+func (c *Corpus) offset(prefix int) int {
+	at := len(magic) + prefix*8
+	return int(binary.LittleEndian.Uint64(c.data[at : at+8]))
+}
+
+func (c *Corpus) record(i int) []byte {
+	return c.records[i*recordSize : (i+1)*recordSize]
+}
+
+// hashPrefix is the first 5 hex digits of hash as a number.
+func hashPrefix(hash [sha1.Size]byte) int {
+	return int(hash[0])<<12 | int(hash[1])<<4 | int(hash[2])>>4
+}
@@ -0,0 +1,157 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var breached = map[string]uint32{
+	"password":      3861493,
+	"hunter2":       24230,
+	"Tr0ub4dor&3":   37,
+	"correct horse": 2,
+}
+
+func hashLine(password string, count uint32) string {
+	return fmt.Sprintf("%X:%d", sha1.Sum([]byte(password)), count)
+}
+
+func sortedHashes() string {
+	var lines []string
+	for password, count := range breached {
+		lines = append(lines, hashLine(password, count))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\r\n")
+}
+
+func build(t *testing.T, fill func(w *Writer) error) *Corpus {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "corpus.bin")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := fill(w); err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	c, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func checkCounts(t *testing.T, c *Corpus, minCount uint32) {
+	t.Helper()
+
+	for password, count := range breached {
+		want := int(count)
+		if count < minCount {
+			want = 0
+		}
+		if got := c.Count(password); got != want {
+			t.Errorf("Count(%q) = %d, want %d", password, got, want)
+		}
+	}
+	for _, password := range []string{"", "Correct-Orbit-42", "Password"} {
+		if got := c.Count(password); got != 0 {
+			t.Errorf("Count(%q) = %d, want 0", password, got)
+		}
+	}
+}
+
+func TestImportHashes(t *testing.T) {
+	c := build(t, func(w *Writer) error {
+		added, err := ImportHashes(w, strings.NewReader(sortedHashes()), 10)
+		if added != 3 {
+			t.Errorf("added %d hashes, want 3", added)
+		}
+		return err
+	})
+
+	if c.Len() != 3 {
+		t.Errorf("Len = %d, want 3", c.Len())
+	}
+	checkCounts(t, c, 10)
+}
+
+func TestImportRanges(t *testing.T) {
+	dir := t.TempDir()
+	for password, count := range breached {
+		line := hashLine(password, count)
+		name := filepath.Join(dir, line[:5]+".txt")
+
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(f, line[5:])
+		f.Close()
+	}
+
+	c := build(t, func(w *Writer) error {
+		_, err := ImportRanges(w, dir, 1)
+		return err
+	})
+
+	checkCounts(t, c, 1)
+}
+
+func TestEmptyCorpus(t *testing.T) {
+	c := build(t, func(w *Writer) error { return nil })
+
+	if c.Len() != 0 {
+		t.Errorf("Len = %d, want 0", c.Len())
+	}
+	checkCounts(t, c, 1<<31)
+}
+
+func TestWriterRejectsUnsortedInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.bin")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer w.Close()
+
+	input := hashLine("b", 1) + "\n" + hashLine("a", 1) + "\n" + hashLine("b", 1)
+	if _, err := ImportHashes(w, strings.NewReader(input), 1); err == nil {
+		t.Error("ImportHashes accepted unsorted input")
+	}
+}
+
+func TestOpenRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := map[string][]byte{
+		"empty":     {},
+		"truncated": []byte(magic),
+		"bad magic": make([]byte, headerSize),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_"))
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Open(path); !errors.Is(err, ErrInvalidCorpus) {
+				t.Errorf("Open = %v, want ErrInvalidCorpus", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImportHashes adds the lines of a sorted "HASH:COUNT" file, the format of
+// the downloadable pwned-passwords-sha1-ordered-by-hash file. Hashes seen
+// fewer than minCount times are skipped. It returns the number of hashes added.
+func ImportHashes(w *Writer, r io.Reader, minCount uint32) (int, error) {
+	scanner := bufio.NewScanner(r)
+	added := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		hash, count, err := parseLine("", text)
+		if err != nil {
+			return added, fmt.Errorf("line %d: %w", line, err)
+		}
+		if count < minCount {
+			continue
+		}
+		if err := w.Add(hash, count); err != nil {
+			return added, fmt.Errorf("line %d: %w", line, err)
+		}
+		added++
+	}
+	return added, scanner.Err()
+}
+
+// ImportRanges adds a directory of range files as written by the Pwned
+// Passwords downloader: one file per prefix named ABCDE.txt holding
+// "SUFFIX:COUNT" lines. Missing prefixes are skipped.
+func ImportRanges(w *Writer, dir string, minCount uint32) (int, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	// ABCDE.txt for every prefix, in prefix order
+	prefixes := make(map[int]string)
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), ".txt")
+		if !ok || len(name) != 5 || file.IsDir() {
+			continue
+		}
+		if prefix, err := strconv.ParseUint(name, 16, prefixBits); err == nil {
+			prefixes[int(prefix)] = file.Name()
+		}
+	}
+
+	added := 0
+	for prefix := 0; prefix < prefixCount; prefix++ {
+		file, ok := prefixes[prefix]
+		if !ok {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return added, err
+		}
+		name := fmt.Sprintf("%05X", prefix)
+
+		type entry struct {
+			hash  [sha1.Size]byte
+			count uint32
+		}
+		var entries []entry
+		for line, text := range strings.Split(string(data), "\n") {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+
+			hash, count, err := parseLine(name, text)
+			if err != nil {
+				return added, fmt.Errorf("%s.txt line %d: %w", name, line+1, err)
+			}
+			if count >= minCount {
+				entries = append(entries, entry{hash, count})
+			}
+		}
+
+		// the API returns suffixes sorted, but don't rely on it for local files
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].hash[:], entries[j].hash[:]) < 0
+		})
+		for _, e := range entries {
+			if err := w.Add(e.hash, e.count); err != nil {
+				return added, fmt.Errorf("%s.txt: %w", name, err)
+			}
+			added++
+		}
+	}
+	return added, nil
+}
+
+// parseLine parses "HASH:COUNT", with the first digits of HASH given
+// separately for range files.
+func parseLine(prefix, line string) ([sha1.Size]byte, uint32, error) {
+	var hash [sha1.Size]byte
+
+	hexHash, countText, ok := strings.Cut(line, ":")
+	if !ok {
+		return hash, 0, fmt.Errorf("missing count in %q", line)
+	}
+
+	hexHash = prefix + hexHash
+	if len(hexHash) != hex.EncodedLen(sha1.Size) {
+		return hash, 0, fmt.Errorf("hash %q is not a SHA-1", hexHash)
+	}
+	if _, err := hex.Decode(hash[:], []byte(hexHash)); err != nil {
+		return hash, 0, fmt.Errorf("hash %q: %w", hexHash, err)
+	}
+
+	count, err := strconv.ParseUint(countText, 10, 32)
+	if err != nil {
+		return hash, 0, fmt.Errorf("count %q: %w", countText, err)
+	}
+	return hash, uint32(count), nil
+}
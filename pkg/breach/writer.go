@@ -0,0 +1,80 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Writer builds a corpus file. Hashes must be added in ascending order.
+type Writer struct {
+	f      *os.File
+	buf    *bufio.Writer
+	counts []uint64
+	last   []byte
+}
+
+// Create starts a new corpus at path, replacing any existing file.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the header is written last, once the offsets are known
+	if _, err := f.Seek(int64(headerSize), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Writer{
+		f:      f,
+		buf:    bufio.NewWriterSize(f, 1<<20),
+		counts: make([]uint64, prefixCount),
+	}, nil
+}
+
+// Add appends hash with its breach count.
+func (w *Writer) Add(hash [sha1.Size]byte, count uint32) error {
+	if w.last != nil && bytes.Compare(hash[:], w.last) <= 0 {
+		return fmt.Errorf("hash %X is not after %X, input must be sorted and unique", hash, w.last)
+	}
+
+	var record [recordSize]byte
+	copy(record[:], hash[:])
+	binary.LittleEndian.PutUint32(record[sha1.Size:], count)
+	if _, err := w.buf.Write(record[:]); err != nil {
+		return err
+	}
+
+	w.counts[hashPrefix(hash)]++
+	w.last = append(w.last[:0], hash[:]...)
+	return nil
+}
+
+// Close writes the header and closes the file.
+func (w *Writer) Close() error {
+	defer w.f.Close()
+
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	var offset uint64
+	for prefix := 0; prefix <= prefixCount; prefix++ {
+		binary.LittleEndian.PutUint64(header[len(magic)+prefix*8:], offset)
+		if prefix < prefixCount {
+			offset += w.counts[prefix]
+		}
+	}
+
+	if _, err := w.f.WriteAt(header, 0); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
@@ -0,0 +1,135 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Domain errors returned by repositories in place of raw driver errors.
+var (
+	ErrDuplicateKey         = errors.New("duplicate key")
+	ErrForeignKeyViolation  = errors.New("foreign key violation")
+	ErrCheckViolation       = errors.New("check constraint violation")
+	ErrNotNullViolation     = errors.New("not null violation")
+	ErrSerializationFailure = errors.New("serialization failure")
+	ErrTimeout              = errors.New("database timeout")
+	ErrUnavailable          = errors.New("database unavailable")
+)
+
+// DBError keeps the domain error, the violated constraint (if known)
+// and the original driver error together.
+type DBError struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (e *DBError) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + " (" + e.Constraint + "): " + e.Err.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap lets errors.Is match both the domain error and the driver error.
+func (e *DBError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// TranslateError converts driver errors into domain errors.
+// nil and gorm.ErrRecordNotFound are returned unchanged.
+func TranslateError(err error) error {
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var dbErr *DBError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if kind := pgErrorKind(pgErr.Code); kind != nil {
+			return &DBError{Kind: kind, Constraint: pgErr.ConstraintName, Err: err}
+		}
+		return err
+	}
+
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		if kind := sqliteErrorKind(sqliteErr.Code()); kind != nil {
+			return &DBError{Kind: kind, Err: err}
+		}
+		return err
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &DBError{Kind: ErrDuplicateKey, Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &DBError{Kind: ErrForeignKeyViolation, Err: err}
+	case errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return &DBError{Kind: ErrCheckViolation, Err: err}
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return &DBError{Kind: ErrTimeout, Err: err}
+	}
+
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) {
+		return &DBError{Kind: ErrUnavailable, Err: err}
+	}
+
+	return err
+}
+
+// pgErrorKind maps a PostgreSQL SQLSTATE code to a domain error,
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+func pgErrorKind(code string) error {
+	switch code {
+	case "23505":
+		return ErrDuplicateKey
+	case "23503":
+		return ErrForeignKeyViolation
+	case "23514":
+		return ErrCheckViolation
+	case "23502":
+		return ErrNotNullViolation
+	case "40001", "40P01":
+		return ErrSerializationFailure
+	case "57014", "55P03":
+		return ErrTimeout
+	case "57P01", "57P02", "57P03":
+		return ErrUnavailable
+	}
+
+	// connection exceptions and insufficient resources
+	if strings.HasPrefix(code, "08") || strings.HasPrefix(code, "53") {
+		return ErrUnavailable
+	}
+
+	return nil
+}
+
+// sqliteErrorKind maps an SQLite extended result code to a domain error,
+// see https://www.sqlite.org/rescode.html.
+func sqliteErrorKind(code int) error {
+	switch code {
+	case 2067, 1555: // SQLITE_CONSTRAINT_UNIQUE, SQLITE_CONSTRAINT_PRIMARYKEY
+		return ErrDuplicateKey
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return ErrForeignKeyViolation
+	case 275: // SQLITE_CONSTRAINT_CHECK
+		return ErrCheckViolation
+	case 1299: // SQLITE_CONSTRAINT_NOTNULL
+		return ErrNotNullViolation
+	case 5, 6: // SQLITE_BUSY, SQLITE_LOCKED
+		return ErrTimeout
+	}
+
+	return nil
+}
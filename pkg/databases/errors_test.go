@@ -0,0 +1,119 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// sqliteError stands in for the driver's error type, which TranslateError
+// only knows by its Code method.
+type sqliteError int
+
+func (e sqliteError) Error() string { return fmt.Sprintf("sqlite error %d", int(e)) }
+func (e sqliteError) Code() int     { return int(e) }
+
+func TestTranslateErrorSQLSTATE(t *testing.T) {
+	tests := map[string]error{
+		"23505": ErrDuplicateKey,
+		"23503": ErrForeignKeyViolation,
+		"23514": ErrCheckViolation,
+		"23502": ErrNotNullViolation,
+		"40001": ErrSerializationFailure,
+		"40P01": ErrSerializationFailure,
+		"57014": ErrTimeout,
+		"55P03": ErrTimeout,
+		"57P01": ErrUnavailable,
+		"57P02": ErrUnavailable,
+		"57P03": ErrUnavailable,
+		"08006": ErrUnavailable,
+		"08001": ErrUnavailable,
+		"53300": ErrUnavailable,
+		"53100": ErrUnavailable,
+	}
+
+	for code, want := range tests {
+		pgErr := &pgconn.PgError{Code: code, ConstraintName: "users_email_key"}
+		err := TranslateError(fmt.Errorf("insert user: %w", pgErr))
+
+		var dbErr *DBError
+		if !errors.As(err, &dbErr) || dbErr.Kind != want {
+			t.Errorf("SQLSTATE %s: TranslateError = %v, want %v", code, err, want)
+			continue
+		}
+		if dbErr.Constraint != "users_email_key" || !errors.Is(err, pgErr) {
+			t.Errorf("SQLSTATE %s: lost the constraint or the driver error: %+v", code, dbErr)
+		}
+	}
+}
+
+func TestTranslateErrorUnknownSQLSTATE(t *testing.T) {
+	// syntax error, undefined table, division by zero
+	for _, code := range []string{"42601", "42P01", "22012"} {
+		pgErr := &pgconn.PgError{Code: code}
+		if err := TranslateError(pgErr); err != error(pgErr) {
+			t.Errorf("SQLSTATE %s: TranslateError = %v, want the error unchanged", code, err)
+		}
+	}
+}
+
+func TestTranslateErrorSQLite(t *testing.T) {
+	tests := map[int]error{
+		2067: ErrDuplicateKey,
+		1555: ErrDuplicateKey,
+		787:  ErrForeignKeyViolation,
+		275:  ErrCheckViolation,
+		1299: ErrNotNullViolation,
+		5:    ErrTimeout,
+		6:    ErrTimeout,
+	}
+
+	for code, want := range tests {
+		if err := TranslateError(sqliteError(code)); !errors.Is(err, want) {
+			t.Errorf("SQLite code %d: TranslateError = %v, want %v", code, err, want)
+		}
+	}
+	if err := TranslateError(sqliteError(1)); err != error(sqliteError(1)) {
+		t.Errorf("SQLITE_ERROR: TranslateError = %v, want the error unchanged", err)
+	}
+}
+
+func TestTranslateErrorGeneric(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"duplicated key", gorm.ErrDuplicatedKey, ErrDuplicateKey},
+		{"foreign key", gorm.ErrForeignKeyViolated, ErrForeignKeyViolation},
+		{"check", gorm.ErrCheckConstraintViolated, ErrCheckViolation},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), ErrTimeout},
+		{"connect", &pgconn.ConnectError{}, ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		if err := TranslateError(tt.err); !errors.Is(err, tt.want) || !errors.Is(err, tt.err) {
+			// %T, a bare ConnectError can't print itself
+			t.Errorf("%s: TranslateError = %T, want %v wrapping the original", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestTranslateErrorPassesThrough(t *testing.T) {
+	other := errors.New("something else")
+	for _, err := range []error{nil, gorm.ErrRecordNotFound, other} {
+		if got := TranslateError(err); got != err {
+			t.Errorf("TranslateError(%v) = %v, want it unchanged", err, got)
+		}
+	}
+
+	// translating twice keeps the first translation
+	once := TranslateError(&pgconn.PgError{Code: "23505"})
+	if twice := TranslateError(once); twice != once {
+		t.Errorf("TranslateError twice = %v, want %v", twice, once)
+	}
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+type emailCollision struct {
+	Email string
+	Count int
+}
+
+// normalizeEmails lower-cases existing emails and enforces uniqueness on the
+// normalised form. If existing rows collide once normalised, they are
+// reported and left untouched so they can be merged by hand.
+func normalizeEmails(db *gorm.DB) {
+	var collisions []emailCollision
+	err := db.Raw(`SELECT lower(trim(email)) AS email, count(*) AS count
+		FROM users
+		GROUP BY lower(trim(email))
+		HAVING count(*) > 1`).Scan(&collisions).Error
+	if err != nil {
+		log.Fatalf("Failed to check email collisions: %v", err)
+	}
+
+	if len(collisions) > 0 {
+		for _, c := range collisions {
+			log.Printf("Email collision: %d accounts share %q", c.Count, c.Email)
+		}
+		log.Printf("Skipping email normalisation: resolve %d collision(s) and restart", len(collisions))
+		return
+	}
+
+	if err := db.Exec(`UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email))`).Error; err != nil {
+		log.Fatalf("Failed to normalise emails: %v", err)
+	}
+
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email))`).Error; err != nil {
+		log.Fatalf("Failed to create email index: %v", err)
+	}
+}
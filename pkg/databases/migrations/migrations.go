@@ -3,7 +3,10 @@ package migrations
 import (
 	"log"
 
+	oauthEntities "github.com/natchaphonbw/usermanagement/modules/oauth/entities"
 	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	webhookEntities "github.com/natchaphonbw/usermanagement/modules/webhooks/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/outbox"
 	"gorm.io/gorm"
 )
 
@@ -11,10 +14,29 @@ func Migrate(db *gorm.DB) {
 	err := db.AutoMigrate(
 		&entities.User{},
 		&entities.Session{},
+		&entities.EmailChange{},
+		&entities.LoginLink{},
+		&entities.Passkey{},
+		&entities.PasskeyCeremony{},
+		&entities.AccessToken{},
+		&entities.Identity{},
+		&entities.SSOLoginState{},
+		&entities.SAMLLogin{},
+		&oauthEntities.ServiceAccount{},
+		&oauthEntities.ServiceAccountSecret{},
+		&oauthEntities.Client{},
+		&oauthEntities.AuthorizationRequest{},
+		&oauthEntities.Consent{},
+		&outbox.Event{},
+		&webhookEntities.Subscription{},
+		&webhookEntities.Delivery{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
+
+	convertPasswordHashes(db)
+	normalizeEmails(db)
 	log.Println("Migrations completed successfully")
 
 }
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"log"
+
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"gorm.io/gorm"
+)
+
+// legacyArgon2Prefix holds the parameters of every hash stored before
+// hashes carried their own, DefaultArgon2Config at the time.
+const legacyArgon2Prefix = "$argon2id$v=19$m=65536,t=3,p=2$"
+
+// convertPasswordHashes folds the old salt column into PHC-format hashes,
+// $argon2id$v=19$m=...$<salt>$<hash>, and drops it. Both values are
+// already unpadded standard base64, as PHC expects.
+func convertPasswordHashes(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&entities.User{}, "salt") {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`UPDATE users SET password_hash = ? || salt || '$' || password_hash
+			WHERE password_hash NOT LIKE '$%'`, legacyArgon2Prefix)
+		if result.Error != nil {
+			return result.Error
+		}
+		log.Printf("Converted %d password hash(es) to PHC format", result.RowsAffected)
+
+		return tx.Exec(`ALTER TABLE users DROP COLUMN salt`).Error
+	})
+	if err != nil {
+		log.Fatalf("Failed to convert password hashes: %v", err)
+	}
+}
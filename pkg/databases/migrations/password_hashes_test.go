@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/natchaphonbw/usermanagement/config"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+func TestConvertPasswordHashes(t *testing.T) {
+	db := databases.Connect(&config.Config{DBDriver: databases.DriverSQLite, DBPath: ":memory:"})
+
+	// the users table as it was with a separate salt column
+	err := db.Exec(`CREATE TABLE users (
+		id text PRIMARY KEY,
+		name varchar(100) NOT NULL,
+		email varchar(100) NOT NULL UNIQUE,
+		password_hash text NOT NULL,
+		salt text NOT NULL,
+		age int NOT NULL,
+		created_at timestamp DEFAULT current_timestamp,
+		updated_at timestamp DEFAULT current_timestamp
+	)`).Error
+	if err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+
+	salt := []byte("0123456789abcdef")
+	hash := argon2.IDKey([]byte("Correct-Orbit-42"), salt, 3, 64*1024, 2, 32)
+	err = db.Exec(`INSERT INTO users (id, name, email, password_hash, salt, age) VALUES (?, ?, ?, ?, ?, ?)`,
+		"8d7c5a4e-1f0b-4c2a-9e3d-5b6a7c8d9e0f", "Bob", "bob@example.com",
+		base64.RawStdEncoding.EncodeToString(hash), base64.RawStdEncoding.EncodeToString(salt), 30).Error
+	if err != nil {
+		t.Fatalf("insert legacy user: %v", err)
+	}
+
+	Migrate(db)
+	// a second run must leave converted hashes alone
+	Migrate(db)
+
+	if db.Migrator().HasColumn(&entities.User{}, "salt") {
+		t.Error("salt column was not dropped")
+	}
+
+	var user entities.User
+	if err := db.First(&user, "email = ?", "bob@example.com").Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+
+	match, err := utils.VerifyPassword(context.Background(), "Correct-Orbit-42", user.PasswordHash)
+	if err != nil || !match {
+		t.Fatalf("VerifyPassword(%q) = %v, %v, want true", user.PasswordHash, match, err)
+	}
+	if utils.PasswordNeedsRehash(user.PasswordHash, &utils.DefaultArgon2Config) {
+		t.Error("converted hash with default parameters reported as needing a rehash")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/glebarez/sqlite"
 	"github.com/natchaphonbw/usermanagement/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -11,18 +12,47 @@ import (
 
 var DB *gorm.DB
 
-func Connect(cfg *config.Config) *gorm.DB {
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
-	)
+// Connect opens the database selected by DB_DRIVER. The memory driver is
+// an in-memory SQLite database, gone when the process exits.
+func Connect(cfg *config.Config) *gorm.DB {
+	var dialector gorm.Dialector
+
+	switch cfg.DBDriver {
+	case DriverPostgres, "":
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
+		)
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(cfg.DBPath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	case DriverMemory:
+		dialector = sqlite.Open(":memory:?_pragma=foreign_keys(1)")
+	default:
+		log.Fatalf("Unknown database driver %q", cfg.DBDriver)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 
 	}
 
-	log.Println("Connected to the PostgreSQL database successfully")
+	// SQLite allows a single writer, and every connection to :memory: is a
+	// separate database.
+	if cfg.DBDriver == DriverSQLite || cfg.DBDriver == DriverMemory {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get the database handle: %v", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	log.Printf("Connected to the %s database successfully", cfg.DBDriver)
 	return db
 }
@@ -0,0 +1,101 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const txMaxAttempts = 3
+
+type txKey struct{}
+
+type rollbackKey struct{}
+
+// rollbackJournal collects what to undo outside the database if a
+// transaction rolls back, see OnRollback.
+type rollbackJournal struct {
+	mu   sync.Mutex
+	undo []func()
+}
+
+// TxManager runs a function inside a database transaction. Repositories
+// called with the ctx passed to fn join that transaction through Conn.
+type TxManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type gormTxManager struct {
+	db *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) TxManager {
+	return &gormTxManager{db: db}
+}
+
+// WithinTransaction commits when fn returns nil and rolls back otherwise.
+// Serialization failures and deadlocks retry the whole function, so fn must
+// not have side effects outside the database. Nested calls join the
+// outer transaction and are not retried on their own.
+func (m *gormTxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= txMaxAttempts; attempt++ {
+		journal := &rollbackJournal{}
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			txCtx := context.WithValue(ctx, txKey{}, tx)
+			return fn(context.WithValue(txCtx, rollbackKey{}, journal))
+		})
+		if err != nil {
+			journal.rollback()
+		}
+		err = TranslateError(err)
+
+		if !errors.Is(err, ErrSerializationFailure) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 20 * time.Millisecond):
+		}
+	}
+
+	return err
+}
+
+// OnRollback registers undo to run if the transaction carried by ctx rolls
+// back, so storage outside the database, such as the memory repositories,
+// can take part in it. Without a transaction it does nothing.
+func OnRollback(ctx context.Context, undo func()) {
+	if journal, ok := ctx.Value(rollbackKey{}).(*rollbackJournal); ok {
+		journal.mu.Lock()
+		defer journal.mu.Unlock()
+		journal.undo = append(journal.undo, undo)
+	}
+}
+
+// rollback runs the registered undos, latest first.
+func (j *rollbackJournal) rollback() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		j.undo[i]()
+	}
+	j.undo = nil
+}
+
+// Conn returns the transaction carried by ctx, or db when there is none.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -2,24 +2,53 @@ package errors
 
 import (
 	"net/http"
+	"time"
 )
 
+// Error codes clients can react to, sent with the message.
+const (
+	// CodeReauthenticationRequired asks the user to authenticate again,
+	// see POST /auth/reauthenticate.
+	CodeReauthenticationRequired = "reauthentication_required"
+)
+
+// StatusClientClosedRequest is nginx's status for a request the client
+// gave up on before the response. It keeps them out of the 5xx counts.
+const StatusClientClosedRequest = 499
+
 type AppError struct {
-	Code    int         // HTTP Status Code
-	Message string      // Human-readable message
-	Err     error       // Raw error (optional)
-	Details interface{} // Additional details (optional)
+	Code       int           // HTTP Status Code
+	Message    string        // Human-readable message
+	Err        error         // Raw error (optional)
+	Details    interface{}   // Additional details (optional)
+	ErrorCode  string        // Machine-readable code (optional)
+	RetryAfter time.Duration // Sent as Retry-After (optional)
 }
 
 func (e *AppError) Error() string {
 	return e.Message
 }
 
+// Unwrap exposes the raw error to errors.Is and errors.As.
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
 func (e *AppError) WithDetails(details interface{}) *AppError {
 	e.Details = details
 	return e
 }
 
+func (e *AppError) WithErrorCode(code string) *AppError {
+	e.ErrorCode = code
+	return e
+}
+
+func (e *AppError) WithRetryAfter(d time.Duration) *AppError {
+	e.RetryAfter = d
+	return e
+}
+
 func New(code int, message string, err error) *AppError {
 	return &AppError{
 		Code:    code,
@@ -48,3 +77,15 @@ func Unautherized(message string, err error) *AppError {
 func Conflict(message string, err error) *AppError {
 	return New(http.StatusConflict, message, err)
 }
+
+func UnprocessableEntity(message string, err error) *AppError {
+	return New(http.StatusUnprocessableEntity, message, err)
+}
+
+func ServiceUnavailable(message string, err error) *AppError {
+	return New(http.StatusServiceUnavailable, message, err)
+}
+
+func ClientClosedRequest(message string, err error) *AppError {
+	return New(StatusClientClosedRequest, message, err)
+}
@@ -0,0 +1,30 @@
+package errors
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+)
+
+// FromDB maps a repository error to an AppError.
+// message is used for errors that have no more specific mapping.
+func FromDB(err error, message string) *AppError {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound("Resource not found", err)
+	case errors.Is(err, databases.ErrDuplicateKey):
+		return Conflict("Resource already exists", err)
+	case errors.Is(err, databases.ErrForeignKeyViolation):
+		return UnprocessableEntity("Referenced resource does not exist", err)
+	case errors.Is(err, databases.ErrCheckViolation), errors.Is(err, databases.ErrNotNullViolation):
+		return UnprocessableEntity("Data violates a constraint", err)
+	case errors.Is(err, databases.ErrSerializationFailure):
+		return Conflict("Concurrent update detected, please retry", err)
+	case errors.Is(err, databases.ErrTimeout), errors.Is(err, databases.ErrUnavailable):
+		return ServiceUnavailable("Database temporarily unavailable", err)
+	}
+
+	return InternalServer(message, err)
+}
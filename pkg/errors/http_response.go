@@ -1,9 +1,15 @@
 package errors
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 type ErrorResponse struct {
 	Message string      `json:"message"`
+	Code    string      `json:"code,omitempty"`
 	Details interface{} `json:"details,omitempty"`
 }
 
@@ -11,9 +17,13 @@ func Send(c *fiber.Ctx, appErr *AppError) error {
 
 	resp := ErrorResponse{
 		Message: appErr.Message,
+		Code:    appErr.ErrorCode,
 	}
 	if appErr.Details != nil {
 		resp.Details = appErr.Details
 	}
+	if appErr.RetryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
 	return c.Status(appErr.Code).JSON(resp)
 }
@@ -1,11 +1,16 @@
 package jwt
 
 import (
+	"context"
+	"crypto/subtle"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/matthewhartstonge/argon2"
+
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
 )
 
 var (
@@ -13,18 +18,34 @@ var (
 	refreshSecretKey = []byte(os.Getenv("JWT_REFRESH_SECRET"))
 	accessTokenTTL   = 24 * time.Hour
 	refreshTokenTTL  = 7 * 24 * time.Hour
+	serviceTokenTTL  = time.Hour
+)
+
+// Authentication methods of the amr claim, see RFC 8176.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRMultiFactor = "mfa"
+	AMRFederated   = "fed" // an upstream identity provider
 )
 
 type Claims struct {
 	UserID    string `json:"user_id"`
 	SessionID string `json:"session_id"`
+	// AuthTime is when the user last authenticated, which refreshing the
+	// session doesn't change, and AMR how.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userID, sessionID string) (string, error) {
+func GenerateAccessToken(userID, sessionID string, authTime time.Time, amr []string) (string, error) {
 	claims := &Claims{
 		UserID:    userID,
 		SessionID: sessionID,
+		AuthTime:  jwt.NewNumericDate(authTime),
+		AMR:       amr,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -59,6 +80,55 @@ func GenerateRefreshToken(userID, sessionID string) (string, time.Time, time.Tim
 	return signed, issuedAt, expiresAt, nil
 }
 
+// ServiceClaims are the claims of a token issued to a service account by
+// the client credentials grant. Subject is the service account's ID, and
+// SecretID the ID of the secret it authenticated with.
+type ServiceClaims struct {
+	ClientID string `json:"client_id"`
+	SecretID string `json:"secret_id"`
+	Scope    string `json:"scope"` // space separated
+	jwt.RegisteredClaims
+}
+
+// GenerateServiceToken signs an access token for a service account and
+// returns it with its lifetime.
+func GenerateServiceToken(serviceAccountID, secretID, clientID, scope string) (string, time.Duration, error) {
+	claims := &ServiceClaims{
+		ClientID: clientID,
+		SecretID: secretID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   serviceAccountID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(serviceTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(accessSecretKey)
+	if err != nil {
+		return "", 0, err
+	}
+	return signed, serviceTokenTTL, nil
+}
+
+// VerifyServiceToken verifies a token from GenerateServiceToken. User
+// access tokens share the key, so it checks the token has a client.
+func VerifyServiceToken(tokenStr string) (*ServiceClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &ServiceClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return accessSecretKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*ServiceClaims); ok && token.Valid && claims.ClientID != "" && claims.SecretID != "" && claims.Subject != "" {
+		return claims, nil
+	}
+
+	return nil, jwt.ErrTokenInvalidClaims
+}
+
 func VerifyAccessToken(tokenStr string) (*Claims, error) {
 	return verifyToken(tokenStr, accessSecretKey)
 }
@@ -71,25 +141,36 @@ func verifyToken(tokenStr string, secretKey []byte) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return secretKey, nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 		return claims, nil
 	}
 
-	return nil, err
+	return nil, jwt.ErrTokenInvalidClaims
 }
 
+// HashRefreshToken returns the SHA-256 of a refresh token, to store with
+// its session. The token is a signed JWT, too long to guess, so a slow
+// hash would only cost CPU and memory on every login and refresh.
 func HashRefreshToken(token string) (string, error) {
-	config := argon2.DefaultConfig()
+	return utils.HashToken(token), nil
+}
 
-	encoded, err := config.HashEncoded([]byte(token))
-	if err != nil {
-		return "", err
+// VerifyRefreshTokenHash compares a refresh token with its stored hash.
+// Sessions from before HashRefreshToken used SHA-256 have an argon2 hash,
+// which is checked under utils.PasswordHashLimiter like a password.
+func VerifyRefreshTokenHash(ctx context.Context, rawToken, hashedToken string) (bool, error) {
+	if !strings.HasPrefix(hashedToken, "$argon2") {
+		return subtle.ConstantTimeCompare([]byte(utils.HashToken(rawToken)), []byte(hashedToken)) == 1, nil
 	}
 
-	return string(encoded), nil
-}
-
-func VerifyRefreshTokenHash(rawToken, hashedToken string) (bool, error) {
+	release, err := utils.PasswordHashLimiter.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
 	return argon2.VerifyEncoded([]byte(rawToken), []byte(hashedToken))
 }
@@ -0,0 +1,161 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/argon2"
+)
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	token, err := GenerateAccessToken("user-1", "session-1", time.Now(), []string{AMRPassword})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	claims, err := VerifyAccessToken(token)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	if claims.UserID != "user-1" || claims.SessionID != "session-1" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.AuthTime == nil || time.Since(claims.AuthTime.Time) > time.Minute || len(claims.AMR) != 1 || claims.AMR[0] != AMRPassword {
+		t.Errorf("auth_time = %v, amr = %v", claims.AuthTime, claims.AMR)
+	}
+}
+
+func TestRefreshTokenRoundTrip(t *testing.T) {
+	token, issuedAt, expiresAt, err := GenerateRefreshToken("user-1", "session-1")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if !expiresAt.After(issuedAt) {
+		t.Errorf("expiresAt %v is not after issuedAt %v", expiresAt, issuedAt)
+	}
+
+	claims, err := VerifyRefreshToken(token)
+	if err != nil {
+		t.Fatalf("VerifyRefreshToken: %v", err)
+	}
+	if claims.UserID != "user-1" || claims.SessionID != "session-1" {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestRefreshTokenHash(t *testing.T) {
+	ctx := context.Background()
+	hash, err := HashRefreshToken("refresh-token")
+	if err != nil {
+		t.Fatalf("HashRefreshToken: %v", err)
+	}
+	// sessions stored before the hash was SHA-256
+	config := argon2.DefaultConfig()
+	config.MemoryCost = 1024
+	legacy, err := config.HashEncoded([]byte("refresh-token"))
+	if err != nil {
+		t.Fatalf("HashEncoded: %v", err)
+	}
+
+	for _, stored := range []string{hash, string(legacy)} {
+		if match, err := VerifyRefreshTokenHash(ctx, "refresh-token", stored); err != nil || !match {
+			t.Errorf("VerifyRefreshTokenHash(right, %q) = %v, %v", stored, match, err)
+		}
+		if match, _ := VerifyRefreshTokenHash(ctx, "other-token", stored); match {
+			t.Errorf("VerifyRefreshTokenHash(wrong, %q) matched", stored)
+		}
+	}
+}
+
+func TestVerifyTokenRejectsTampering(t *testing.T) {
+	token, err := GenerateAccessToken("user-1", "session-1", time.Now(), []string{AMRPassword})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	tests := map[string]string{
+		"empty":            "",
+		"garbage":          "not-a-token",
+		"wrong secret":     mustSign(t, []byte("other-secret")),
+		"truncated":        token[:len(token)-2],
+		"alg none":         "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJ1c2VyX2lkIjoidXNlci0xIn0.",
+		"swapped segments": strings.Join(reverse(strings.Split(token, ".")), "."),
+	}
+
+	for name, tokenStr := range tests {
+		t.Run(name, func(t *testing.T) {
+			if claims, err := VerifyAccessToken(tokenStr); err == nil {
+				t.Errorf("VerifyAccessToken(%q) = %+v, want error", tokenStr, claims)
+			}
+		})
+	}
+}
+
+func FuzzVerifyToken(f *testing.F) {
+	token, err := GenerateAccessToken("user-1", "session-1", time.Now(), []string{AMRPassword})
+	if err != nil {
+		f.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	f.Add(token)
+	f.Add("")
+	f.Add("a.b.c")
+	f.Add("eyJhbGciOiJub25lIn0.e30.")
+
+	f.Fuzz(func(t *testing.T, tokenStr string) {
+		claims, err := VerifyAccessToken(tokenStr)
+		if (err == nil) == (claims == nil) {
+			t.Fatalf("VerifyAccessToken(%q) = %v, %v: want exactly one of claims and error", tokenStr, claims, err)
+		}
+	})
+}
+
+func mustSign(t *testing.T, secret []byte) string {
+	t.Helper()
+
+	saved := accessSecretKey
+	accessSecretKey = secret
+	defer func() { accessSecretKey = saved }()
+
+	token, err := GenerateAccessToken("user-1", "session-1", time.Now(), []string{AMRPassword})
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return token
+}
+
+func reverse(parts []string) []string {
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return parts
+}
+
+func TestServiceTokenRoundTrip(t *testing.T) {
+	token, ttl, err := GenerateServiceToken("account-1", "secret-1", "svc_client", "users:import")
+	if err != nil {
+		t.Fatalf("GenerateServiceToken: %v", err)
+	}
+	if ttl != serviceTokenTTL {
+		t.Errorf("ttl = %s, want %s", ttl, serviceTokenTTL)
+	}
+
+	claims, err := VerifyServiceToken(token)
+	if err != nil {
+		t.Fatalf("VerifyServiceToken: %v", err)
+	}
+	if claims.Subject != "account-1" || claims.SecretID != "secret-1" || claims.ClientID != "svc_client" || claims.Scope != "users:import" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// user and service tokens share a key but are not interchangeable
+	if claims, err := VerifyAccessToken(token); err == nil && claims.UserID != "" {
+		t.Errorf("service token verified as a user token: %+v", claims)
+	}
+	userToken, _ := GenerateAccessToken("user-1", "session-1", time.Now(), []string{AMRPassword})
+	if _, err := VerifyServiceToken(userToken); err == nil {
+		t.Error("user token verified as a service token")
+	}
+}
@@ -0,0 +1,204 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	oauthTokenTTL = time.Hour
+	idTokenTTL    = time.Hour
+)
+
+// SigningKey is the RSA key tokens for OpenID Connect relying parties are
+// signed with. Unlike the HMAC secrets, its public half is published so
+// relying parties can verify ID tokens themselves.
+type SigningKey struct {
+	ID      string
+	private *rsa.PrivateKey
+}
+
+// NewSigningKey wraps key, with its RFC 7638 thumbprint as key ID.
+func NewSigningKey(key *rsa.PrivateKey) *SigningKey {
+	// members in lexicographic order, as the thumbprint requires
+	thumbprint, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+	})
+	sum := sha256.Sum256(thumbprint)
+
+	return &SigningKey{ID: base64.RawURLEncoding.EncodeToString(sum[:]), private: key}
+}
+
+// LoadSigningKey reads a PEM encoded PKCS #1 or PKCS #8 RSA key from path.
+// If path is empty it generates a key, which lasts until the process
+// exits.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	if path == "" {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(key), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(key), nil
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an RSA key", path)
+		}
+		return NewSigningKey(key), nil
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM block %q", path, block.Type)
+	}
+}
+
+// JWK is an RFC 7517 public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKSet is the document served at the JWKS URI.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public key as a key set.
+func (k *SigningKey) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: k.ID,
+		N:   base64.RawURLEncoding.EncodeToString(k.private.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.private.E)).Bytes()),
+	}}}
+}
+
+// OAuthClaims are the claims of an access token issued to a relying party
+// for a user. Subject is the user's ID.
+type OAuthClaims struct {
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"` // space separated
+	jwt.RegisteredClaims
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. Which
+// profile claims are set depends on the scopes granted.
+type IDTokenClaims struct {
+	Nonce  string `json:"nonce,omitempty"`
+	AtHash string `json:"at_hash,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Locale string `json:"locale,omitempty"`
+	Email  string `json:"email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateOAuthToken signs an access token a relying party uses to act for
+// the user, and returns it with its lifetime.
+func (k *SigningKey) GenerateOAuthToken(issuer, userID, clientID, scope string) (string, time.Duration, error) {
+	claims := &OAuthClaims{
+		ClientID: clientID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oauthTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	signed, err := k.sign(claims)
+	if err != nil {
+		return "", 0, err
+	}
+	return signed, oauthTokenTTL, nil
+}
+
+// GenerateIDToken signs an ID token about the user for the client, with
+// the nonce and profile claims set in claims.
+func (k *SigningKey) GenerateIDToken(issuer, userID, clientID string, claims IDTokenClaims) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{clientID},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(idTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	return k.sign(&claims)
+}
+
+// VerifyOAuthToken verifies a token from GenerateOAuthToken issued by
+// issuer.
+func (k *SigningKey) VerifyOAuthToken(tokenStr, issuer string) (*OAuthClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &OAuthClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return &k.private.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(issuer))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*OAuthClaims); ok && token.Valid && claims.ClientID != "" && claims.Subject != "" {
+		return claims, nil
+	}
+
+	return nil, jwt.ErrTokenInvalidClaims
+}
+
+// AtHash returns the at_hash of an access token for its ID token, the left
+// half of its SHA-256.
+func AtHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func (k *SigningKey) sign(claims jwt.Claims) (string, error) {
+	if k == nil {
+		return "", errors.New("no signing key")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.private)
+}
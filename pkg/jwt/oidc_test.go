@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestOAuthTokenRoundTrip(t *testing.T) {
+	key, err := LoadSigningKey("")
+	if err != nil {
+		t.Fatalf("LoadSigningKey: %v", err)
+	}
+
+	token, ttl, err := key.GenerateOAuthToken("https://id.example.com", "user-1", "app_1", "openid email")
+	if err != nil || ttl <= 0 {
+		t.Fatalf("GenerateOAuthToken: %v, ttl %v", err, ttl)
+	}
+
+	claims, err := key.VerifyOAuthToken(token, "https://id.example.com")
+	if err != nil {
+		t.Fatalf("VerifyOAuthToken: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.ClientID != "app_1" || claims.Scope != "openid email" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := key.VerifyOAuthToken(token, "https://other.example.com"); err == nil {
+		t.Error("VerifyOAuthToken accepted another issuer")
+	}
+	other, _ := LoadSigningKey("")
+	if _, err := other.VerifyOAuthToken(token, "https://id.example.com"); err == nil {
+		t.Error("VerifyOAuthToken accepted a token signed with another key")
+	}
+	// user and service tokens are HMAC, which must not accept RS256
+	if _, err := VerifyAccessToken(token); err == nil {
+		t.Error("VerifyAccessToken accepted an OAuth token")
+	}
+	if _, err := VerifyServiceToken(token); err == nil {
+		t.Error("VerifyServiceToken accepted an OAuth token")
+	}
+}
+
+func TestIDTokenVerifiesWithJWKS(t *testing.T) {
+	key, err := LoadSigningKey("")
+	if err != nil {
+		t.Fatalf("LoadSigningKey: %v", err)
+	}
+
+	idToken, err := key.GenerateIDToken("https://id.example.com", "user-1", "app_1", IDTokenClaims{Nonce: "n-1", AtHash: AtHash("access"), Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("GenerateIDToken: %v", err)
+	}
+
+	jwks := key.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != key.ID || jwks.Keys[0].Alg != "RS256" {
+		t.Fatalf("jwks = %+v", jwks)
+	}
+
+	var claims IDTokenClaims
+	token, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != jwks.Keys[0].Kid {
+			t.Errorf("kid = %v, want %s", token.Header["kid"], jwks.Keys[0].Kid)
+		}
+		return publicKey(t, jwks.Keys[0]), nil
+	}, jwt.WithAudience("app_1"), jwt.WithIssuer("https://id.example.com"))
+	if err != nil || !token.Valid {
+		t.Fatalf("parse ID token: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Nonce != "n-1" || claims.Email != "bob@example.com" || claims.AtHash != AtHash("access") {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func publicKey(t *testing.T, jwk JWK) *rsa.PublicKey {
+	t.Helper()
+
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		t.Fatalf("decode n: %v", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		t.Fatalf("decode e: %v", err)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+}
@@ -0,0 +1,157 @@
+// Package ldapauth checks passwords against an LDAP directory such as
+// Active Directory. It searches for the user's entry as a service account
+// and then binds as that entry with the password given.
+package ldapauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrInvalidCredentials is returned when no entry matches the login or the
+// password is wrong.
+var ErrInvalidCredentials = errors.New("ldapauth: invalid credentials")
+
+// Config describes the directory and where users are found in it.
+type Config struct {
+	URL      string // ldap:// or ldaps://
+	StartTLS bool
+
+	// BindDN and BindPassword are the service account searches are made
+	// as. If BindDN is empty the search is anonymous.
+	BindDN       string
+	BindPassword string
+
+	BaseDN string
+	// UserFilter finds the user's entry, with %s replaced by the escaped
+	// login, e.g. (&(objectClass=user)(userPrincipalName=%s)).
+	UserFilter string
+
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+
+	Timeout time.Duration
+}
+
+// Entry is the directory entry of a user who authenticated.
+type Entry struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string // DNs
+}
+
+// Directory authenticates users against one LDAP server.
+type Directory struct {
+	cfg Config
+}
+
+// New returns a Directory for cfg, filling in defaults for the filter,
+// attributes and timeout.
+func New(cfg Config) *Directory {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(mail=%s)"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.NameAttribute == "" {
+		cfg.NameAttribute = "displayName"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &Directory{cfg: cfg}
+}
+
+// Authenticate finds the entry for login and binds as it with password.
+// It returns ErrInvalidCredentials if either fails the way a wrong login
+// or password would, and other errors if the directory can't be used.
+func (d *Directory) Authenticate(ctx context.Context, login, password string) (*Entry, error) {
+	// a simple bind without a password is anonymous, not a failed login
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if d.cfg.BindDN != "" {
+		if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("bind as %s: %w", d.cfg.BindDN, err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(d.cfg.Timeout.Seconds()), false,
+		fmt.Sprintf(d.cfg.UserFilter, ldap.EscapeFilter(login)),
+		[]string{d.cfg.EmailAttribute, d.cfg.NameAttribute, d.cfg.GroupAttribute},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, fmt.Errorf("search for %s: %w", login, err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("search for %s: more than one entry", login)
+	}
+	found := result.Entries[0]
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("bind as %s: %w", found.DN, err)
+	}
+
+	return &Entry{
+		DN:     found.DN,
+		Email:  found.GetEqualFoldAttributeValue(d.cfg.EmailAttribute),
+		Name:   found.GetEqualFoldAttributeValue(d.cfg.NameAttribute),
+		Groups: found.GetEqualFoldAttributeValues(d.cfg.GroupAttribute),
+	}, nil
+}
+
+func (d *Directory) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(d.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname()}
+
+	conn, err := ldap.DialURL(d.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: d.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(d.cfg.Timeout)
+
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("start TLS: %w", err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,70 @@
+package ldapauth_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/natchaphonbw/usermanagement/pkg/ldapauth"
+	"github.com/natchaphonbw/usermanagement/pkg/ldapauth/ldaptest"
+)
+
+func TestAuthenticate(t *testing.T) {
+	directory := ldaptest.NewDirectory(t, "dc=corp,dc=example", "cn=svc,dc=corp,dc=example", "svc-secret")
+	directory.AddUser("cn=Alice,ou=people,dc=corp,dc=example", "alice-secret", map[string][]string{
+		"objectClass": {"person"},
+		"mail":        {"alice@corp.example"},
+		"displayName": {"Alice Liddell"},
+		"memberOf":    {"cn=admins,ou=groups,dc=corp,dc=example", "cn=staff,ou=groups,dc=corp,dc=example"},
+	})
+	directory.AddUser("cn=Other,ou=people,dc=other,dc=example", "other-secret", map[string][]string{
+		"objectClass": {"person"},
+		"mail":        {"other@corp.example"},
+	})
+
+	config := ldapauth.Config{
+		URL:          directory.URL,
+		BindDN:       directory.BindDN,
+		BindPassword: directory.BindPassword,
+		BaseDN:       directory.BaseDN,
+		UserFilter:   "(&(objectClass=person)(mail=%s))",
+	}
+	ctx := context.Background()
+
+	entry, err := ldapauth.New(config).Authenticate(ctx, "Alice@corp.example", "alice-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if entry.DN != "cn=Alice,ou=people,dc=corp,dc=example" || entry.Email != "alice@corp.example" || entry.Name != "Alice Liddell" ||
+		!slices.Contains(entry.Groups, "cn=admins,ou=groups,dc=corp,dc=example") {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+	}{
+		{name: "wrong password", login: "alice@corp.example", password: "wrong"},
+		{name: "empty password", login: "alice@corp.example", password: ""},
+		{name: "unknown login", login: "bob@corp.example", password: "alice-secret"},
+		{name: "outside base DN", login: "other@corp.example", password: "other-secret"},
+		{name: "filter injection", login: "*", password: "alice-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ldapauth.New(config).Authenticate(ctx, tt.login, tt.password); !errors.Is(err, ldapauth.ErrInvalidCredentials) {
+				t.Errorf("err = %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+
+	t.Run("wrong service account password", func(t *testing.T) {
+		broken := config
+		broken.BindPassword = "wrong"
+		if _, err := ldapauth.New(broken).Authenticate(ctx, "alice@corp.example", "alice-secret"); err == nil || errors.Is(err, ldapauth.ErrInvalidCredentials) {
+			t.Errorf("err = %v, want a directory error", err)
+		}
+	})
+}
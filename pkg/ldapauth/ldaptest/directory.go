@@ -0,0 +1,180 @@
+// Package ldaptest provides an in-process LDAP server for tests.
+package ldaptest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jimlambrt/gldap"
+)
+
+// Directory is an LDAP server holding a service account and the entries
+// added with AddUser. Only the service account may search, and filters may
+// only use and, or, equality and presence.
+type Directory struct {
+	URL          string
+	BaseDN       string
+	BindDN       string
+	BindPassword string
+
+	mu      sync.Mutex
+	entries []entry
+	bound   map[int]string // connection ID to DN
+}
+
+type entry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// NewDirectory starts a directory, which is stopped when the test ends.
+func NewDirectory(t testing.TB, baseDN, bindDN, bindPassword string) *Directory {
+	t.Helper()
+
+	d := &Directory{
+		BaseDN:       baseDN,
+		BindDN:       bindDN,
+		BindPassword: bindPassword,
+		bound:        make(map[int]string),
+	}
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatalf("create LDAP server: %v", err)
+	}
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatalf("create LDAP mux: %v", err)
+	}
+	mux.Bind(d.bind)
+	mux.Search(d.search)
+	server.Router(mux)
+
+	// gldap doesn't expose its listener, so find a free port first
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("find a free port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	go server.Run(addr)
+	t.Cleanup(func() { server.Stop() })
+	for !server.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	d.URL = "ldap://" + addr
+	return d
+}
+
+// AddUser adds an entry that can bind with password.
+func (d *Directory) AddUser(dn, password string, attrs map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, entry{dn: dn, password: password, attrs: attrs})
+}
+
+func (d *Directory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer w.Write(resp)
+
+	m, err := r.GetSimpleBindMessage()
+	if err != nil || m.Password == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.bound, r.ConnectionID())
+	ok := strings.EqualFold(m.UserName, d.BindDN) && string(m.Password) == d.BindPassword
+	for _, e := range d.entries {
+		ok = ok || strings.EqualFold(m.UserName, e.dn) && string(m.Password) == e.password
+	}
+	if ok {
+		d.bound[r.ConnectionID()] = m.UserName
+		resp.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+func (d *Directory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultOperationsError))
+	defer w.Write(resp)
+
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		return
+	}
+	filter, err := ldap.CompileFilter(m.Filter)
+	if err != nil {
+		resp.SetResultCode(gldap.ResultProtocolError)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !strings.EqualFold(d.bound[r.ConnectionID()], d.BindDN) {
+		resp.SetResultCode(gldap.ResultInsufficientAccessRights)
+		return
+	}
+
+	for _, e := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(e.dn), strings.ToLower(m.BaseDN)) || !matches(filter, e) {
+			continue
+		}
+		result := r.NewSearchResponseEntry(e.dn)
+		for _, name := range m.Attributes {
+			if values := e.values(name); values != nil {
+				result.AddAttribute(name, values)
+			}
+		}
+		w.Write(result)
+	}
+	resp.SetResultCode(gldap.ResultSuccess)
+}
+
+func (e entry) values(name string) []string {
+	for attr, values := range e.attrs {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func matches(filter *ber.Packet, e entry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(child, e) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(child, e) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterEqualityMatch:
+		want := filter.Children[1].Data.String()
+		for _, v := range e.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return e.values(filter.Data.String()) != nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/natchaphonbw/usermanagement/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New builds the mailer selected by MAIL_DRIVER, wrapped in an async queue.
+func New(cfg *config.Config) (*Queue, error) {
+	var backend Mailer
+
+	switch cfg.MailDriver {
+	case "smtp":
+		backend = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "file":
+		backend = NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "memory":
+		backend = NewMemoryMailer()
+	case "log", "":
+		backend = NewLogMailer()
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+
+	return NewQueue(backend, DefaultQueueConfig), nil
+}
+
+// logMailer writes messages to the application log instead of sending them.
+type logMailer struct{}
+
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
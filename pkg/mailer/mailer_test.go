@@ -0,0 +1,147 @@
+package mailer_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/natchaphonbw/usermanagement/config"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+)
+
+var testMessage = mailer.Message{
+	To:      "alice@example.com",
+	Subject: "Verify your email",
+	Text:    "Open the link",
+	HTML:    "<p>Open the link</p>",
+}
+
+func TestNewUnknownDriver(t *testing.T) {
+	if _, err := mailer.New(&config.Config{MailDriver: "pigeon"}); err == nil {
+		t.Fatal("New accepted an unknown driver")
+	}
+
+	q, err := mailer.New(&config.Config{MailDriver: ""})
+	if err != nil {
+		t.Fatalf("New with the default driver: %v", err)
+	}
+	q.Close()
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := mailer.NewMemoryMailer()
+	if err := m.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := m.Messages()
+	if len(got) != 1 || got[0] != testMessage {
+		t.Fatalf("Messages() = %+v, want [%+v]", got, testMessage)
+	}
+	got[0].To = "mallory@example.com"
+	if m.Messages()[0].To != testMessage.To {
+		t.Error("Messages() returned the stored slice, not a copy")
+	}
+
+	m.Reset()
+	if n := len(m.Messages()); n != 0 {
+		t.Errorf("%d messages after Reset, want 0", n)
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if err := mailer.NewLogMailer().Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	for _, want := range []string{testMessage.To, testMessage.Subject, testMessage.Text} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log %q does not contain %q", buf.String(), want)
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := mailer.NewFileMailer(dir, "no-reply@example.com")
+	if err := m.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("found %v (%v), want one .eml file", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	checkMIME(t, raw, "no-reply@example.com", testMessage)
+}
+
+// checkMIME parses a message built by the mailer and compares it to msg.
+func checkMIME(t *testing.T, raw []byte, from string, msg mailer.Message) {
+	t.Helper()
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if parsed.Header.Get("From") != from || parsed.Header.Get("To") != msg.To || subject != msg.Subject {
+		t.Errorf("headers = %v, want from %s to %s about %q", parsed.Header, from, msg.To, msg.Subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", parsed.Header.Get("Content-Type"), err)
+	}
+	bodies := map[string]string{}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+	if bodies["text/plain"] != msg.Text || bodies["text/html"] != msg.HTML {
+		t.Errorf("parts = %v, want the text and HTML bodies", bodies)
+	}
+}
+
+func TestRenderLocalesMatch(t *testing.T) {
+	data := map[string]any{"Name": "Bob", "NewEmail": "robert@example.com"}
+	for _, name := range []string{"email_change_confirm", "email_change_requested", "email_changed", "login_code", "magic_link"} {
+		en, err := mailer.Render(mailer.DefaultLocale, name, "bob@example.com", data)
+		if err != nil {
+			t.Fatalf("Render(en, %s): %v", name, err)
+		}
+		th, err := mailer.Render("th", name, "bob@example.com", data)
+		if err != nil {
+			t.Fatalf("Render(th, %s): %v", name, err)
+		}
+		if th.Subject == en.Subject {
+			t.Errorf("%s: th fell back to the en subject %q", name, en.Subject)
+		}
+		if (th.HTML == "") != (en.HTML == "") {
+			t.Errorf("%s: th HTML %q, en HTML %q, want both or neither", name, th.HTML, en.HTML)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("mail queue is full")
+	ErrQueueClosed = errors.New("mail queue is closed")
+)
+
+type QueueConfig struct {
+	Workers     int
+	Size        int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	SendTimeout time.Duration
+}
+
+var DefaultQueueConfig = QueueConfig{
+	Workers:     2,
+	Size:        100,
+	MaxAttempts: 5,
+	BaseBackoff: time.Second,
+	SendTimeout: 30 * time.Second,
+}
+
+// Queue sends messages asynchronously through a backend, retrying failed
+// sends with exponential backoff. A message waiting for its retry does not
+// hold up a worker.
+type Queue struct {
+	backend Mailer
+	config  QueueConfig
+	jobs    chan mailJob
+	closing chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+	// outstanding counts messages accepted and not yet sent or given up,
+	// queued or waiting for a retry
+	outstanding sync.WaitGroup
+	wg          sync.WaitGroup
+}
+
+// mailJob is a message and the number of the attempt about to be made.
+type mailJob struct {
+	msg     Message
+	attempt int
+}
+
+func NewQueue(backend Mailer, config QueueConfig) *Queue {
+	q := &Queue{
+		backend: backend,
+		config:  config,
+		jobs:    make(chan mailJob, config.Size),
+		closing: make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+// Send enqueues the message and returns without waiting for delivery.
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	q.outstanding.Add(1)
+	select {
+	case q.jobs <- mailJob{msg: msg, attempt: 1}:
+		return nil
+	case <-ctx.Done():
+		q.outstanding.Done()
+		return ctx.Err()
+	default:
+		q.outstanding.Done()
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for queued ones to be sent.
+// Messages waiting for a retry are retried at once rather than after
+// their backoff.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.closing)
+	q.mu.Unlock()
+
+	q.outstanding.Wait()
+	close(q.jobs)
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+
+	for job := range q.jobs {
+		q.deliver(job)
+	}
+}
+
+func (q *Queue) deliver(job mailJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.config.SendTimeout)
+	err := q.backend.Send(ctx, job.msg)
+	cancel()
+
+	if err == nil {
+		q.outstanding.Done()
+		return
+	}
+
+	log.Printf("Mail to %s failed (attempt %d/%d): %v", job.msg.To, job.attempt, q.config.MaxAttempts, err)
+	if job.attempt >= q.config.MaxAttempts {
+		log.Printf("Giving up on mail to %s: %s", job.msg.To, job.msg.Subject)
+		q.outstanding.Done()
+		return
+	}
+
+	backoff := q.config.BaseBackoff << (job.attempt - 1)
+	job.attempt++
+	go q.retry(job, backoff)
+}
+
+// retry queues the job again after backoff, or as soon as the queue is
+// closing. jobs stays open until every outstanding message is done, so
+// the send can't hit a closed channel.
+func (q *Queue) retry(job mailJob, backoff time.Duration) {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-q.closing:
+	}
+	q.jobs <- job
+}
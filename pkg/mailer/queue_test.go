@@ -0,0 +1,166 @@
+package mailer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+)
+
+// flakyMailer fails the first sends to each recipient in failures and
+// records the messages it sends.
+type flakyMailer struct {
+	mu       sync.Mutex
+	failures map[string]int
+	attempts map[string]int
+	sent     chan mailer.Message
+}
+
+func newFlakyMailer(failures map[string]int) *flakyMailer {
+	return &flakyMailer{
+		failures: failures,
+		attempts: make(map[string]int),
+		sent:     make(chan mailer.Message, 10),
+	}
+}
+
+func (m *flakyMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	m.attempts[msg.To]++
+	fail := m.attempts[msg.To] <= m.failures[msg.To]
+	m.mu.Unlock()
+
+	if fail {
+		return errors.New("smtp unavailable")
+	}
+	m.sent <- msg
+	return nil
+}
+
+func (m *flakyMailer) attemptsFor(to string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attempts[to]
+}
+
+func TestQueueBackoffDoesNotStallWorker(t *testing.T) {
+	backend := newFlakyMailer(map[string]int{"broken@example.com": 1})
+	q := mailer.NewQueue(backend, mailer.QueueConfig{
+		Workers:     1,
+		Size:        10,
+		MaxAttempts: 2,
+		BaseBackoff: time.Hour,
+		SendTimeout: time.Second,
+	})
+	ctx := context.Background()
+
+	if err := q.Send(ctx, mailer.Message{To: "broken@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := q.Send(ctx, mailer.Message{To: "ok@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	// the only worker is free while the first message waits an hour
+	select {
+	case msg := <-backend.sent:
+		if msg.To != "ok@example.com" {
+			t.Fatalf("sent to %s first, want ok@example.com", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second message not sent while the first waits for its retry")
+	}
+
+	// closing retries the waiting message at once
+	done := make(chan struct{})
+	go func() {
+		q.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited for the backoff")
+	}
+	if n := backend.attemptsFor("broken@example.com"); n != 2 {
+		t.Errorf("broken@example.com attempted %d times, want 2", n)
+	}
+}
+
+func TestQueueGivesUp(t *testing.T) {
+	backend := newFlakyMailer(map[string]int{"broken@example.com": 10})
+	q := mailer.NewQueue(backend, mailer.QueueConfig{
+		Workers:     1,
+		Size:        10,
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		SendTimeout: time.Second,
+	})
+
+	if err := q.Send(context.Background(), mailer.Message{To: "broken@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	// Close returns once the message is given up
+	q.Close()
+
+	if n := backend.attemptsFor("broken@example.com"); n != 3 {
+		t.Errorf("attempted %d times, want 3", n)
+	}
+	if len(backend.sent) != 0 {
+		t.Error("message sent after it should have been given up")
+	}
+}
+
+func TestQueueSendAfterClose(t *testing.T) {
+	q := mailer.NewQueue(mailer.NewMemoryMailer(), mailer.DefaultQueueConfig)
+	q.Close()
+	q.Close()
+
+	if err := q.Send(context.Background(), testMessage); !errors.Is(err, mailer.ErrQueueClosed) {
+		t.Errorf("Send after Close = %v, want ErrQueueClosed", err)
+	}
+}
+
+// blockingMailer holds every send until release is closed.
+type blockingMailer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.started <- struct{}{}
+	<-m.release
+	return nil
+}
+
+func TestQueueFull(t *testing.T) {
+	backend := &blockingMailer{started: make(chan struct{}, 10), release: make(chan struct{})}
+	q := mailer.NewQueue(backend, mailer.QueueConfig{
+		Workers:     1,
+		Size:        1,
+		MaxAttempts: 1,
+		SendTimeout: time.Second,
+	})
+	ctx := context.Background()
+
+	// one message in the worker, one in the buffer
+	if err := q.Send(ctx, testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-backend.started
+	if err := q.Send(ctx, testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := q.Send(ctx, testMessage); !errors.Is(err, mailer.ErrQueueFull) {
+		t.Errorf("Send to a full queue = %v, want ErrQueueFull", err)
+	}
+
+	close(backend.release)
+	q.Close()
+	if n := len(backend.started); n != 1 {
+		t.Errorf("%d more messages sent after the first, want 1", n)
+	}
+}
@@ -0,0 +1,140 @@
+package mailer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+)
+
+// smtpServer is a minimal SMTP server that accepts one message per
+// connection and hands its envelope and data to received. A silent server
+// accepts connections and never answers.
+type smtpServer struct {
+	listener net.Listener
+	silent   bool
+	received chan smtpMessage
+}
+
+type smtpMessage struct {
+	from, to string
+	data     string
+}
+
+func newSMTPServer(t *testing.T, silent bool) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	s := &smtpServer{listener: listener, silent: silent, received: make(chan smtpMessage, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+// mailer returns an SMTP mailer for the server, without auth.
+func (s *smtpServer) mailer() mailer.Mailer {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return mailer.NewSMTPMailer(host, port, "", "", "no-reply@example.com")
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	if s.silent {
+		// hold the connection until the client gives up
+		conn.Read(make([]byte, 1))
+		return
+	}
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.received <- msg
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newSMTPServer(t, false)
+
+	if err := server.mailer().Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := <-server.received
+	if got.from != "no-reply@example.com" || got.to != testMessage.To {
+		t.Errorf("envelope from %s to %s, want no-reply@example.com to %s", got.from, got.to, testMessage.To)
+	}
+	checkMIME(t, []byte(got.data), "no-reply@example.com", testMessage)
+}
+
+func TestSMTPMailerHonoursContext(t *testing.T) {
+	server := newSMTPServer(t, true)
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := server.mailer().Send(ctx, testMessage)
+		if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+			t.Errorf("Send = %v after %s, want context.DeadlineExceeded at once", err, time.Since(start))
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		start := time.Now()
+		err := server.mailer().Send(ctx, testMessage)
+		if !errors.Is(err, context.Canceled) || time.Since(start) > 5*time.Second {
+			t.Errorf("Send = %v after %s, want context.Canceled at once", err, time.Since(start))
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	texttemplate "text/template"
+)
+
+const DefaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Render builds a message from the embedded templates for name in locale,
+// falling back to DefaultLocale when the locale has no such template.
+//
+// templates/<locale>/<name>.txt.tmpl must define "subject" and "text";
+// templates/<locale>/<name>.html.tmpl is optional and defines "html".
+func Render(locale, name, to string, data any) (Message, error) {
+	dir := locale
+	if _, err := fs.Stat(templateFS, path.Join("templates", dir, name+".txt.tmpl")); err != nil {
+		dir = DefaultLocale
+	}
+
+	textTmpl, err := texttemplate.ParseFS(templateFS, path.Join("templates", dir, name+".txt.tmpl"))
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{To: to}
+
+	var subject, text bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTmpl.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	msg.Subject = subject.String()
+	msg.Text = text.String()
+
+	htmlPath := path.Join("templates", dir, name+".html.tmpl")
+	htmlTmpl, err := htmltemplate.ParseFS(templateFS, htmlPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return msg, nil
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	var html bytes.Buffer
+	if err := htmlTmpl.ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, err
+	}
+	msg.HTML = html.String()
+
+	return msg, nil
+}
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+)
+
+// ServiceTokenVerifier checks that the service account a token was issued
+// to is still enabled and the secret it was issued for still active. An
+// *app_errors.AppError error decides the response status.
+type ServiceTokenVerifier interface {
+	Authenticate(ctx context.Context, serviceAccountID, secretID uuid.UUID) error
+}
+
+// AdminKeyMiddleware guards admin routes with a static key sent in the
+// X-Admin-Key header. An empty key disables the admin API.
+func AdminKeyMiddleware(adminKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-Admin-Key")
+
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or missing admin key")
+		}
+
+		return c.Next()
+	}
+}
+
+// MetricsTokenMiddleware guards the metrics endpoint with a static bearer
+// token, which scrapers can send without an admin key. An empty token
+// disables the endpoint.
+func MetricsTokenMiddleware(metricsToken string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, _ := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+
+		if metricsToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(metricsToken)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or missing metrics token")
+		}
+
+		return c.Next()
+	}
+}
+
+// AdminAuthMiddleware guards admin routes with the admin key or a service
+// account's access token. For a token it sets clientID and scopes, to be
+// checked with RequireScope or RequireAdminKey.
+func AdminAuthMiddleware(adminKey string, services ServiceTokenVerifier) fiber.Handler {
+	verifyKey := AdminKeyMiddleware(adminKey)
+
+	return func(c *fiber.Ctx) error {
+		tokenStr, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+		if !ok || c.Get("X-Admin-Key") != "" {
+			return verifyKey(c)
+		}
+
+		claims, err := jwt.VerifyServiceToken(tokenStr)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+		accountID, err := uuid.Parse(claims.Subject)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+		secretID, err := uuid.Parse(claims.SecretID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+
+		// a disabled account or deleted secret revokes its tokens
+		if err := services.Authenticate(c.UserContext(), accountID, secretID); err != nil {
+			var appErr *app_errors.AppError
+			if errors.As(err, &appErr) {
+				return fiber.NewError(appErr.Code, appErr.Message)
+			}
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+
+		c.Locals("clientID", claims.ClientID)
+		c.Locals("scopes", strings.Fields(claims.Scope))
+		return c.Next()
+	}
+}
+
+// RequireAdminKey only lets through requests made with the admin key, for
+// admin routes no service account may reach whatever its scopes.
+func RequireAdminKey() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("clientID").(string); ok {
+			return fiber.NewError(fiber.StatusForbidden, "This endpoint requires the admin key")
+		}
+		return c.Next()
+	}
+}
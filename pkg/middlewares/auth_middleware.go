@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
+)
+
+// accessTokenPrefix marks a personal access token in the Authorization
+// header, anything else is taken for a JWT.
+const accessTokenPrefix = "pat_"
+
+// AccessTokenVerifier resolves a personal access token to its user and
+// scopes. An *app_errors.AppError error decides the response status.
+type AccessTokenVerifier interface {
+	Authenticate(ctx context.Context, token, ip string) (uuid.UUID, []string, error)
+}
+
+// AuthMiddleware authenticates requests with an access token JWT or a
+// personal access token. For a JWT it sets the same locals as
+// JWTAuthMiddleware, for a personal access token it sets userID and
+// scopes but no session, see RequireSession and RequireScope.
+func AuthMiddleware(tokens AccessTokenVerifier, sessions SessionVerifier) fiber.Handler {
+	verifyJWT := JWTAuthMiddleware(sessions)
+
+	return func(c *fiber.Ctx) error {
+		tokenStr, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(tokenStr, accessTokenPrefix) {
+			return verifyJWT(c)
+		}
+
+		userID, scopes, err := tokens.Authenticate(c.UserContext(), tokenStr, c.IP())
+		if err != nil {
+			var appErr *app_errors.AppError
+			if errors.As(err, &appErr) {
+				return fiber.NewError(appErr.Code, appErr.Message)
+			}
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+
+		c.Locals("userID", userID)
+		c.Locals("scopes", scopes)
+		return c.Next()
+	}
+}
+
+// RequireSession only lets through requests authenticated by a login
+// session, for endpoints a personal access token must never reach, such
+// as managing sessions and tokens.
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("sessionID").(uuid.UUID); !ok {
+			return fiber.NewError(fiber.StatusForbidden, "This endpoint requires a login session")
+		}
+		return c.Next()
+	}
+}
+
+// RequireRecentAuth only lets through login sessions whose user
+// authenticated within maxAge, for sensitive endpoints. Others are told
+// to reauthenticate with CodeReauthenticationRequired.
+func RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if authTime, ok := c.Locals("authTime").(time.Time); ok && time.Since(authTime) <= maxAge {
+			return c.Next()
+		}
+
+		c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, int(maxAge.Seconds())))
+		appErr := app_errors.Unautherized("This endpoint requires a recent login", nil).
+			WithErrorCode(app_errors.CodeReauthenticationRequired).
+			WithDetails(fiber.Map{"max_age": int(maxAge.Seconds())})
+		return app_errors.Send(c, appErr)
+	}
+}
+
+// RequireScope lets through login sessions, which have every scope, and
+// personal access tokens granted scope.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if scopes, ok := c.Locals("scopes").([]string); ok && !slices.Contains(scopes, scope) {
+			return fiber.NewError(fiber.StatusForbidden, "Token is missing the "+scope+" scope")
+		}
+		return c.Next()
+	}
+}
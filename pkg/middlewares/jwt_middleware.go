@@ -1,14 +1,34 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	app_errors "github.com/natchaphonbw/usermanagement/pkg/errors"
 	"github.com/natchaphonbw/usermanagement/pkg/jwt"
 )
 
-func JWTAuthMiddleware() fiber.Handler {
+// SessionVerifier checks that the session a JWT belongs to is still live
+// and its user may still use it. An *app_errors.AppError error decides
+// the response status.
+type SessionVerifier interface {
+	VerifySession(ctx context.Context, userID, sessionID uuid.UUID) error
+}
+
+// JWTAuthMiddleware authenticates requests with an access token.
+func JWTAuthMiddleware(sessions SessionVerifier) fiber.Handler {
+	return jwtMiddleware(jwt.VerifyAccessToken, sessions)
+}
+
+// JWTRefreshMiddleware authenticates requests with a refresh token.
+func JWTRefreshMiddleware(sessions SessionVerifier) fiber.Handler {
+	return jwtMiddleware(jwt.VerifyRefreshToken, sessions)
+}
+
+func jwtMiddleware(verify func(tokenStr string) (*jwt.Claims, error), sessions SessionVerifier) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 
@@ -17,7 +37,7 @@ func JWTAuthMiddleware() fiber.Handler {
 		}
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := jwt.VerifyAccessToken(tokenStr)
+		claims, err := verify(tokenStr)
 		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
 		}
@@ -32,9 +52,21 @@ func JWTAuthMiddleware() fiber.Handler {
 			return fiber.NewError(fiber.StatusUnauthorized, "Invalid session ID in token")
 		}
 
+		// logging out or locking the user must not wait for the token to expire
+		if err := sessions.VerifySession(c.UserContext(), userID, sessionID); err != nil {
+			var appErr *app_errors.AppError
+			if errors.As(err, &appErr) {
+				return fiber.NewError(appErr.Code, appErr.Message)
+			}
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		}
+
 		c.Locals("userID", userID)
 		c.Locals("sessionID", sessionID)
 		c.Locals("tokenStr", tokenStr)
+		if claims.AuthTime != nil {
+			c.Locals("authTime", claims.AuthTime.Time)
+		}
 		return c.Next()
 
 	}
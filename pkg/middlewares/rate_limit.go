@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/natchaphonbw/usermanagement/pkg/ratelimit"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// RateLimitMiddleware rejects requests over any of policies with 429 and
+// reports the closest limit in RateLimit-* headers. A policy whose key is
+// missing from the request, e.g. the user on an anonymous request, is
+// skipped. If the store fails the request is let through, so an outage of
+// the store doesn't take the API down with it.
+func RateLimitMiddleware(store ratelimit.Store, policies ...ratelimit.Policy) fiber.Handler {
+	if len(policies) == 0 {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+
+	described := make([]string, len(policies))
+	for i, policy := range policies {
+		described[i] = fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds()))
+	}
+	policyHeader := strings.Join(described, ", ")
+
+	return func(c *fiber.Ctx) error {
+		var closest *ratelimit.Result
+		for _, policy := range policies {
+			key := rateLimitKey(c, policy.KeyBy)
+			if key == "" {
+				continue
+			}
+
+			result, err := store.Take(c.UserContext(), key, policy)
+			if err != nil {
+				log.Printf("Rate limit check failed, allowing request: %v", err)
+				continue
+			}
+
+			if !result.Allowed {
+				setRateLimitHeaders(c, policyHeader, result)
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return fiber.NewError(fiber.StatusTooManyRequests, "Too many requests, please retry later")
+			}
+			if closest == nil || result.Remaining < closest.Remaining {
+				closest = &result
+			}
+		}
+
+		if closest != nil {
+			setRateLimitHeaders(c, policyHeader, *closest)
+		}
+		return c.Next()
+	}
+}
+
+func setRateLimitHeaders(c *fiber.Ctx, policy string, result ratelimit.Result) {
+	c.Set("RateLimit-Policy", policy)
+	c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+// rateLimitKey returns what the request is counted by. Emails and keys
+// are hashed so the store never holds them in the clear.
+func rateLimitKey(c *fiber.Ctx, keyBy string) string {
+	switch keyBy {
+	case ratelimit.KeyIP:
+		return c.IP()
+	case ratelimit.KeyUser:
+		if userID, ok := c.Locals("userID").(uuid.UUID); ok {
+			return userID.String()
+		}
+	case ratelimit.KeyEmail:
+		var body struct {
+			Email string `json:"email"`
+		}
+		if json.Unmarshal(c.Body(), &body) == nil && body.Email != "" {
+			return hashKey(utils.NormalizeEmail(body.Email))
+		}
+	case ratelimit.KeyAPIKey:
+		if key := c.Get("X-Admin-Key"); key != "" {
+			return hashKey(key)
+		}
+		if key := c.Get(fiber.HeaderAuthorization); key != "" {
+			return hashKey(key)
+		}
+	}
+	return ""
+}
+
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:16])
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sink receives dispatched events. Delivery is at least once, so sinks
+// must tolerate duplicates (Event.EventID is stable across retries).
+type Sink interface {
+	Deliver(ctx context.Context, event Event) error
+}
+
+type DispatcherConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+}
+
+var DefaultDispatcherConfig = DispatcherConfig{
+	BatchSize:    100,
+	PollInterval: time.Second,
+	MaxAttempts:  10,
+	BaseBackoff:  time.Second,
+}
+
+// Dispatcher polls the outbox table and delivers pending events to every
+// sink. Events of one aggregate are delivered in order: when one fails, it
+// is retried with exponential backoff and the later events of the same
+// aggregate wait for it, while other aggregates go on. After MaxAttempts
+// the event is parked and the rest of its aggregate is delivered without
+// it.
+//
+// On Postgres, dispatchers hold an advisory lock while they work through
+// a batch, so several instances can run but only one dispatches at a
+// time.
+type Dispatcher struct {
+	db     *gorm.DB
+	sinks  []Sink
+	config DispatcherConfig
+}
+
+func NewDispatcher(db *gorm.DB, config DispatcherConfig, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		sinks:  sinks,
+		config: config,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchPending(ctx); err != nil {
+			log.Printf("Outbox dispatch failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending delivers one batch of pending events and returns how many
+// were delivered. It delivers nothing while another dispatcher is busy.
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	// SQLite has no advisory locks, and one instance owns its database
+	if !isPostgres(d.db) {
+		return d.dispatchBatch(ctx)
+	}
+
+	delivered := 0
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the transaction only holds the lock, the batch commits as it goes
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?::int, 0)", dispatcherLockClass).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var err error
+		delivered, err = d.dispatchBatch(ctx)
+		return err
+	})
+	return delivered, err
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	// aggregates waiting for a retry are left out, so they can't fill the
+	// batch and hold back the others
+	now := time.Now()
+	waiting := d.db.Table("outbox_events AS w").Select("1").
+		Where("w.aggregate_type = e.aggregate_type AND w.aggregate_id = e.aggregate_id").
+		Where("w.dispatched_at IS NULL AND w.parked_at IS NULL AND w.next_attempt_at > ?", now)
+
+	var events []Event
+	err := d.db.WithContext(ctx).Table("outbox_events AS e").Select("e.*").
+		Where("e.dispatched_at IS NULL AND e.parked_at IS NULL").
+		Where("NOT EXISTS (?)", waiting).
+		Order("e.id").
+		Limit(d.config.BatchSize).
+		Find(&events).Error
+	if err != nil {
+		return 0, err
+	}
+
+	blocked := make(map[string]bool)
+	delivered := 0
+
+	for _, event := range events {
+		key := event.AggregateType + ":" + event.AggregateID
+		if blocked[key] {
+			continue
+		}
+
+		if deliverErr := d.deliver(ctx, event); deliverErr != nil {
+			blocked[key] = true
+			if err := d.fail(ctx, event, deliverErr); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		err := d.db.WithContext(ctx).Model(&Event{}).
+			Where("id = ?", event.ID).
+			Update("dispatched_at", time.Now()).Error
+		if err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// fail records a failed attempt and schedules the next one, or parks the
+// event once it has had MaxAttempts.
+func (d *Dispatcher) fail(ctx context.Context, event Event, deliverErr error) error {
+	attempts := event.Attempts + 1
+	updates := map[string]any{
+		"attempts":   attempts,
+		"last_error": deliverErr.Error(),
+	}
+	if attempts >= d.config.MaxAttempts {
+		log.Printf("Outbox event %s (%s) parked after %d attempts: %v", event.EventID, event.Type, attempts, deliverErr)
+		updates["parked_at"] = time.Now()
+	} else {
+		log.Printf("Outbox event %s (%s) failed: %v", event.EventID, event.Type, deliverErr)
+		updates["next_attempt_at"] = time.Now().Add(d.config.BaseBackoff << (attempts - 1))
+	}
+
+	return d.db.WithContext(ctx).Model(&Event{}).Where("id = ?", event.ID).Updates(updates).Error
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, event Event) error {
+	for _, sink := range d.sinks {
+		if err := sink.Deliver(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logSink writes events to the application log.
+type logSink struct{}
+
+func NewLogSink() Sink {
+	return &logSink{}
+}
+
+func (s *logSink) Deliver(ctx context.Context, event Event) error {
+	log.Printf("Event %s %s/%s: %s", event.Type, event.AggregateType, event.AggregateID, event.Payload)
+	return nil
+}
@@ -0,0 +1,171 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/natchaphonbw/usermanagement/config"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/outbox"
+)
+
+// recordingSink records the events it delivers and fails those of the
+// aggregates in failing.
+type recordingSink struct {
+	mu        sync.Mutex
+	failing   map[uuid.UUID]bool
+	delivered []string
+}
+
+func (s *recordingSink) Deliver(ctx context.Context, event outbox.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing[uuid.MustParse(event.AggregateID)] {
+		return errors.New("sink unavailable")
+	}
+	s.delivered = append(s.delivered, event.Type)
+	return nil
+}
+
+func newOutboxDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := databases.Connect(&config.Config{DBDriver: databases.DriverSQLite, DBPath: ":memory:"})
+	if err := db.AutoMigrate(&outbox.Event{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func appendEvents(t *testing.T, db *gorm.DB, aggregateID uuid.UUID, types ...string) {
+	t.Helper()
+
+	for _, eventType := range types {
+		if err := outbox.Append(db, "user", aggregateID, eventType, map[string]any{}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+}
+
+func dispatch(t *testing.T, d *outbox.Dispatcher) int {
+	t.Helper()
+
+	n, err := d.DispatchPending(context.Background())
+	if err != nil {
+		t.Fatalf("DispatchPending: %v", err)
+	}
+	return n
+}
+
+func TestDispatcherFailingAggregateWaits(t *testing.T) {
+	db := newOutboxDB(t)
+	broken, healthy := uuid.New(), uuid.New()
+	sink := &recordingSink{failing: map[uuid.UUID]bool{broken: true}}
+	d := outbox.NewDispatcher(db, outbox.DispatcherConfig{BatchSize: 2, MaxAttempts: 5, BaseBackoff: time.Hour}, sink)
+
+	appendEvents(t, db, broken, "broken.1", "broken.2", "broken.3")
+	appendEvents(t, db, healthy, "healthy.1")
+
+	// the broken aggregate fills the first batch, then waits for its retry
+	if n := dispatch(t, d); n != 0 {
+		t.Errorf("first dispatch delivered %d, want 0", n)
+	}
+	if n := dispatch(t, d); n != 1 {
+		t.Errorf("second dispatch delivered %d, want 1", n)
+	}
+	if len(sink.delivered) != 1 || sink.delivered[0] != "healthy.1" {
+		t.Errorf("delivered %v, want [healthy.1]", sink.delivered)
+	}
+
+	var head outbox.Event
+	db.Order("id").First(&head, "aggregate_id = ?", broken.String())
+	if head.Attempts != 1 || head.NextAttemptAt == nil || time.Until(*head.NextAttemptAt) < 59*time.Minute {
+		t.Errorf("failed event: attempts %d, next attempt %v, want 1 and in an hour", head.Attempts, head.NextAttemptAt)
+	}
+}
+
+func TestDispatcherParksPoisonEvent(t *testing.T) {
+	db := newOutboxDB(t)
+	aggregate := uuid.New()
+	sink := &recordingSink{failing: map[uuid.UUID]bool{aggregate: true}}
+	d := outbox.NewDispatcher(db, outbox.DispatcherConfig{BatchSize: 10, MaxAttempts: 3}, sink)
+
+	appendEvents(t, db, aggregate, "first")
+	for range 3 {
+		dispatch(t, d)
+	}
+
+	var poison outbox.Event
+	db.First(&poison, "aggregate_id = ?", aggregate.String())
+	if poison.ParkedAt == nil || poison.Attempts != 3 || poison.LastError == "" {
+		t.Fatalf("event after 3 failures: parked %v, attempts %d, error %q", poison.ParkedAt, poison.Attempts, poison.LastError)
+	}
+
+	// the parked event no longer holds back its aggregate
+	sink.failing = nil
+	appendEvents(t, db, aggregate, "second")
+	if n := dispatch(t, d); n != 1 || len(sink.delivered) != 1 || sink.delivered[0] != "second" {
+		t.Errorf("dispatch delivered %d %v, want [second]", n, sink.delivered)
+	}
+}
+
+// slowSink counts deliveries and takes a while over each, so concurrent
+// dispatchers overlap.
+type slowSink struct {
+	mu        sync.Mutex
+	delivered map[uuid.UUID]int
+}
+
+func (s *slowSink) Deliver(ctx context.Context, event outbox.Event) error {
+	time.Sleep(10 * time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered[event.EventID]++
+	return nil
+}
+
+func TestDispatchersTakeTurnsOnPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connect postgres: %v", err)
+	}
+	if err := db.AutoMigrate(&outbox.Event{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	aggregate := uuid.New()
+	appendEvents(t, db, aggregate, "first", "second", "third")
+
+	sink := &slowSink{delivered: make(map[uuid.UUID]int)}
+	var wg sync.WaitGroup
+	for range 2 {
+		d := outbox.NewDispatcher(db, outbox.DispatcherConfig{BatchSize: 10, MaxAttempts: 3}, sink)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.DispatchPending(context.Background()); err != nil {
+				t.Errorf("DispatchPending: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var events []outbox.Event
+	db.Find(&events, "aggregate_id = ?", aggregate.String())
+	for _, event := range events {
+		if n := sink.delivered[event.EventID]; n > 1 {
+			t.Errorf("event %s delivered %d times", event.Type, n)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event is a domain event stored in the outbox table. ID is a monotonically
+// increasing sequence used to deliver events in order per aggregate.
+type Event struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement" json:"-"`
+	EventID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"id"`
+	AggregateType string     `gorm:"type:varchar(50);not null" json:"aggregate_type"`
+	AggregateID   string     `gorm:"type:varchar(100);not null;index" json:"aggregate_id"`
+	Type          string     `gorm:"type:varchar(100);not null" json:"type"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	OccurredAt    time.Time  `gorm:"not null" json:"occurred_at"`
+	DispatchedAt  *time.Time `gorm:"index" json:"-"`
+	Attempts      int        `gorm:"not null;default:0" json:"-"`
+	LastError     string     `gorm:"type:text" json:"-"`
+	// NextAttemptAt is when a failed event is retried, nil until it fails.
+	NextAttemptAt *time.Time `json:"-"`
+	// ParkedAt is when the event was given up on after MaxAttempts. It is
+	// kept for inspection and no longer holds back its aggregate; clear it
+	// to dispatch the event again.
+	ParkedAt *time.Time `gorm:"index" json:"-"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Advisory lock classes, the first key of Postgres' two-key advisory
+// locks.
+const (
+	dispatcherLockClass = 0x6f627801
+	aggregateLockClass  = 0x6f627802
+)
+
+func isPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// Append writes an event using tx, so it commits or rolls back together
+// with the state change that raised it.
+func Append(tx *gorm.DB, aggregateType string, aggregateID uuid.UUID, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	// IDs come from a sequence at insert, not at commit. Serializing the
+	// appends of an aggregate keeps its IDs in commit order, so the
+	// dispatcher can't deliver an event before an earlier one commits.
+	if isPostgres(tx) {
+		key := aggregateType + ":" + aggregateID.String()
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?::int, hashtext(?))", aggregateLockClass, key).Error; err != nil {
+			return err
+		}
+	}
+
+	event := &Event{
+		EventID:       uuid.New(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID.String(),
+		Type:          eventType,
+		Payload:       string(data),
+		OccurredAt:    time.Now(),
+	}
+
+	return tx.Create(event).Error
+}
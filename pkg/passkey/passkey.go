@@ -0,0 +1,260 @@
+// Package passkey runs WebAuthn registration and assertion ceremonies as
+// a relying party. Options and responses are passed through as the JSON
+// the browser's navigator.credentials API takes and returns, and the
+// state kept between the two halves of a ceremony is opaque bytes.
+package passkey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ErrInvalidResponse is returned when a response fails verification.
+var ErrInvalidResponse = errors.New("passkey: invalid response")
+
+// Config describes the relying party.
+type Config struct {
+	// RPID is the domain credentials are scoped to.
+	RPID   string
+	RPName string
+	// Origins the browser may run the ceremony from.
+	Origins []string
+}
+
+// User is the account a ceremony is for.
+type User struct {
+	// Handle is the stable, opaque user ID stored on the authenticator.
+	Handle      []byte
+	Name        string
+	DisplayName string
+	Credentials []Credential
+}
+
+// Credential is a registered public key credential.
+type Credential struct {
+	ID              []byte
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	SignCount       uint32
+	Transports      []string
+	BackupEligible  bool
+	BackupState     bool
+	// CloneWarning is set when the sign count didn't increase, which
+	// suggests the authenticator has been cloned.
+	CloneWarning bool
+}
+
+// Ceremony is the first half of a ceremony: Options go to the browser and
+// Session is kept until the response comes back.
+type Ceremony struct {
+	Options json.RawMessage
+	Session []byte
+}
+
+// RelyingParty runs ceremonies for one relying party.
+type RelyingParty struct {
+	webauthn *webauthn.WebAuthn
+}
+
+func New(cfg Config) (*RelyingParty, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.Origins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("passkey: %w", err)
+	}
+	return &RelyingParty{webauthn: w}, nil
+}
+
+// BeginRegistration starts registering a new credential for the user. The
+// user's existing credentials are excluded so an authenticator isn't
+// registered twice.
+func (rp *RelyingParty) BeginRegistration(user User) (*Ceremony, error) {
+	wu := webauthnUser(user)
+	creation, session, err := rp.webauthn.BeginRegistration(wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("passkey: %w", err)
+	}
+	return newCeremony(creation, session)
+}
+
+// FinishRegistration verifies the browser's response and returns the new
+// credential.
+func (rp *RelyingParty) FinishRegistration(user User, session, response []byte) (*Credential, error) {
+	data, err := decodeSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	cred, err := rp.webauthn.CreateCredential(webauthnUser(user), *data, parsed)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	return fromWebauthn(cred), nil
+}
+
+// BeginLogin starts an assertion with the user's credentials. With a nil
+// user any discoverable credential for the relying party may be used.
+func (rp *RelyingParty) BeginLogin(user *User) (*Ceremony, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		err       error
+	)
+	if user == nil {
+		assertion, session, err = rp.webauthn.BeginDiscoverableLogin()
+	} else {
+		assertion, session, err = rp.webauthn.BeginLogin(webauthnUser(*user))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("passkey: %w", err)
+	}
+	return newCeremony(assertion, session)
+}
+
+// FinishLogin verifies the browser's response. lookup loads the user with
+// the given handle; its errors are returned as is.
+func (rp *RelyingParty) FinishLogin(session, response []byte, lookup func(handle []byte) (*User, error)) (*User, *Credential, error) {
+	data, err := decodeSession(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, invalid(err)
+	}
+
+	var (
+		found     *User
+		lookupErr error
+	)
+	load := func(handle []byte) (*passkeyUser, error) {
+		found, lookupErr = lookup(handle)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return webauthnUser(*found), nil
+	}
+
+	var cred *webauthn.Credential
+	if len(data.UserID) > 0 {
+		var wu *passkeyUser
+		if wu, err = load(data.UserID); err == nil {
+			cred, err = rp.webauthn.ValidateLogin(wu, *data, parsed)
+		}
+	} else {
+		_, cred, err = rp.webauthn.ValidatePasskeyLogin(func(_, handle []byte) (webauthn.User, error) {
+			return load(handle)
+		}, *data, parsed)
+	}
+	if lookupErr != nil {
+		return nil, nil, lookupErr
+	}
+	if err != nil {
+		return nil, nil, invalid(err)
+	}
+	return found, fromWebauthn(cred), nil
+}
+
+func newCeremony(options any, session *webauthn.SessionData) (*Ceremony, error) {
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("passkey: encode options: %w", err)
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("passkey: encode session: %w", err)
+	}
+	return &Ceremony{Options: opts, Session: data}, nil
+}
+
+func decodeSession(session []byte) (*webauthn.SessionData, error) {
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return nil, fmt.Errorf("passkey: decode session: %w", err)
+	}
+	return &data, nil
+}
+
+// invalid wraps a verification failure, keeping the library's details.
+func invalid(err error) error {
+	var protoErr *protocol.Error
+	if errors.As(err, &protoErr) && protoErr.Details != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidResponse, protoErr.Details)
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+}
+
+// passkeyUser adapts User to webauthn.User.
+type passkeyUser struct {
+	user        User
+	credentials []webauthn.Credential
+}
+
+func webauthnUser(user User) *passkeyUser {
+	creds := make([]webauthn.Credential, len(user.Credentials))
+	for i, c := range user.Credentials {
+		creds[i] = toWebauthn(c)
+	}
+	return &passkeyUser{user: user, credentials: creds}
+}
+
+func (u *passkeyUser) WebAuthnID() []byte                         { return u.user.Handle }
+func (u *passkeyUser) WebAuthnName() string                       { return u.user.Name }
+func (u *passkeyUser) WebAuthnDisplayName() string                { return u.user.DisplayName }
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+func toWebauthn(c Credential) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, len(c.Transports))
+	for i, t := range c.Transports {
+		transports[i] = protocol.AuthenticatorTransport(t)
+	}
+	return webauthn.Credential{
+		ID:              c.ID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: c.BackupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    c.AAGUID,
+			SignCount: c.SignCount,
+		},
+	}
+}
+
+func fromWebauthn(c *webauthn.Credential) *Credential {
+	transports := make([]string, len(c.Transport))
+	for i, t := range c.Transport {
+		transports[i] = string(t)
+	}
+	return &Credential{
+		ID:              c.ID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		AAGUID:          c.Authenticator.AAGUID,
+		SignCount:       c.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  c.Flags.BackupEligible,
+		BackupState:     c.Flags.BackupState,
+		CloneWarning:    c.Authenticator.CloneWarning,
+	}
+}
@@ -0,0 +1,141 @@
+package passkey_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/natchaphonbw/usermanagement/pkg/passkey"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey/passkeytest"
+)
+
+const origin = "https://app.example.com"
+
+func newRelyingParty(t *testing.T) *passkey.RelyingParty {
+	t.Helper()
+
+	rp, err := passkey.New(passkey.Config{RPID: "app.example.com", RPName: "Example", Origins: []string{origin}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return rp
+}
+
+func register(t *testing.T, rp *passkey.RelyingParty, authn *passkeytest.Authenticator, user *passkey.User) *passkey.Credential {
+	t.Helper()
+
+	ceremony, err := rp.BeginRegistration(*user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	cred, err := rp.FinishRegistration(*user, ceremony.Session, authn.Register(t, ceremony.Options))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	user.Credentials = append(user.Credentials, *cred)
+	return cred
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	rp := newRelyingParty(t)
+	authn := passkeytest.NewAuthenticator(origin)
+	user := &passkey.User{Handle: []byte("user-1"), Name: "ann@example.com", DisplayName: "Ann"}
+
+	cred := register(t, rp, authn, user)
+	if len(cred.ID) == 0 || len(cred.PublicKey) == 0 {
+		t.Fatalf("credential = %+v", cred)
+	}
+	if len(cred.Transports) != 1 || cred.Transports[0] != "internal" {
+		t.Errorf("Transports = %v", cred.Transports)
+	}
+
+	lookup := func(handle []byte) (*passkey.User, error) {
+		if !bytes.Equal(handle, user.Handle) {
+			t.Fatalf("lookup(%q)", handle)
+		}
+		return user, nil
+	}
+
+	t.Run("with the user's credentials", func(t *testing.T) {
+		ceremony, err := rp.BeginLogin(user)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		found, used, err := rp.FinishLogin(ceremony.Session, authn.Login(t, ceremony.Options), lookup)
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+		if found != user || !bytes.Equal(used.ID, cred.ID) || used.SignCount != 1 {
+			t.Errorf("FinishLogin = %v, %+v", found, used)
+		}
+		user.Credentials[0].SignCount = used.SignCount
+	})
+
+	t.Run("discoverable", func(t *testing.T) {
+		ceremony, err := rp.BeginLogin(nil)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		found, used, err := rp.FinishLogin(ceremony.Session, authn.Login(t, ceremony.Options), lookup)
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+		if found != user || used.SignCount != 2 || used.CloneWarning {
+			t.Errorf("FinishLogin = %v, %+v", found, used)
+		}
+	})
+
+	t.Run("sign count going back", func(t *testing.T) {
+		user.Credentials[0].SignCount = 10
+
+		ceremony, err := rp.BeginLogin(user)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		_, used, err := rp.FinishLogin(ceremony.Session, authn.Login(t, ceremony.Options), lookup)
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+		if !used.CloneWarning {
+			t.Error("CloneWarning not set")
+		}
+	})
+}
+
+func TestInvalidResponses(t *testing.T) {
+	rp := newRelyingParty(t)
+	authn := passkeytest.NewAuthenticator(origin)
+	user := &passkey.User{Handle: []byte("user-1"), Name: "ann@example.com", DisplayName: "Ann"}
+	register(t, rp, authn, user)
+
+	lookup := func([]byte) (*passkey.User, error) { return user, nil }
+
+	t.Run("replayed challenge", func(t *testing.T) {
+		first, _ := rp.BeginLogin(user)
+		second, _ := rp.BeginLogin(user)
+		response := authn.Login(t, first.Options)
+		if _, _, err := rp.FinishLogin(second.Session, response, lookup); !errors.Is(err, passkey.ErrInvalidResponse) {
+			t.Fatalf("err = %v, want ErrInvalidResponse", err)
+		}
+	})
+
+	t.Run("other origin", func(t *testing.T) {
+		other := &passkey.User{Handle: []byte("user-2"), Name: "bob@example.com"}
+		ceremony, _ := rp.BeginRegistration(*other)
+		response := passkeytest.NewAuthenticator("https://evil.example.com").Register(t, ceremony.Options)
+		if _, err := rp.FinishRegistration(*other, ceremony.Session, response); !errors.Is(err, passkey.ErrInvalidResponse) {
+			t.Fatalf("err = %v, want ErrInvalidResponse", err)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		missing := errors.New("no such user")
+		ceremony, _ := rp.BeginLogin(nil)
+		_, _, err := rp.FinishLogin(ceremony.Session, authn.Login(t, ceremony.Options), func([]byte) (*passkey.User, error) {
+			return nil, missing
+		})
+		if !errors.Is(err, missing) {
+			t.Fatalf("err = %v, want lookup error", err)
+		}
+	})
+}
@@ -0,0 +1,237 @@
+// Package passkeytest provides a software authenticator for tests.
+package passkeytest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Authenticator is a platform authenticator holding P-256 credentials. It
+// answers the options produced by the passkey package with responses as
+// a browser would send them, with the user present and verified.
+type Authenticator struct {
+	origin string
+
+	mu          sync.Mutex
+	credentials []*credential
+}
+
+type credential struct {
+	id     []byte
+	rpID   string
+	handle []byte
+	key    *ecdsa.PrivateKey
+	count  uint32
+}
+
+// NewAuthenticator returns an authenticator that reports the browser as
+// being at origin.
+func NewAuthenticator(origin string) *Authenticator {
+	return &Authenticator{origin: origin}
+}
+
+// Register creates a credential for registration options and returns the
+// attestation response.
+func (a *Authenticator) Register(t testing.TB, options []byte) []byte {
+	t.Helper()
+
+	var opts struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		t.Fatalf("decode registration options: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate credential key: %v", err)
+	}
+	cred := &credential{
+		id:     random(t, 16),
+		rpID:   opts.PublicKey.RP.ID,
+		handle: decode(t, opts.PublicKey.User.ID),
+		key:    key,
+	}
+
+	x, y := key.PublicKey.X.FillBytes(make([]byte, 32)), key.PublicKey.Y.FillBytes(make([]byte, 32))
+	coseKey, err := cbor.Marshal(map[int]any{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+
+	var attested bytes.Buffer
+	attested.Write(make([]byte, 16)) // AAGUID
+	binary.Write(&attested, binary.BigEndian, uint16(len(cred.id)))
+	attested.Write(cred.id)
+	attested.Write(coseKey)
+
+	authData := authenticatorData(cred.rpID, 0x01|0x04|0x40, cred.count, attested.Bytes())
+	attestation, err := cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+
+	a.mu.Lock()
+	a.credentials = append(a.credentials, cred)
+	a.mu.Unlock()
+
+	return respond(t, cred.id, map[string]any{
+		"clientDataJSON":    encode(a.clientData(t, "webauthn.create", opts.PublicKey.Challenge)),
+		"attestationObject": encode(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// Login signs an assertion for login options with the most recently
+// registered credential they allow and returns the assertion response.
+func (a *Authenticator) Login(t testing.TB, options []byte) []byte {
+	t.Helper()
+
+	var opts struct {
+		PublicKey struct {
+			Challenge        string `json:"challenge"`
+			RPID             string `json:"rpId"`
+			AllowCredentials []struct {
+				ID string `json:"id"`
+			} `json:"allowCredentials"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		t.Fatalf("decode login options: %v", err)
+	}
+
+	cred := a.find(t, opts.PublicKey.RPID, opts.PublicKey.AllowCredentials)
+	a.mu.Lock()
+	cred.count++
+	count := cred.count
+	a.mu.Unlock()
+
+	authData := authenticatorData(cred.rpID, 0x01|0x04, count, nil)
+	clientData := a.clientData(t, "webauthn.get", opts.PublicKey.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return respond(t, cred.id, map[string]any{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(cred.handle),
+	})
+}
+
+func (a *Authenticator) find(t testing.TB, rpID string, allowed []struct {
+	ID string `json:"id"`
+}) *credential {
+	t.Helper()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := len(a.credentials) - 1; i >= 0; i-- {
+		cred := a.credentials[i]
+		if cred.rpID != rpID {
+			continue
+		}
+		if len(allowed) == 0 {
+			return cred
+		}
+		for _, allow := range allowed {
+			if bytes.Equal(decode(t, allow.ID), cred.id) {
+				return cred
+			}
+		}
+	}
+	t.Fatalf("no credential for %s", rpID)
+	return nil
+}
+
+func (a *Authenticator) clientData(t testing.TB, typ, challenge string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+	return data
+}
+
+func authenticatorData(rpID string, flags byte, count uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	var data bytes.Buffer
+	data.Write(rpIDHash[:])
+	data.WriteByte(flags)
+	binary.Write(&data, binary.BigEndian, count)
+	data.Write(attested)
+	return data.Bytes()
+}
+
+func respond(t testing.TB, id []byte, response map[string]any) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{
+		"id":       encode(id),
+		"rawId":    encode(id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode response: %v", err)
+	}
+	return body
+}
+
+func random(t testing.TB, n int) []byte {
+	t.Helper()
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("generate credential ID: %v", err)
+	}
+	return b
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(t testing.TB, s string) []byte {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
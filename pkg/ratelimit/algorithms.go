@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// state is what a store keeps per key, times are in milliseconds. For a
+// token bucket it is the tokens left at time at, for a sliding window the
+// counts of the window starting at at and of the one before it.
+// redis.go implements the same arithmetic in Lua.
+type state struct {
+	at          int64
+	tokens      float64
+	cur, prev   float64
+	initialized bool
+}
+
+// take counts one request at now (ms) and returns the result and how long
+// the state has to be kept.
+func (s *state) take(policy Policy, now int64) (Result, time.Duration) {
+	if policy.Algorithm == SlidingWindow {
+		return s.takeWindow(policy, now)
+	}
+	return s.takeToken(policy, now)
+}
+
+func (s *state) takeToken(policy Policy, now int64) (Result, time.Duration) {
+	limit := float64(policy.Limit)
+	rate := limit / float64(policy.Window.Milliseconds())
+
+	if !s.initialized {
+		s.tokens, s.at, s.initialized = limit, now, true
+	}
+	s.tokens = math.Min(limit, s.tokens+float64(max(now-s.at, 0))*rate)
+	s.at = now
+
+	result := Result{Limit: policy.Limit}
+	if s.tokens >= 1 {
+		s.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = millis(math.Ceil((1 - s.tokens) / rate))
+	}
+	result.Remaining = int(s.tokens)
+	result.Reset = millis(math.Ceil((limit - s.tokens) / rate))
+
+	// a bucket that has refilled is the same as no bucket
+	return result, result.Reset
+}
+
+func (s *state) takeWindow(policy Policy, now int64) (Result, time.Duration) {
+	limit := float64(policy.Limit)
+	window := policy.Window.Milliseconds()
+	start := now - now%window
+
+	switch {
+	case s.initialized && s.at == start:
+	case s.initialized && s.at == start-window:
+		s.prev, s.cur = s.cur, 0
+	default:
+		s.prev, s.cur = 0, 0
+	}
+	s.at, s.initialized = start, true
+
+	elapsed := float64(now - start)
+	count := s.prev*(float64(window)-elapsed)/float64(window) + s.cur
+
+	result := Result{Limit: policy.Limit}
+	if count+1 <= limit {
+		s.cur++
+		count++
+		result.Allowed = true
+	} else if s.cur+1 <= limit {
+		// only the previous window's weight is in the way, wait for it to
+		// fade to limit-1-cur
+		wait := float64(window) - (limit-1-s.cur)*float64(window)/s.prev - elapsed
+		result.RetryAfter = millis(math.Ceil(math.Max(wait, 1)))
+	} else {
+		// the current window is full, wait for it to become the previous
+		// one and fade to limit-1
+		wait := float64(window) * (1 - (limit-1)/s.cur)
+		result.RetryAfter = millis(float64(window) - elapsed + math.Ceil(math.Max(wait, 0)))
+	}
+	result.Remaining = max(int(limit-count), 0)
+	result.Reset = millis(float64(window) - elapsed)
+
+	return result, 2 * policy.Window
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many Takes pass between removing expired keys.
+const sweepEvery = 1024
+
+// MemoryStore keeps counts in process, for running a single node.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	takes   int
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+type memoryEntry struct {
+	state   state
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	key = policy.Name + ":" + key
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.expires) {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+
+	result, ttl := entry.state.take(policy, now.UnixMilli())
+	entry.expires = now.Add(ttl)
+	return result, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package ratelimit counts requests per key against a Policy. Counting is
+// done by a Store, in memory for a single node or in Redis to share limits
+// between nodes; both implement the same two algorithms:
+//
+//   - token bucket: a bucket of Limit tokens refilled evenly over Window,
+//     which allows a burst of Limit requests and then a steady rate.
+//   - sliding window: the current and previous fixed windows, the previous
+//     one weighted by how much of it still overlaps the last Window. It
+//     keeps to Limit per Window closely without storing every request.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	TokenBucket   Algorithm = "token_bucket"
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// What a policy counts requests by.
+const (
+	KeyIP     = "ip"
+	KeyUser   = "user"
+	KeyEmail  = "email"
+	KeyAPIKey = "api_key"
+)
+
+var ErrInvalidPolicy = errors.New("invalid rate limit policy")
+
+// Policy allows Limit requests per Window for every key.
+type Policy struct {
+	// Name identifies the policy in storage, e.g. "auth.login:ip".
+	Name      string
+	KeyBy     string
+	Limit     int
+	Window    time.Duration
+	Algorithm Algorithm
+}
+
+// Result is the outcome of taking one request from a policy.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is the time until the full limit is available again.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next request would be allowed,
+	// zero when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store counts a request for key under policy and reports whether it is
+// allowed. Implementations must be safe for concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// ParsePolicies parses per-route policies, as in
+//
+//	auth.login=ip:20/1m,email:5/15m:sliding_window;auth.register=ip:5/1h
+//
+// Each route has one or more KEY:LIMIT/WINDOW[:ALGORITHM] policies, all of
+// which a request has to pass. The algorithm defaults to token_bucket.
+func ParsePolicies(spec string) (map[string][]Policy, error) {
+	routes := make(map[string][]Policy)
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		route, list, ok := strings.Cut(rule, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("%w: %q is not ROUTE=POLICIES", ErrInvalidPolicy, rule)
+		}
+		if _, dup := routes[route]; dup {
+			return nil, fmt.Errorf("%w: route %s defined twice", ErrInvalidPolicy, route)
+		}
+
+		for _, text := range strings.Split(list, ",") {
+			policy, err := parsePolicy(route, strings.TrimSpace(text))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPolicy, route, err)
+			}
+			routes[route] = append(routes[route], policy)
+		}
+	}
+	return routes, nil
+}
+
+func parsePolicy(route, text string) (Policy, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Policy{}, fmt.Errorf("%q is not KEY:LIMIT/WINDOW[:ALGORITHM]", text)
+	}
+
+	policy := Policy{
+		Name:      route + ":" + parts[0],
+		KeyBy:     parts[0],
+		Algorithm: TokenBucket,
+	}
+	switch policy.KeyBy {
+	case KeyIP, KeyUser, KeyEmail, KeyAPIKey:
+	default:
+		return Policy{}, fmt.Errorf("unknown key %q", policy.KeyBy)
+	}
+
+	limit, window, ok := strings.Cut(parts[1], "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("%q is not LIMIT/WINDOW", parts[1])
+	}
+	var err error
+	if policy.Limit, err = strconv.Atoi(limit); err != nil || policy.Limit < 1 {
+		return Policy{}, fmt.Errorf("limit %q must be a positive number", limit)
+	}
+	if policy.Window, err = time.ParseDuration(window); err != nil || policy.Window < time.Millisecond {
+		return Policy{}, fmt.Errorf("window %q must be a duration of at least 1ms", window)
+	}
+
+	if len(parts) == 3 {
+		policy.Algorithm = Algorithm(parts[2])
+		if policy.Algorithm != TokenBucket && policy.Algorithm != SlidingWindow {
+			return Policy{}, fmt.Errorf("unknown algorithm %q", parts[2])
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// start is aligned to a 10s window.
+var start = time.UnixMilli(1_700_000_000_000)
+
+// testStores returns every Store with a function setting its clock.
+func testStores(t *testing.T) map[string]func() (Store, func(time.Time)) {
+	return map[string]func() (Store, func(time.Time)){
+		"memory": func() (Store, func(time.Time)) {
+			store := NewMemoryStore()
+			now := start
+			store.now = func() time.Time { return now }
+			return store, func(t time.Time) { now = t }
+		},
+		"redis": func() (Store, func(time.Time)) {
+			server := miniredis.RunT(t)
+			server.SetTime(start)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { client.Close() })
+			return NewRedisStore(client, "ratelimit:"), server.SetTime
+		},
+	}
+}
+
+type step struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func TestStores(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{
+			name:   "token bucket",
+			policy: Policy{Name: "bucket", Limit: 3, Window: 3 * time.Second, Algorithm: TokenBucket},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 0, allowed: false, retryAfter: time.Second},
+				{at: 500 * time.Millisecond, allowed: false, retryAfter: 500 * time.Millisecond},
+				// one token a second
+				{at: time.Second, allowed: true, remaining: 0},
+				{at: time.Second, allowed: false, retryAfter: time.Second},
+				{at: 10 * time.Second, allowed: true, remaining: 2},
+			},
+		},
+		{
+			name:   "sliding window",
+			policy: Policy{Name: "window", Limit: 4, Window: 10 * time.Second, Algorithm: SlidingWindow},
+			steps: []step{
+				{at: 0, allowed: true, remaining: 3},
+				{at: time.Second, allowed: true, remaining: 2},
+				{at: 2 * time.Second, allowed: true, remaining: 1},
+				{at: 3 * time.Second, allowed: true, remaining: 0},
+				// the window is full, the 4 requests have to fade to 3
+				// in the next one: 1/4 into it
+				{at: 4 * time.Second, allowed: false, retryAfter: 8500 * time.Millisecond},
+				{at: 12500 * time.Millisecond, allowed: true, remaining: 0},
+				// 4*0.75 + 1 = 4, wait for 4*w + 1 <= 3
+				{at: 12500 * time.Millisecond, allowed: false, retryAfter: 2500 * time.Millisecond},
+				{at: 15 * time.Second, allowed: true, remaining: 0},
+				{at: 40 * time.Second, allowed: true, remaining: 3},
+			},
+		},
+	}
+
+	for storeName, newStore := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				store, setTime := newStore()
+				ctx := context.Background()
+
+				for i, s := range tt.steps {
+					setTime(start.Add(s.at))
+					result, err := store.Take(ctx, "10.0.0.1", tt.policy)
+					if err != nil {
+						t.Fatalf("step %d: Take: %v", i, err)
+					}
+					if result.Allowed != s.allowed || result.Limit != tt.policy.Limit ||
+						(s.allowed && result.Remaining != s.remaining) || result.RetryAfter != s.retryAfter {
+						t.Errorf("step %d at %s: got %+v, want allowed %t, remaining %d, retry after %s",
+							i, s.at, result, s.allowed, s.remaining, s.retryAfter)
+					}
+				}
+
+				// other keys and policies are counted separately
+				other := tt.policy
+				other.Name += "-other"
+				for _, take := range []struct {
+					key    string
+					policy Policy
+				}{{"10.0.0.2", tt.policy}, {"10.0.0.1", other}} {
+					result, err := store.Take(ctx, take.key, take.policy)
+					if err != nil || !result.Allowed || result.Remaining != tt.policy.Limit-1 {
+						t.Errorf("Take(%s, %s) = %+v, %v, want a fresh limit", take.key, take.policy.Name, result, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParsePolicies(t *testing.T) {
+	routes, err := ParsePolicies(" auth.login=ip:20/1m, email:5/15m:sliding_window ; users=user:100/1h;")
+	if err != nil {
+		t.Fatalf("ParsePolicies: %v", err)
+	}
+
+	want := map[string][]Policy{
+		"auth.login": {
+			{Name: "auth.login:ip", KeyBy: KeyIP, Limit: 20, Window: time.Minute, Algorithm: TokenBucket},
+			{Name: "auth.login:email", KeyBy: KeyEmail, Limit: 5, Window: 15 * time.Minute, Algorithm: SlidingWindow},
+		},
+		"users": {
+			{Name: "users:user", KeyBy: KeyUser, Limit: 100, Window: time.Hour, Algorithm: TokenBucket},
+		},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("routes = %+v, want %+v", routes, want)
+	}
+	for route, policies := range want {
+		if len(routes[route]) != len(policies) {
+			t.Fatalf("%s = %+v, want %+v", route, routes[route], policies)
+		}
+		for i := range policies {
+			if routes[route][i] != policies[i] {
+				t.Errorf("%s[%d] = %+v, want %+v", route, i, routes[route][i], policies[i])
+			}
+		}
+	}
+
+	if routes, err := ParsePolicies(""); err != nil || len(routes) != 0 {
+		t.Errorf("ParsePolicies(\"\") = %v, %v, want none", routes, err)
+	}
+
+	for _, spec := range []string{
+		"auth.login",
+		"=ip:1/1m",
+		"auth.login=ip",
+		"auth.login=cookie:1/1m",
+		"auth.login=ip:0/1m",
+		"auth.login=ip:1/forever",
+		"auth.login=ip:1/1m:leaky_bucket",
+		"auth.login=ip:1/1m;auth.login=email:1/1m",
+	} {
+		if _, err := ParsePolicies(spec); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("ParsePolicies(%q) = %v, want ErrInvalidPolicy", spec, err)
+		}
+	}
+}
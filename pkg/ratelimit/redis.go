@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps counts in Redis so every node shares the same limits.
+// Each Take is one script call, atomic on the server and timed by the
+// server's clock, so nodes with skewed clocks still agree.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore stores keys under prefix, e.g. "ratelimit:".
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// takeScript mirrors state.take in algorithms.go.
+//
+// KEYS[1] the hash holding at, tokens, cur and prev
+// ARGV    algorithm, limit, window in ms
+// returns {allowed, remaining, retry after ms, reset ms}
+var takeScript = redis.NewScript(`
+local algorithm = ARGV[1]
+local limit = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local stored = redis.call('HMGET', KEYS[1], 'at', 'tokens', 'cur', 'prev')
+local at = tonumber(stored[1])
+
+local allowed, remaining, retry, reset, ttl = 0, 0, 0, 0, 0
+
+if algorithm == 'token_bucket' then
+	local rate = limit / window
+	local tokens = tonumber(stored[2])
+	if at == nil then
+		tokens, at = limit, now
+	end
+	tokens = math.min(limit, tokens + math.max(now - at, 0) * rate)
+
+	if tokens >= 1 then
+		tokens = tokens - 1
+		allowed = 1
+	else
+		retry = math.ceil((1 - tokens) / rate)
+	end
+	remaining = math.floor(tokens)
+	reset = math.ceil((limit - tokens) / rate)
+	ttl = math.max(reset, 1)
+
+	redis.call('HSET', KEYS[1], 'at', now, 'tokens', tostring(tokens))
+else
+	local start = now - now % window
+	local cur, prev = tonumber(stored[3]) or 0, tonumber(stored[4]) or 0
+	if at == start then
+	elseif at == start - window then
+		prev, cur = cur, 0
+	else
+		prev, cur = 0, 0
+	end
+
+	local elapsed = now - start
+	local count = prev * (window - elapsed) / window + cur
+
+	if count + 1 <= limit then
+		cur = cur + 1
+		count = count + 1
+		allowed = 1
+	elseif cur + 1 <= limit then
+		local wait = window - (limit - 1 - cur) * window / prev - elapsed
+		retry = math.ceil(math.max(wait, 1))
+	else
+		local wait = window * (1 - (limit - 1) / cur)
+		retry = window - elapsed + math.ceil(math.max(wait, 0))
+	end
+	remaining = math.max(math.floor(limit - count), 0)
+	reset = window - elapsed
+	ttl = 2 * window
+
+	redis.call('HSET', KEYS[1], 'at', start, 'cur', cur, 'prev', prev)
+end
+
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, remaining, retry, reset}
+`)
+
+func (s *RedisStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	values, err := takeScript.Run(ctx, s.client,
+		[]string{s.prefix + policy.Name + ":" + key},
+		string(policy.Algorithm), policy.Limit, policy.Window.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit %s: %w", policy.Name, err)
+	}
+	if len(values) != 4 {
+		return Result{}, fmt.Errorf("rate limit %s: unexpected reply %v", policy.Name, values)
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		Reset:      time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,256 @@
+// Package samlauth is a SAML 2.0 service provider that signs users in with
+// an enterprise identity provider. Requests are sent with the redirect or
+// POST binding and responses are received at the assertion consumer
+// service with the POST binding.
+package samlauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// Attributes the profile is read from unless Config names others. They
+// are matched against both the name and friendly name of attributes.
+const (
+	DefaultEmailAttribute  = "urn:oid:0.9.2342.19200300.100.1.3" // mail
+	DefaultNameAttribute   = "urn:oid:2.16.840.1.113730.3.1.241" // displayName
+	DefaultLocaleAttribute = "urn:oid:2.16.840.1.113730.3.1.39"  // preferredLanguage
+)
+
+// Bindings an authentication request can be sent with.
+const (
+	BindingRedirect = "redirect"
+	BindingPOST     = "post"
+)
+
+// Config describes one identity provider and how this service is known
+// to it.
+type Config struct {
+	Name string
+	// BaseURL is the public URL of this service. The metadata and
+	// assertion consumer service are at /auth/saml/<Name>/metadata and
+	// /auth/saml/<Name>/acs under it.
+	BaseURL string
+	// IDPMetadata is the identity provider's metadata XML.
+	IDPMetadata []byte
+	// Key and Certificate sign requests and decrypt assertions.
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+	// Binding is BindingRedirect or BindingPOST. The other is used if the
+	// identity provider doesn't support it.
+	Binding string
+
+	EmailAttribute  string
+	NameAttribute   string
+	LocaleAttribute string
+}
+
+// Profile is who the identity provider asserts the user is.
+type Profile struct {
+	Subject string // persistent name ID
+	Email   string
+	Name    string
+	Locale  string
+}
+
+// AuthnRequest is an authentication request ready to be sent to the
+// identity provider: the browser is either redirected to RedirectURL or
+// shown Form, which posts itself.
+type AuthnRequest struct {
+	ID          string
+	RedirectURL string
+	Form        []byte
+}
+
+// Provider is the service provider for one identity provider.
+type Provider struct {
+	cfg Config
+	sp  *saml.ServiceProvider
+}
+
+// NewProvider checks cfg and parses the identity provider's metadata.
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.Key == nil || cfg.Certificate == nil {
+		return nil, errors.New("samlauth: missing key or certificate")
+	}
+	var idpMetadata saml.EntityDescriptor
+	if err := xml.Unmarshal(cfg.IDPMetadata, &idpMetadata); err != nil {
+		return nil, fmt.Errorf("samlauth: parse IdP metadata: %w", err)
+	}
+	if len(idpMetadata.IDPSSODescriptors) == 0 {
+		return nil, errors.New("samlauth: IdP metadata has no IDPSSODescriptor")
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/") + "/auth/saml/" + url.PathEscape(cfg.Name))
+	if err != nil {
+		return nil, fmt.Errorf("samlauth: parse base URL: %w", err)
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = DefaultEmailAttribute
+	}
+	if cfg.NameAttribute == "" {
+		cfg.NameAttribute = DefaultNameAttribute
+	}
+	if cfg.LocaleAttribute == "" {
+		cfg.LocaleAttribute = DefaultLocaleAttribute
+	}
+
+	return &Provider{cfg: cfg, sp: &saml.ServiceProvider{
+		Key:               cfg.Key,
+		Certificate:       cfg.Certificate,
+		MetadataURL:       *base.JoinPath("metadata"),
+		AcsURL:            *base.JoinPath("acs"),
+		IDPMetadata:       &idpMetadata,
+		AuthnNameIDFormat: saml.PersistentNameIDFormat,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+	}}, nil
+}
+
+// Name
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// Metadata returns the service provider metadata XML to register with the
+// identity provider.
+func (p *Provider) Metadata() ([]byte, error) {
+	return xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+}
+
+// NewAuthnRequest makes a signed authentication request carrying
+// relayState, which comes back with the response.
+func (p *Provider) NewAuthnRequest(relayState string) (*AuthnRequest, error) {
+	binding, bindingURL := saml.HTTPRedirectBinding, p.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if postURL := p.sp.GetSSOBindingLocation(saml.HTTPPostBinding); postURL != "" && (p.cfg.Binding == BindingPOST || bindingURL == "") {
+		binding, bindingURL = saml.HTTPPostBinding, postURL
+	}
+	if bindingURL == "" {
+		return nil, errors.New("samlauth: IdP supports neither the redirect nor the POST binding")
+	}
+
+	req, err := p.sp.MakeAuthenticationRequest(bindingURL, binding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, err
+	}
+	if binding == saml.HTTPPostBinding {
+		return &AuthnRequest{ID: req.ID, Form: req.Post(relayState)}, nil
+	}
+	redirect, err := req.Redirect(relayState, p.sp)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthnRequest{ID: req.ID, RedirectURL: redirect.String()}, nil
+}
+
+// ParseResponse verifies the base64 SAMLResponse posted to the assertion
+// consumer service in answer to the request requestID and returns the
+// profile it asserts.
+func (p *Provider) ParseResponse(samlResponse, requestID string) (*Profile, error) {
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("samlauth: decode response: %w", err)
+	}
+
+	assertion, err := p.sp.ParseXMLResponse(raw, []string{requestID}, p.sp.AcsURL)
+	if err != nil {
+		// the error's message is deliberately vague, the cause is inside
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			return nil, fmt.Errorf("samlauth: %w", invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("samlauth: %w", err)
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, errors.New("samlauth: assertion has no name ID")
+	}
+
+	profile := &Profile{
+		Subject: assertion.Subject.NameID.Value,
+		Email:   attribute(assertion, p.cfg.EmailAttribute),
+		Name:    attribute(assertion, p.cfg.NameAttribute),
+		Locale:  attribute(assertion, p.cfg.LocaleAttribute),
+	}
+	// some identity providers only send the email as the name ID
+	if profile.Email == "" && assertion.Subject.NameID.Format == string(saml.EmailAddressNameIDFormat) {
+		profile.Email = assertion.Subject.NameID.Value
+	}
+	return profile, nil
+}
+
+// attribute returns the first value of the attribute with name as its name
+// or friendly name.
+func attribute(assertion *saml.Assertion, name string) string {
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if (attr.Name == name || attr.FriendlyName == name) && len(attr.Values) > 0 {
+				return strings.TrimSpace(attr.Values[0].Value)
+			}
+		}
+	}
+	return ""
+}
+
+// LoadKeyPair reads a PEM encoded RSA key and certificate. If both paths
+// are empty it generates a key and self-signed certificate, which last
+// until the process exits.
+func LoadKeyPair(keyFile, certFile string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	if keyFile == "" && certFile == "" {
+		return generateKeyPair()
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: not an RSA key", keyFile)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+func generateKeyPair() (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "usermanagement SAML"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
@@ -0,0 +1,93 @@
+package samlauth_test
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth"
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth/samltest"
+)
+
+func newProvider(t *testing.T, idp *samltest.IdP, binding string) *samlauth.Provider {
+	t.Helper()
+
+	key, cert, err := samlauth.LoadKeyPair("", "")
+	if err != nil {
+		t.Fatalf("LoadKeyPair: %v", err)
+	}
+	provider, err := samlauth.NewProvider(samlauth.Config{
+		Name:        "corp",
+		BaseURL:     "https://api.example.com",
+		IDPMetadata: idp.Metadata(t),
+		Key:         key,
+		Certificate: cert,
+		Binding:     binding,
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	metadata, err := provider.Metadata()
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	idp.RegisterSP(t, metadata)
+	return provider
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := samltest.NewIdP(t)
+	idp.SignIn(samltest.User{NameID: "u-1", Email: "alice@corp.example", Name: "Alice", Locale: "th"})
+
+	for _, binding := range []string{samlauth.BindingRedirect, samlauth.BindingPOST} {
+		t.Run(binding, func(t *testing.T) {
+			provider := newProvider(t, idp, binding)
+
+			req, err := provider.NewAuthnRequest("relay-1")
+			if err != nil {
+				t.Fatalf("NewAuthnRequest: %v", err)
+			}
+			if (binding == samlauth.BindingPOST) != (req.Form != nil) {
+				t.Fatalf("request = %+v, want the %s binding", req, binding)
+			}
+
+			acs, form := samltest.Send(t, req.RedirectURL, req.Form)
+			if acs != "https://api.example.com/auth/saml/corp/acs" || form.Get("RelayState") != "relay-1" {
+				t.Fatalf("response posts to %s with %v", acs, form)
+			}
+
+			profile, err := provider.ParseResponse(form.Get("SAMLResponse"), req.ID)
+			if err != nil {
+				t.Fatalf("ParseResponse: %v", err)
+			}
+			want := samlauth.Profile{Subject: "u-1", Email: "alice@corp.example", Name: "Alice", Locale: "th"}
+			if *profile != want {
+				t.Errorf("profile = %+v, want %+v", *profile, want)
+			}
+
+			if _, err := provider.ParseResponse(form.Get("SAMLResponse"), "id-other"); err == nil {
+				t.Error("ParseResponse accepted a response to another request")
+			}
+		})
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		provider := newProvider(t, idp, samlauth.BindingRedirect)
+		req, err := provider.NewAuthnRequest("relay-1")
+		if err != nil {
+			t.Fatalf("NewAuthnRequest: %v", err)
+		}
+		_, form := samltest.Send(t, req.RedirectURL, nil)
+
+		raw, _ := base64.StdEncoding.DecodeString(form.Get("SAMLResponse"))
+		// a second earlier is still valid, but not what the IdP signed
+		issueInstant := regexp.MustCompile(`IssueInstant="[^"]*"`)
+		earlier := time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+		tampered := strings.Replace(string(raw), issueInstant.FindString(string(raw)), `IssueInstant="`+earlier+`"`, 1)
+		if _, err := provider.ParseResponse(base64.StdEncoding.EncodeToString([]byte(tampered)), req.ID); err == nil {
+			t.Error("ParseResponse accepted a tampered response")
+		}
+	})
+}
@@ -0,0 +1,198 @@
+// Package samltest provides a mock SAML identity provider for tests.
+package samltest
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/logger"
+
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth"
+)
+
+// User is who signs in at the mock identity provider.
+type User struct {
+	NameID string
+	Email  string
+	Name   string
+	Locale string
+}
+
+// IdP is a mock identity provider with a locally generated key. Its single
+// sign-on endpoint signs in the user set with SignIn at once and answers
+// with the form that posts the response to the service provider.
+type IdP struct {
+	URL string
+
+	idp *saml.IdentityProvider
+
+	mu   sync.Mutex
+	user User
+	sps  map[string]*saml.EntityDescriptor
+}
+
+// NewIdP starts an identity provider, which is stopped when the test ends.
+func NewIdP(t testing.TB) *IdP {
+	t.Helper()
+
+	key, cert, err := samlauth.LoadKeyPair("", "")
+	if err != nil {
+		t.Fatalf("generate IdP key: %v", err)
+	}
+
+	i := &IdP{sps: make(map[string]*saml.EntityDescriptor)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			i.idp.ServeMetadata(w, r)
+		case "/sso":
+			i.idp.ServeSSO(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	i.URL = server.URL
+
+	base, _ := url.Parse(server.URL)
+	i.idp = &saml.IdentityProvider{
+		Key:                     key,
+		Certificate:             cert,
+		Logger:                  logger.DefaultLogger,
+		MetadataURL:             *base.JoinPath("metadata"),
+		SSOURL:                  *base.JoinPath("sso"),
+		ServiceProviderProvider: i,
+		SessionProvider:         i,
+	}
+	return i
+}
+
+// Metadata returns the identity provider's metadata XML.
+func (i *IdP) Metadata(t testing.TB) []byte {
+	t.Helper()
+
+	data, err := xml.Marshal(i.idp.Metadata())
+	if err != nil {
+		t.Fatalf("marshal IdP metadata: %v", err)
+	}
+	return data
+}
+
+// RegisterSP trusts the service provider with metadata.
+func (i *IdP) RegisterSP(t testing.TB, metadata []byte) {
+	t.Helper()
+
+	var sp saml.EntityDescriptor
+	if err := xml.Unmarshal(metadata, &sp); err != nil {
+		t.Fatalf("parse SP metadata: %v", err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.sps[sp.EntityID] = &sp
+}
+
+// SignIn sets the user signed in by the next authentication requests.
+func (i *IdP) SignIn(user User) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.user = user
+}
+
+// GetServiceProvider implements saml.ServiceProviderProvider.
+func (i *IdP) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if sp, ok := i.sps[serviceProviderID]; ok {
+		return sp, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// GetSession implements saml.SessionProvider.
+func (i *IdP) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	session := &saml.Session{
+		ID:           "session-" + i.user.NameID,
+		CreateTime:   time.Now(),
+		ExpireTime:   time.Now().Add(time.Hour),
+		Index:        "1",
+		NameID:       i.user.NameID,
+		NameIDFormat: string(saml.PersistentNameIDFormat),
+		UserEmail:    i.user.Email,
+	}
+	if i.user.Name != "" {
+		session.CustomAttributes = append(session.CustomAttributes, stringAttribute("displayName", samlauth.DefaultNameAttribute, i.user.Name))
+	}
+	if i.user.Locale != "" {
+		session.CustomAttributes = append(session.CustomAttributes, stringAttribute("preferredLanguage", samlauth.DefaultLocaleAttribute, i.user.Locale))
+	}
+	return session
+}
+
+// Send delivers an authentication request to the identity provider the way
+// a browser would, following redirectURL or submitting the HTML form, and
+// returns where the response form posts to and its fields.
+func Send(t testing.TB, redirectURL string, form []byte) (string, url.Values) {
+	t.Helper()
+
+	var resp *http.Response
+	var err error
+	if redirectURL != "" {
+		resp, err = http.Get(redirectURL)
+	} else {
+		action, values := parseForm(t, form)
+		resp, err = http.PostForm(action, values)
+	}
+	if err != nil {
+		t.Fatalf("send authentication request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	page, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("send authentication request: status %d, body %s", resp.StatusCode, page)
+	}
+	return parseForm(t, page)
+}
+
+var (
+	formAction = regexp.MustCompile(`<form[^>]* action="([^"]*)"`)
+	formInput  = regexp.MustCompile(`<input type="hidden" name="([^"]*)" value="([^"]*)"`)
+)
+
+// parseForm returns the action and hidden fields of an auto-submitting
+// SAML form.
+func parseForm(t testing.TB, page []byte) (string, url.Values) {
+	t.Helper()
+
+	action := formAction.FindSubmatch(page)
+	if action == nil {
+		t.Fatalf("no form in %s", page)
+	}
+	values := url.Values{}
+	for _, input := range formInput.FindAllSubmatch(page, -1) {
+		values.Set(html.UnescapeString(string(input[1])), html.UnescapeString(string(input[2])))
+	}
+	return html.UnescapeString(string(action[1])), values
+}
+
+func stringAttribute(friendlyName, name, value string) saml.Attribute {
+	return saml.Attribute{
+		FriendlyName: friendlyName,
+		Name:         name,
+		NameFormat:   "urn:oasis:names:tc:SAML:2.0:attrname-format:uri",
+		Values:       []saml.AttributeValue{{Type: "xs:string", Value: value}},
+	}
+}
@@ -0,0 +1,164 @@
+// Package sso signs users in with upstream OpenID Connect providers, such
+// as a company identity provider, with the authorization code flow and
+// PKCE.
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ErrNonceMismatch is returned by Exchange when the ID token wasn't issued
+// for the login the code came from.
+var ErrNonceMismatch = errors.New("sso: ID token nonce mismatch")
+
+// Config is a provider as registered with it. RedirectURL is the page the
+// provider sends the browser back to with the code.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity is who the provider says signed in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an upstream OpenID Connect provider.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewProvider(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns where to send the browser to sign in.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	provider, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems the code the provider sent back for the identity of the
+// user, verifying the ID token was issued to us for this login.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	provider, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("sso: exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("sso: token response has no ID token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("sso: verify ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("sso: decode ID token: %w", err)
+	}
+
+	// some providers only put the profile in userinfo
+	if claims.Email == "" && provider.UserInfoEndpoint() != "" {
+		info, err := provider.UserInfo(oidc.ClientContext(ctx, p.client), oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("sso: get userinfo: %w", err)
+		}
+		if info.Subject != idToken.Subject {
+			return nil, errors.New("sso: userinfo subject mismatch")
+		}
+		var profile struct {
+			Name string `json:"name"`
+		}
+		if err := info.Claims(&profile); err != nil {
+			return nil, fmt.Errorf("sso: decode userinfo: %w", err)
+		}
+		claims.Email, claims.EmailVerified, claims.Name = info.Email, info.EmailVerified, profile.Name
+	}
+
+	return &Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the provider's metadata on first use, so a provider
+// that is down doesn't stop the service from starting. A failure is
+// retried on the next call.
+func (p *Provider) discover() (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return p.provider, nil
+	}
+
+	// the context is kept to fetch signing keys, so it must outlive any
+	// one request
+	provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.client), p.cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("sso: discover %s: %w", p.cfg.Issuer, err)
+	}
+	p.provider = provider
+	return provider, nil
+}
+
+func (p *Provider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+}
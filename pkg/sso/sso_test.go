@@ -0,0 +1,71 @@
+package sso_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/natchaphonbw/usermanagement/pkg/sso"
+	"github.com/natchaphonbw/usermanagement/pkg/sso/ssotest"
+)
+
+// signIn starts a login and follows the provider back, returning the code.
+func signIn(t *testing.T, provider *sso.Provider, state, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", authURL, err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Query().Get("state") != state {
+		t.Fatalf("authorize redirected to %q", resp.Header.Get("Location"))
+	}
+	return location.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	issuer := ssotest.NewIssuer(t, "client-1", "secret-1")
+	issuer.SignIn(ssotest.User{Subject: "u-1", Email: "bob@corp.example", EmailVerified: true, Name: "Bob"})
+
+	provider := sso.NewProvider(sso.Config{
+		Name:         "corp",
+		Issuer:       issuer.URL,
+		ClientID:     "client-1",
+		ClientSecret: "secret-1",
+		RedirectURL:  "https://app.example.com/sso/callback",
+	})
+	verifier := oauth2.GenerateVerifier()
+
+	code := signIn(t, provider, "state-1", "nonce-1", verifier)
+	identity, err := provider.Exchange(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := sso.Identity{Subject: "u-1", Email: "bob@corp.example", EmailVerified: true, Name: "Bob"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	code = signIn(t, provider, "state-2", "nonce-2", verifier)
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce-other"); !errors.Is(err, sso.ErrNonceMismatch) {
+		t.Errorf("Exchange with another nonce: err = %v, want ErrNonceMismatch", err)
+	}
+
+	code = signIn(t, provider, "state-3", "nonce-3", verifier)
+	if _, err := provider.Exchange(context.Background(), code, oauth2.GenerateVerifier(), "nonce-3"); err == nil {
+		t.Error("Exchange with another verifier succeeded")
+	}
+}
@@ -0,0 +1,13 @@
+package utils
+
+import "strings"
+
+// NormalizeEmail returns the canonical form of an email address used as the
+// login identity: surrounding whitespace is trimmed and the whole address
+// is lower-cased. RFC 5321 lets the local part be case-sensitive, but no
+// mainstream provider treats it so, and the unique lower(email) index on
+// users already holds addresses differing only in case to be the same.
+// Dots and +tags are kept, they are significant at most providers.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
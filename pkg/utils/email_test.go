@@ -0,0 +1,20 @@
+package utils
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := map[string]string{
+		"Alice@Example.COM":          "alice@example.com",
+		"  bob@example.com\t\n":      "bob@example.com",
+		"First.Last+Tag@Example.com": "first.last+tag@example.com",
+		`"Odd@Local"@Example.com`:    `"odd@local"@example.com`,
+		"NoAtSign":                   "noatsign",
+		"":                           "",
+	}
+
+	for in, want := range tests {
+		if got := NormalizeEmail(in); got != want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
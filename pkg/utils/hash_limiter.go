@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// HashLimiter bounds how many password hashes are computed at once. Each
+// argon2id hash takes DefaultArgon2Config.Memory of memory, so unbounded
+// concurrent logins could exhaust the server; callers beyond the limit
+// queue until a slot frees up, their ctx ends or the queue timeout passes.
+type HashLimiter struct {
+	slots      chan struct{}
+	maxWaiting int64
+	timeout    time.Duration
+
+	waiting  atomic.Int64
+	rejected atomic.Uint64
+}
+
+// PasswordHashLimiter is used by GeneratePasswordHash and VerifyPassword,
+// nil disables limiting. Set it once during start up.
+var PasswordHashLimiter *HashLimiter
+
+var ErrHashingBusy = errors.New("password hashing is saturated")
+
+// NewHashLimiter allows concurrency hashes at once with at most maxWaiting
+// callers queued, each for up to timeout. maxWaiting < 0 means no limit and
+// timeout 0 waits as long as ctx allows.
+func NewHashLimiter(concurrency, maxWaiting int, timeout time.Duration) *HashLimiter {
+	return &HashLimiter{
+		slots:      make(chan struct{}, max(concurrency, 1)),
+		maxWaiting: int64(maxWaiting),
+		timeout:    timeout,
+	}
+}
+
+// HashLimiterStats is a snapshot of a HashLimiter.
+type HashLimiterStats struct {
+	Capacity int
+	InFlight int
+	Waiting  int
+	Rejected uint64
+}
+
+// Stats reports the limiter's current state, zero for a nil limiter.
+func (l *HashLimiter) Stats() HashLimiterStats {
+	if l == nil {
+		return HashLimiterStats{}
+	}
+	return HashLimiterStats{
+		Capacity: cap(l.slots),
+		InFlight: len(l.slots),
+		Waiting:  int(l.waiting.Load()),
+		Rejected: l.rejected.Load(),
+	}
+}
+
+// Acquire waits for a slot and returns the function releasing it. The
+// error wraps ErrHashingBusy when the limiter turned the caller away, or is
+// ctx.Err() if ctx ended first, which is not counted as a rejection.
+func (l *HashLimiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-l.slots }
+
+	// fast path, don't count callers that never wait
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if waiting := l.waiting.Add(1); l.maxWaiting >= 0 && waiting > l.maxWaiting {
+		l.waiting.Add(-1)
+		l.rejected.Add(1)
+		return nil, fmt.Errorf("%w: queue is full", ErrHashingBusy)
+	}
+	defer l.waiting.Add(-1)
+
+	var timeout <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		l.rejected.Add(1)
+		return nil, fmt.Errorf("%w: timed out after %s", ErrHashingBusy, l.timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
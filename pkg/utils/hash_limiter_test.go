@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHashLimiter(t *testing.T) {
+	l := NewHashLimiter(1, 1, 50*time.Millisecond)
+	ctx := context.Background()
+
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if stats := l.Stats(); stats.Capacity != 1 || stats.InFlight != 1 {
+		t.Errorf("stats = %+v, want 1 of 1 in flight", stats)
+	}
+
+	// one caller may queue, the next is turned away at once
+	queued := make(chan error, 1)
+	go func() {
+		release, err := l.Acquire(ctx)
+		if err == nil {
+			release()
+		}
+		queued <- err
+	}()
+	waitFor(t, func() bool { return l.Stats().Waiting == 1 })
+
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrHashingBusy) {
+		t.Errorf("acquire with a full queue = %v, want ErrHashingBusy", err)
+	}
+
+	release()
+	if err := <-queued; err != nil {
+		t.Errorf("queued acquire: %v", err)
+	}
+
+	// a waiter gives up after the queue timeout or when ctx ends
+	release, _ = l.Acquire(ctx)
+	defer release()
+
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrHashingBusy) {
+		t.Errorf("acquire after timeout = %v, want ErrHashingBusy", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := l.Acquire(cancelled); !errors.Is(err, context.Canceled) || errors.Is(err, ErrHashingBusy) {
+		t.Errorf("acquire with cancelled ctx = %v, want context.Canceled only", err)
+	}
+
+	// the caller giving up is not the limiter turning it away
+	if stats := l.Stats(); stats.Waiting != 0 || stats.Rejected != 2 {
+		t.Errorf("stats = %+v, want none waiting and 2 rejected", stats)
+	}
+}
+
+func TestPasswordHashingUsesLimiter(t *testing.T) {
+	saved := PasswordHashLimiter
+	PasswordHashLimiter = NewHashLimiter(1, 0, 0)
+	t.Cleanup(func() { PasswordHashLimiter = saved })
+
+	ctx := context.Background()
+	hash, err := GeneratePasswordHash(ctx, "Correct-Orbit-42", &testArgon2Config)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+
+	release, err := PasswordHashLimiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	if _, err := GeneratePasswordHash(ctx, "Correct-Orbit-42", &testArgon2Config); !errors.Is(err, ErrHashingBusy) {
+		t.Errorf("GeneratePasswordHash while saturated = %v, want ErrHashingBusy", err)
+	}
+	if _, err := VerifyPassword(ctx, "Correct-Orbit-42", hash); !errors.Is(err, ErrHashingBusy) {
+		t.Errorf("VerifyPassword while saturated = %v, want ErrHashingBusy", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
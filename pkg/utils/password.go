@@ -1,10 +1,14 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -25,39 +29,201 @@ var DefaultArgon2Config = Argon2Config{
 	SaltLength: 16,
 }
 
-func GeneratePasswordHash(password string, config *Argon2Config) (hashBase64 string, saltBase64 string, err error) {
+// maxArgon2Memory caps the memory a stored hash may ask for (in KiB),
+// so a tampered or imported hash can't exhaust the server.
+const maxArgon2Memory = 1024 * 1024
+
+// maxArgon2Time and maxArgon2Threads cap the passes and lanes a stored
+// hash may ask for, for the same reason.
+const (
+	maxArgon2Time    = 64
+	maxArgon2Threads = 16
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// GeneratePasswordHash hashes password with argon2id and returns it in PHC
+// string format, $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>, so the
+// parameters are stored with the hash. With PasswordPeppers set, the
+// password is peppered first and the pepper's id is stored as keyid.
+// It waits for a PasswordHashLimiter slot, see HashLimiter.Acquire.
+func GeneratePasswordHash(ctx context.Context, password string, config *Argon2Config) (string, error) {
 	salt := make([]byte, config.SaltLength)
-	_, err = rand.Read(salt)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	keyID := PasswordPeppers.currentID()
+	input, err := PasswordPeppers.apply(keyID, password)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate salt: %w", err)
+		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(password), salt, config.Time, config.Memory, config.Threads, config.KeyLength)
+	release, err := PasswordHashLimiter.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey(input, salt, config.Time, config.Memory, config.Threads, config.KeyLength)
+	release()
 
-	hashBase64 = base64.RawStdEncoding.EncodeToString(hash)
-	saltBase64 = base64.RawStdEncoding.EncodeToString(salt)
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", config.Memory, config.Time, config.Threads)
+	if keyID != "" {
+		params += ",keyid=" + base64.RawStdEncoding.EncodeToString([]byte(keyID))
+	}
 
-	return hashBase64, saltBase64, nil
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s",
+		argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
 }
 
-func VerifyPassword(password, encodedHash, encodedSalt string, config *Argon2Config) (bool, error) {
-	salt, err := base64.RawStdEncoding.DecodeString(encodedSalt)
+// VerifyPassword checks password against an encoded hash, either one from
+// GeneratePasswordHash or a legacy format from a registered PasswordHasher,
+// using the parameters stored in the hash. Like GeneratePasswordHash it
+// waits for a PasswordHashLimiter slot.
+func VerifyPassword(ctx context.Context, password, encodedHash string) (bool, error) {
+	hasher := findPasswordHasher(encodedHash)
+	if hasher == nil {
+		return false, fmt.Errorf("%w: unknown format", ErrInvalidHash)
+	}
+
+	release, err := PasswordHashLimiter.Acquire(ctx)
 	if err != nil {
-		return false, fmt.Errorf("failed to decode salt: %w", err)
+		return false, err
 	}
+	defer release()
+
+	return hasher.Verify(password, encodedHash)
+}
 
-	expectedHash, err := base64.RawStdEncoding.DecodeString(encodedHash)
+// argon2Hasher verifies the hashes made by GeneratePasswordHash.
+type argon2Hasher struct{}
+
+func (argon2Hasher) Match(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func (argon2Hasher) Validate(encodedHash string) error {
+	stored, err := decodeArgon2Hash(encodedHash)
 	if err != nil {
-		return false, fmt.Errorf("failed to decode hash: %w", err)
+		return err
 	}
+	_, err = PasswordPeppers.apply(stored.keyID, "")
+	return err
+}
 
-	newHash := argon2.IDKey([]byte(password), salt, config.Time, config.Memory, config.Threads, config.KeyLength)
+func (argon2Hasher) Verify(password, encodedHash string) (bool, error) {
+	stored, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return false, err
+	}
 
-	if len(newHash) != len(expectedHash) {
-		return false, nil
+	input, err := PasswordPeppers.apply(stored.keyID, password)
+	if err != nil {
+		return false, err
 	}
-	
-	match := subtle.ConstantTimeCompare(newHash, expectedHash) == 1
 
-	return match, nil
+	config := stored.config
+	newHash := argon2.IDKey(input, stored.salt, config.Time, config.Memory, config.Threads, config.KeyLength)
+
+	return subtle.ConstantTimeCompare(newHash, stored.hash) == 1, nil
+}
+
+// PasswordNeedsRehash reports whether encodedHash is in a legacy format,
+// was made with other parameters than config or with another pepper than
+// the current one, and should be replaced on the next login.
+func PasswordNeedsRehash(encodedHash string, config *Argon2Config) bool {
+	stored, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return stored.config != *config || stored.keyID != PasswordPeppers.currentID()
+}
+
+type argon2Hash struct {
+	config     Argon2Config
+	keyID      string
+	salt, hash []byte
+}
+
+func decodeArgon2Hash(encodedHash string) (*argon2Hash, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...[,keyid=...]", salt, hash
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("%w: not an argon2id PHC string", ErrInvalidHash)
+	}
+
+	if parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrInvalidHash, parts[2])
+	}
+
+	var stored argon2Hash
+	if err := stored.decodeParams(parts[3]); err != nil {
+		return nil, fmt.Errorf("%w: parameters %q: %v", ErrInvalidHash, parts[3], err)
+	}
+
+	var err error
+	if stored.salt, err = base64.RawStdEncoding.Strict().DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("%w: salt: %v", ErrInvalidHash, err)
+	}
+	if stored.hash, err = base64.RawStdEncoding.Strict().DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("%w: hash: %v", ErrInvalidHash, err)
+	}
+	if len(stored.salt) == 0 || len(stored.hash) == 0 {
+		return nil, fmt.Errorf("%w: empty salt or hash", ErrInvalidHash)
+	}
+
+	stored.config.SaltLength = uint32(len(stored.salt))
+	stored.config.KeyLength = uint32(len(stored.hash))
+	return &stored, nil
+}
+
+func (h *argon2Hash) decodeParams(params string) error {
+	seen := make(map[string]bool)
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(param, "=")
+		if seen[name] {
+			return fmt.Errorf("duplicate %s", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "m":
+			h.config.Memory, err = parseUint32(value)
+		case "t":
+			h.config.Time, err = parseUint32(value)
+		case "p":
+			var threads uint64
+			threads, err = strconv.ParseUint(value, 10, 8)
+			h.config.Threads = uint8(threads)
+		case "keyid":
+			var id []byte
+			id, err = base64.RawStdEncoding.Strict().DecodeString(value)
+			h.keyID = string(id)
+		default:
+			return fmt.Errorf("unknown parameter %q", name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	config := h.config
+	if !seen["m"] || !seen["t"] || !seen["p"] {
+		return errors.New("m, t and p are required")
+	}
+	if config.Time == 0 || config.Time > maxArgon2Time ||
+		config.Threads == 0 || config.Threads > maxArgon2Threads ||
+		config.Memory < 8*uint32(config.Threads) || config.Memory > maxArgon2Memory {
+		return errors.New("out of range")
+	}
+	return nil
+}
+
+func parseUint32(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err
 }
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordHasher verifies one family of encoded password hashes, so users
+// imported from other systems can log in and be moved to argon2id.
+type PasswordHasher interface {
+	// Match reports whether encodedHash looks like this hasher's format.
+	Match(encodedHash string) bool
+	// Validate parses encodedHash without hashing anything.
+	Validate(encodedHash string) error
+	Verify(password, encodedHash string) (bool, error)
+}
+
+var passwordHashers = []PasswordHasher{
+	argon2Hasher{},
+	bcryptHasher{},
+	scryptHasher{},
+	pbkdf2Hasher{},
+}
+
+// RegisterPasswordHasher adds support for another hash format. It is not
+// safe for concurrent use and should be called during start up.
+func RegisterPasswordHasher(h PasswordHasher) {
+	passwordHashers = append(passwordHashers, h)
+}
+
+func findPasswordHasher(encodedHash string) PasswordHasher {
+	for _, h := range passwordHashers {
+		if h.Match(encodedHash) {
+			return h
+		}
+	}
+	return nil
+}
+
+// ValidatePasswordHash checks that encodedHash is in a supported format
+// with sane parameters, e.g. before importing it.
+func ValidatePasswordHash(encodedHash string) error {
+	hasher := findPasswordHasher(encodedHash)
+	if hasher == nil {
+		return fmt.Errorf("%w: unknown format", ErrInvalidHash)
+	}
+	return hasher.Validate(encodedHash)
+}
+
+// bcryptHasher verifies $2a$, $2b$ and $2y$ hashes.
+type bcryptHasher struct{}
+
+// bcryptMaxPassword is where bcrypt implementations truncate passwords.
+const bcryptMaxPassword = 72
+
+func (bcryptHasher) Match(encodedHash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encodedHash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (bcryptHasher) Validate(encodedHash string) error {
+	if _, err := bcrypt.Cost([]byte(encodedHash)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return nil
+}
+
+func (bcryptHasher) Verify(password, encodedHash string) (bool, error) {
+	// the systems we import from silently truncated long passwords
+	if len(password) > bcryptMaxPassword {
+		password = password[:bcryptMaxPassword]
+	}
+
+	// $2y$ is PHP's name for the same algorithm
+	if strings.HasPrefix(encodedHash, "$2y$") {
+		encodedHash = "$2b$" + encodedHash[len("$2y$"):]
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return true, nil
+}
+
+// scryptHasher verifies PHC-style $scrypt$ln=15,r=8,p=1$<salt>$<hash>
+// hashes with unpadded standard base64, as written by passlib.
+type scryptHasher struct{}
+
+// scryptMaxMemory caps 128*N*r, the memory a stored hash may ask for.
+const scryptMaxMemory = 1 << 30
+
+type scryptParams struct {
+	logN, r, p int
+	salt, hash []byte
+}
+
+func (scryptHasher) Match(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$scrypt$")
+}
+
+func (scryptHasher) Validate(encodedHash string) error {
+	_, err := decodeScryptHash(encodedHash)
+	return err
+}
+
+func (scryptHasher) Verify(password, encodedHash string) (bool, error) {
+	params, err := decodeScryptHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	newHash, err := scrypt.Key([]byte(password), params.salt, 1<<params.logN, params.r, params.p, len(params.hash))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return subtle.ConstantTimeCompare(newHash, params.hash) == 1, nil
+}
+
+func decodeScryptHash(encodedHash string) (*scryptParams, error) {
+	// "", "scrypt", "ln=..,r=..,p=..", salt, hash
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return nil, fmt.Errorf("%w: not a scrypt PHC string", ErrInvalidHash)
+	}
+
+	var params scryptParams
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.logN, &params.r, &params.p); err != nil {
+		return nil, fmt.Errorf("%w: parameters %q: %v", ErrInvalidHash, parts[2], err)
+	}
+	if params.logN < 1 || params.logN > 24 || params.r < 1 || params.p < 1 || params.p > 16 ||
+		128*(1<<params.logN)*params.r > scryptMaxMemory {
+		return nil, fmt.Errorf("%w: parameters %q out of range", ErrInvalidHash, parts[2])
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(params.salt) == 0 {
+		return nil, fmt.Errorf("%w: bad salt", ErrInvalidHash)
+	}
+	if params.hash, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil || len(params.hash) == 0 {
+		return nil, fmt.Errorf("%w: bad hash", ErrInvalidHash)
+	}
+	return &params, nil
+}
+
+// pbkdf2Hasher verifies the two common PBKDF2 formats:
+//
+//	$pbkdf2-sha256$29000$<salt>$<hash>   passlib, "adapted" base64 (. for +)
+//	pbkdf2_sha256$260000$<salt>$<hash>   Django, raw salt and padded base64
+//
+// with sha1, sha256 or sha512 (passlib's plain $pbkdf2$ is sha1).
+type pbkdf2Hasher struct{}
+
+// pbkdf2MaxIterations stops a stored hash from pinning a CPU for minutes.
+const pbkdf2MaxIterations = 10_000_000
+
+var adaptedBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding)
+
+type pbkdf2Params struct {
+	digest     func() hash.Hash
+	iterations int
+	salt, hash []byte
+}
+
+func (pbkdf2Hasher) Match(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$pbkdf2") || strings.HasPrefix(encodedHash, "pbkdf2_")
+}
+
+func (pbkdf2Hasher) Validate(encodedHash string) error {
+	_, err := decodePBKDF2Hash(encodedHash)
+	return err
+}
+
+func (pbkdf2Hasher) Verify(password, encodedHash string) (bool, error) {
+	params, err := decodePBKDF2Hash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	newHash, err := pbkdf2.Key(params.digest, password, params.salt, params.iterations, len(params.hash))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return subtle.ConstantTimeCompare(newHash, params.hash) == 1, nil
+}
+
+func decodePBKDF2Hash(encodedHash string) (*pbkdf2Params, error) {
+	parts := strings.Split(encodedHash, "$")
+
+	var (
+		params                 pbkdf2Params
+		algorithm              string
+		iterations             string
+		err                    error
+		decodeSalt, decodeHash func(string) ([]byte, error)
+	)
+	switch {
+	case len(parts) == 5 && parts[0] == "":
+		// passlib
+		algorithm, iterations = strings.TrimPrefix(parts[1], "pbkdf2"), parts[2]
+		algorithm = strings.TrimPrefix(algorithm, "-")
+		if algorithm == "" {
+			algorithm = "sha1"
+		}
+		decodeSalt, decodeHash = adaptedBase64.DecodeString, adaptedBase64.DecodeString
+		parts = parts[3:]
+	case len(parts) == 4:
+		// Django
+		algorithm, iterations = strings.TrimPrefix(parts[0], "pbkdf2_"), parts[1]
+		decodeSalt = func(s string) ([]byte, error) { return []byte(s), nil }
+		decodeHash = base64.StdEncoding.DecodeString
+		parts = parts[2:]
+	default:
+		return nil, fmt.Errorf("%w: not a PBKDF2 hash", ErrInvalidHash)
+	}
+
+	switch algorithm {
+	case "sha1":
+		params.digest = sha1.New
+	case "sha256":
+		params.digest = sha256.New
+	case "sha512":
+		params.digest = sha512.New
+	default:
+		return nil, fmt.Errorf("%w: unsupported PBKDF2 digest %q", ErrInvalidHash, algorithm)
+	}
+
+	if params.iterations, err = strconv.Atoi(iterations); err != nil || params.iterations < 1 || params.iterations > pbkdf2MaxIterations {
+		return nil, fmt.Errorf("%w: iterations %q out of range", ErrInvalidHash, iterations)
+	}
+	if params.salt, err = decodeSalt(parts[0]); err != nil || len(params.salt) == 0 {
+		return nil, fmt.Errorf("%w: bad salt", ErrInvalidHash)
+	}
+	if params.hash, err = decodeHash(parts[1]); err != nil || len(params.hash) == 0 {
+		return nil, fmt.Errorf("%w: bad hash", ErrInvalidHash)
+	}
+	return &params, nil
+}
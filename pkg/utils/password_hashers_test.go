@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLegacyPasswordHashes(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("Correct-Orbit-42"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	phpHash := "$2y$" + strings.TrimPrefix(string(bcryptHash), "$2a$")
+
+	// made with Python's hashlib
+	tests := map[string]string{
+		"bcrypt":        string(bcryptHash),
+		"bcrypt php":    phpHash,
+		"scrypt":        "$scrypt$ln=10,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$CYrVcWaoYpe3nWHKq+pfoHQzLhhBqdphYzzYmEPpMwc",
+		"pbkdf2 django": "pbkdf2_sha256$1000$seasalt2024$2hbxnK/8Tuey3RX6zyz5Jh0+6lq2xrmBz1ClO1X6+PQ=",
+		"pbkdf2 sha512": "$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZg$6kQnuHW3GwixGMVe.OAhEk2OFuSMHz6Raien22k1jK7wCLMJSlZHdNIhe7sj4QGNvq9Jr/SbUHK.sl2X5Gh3pA",
+		"pbkdf2 sha1":   "$pbkdf2$1000$MDEyMzQ1Njc4OWFiY2RlZg$8Dzvxel9.fYTxeG.rVI8lEXxyCw",
+	}
+
+	for name, hash := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidatePasswordHash(hash); err != nil {
+				t.Fatalf("ValidatePasswordHash: %v", err)
+			}
+			if match, err := VerifyPassword(context.Background(), "Correct-Orbit-42", hash); err != nil || !match {
+				t.Errorf("VerifyPassword(right) = %v, %v", match, err)
+			}
+			if match, err := VerifyPassword(context.Background(), "Correct-Orbit-43", hash); err != nil || match {
+				t.Errorf("VerifyPassword(wrong) = %v, %v", match, err)
+			}
+			if !PasswordNeedsRehash(hash, &DefaultArgon2Config) {
+				t.Error("legacy hash does not need rehash")
+			}
+		})
+	}
+}
+
+func TestValidatePasswordHashRejects(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"5f4dcc3b5aa765d61d8327deb882cf99",
+		"$2a$99$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"$scrypt$ln=30,r=8,p=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=10,r=8,p=1$$aGFzaA",
+		"$pbkdf2-md5$1000$c2FsdA$aGFzaA",
+		"$pbkdf2-sha256$0$c2FsdA$aGFzaA",
+		"$pbkdf2-sha256$99999999$c2FsdA$aGFzaA",
+		"pbkdf2_sha256$1000$salt$not base64!",
+	} {
+		if err := ValidatePasswordHash(hash); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("ValidatePasswordHash(%q) = %v, want ErrInvalidHash", hash, err)
+		}
+	}
+}
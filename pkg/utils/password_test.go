@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var testArgon2Config = Argon2Config{Memory: 1024, Time: 1, Threads: 1, KeyLength: 32, SaltLength: 16}
+
+func TestPasswordHashRoundTrip(t *testing.T) {
+	hash, err := GeneratePasswordHash(context.Background(), "Correct-Orbit-42", &testArgon2Config)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("hash %q is not in PHC format", hash)
+	}
+
+	if match, err := VerifyPassword(context.Background(), "Correct-Orbit-42", hash); err != nil || !match {
+		t.Errorf("VerifyPassword(right) = %v, %v", match, err)
+	}
+	if match, err := VerifyPassword(context.Background(), "Correct-Orbit-43", hash); err != nil || match {
+		t.Errorf("VerifyPassword(wrong) = %v, %v", match, err)
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	hash, err := GeneratePasswordHash(context.Background(), "Correct-Orbit-42", &testArgon2Config)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+
+	if PasswordNeedsRehash(hash, &testArgon2Config) {
+		t.Error("hash with current parameters needs rehash")
+	}
+
+	stronger := testArgon2Config
+	stronger.Time = 2
+	if !PasswordNeedsRehash(hash, &stronger) {
+		t.Error("hash with outdated parameters does not need rehash")
+	}
+
+	if !PasswordNeedsRehash("not a hash", &testArgon2Config) {
+		t.Error("invalid hash does not need rehash")
+	}
+}
+
+func TestVerifyPasswordRejectsInvalidHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plain-base64-hash",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=1024,t=4294967295,p=1$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=4096,t=1,p=255$c2FsdHNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$not*base64$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$$aGFzaA",
+	} {
+		if match, err := VerifyPassword(context.Background(), "Correct-Orbit-42", hash); err == nil || match {
+			t.Errorf("VerifyPassword(%q) = %v, %v, want ErrInvalidHash", hash, match, err)
+		}
+	}
+}
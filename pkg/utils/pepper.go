@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Peppers are server-side secrets mixed into passwords with HMAC-SHA256
+// before argon2id, so a database dump alone is not enough to brute-force
+// them. Every hash records the id of its pepper, which lets a pepper be
+// rotated: hashes made with an older one still verify and are moved to
+// the current one on the next login.
+type Peppers struct {
+	current string
+	keys    map[string][]byte
+}
+
+// PasswordPeppers is used by GeneratePasswordHash and VerifyPassword,
+// nil disables peppering. Set it once during start up.
+var PasswordPeppers *Peppers
+
+// minPepperLength is the shortest accepted secret, in bytes.
+const minPepperLength = 32
+
+var ErrUnknownPepper = errors.New("unknown password pepper")
+
+// NewPeppers uses keys[current] for new hashes and all keys to verify.
+func NewPeppers(current string, keys map[string][]byte) (*Peppers, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current pepper %q not found", current)
+	}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":\n") {
+			return nil, fmt.Errorf("invalid pepper id %q", id)
+		}
+		if len(key) < minPepperLength {
+			return nil, fmt.Errorf("pepper %q is shorter than %d bytes", id, minPepperLength)
+		}
+	}
+	return &Peppers{current: current, keys: keys}, nil
+}
+
+// LoadPeppers reads peppers from secret, stored under currentID, and from
+// keyFile, which holds one "id:secret" per line, # starting a comment.
+// Keeping retired peppers in the file lets their hashes verify until they
+// have been migrated. It returns nil if neither secret nor keyFile is set.
+func LoadPeppers(currentID, secret, keyFile string) (*Peppers, error) {
+	if secret == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte)
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			id, key, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("%s line %d: want id:secret", keyFile, i+1)
+			}
+			if _, dup := keys[id]; dup {
+				return nil, fmt.Errorf("%s line %d: duplicate pepper %q", keyFile, i+1, id)
+			}
+			keys[id] = []byte(key)
+		}
+	}
+
+	if secret != "" {
+		if key, ok := keys[currentID]; ok && string(key) != secret {
+			return nil, fmt.Errorf("pepper %q is set differently in config and %s", currentID, keyFile)
+		}
+		keys[currentID] = []byte(secret)
+	}
+
+	return NewPeppers(currentID, keys)
+}
+
+func (p *Peppers) currentID() string {
+	if p == nil {
+		return ""
+	}
+	return p.current
+}
+
+// apply returns the input for argon2id: password itself without a pepper,
+// otherwise its HMAC under pepper id.
+func (p *Peppers) apply(id, password string) ([]byte, error) {
+	if id == "" {
+		return []byte(password), nil
+	}
+
+	var key []byte
+	if p != nil {
+		key = p.keys[id]
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPepper, id)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	pepperOne = "first-pepper-0123456789abcdefghijkl"
+	pepperTwo = "second-pepper-0123456789abcdefghijk"
+)
+
+func usePeppers(t *testing.T, p *Peppers) {
+	t.Helper()
+
+	saved := PasswordPeppers
+	PasswordPeppers = p
+	t.Cleanup(func() { PasswordPeppers = saved })
+}
+
+func mustPeppers(t *testing.T, current string, keys map[string]string) *Peppers {
+	t.Helper()
+
+	byteKeys := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		byteKeys[id] = []byte(key)
+	}
+	p, err := NewPeppers(current, byteKeys)
+	if err != nil {
+		t.Fatalf("NewPeppers: %v", err)
+	}
+	return p
+}
+
+func TestPepperRotation(t *testing.T) {
+	unpeppered, err := GeneratePasswordHash(context.Background(), "Correct-Orbit-42", &testArgon2Config)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+
+	usePeppers(t, mustPeppers(t, "1", map[string]string{"1": pepperOne}))
+	first, err := GeneratePasswordHash(context.Background(), "Correct-Orbit-42", &testArgon2Config)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+	if !strings.Contains(first, ",keyid=MQ$") {
+		t.Errorf("hash %q does not record pepper 1", first)
+	}
+	if !PasswordNeedsRehash(unpeppered, &testArgon2Config) {
+		t.Error("unpeppered hash does not need rehash once a pepper is set")
+	}
+
+	// rotate to pepper 2, keeping 1 to verify old hashes
+	usePeppers(t, mustPeppers(t, "2", map[string]string{"1": pepperOne, "2": pepperTwo}))
+	for name, hash := range map[string]string{"unpeppered": unpeppered, "pepper 1": first} {
+		if match, err := VerifyPassword(context.Background(), "Correct-Orbit-42", hash); err != nil || !match {
+			t.Errorf("VerifyPassword(%s) = %v, %v", name, match, err)
+		}
+		if !PasswordNeedsRehash(hash, &testArgon2Config) {
+			t.Errorf("%s hash does not need rehash", name)
+		}
+	}
+
+	second, err := GeneratePasswordHash(context.Background(), "Correct-Orbit-42", &testArgon2Config)
+	if err != nil {
+		t.Fatalf("GeneratePasswordHash: %v", err)
+	}
+	if PasswordNeedsRehash(second, &testArgon2Config) {
+		t.Error("hash with current pepper needs rehash")
+	}
+
+	// the pepper is part of the hash: the same password under another
+	// pepper, or with none, must not verify
+	usePeppers(t, mustPeppers(t, "2", map[string]string{"2": pepperOne}))
+	if match, _ := VerifyPassword(context.Background(), "Correct-Orbit-42", second); match {
+		t.Error("hash verified with the wrong pepper")
+	}
+
+	usePeppers(t, nil)
+	if _, err := VerifyPassword(context.Background(), "Correct-Orbit-42", first); !errors.Is(err, ErrUnknownPepper) {
+		t.Errorf("VerifyPassword without peppers = %v, want ErrUnknownPepper", err)
+	}
+	if err := ValidatePasswordHash(first); !errors.Is(err, ErrUnknownPepper) {
+		t.Errorf("ValidatePasswordHash without peppers = %v, want ErrUnknownPepper", err)
+	}
+}
+
+func TestLoadPeppers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "peppers")
+	content := "# retired\n1:" + pepperOne + "\n\n2:" + pepperTwo + "\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := LoadPeppers("1", "", ""); p != nil || err != nil {
+		t.Errorf("LoadPeppers without secrets = %v, %v, want nil", p, err)
+	}
+
+	p, err := LoadPeppers("2", "", file)
+	if err != nil {
+		t.Fatalf("LoadPeppers(file): %v", err)
+	}
+	if p.currentID() != "2" || len(p.keys) != 2 {
+		t.Errorf("peppers = %+v", p)
+	}
+
+	p, err = LoadPeppers("3", "third-pepper-0123456789abcdefghijkl", file)
+	if err != nil {
+		t.Fatalf("LoadPeppers(secret and file): %v", err)
+	}
+	if p.currentID() != "3" || len(p.keys) != 3 {
+		t.Errorf("peppers = %+v", p)
+	}
+
+	for name, load := range map[string]func() (*Peppers, error){
+		"too short":       func() (*Peppers, error) { return LoadPeppers("1", "short", "") },
+		"missing current": func() (*Peppers, error) { return LoadPeppers("9", "", file) },
+		"missing file":    func() (*Peppers, error) { return LoadPeppers("1", "", file+".missing") },
+		"conflict":        func() (*Peppers, error) { return LoadPeppers("1", pepperTwo, file) },
+	} {
+		if _, err := load(); err == nil {
+			t.Errorf("%s: LoadPeppers succeeded", name)
+		}
+	}
+}
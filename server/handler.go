@@ -1,41 +1,352 @@
 package server
 
 import (
+	"log"
+	"os"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
+	"github.com/natchaphonbw/usermanagement/config"
+	oauthControllers "github.com/natchaphonbw/usermanagement/modules/oauth/controllers"
+	oauthEntities "github.com/natchaphonbw/usermanagement/modules/oauth/entities"
+	oauthRepositories "github.com/natchaphonbw/usermanagement/modules/oauth/repositories"
+	oauthUsecases "github.com/natchaphonbw/usermanagement/modules/oauth/usecases"
 	"github.com/natchaphonbw/usermanagement/modules/users/controllers"
+	"github.com/natchaphonbw/usermanagement/modules/users/entities"
 	"github.com/natchaphonbw/usermanagement/modules/users/repositories"
 	"github.com/natchaphonbw/usermanagement/modules/users/usecases"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	webhookControllers "github.com/natchaphonbw/usermanagement/modules/webhooks/controllers"
+	webhookRepositories "github.com/natchaphonbw/usermanagement/modules/webhooks/repositories"
+	webhookUsecases "github.com/natchaphonbw/usermanagement/modules/webhooks/usecases"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/ldapauth"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
 	"github.com/natchaphonbw/usermanagement/pkg/middlewares"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey"
+	"github.com/natchaphonbw/usermanagement/pkg/ratelimit"
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth"
+	"github.com/natchaphonbw/usermanagement/pkg/sso"
 )
 
-func SetupRoutes(app *fiber.App, db *gorm.DB) {
+func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, m mailer.Mailer, breaches validator.BreachChecker) {
+
+	txManager := databases.NewTxManager(db)
+
+	userRepo, sessionRepo := newUserRepositories(cfg, db)
+	eventRepo := repositories.NewEventPostgresRepository(db)
+	userUseCase := usecases.NewUserUseCase(userRepo, sessionRepo, eventRepo, txManager, validator.NewPasswordPolicy(cfg, breaches))
+	sessionUseCase := usecases.NewSessionUsecase(sessionRepo, userRepo, txManager)
+	emailChangeRepo := repositories.NewEmailChangePostgresRepository(db)
+	passkeyRepo := repositories.NewPasskeyPostgresRepository(db)
+	relyingParty := newRelyingParty(cfg)
+	authUseCase := usecases.NewAuthUseCase(userUseCase, sessionUseCase, userRepo, sessionRepo, emailChangeRepo, passkeyRepo, eventRepo, txManager, m, relyingParty, newCredentialVerifiers(cfg, userRepo, eventRepo, txManager)...)
+	passwordlessUseCase := usecases.NewPasswordlessUsecase(userRepo, repositories.NewLoginLinkPostgresRepository(db), passkeyRepo, sessionUseCase, relyingParty, m, cfg.MagicLinkURL)
+	accessTokenUseCase := usecases.NewAccessTokenUsecase(repositories.NewAccessTokenPostgresRepository(db), userRepo)
+	identityRepo := repositories.NewIdentityPostgresRepository(db)
+	ssoUseCase := usecases.NewSSOUsecase(newSSOProviders(cfg), identityRepo, userRepo, eventRepo, passkeyRepo, sessionUseCase, relyingParty, txManager)
+	samlUseCase := usecases.NewSAMLUsecase(newSAMLProviders(cfg), repositories.NewSAMLLoginPostgresRepository(db), identityRepo, userRepo, eventRepo, passkeyRepo, sessionUseCase, relyingParty, txManager)
 
-	userRepo := repositories.NewUserPostgresRepository(db)
-	userUseCase := usecases.NewUserUseCase(userRepo)
-	sessionRepo := repositories.NewSessionPostgresRepository(db)
-	sessionUseCase := usecases.NewSessionUsecase(sessionRepo)
-	authUseCase := usecases.NewAuthUseCase(userUseCase, sessionUseCase, userRepo, sessionRepo)
+	subscriptionRepo := webhookRepositories.NewSubscriptionPostgresRepository(db)
+	deliveryRepo := webhookRepositories.NewDeliveryPostgresRepository(db)
+	webhookUseCase := webhookUsecases.NewWebhookUsecase(subscriptionRepo, deliveryRepo)
+
+	provider := newOIDCProvider(cfg)
+	serviceAccountRepo := oauthRepositories.NewServiceAccountPostgresRepository(db)
+	clientRepo := oauthRepositories.NewClientPostgresRepository(db)
+	authorizationRepo := oauthRepositories.NewAuthorizationPostgresRepository(db)
+	serviceAccountUseCase := oauthUsecases.NewServiceAccountUsecase(serviceAccountRepo, txManager)
+	clientUseCase := oauthUsecases.NewClientUsecase(clientRepo)
+	authorizationUseCase := oauthUsecases.NewAuthorizationUsecase(clientRepo, authorizationRepo, userRepo, txManager, provider)
+	tokenUseCase := oauthUsecases.NewTokenUsecase(serviceAccountRepo, clientRepo, authorizationRepo, userRepo, provider)
 
 	userController := controllers.NewUserController(userUseCase)
 	authController := controllers.NewAuthController(authUseCase, sessionUseCase)
+	passwordlessController := controllers.NewPasswordlessController(passwordlessUseCase)
+	passkeyController := controllers.NewPasskeyController(authUseCase)
+	accessTokenController := controllers.NewAccessTokenController(accessTokenUseCase)
+	ssoController := controllers.NewSSOController(ssoUseCase)
+	samlController := controllers.NewSAMLController(samlUseCase)
+	webhookController := webhookControllers.NewWebhookController(webhookUseCase)
+	serviceAccountController := oauthControllers.NewServiceAccountController(serviceAccountUseCase)
+	oauthController := oauthControllers.NewOAuthController(tokenUseCase)
+	clientController := oauthControllers.NewClientController(clientUseCase)
+	oidcController := oauthControllers.NewOIDCController(authorizationUseCase, provider)
+
+	limit := newRateLimiter(cfg)
+	authenticate := middlewares.AuthMiddleware(accessTokenUseCase, sessionUseCase)
 
-	userGroup := app.Group("/users")
+	app.Get("/metrics", middlewares.MetricsTokenMiddleware(cfg.MetricsToken), Metrics)
+
+	userGroup := app.Group("/users", limit("users"))
 	userGroup.Post("/", userController.CreateUser)
 	userGroup.Get("/", userController.GetAllUsers)
 	userGroup.Get("/:id", userController.GetUserByID)
-	userGroup.Put("/:id", userController.UpdateUserByID)
-	userGroup.Delete("/:id", userController.DeleteUserByID)
+	// users update only themselves, and delete their account with
+	// DELETE /auth/me
+	userGroup.Put("/:id", authenticate, middlewares.RequireSession(), userController.UpdateUserByID)
 
 	authPublic := app.Group("/auth")
-	authPublic.Post("/register", authController.Register)
-	authPublic.Post("/login", authController.Login)
+	authPublic.Post("/register", limit("auth.register"), authController.Register)
+	authPublic.Post("/login", limit("auth.login"), authController.Login)
+	authPublic.Post("/email/confirm", authController.ConfirmEmailChange)
+	authPublic.Post("/magic-link", limit("auth.magic_link"), passwordlessController.RequestLogin)
+	authPublic.Post("/magic-link/verify", limit("auth.login"), passwordlessController.VerifyLink)
+	authPublic.Post("/otp/verify", limit("auth.login"), passwordlessController.VerifyCode)
+	authPublic.Post("/passkeys/login/begin", limit("auth.login"), passkeyController.BeginLogin)
+	authPublic.Post("/passkeys/login/finish", limit("auth.login"), passkeyController.FinishLogin)
+	authPublic.Post("/refresh", middlewares.JWTRefreshMiddleware(sessionUseCase), authController.RefreshToken)
+	authPublic.Get("/sso/:provider/login", ssoController.StartLogin)
+	authPublic.Post("/sso/:provider/callback", limit("auth.login"), ssoController.Callback)
+	authPublic.Get("/saml/:provider/metadata", samlController.Metadata)
+	authPublic.Get("/saml/:provider/login", samlController.StartLogin)
+	authPublic.Post("/saml/:provider/acs", samlController.ConsumeAssertion)
+	authPublic.Post("/saml/:provider/token", limit("auth.login"), samlController.Token)
+
+	recentAuth := middlewares.RequireRecentAuth(cfg.ReauthMaxAge)
+
+	authProtect := app.Group("/auth", authenticate)
+	authProtect.Get("/me", middlewares.RequireScope(entities.ScopeProfileRead), authController.GetProfile)
+	authProtect.Delete("/me", middlewares.RequireSession(), recentAuth, authController.DeleteAccount)
+	authProtect.Post("/logout", middlewares.RequireSession(), authController.Logout)
+	authProtect.Post("/logout/all", middlewares.RequireSession(), authController.LogoutAll)
+	authProtect.Post("/reauthenticate", middlewares.RequireSession(), limit("auth.login"), authController.Reauthenticate)
+	authProtect.Post("/email/change", middlewares.RequireSession(), recentAuth, authController.RequestEmailChange)
+	authProtect.Post("/tokens", middlewares.RequireSession(), recentAuth, accessTokenController.CreateToken)
+	authProtect.Get("/tokens", middlewares.RequireScope(entities.ScopeTokensRead), accessTokenController.ListTokens)
+	authProtect.Delete("/tokens/:id", middlewares.RequireSession(), accessTokenController.RevokeToken)
+	authProtect.Get("/identities", middlewares.RequireScope(entities.ScopeProfileRead), ssoController.ListIdentities)
+	authProtect.Delete("/identities/:id", middlewares.RequireSession(), recentAuth, ssoController.UnlinkIdentity)
+	authProtect.Post("/sso/:provider/link", middlewares.RequireSession(), recentAuth, ssoController.StartLink)
+	authProtect.Post("/saml/:provider/link", middlewares.RequireSession(), recentAuth, samlController.StartLink)
+	authProtect.Post("/passkeys/register/begin", middlewares.RequireSession(), recentAuth, passkeyController.BeginRegistration)
+	authProtect.Post("/passkeys/register/finish", middlewares.RequireSession(), recentAuth, passkeyController.FinishRegistration)
+	authProtect.Get("/passkeys", middlewares.RequireScope(entities.ScopeProfileRead), passkeyController.ListPasskeys)
+	authProtect.Put("/passkeys/:id", middlewares.RequireSession(), passkeyController.RenamePasskey)
+	authProtect.Delete("/passkeys/:id", middlewares.RequireSession(), recentAuth, passkeyController.DeletePasskey)
+
+	app.Get("/.well-known/openid-configuration", oidcController.Discovery)
+	app.Get("/.well-known/jwks.json", oidcController.JWKS)
+	app.Get("/oauth/authorize", oidcController.Authorize)
+	app.Post("/oauth/token", limit("oauth.token"), oauthController.Token)
+	app.Get("/oauth/userinfo", oidcController.UserInfo)
+	app.Post("/oauth/userinfo", oidcController.UserInfo)
+
+	// the login page shows and answers authorization requests for the user
+	consent := app.Group("/oauth/authorize/requests", authenticate, middlewares.RequireSession())
+	consent.Get("/:id", oidcController.GetAuthorizationRequest)
+	consent.Post("/:id", oidcController.DecideAuthorizationRequest)
+
+	// service accounts reach the admin API with a token, see RequireScope
+	webhooksRead := middlewares.RequireScope(oauthEntities.ScopeWebhooksRead)
+	webhooksWrite := middlewares.RequireScope(oauthEntities.ScopeWebhooksWrite)
+	adminKeyOnly := middlewares.RequireAdminKey()
+
+	adminGroup := app.Group("/admin", middlewares.AdminAuthMiddleware(cfg.AdminAPIKey, serviceAccountUseCase))
+	adminGroup.Post("/users/import", middlewares.RequireScope(oauthEntities.ScopeUsersImport), userController.ImportUsers)
+	adminGroup.Post("/users/:id/lock", adminKeyOnly, userController.LockUser)
+	adminGroup.Delete("/users/:id/lock", adminKeyOnly, userController.UnlockUser)
+	adminGroup.Post("/webhooks", webhooksWrite, webhookController.CreateSubscription)
+	adminGroup.Get("/webhooks", webhooksRead, webhookController.GetAllSubscriptions)
+	adminGroup.Get("/webhooks/:id", webhooksRead, webhookController.GetSubscriptionByID)
+	adminGroup.Put("/webhooks/:id", webhooksWrite, webhookController.UpdateSubscription)
+	adminGroup.Delete("/webhooks/:id", webhooksWrite, webhookController.DeleteSubscription)
+	adminGroup.Get("/webhooks/:id/deliveries", webhooksRead, webhookController.GetDeliveries)
+	adminGroup.Post("/webhooks/:id/deliveries/:deliveryID/redeliver", webhooksWrite, webhookController.Redeliver)
+	adminGroup.Post("/service-accounts", adminKeyOnly, serviceAccountController.CreateServiceAccount)
+	adminGroup.Get("/service-accounts", adminKeyOnly, serviceAccountController.GetAllServiceAccounts)
+	adminGroup.Get("/service-accounts/:id", adminKeyOnly, serviceAccountController.GetServiceAccountByID)
+	adminGroup.Put("/service-accounts/:id", adminKeyOnly, serviceAccountController.UpdateServiceAccount)
+	adminGroup.Delete("/service-accounts/:id", adminKeyOnly, serviceAccountController.DeleteServiceAccount)
+	adminGroup.Post("/service-accounts/:id/secrets", adminKeyOnly, serviceAccountController.RotateSecret)
+	adminGroup.Delete("/service-accounts/:id/secrets/:secretID", adminKeyOnly, serviceAccountController.DeleteSecret)
+	adminGroup.Post("/oauth-clients", adminKeyOnly, clientController.CreateClient)
+	adminGroup.Get("/oauth-clients", adminKeyOnly, clientController.GetAllClients)
+	adminGroup.Get("/oauth-clients/:id", adminKeyOnly, clientController.GetClientByID)
+	adminGroup.Put("/oauth-clients/:id", adminKeyOnly, clientController.UpdateClient)
+	adminGroup.Delete("/oauth-clients/:id", adminKeyOnly, clientController.DeleteClient)
+
+}
+
+// newUserRepositories picks the user and session storage for DB_DRIVER.
+// The memory driver is an in-memory SQLite database, which the other
+// repositories use too, so users and sessions live there as well.
+func newUserRepositories(cfg *config.Config, db *gorm.DB) (repositories.UserRepository, repositories.SessionRepository) {
+	switch cfg.DBDriver {
+	case databases.DriverMemory, databases.DriverSQLite:
+		return repositories.NewUserSQLiteRepository(db), repositories.NewSessionSQLiteRepository(db)
+	}
+	return repositories.NewUserPostgresRepository(db), repositories.NewSessionPostgresRepository(db)
+}
+
+// newCredentialVerifiers returns what logins are checked against besides
+// stored passwords: the LDAP directory, if LDAP_URL is set.
+func newCredentialVerifiers(cfg *config.Config, userRepo repositories.UserRepository, eventRepo repositories.EventRepository, txManager databases.TxManager) []usecases.CredentialVerifier {
+	if cfg.LDAPURL == "" {
+		return nil
+	}
+
+	var groupRoles []usecases.GroupRole
+	for _, pair := range strings.Split(cfg.LDAPGroupRoles, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		role, group, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(role) == "" || strings.TrimSpace(group) == "" {
+			log.Fatalf("Invalid LDAP_GROUP_ROLES entry %q, want ROLE=GROUP_DN", pair)
+		}
+		groupRoles = append(groupRoles, usecases.GroupRole{Group: strings.TrimSpace(group), Role: strings.TrimSpace(role)})
+	}
+
+	directory := ldapauth.New(ldapauth.Config{
+		URL:            cfg.LDAPURL,
+		StartTLS:       cfg.LDAPStartTLS,
+		BindDN:         cfg.LDAPBindDN,
+		BindPassword:   cfg.LDAPBindPassword,
+		BaseDN:         cfg.LDAPBaseDN,
+		UserFilter:     cfg.LDAPUserFilter,
+		EmailAttribute: cfg.LDAPEmailAttribute,
+		NameAttribute:  cfg.LDAPNameAttribute,
+		GroupAttribute: cfg.LDAPGroupAttribute,
+		Timeout:        cfg.LDAPTimeout,
+	})
+	return []usecases.CredentialVerifier{usecases.NewLDAPVerifier(directory, groupRoles, userRepo, eventRepo, txManager)}
+}
+
+// newRelyingParty sets up passkeys for WEBAUTHN_RP_ID.
+func newRelyingParty(cfg *config.Config) usecases.PasskeyRelyingParty {
+	rp, err := passkey.New(passkey.Config{
+		RPID:    cfg.WebAuthnRPID,
+		RPName:  cfg.WebAuthnRPName,
+		Origins: cfg.WebAuthnOrigins,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up passkeys: %v", err)
+	}
+	return rp
+}
+
+// newSSOProviders sets up the upstream identity providers of
+// SSO_PROVIDERS.
+func newSSOProviders(cfg *config.Config) map[string]usecases.IdentityProvider {
+	providers := make(map[string]usecases.IdentityProvider, len(cfg.SSOProviders))
+	for _, p := range cfg.SSOProviders {
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			log.Fatalf("SSO provider %q needs an issuer, client ID and redirect URL", p.Name)
+		}
+		providers[p.Name] = sso.NewProvider(sso.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		})
+	}
+	return providers
+}
+
+// newSAMLProviders sets up the SAML identity providers of
+// SAML_PROVIDERS, which share one key pair.
+func newSAMLProviders(cfg *config.Config) map[string]usecases.SAMLProvider {
+	providers := make(map[string]usecases.SAMLProvider, len(cfg.SAMLProviders))
+	if len(cfg.SAMLProviders) == 0 {
+		return providers
+	}
+
+	if cfg.SAMLKeyFile == "" && cfg.SAMLCertFile == "" {
+		log.Println("SAML_KEY_FILE and SAML_CERT_FILE are not set, using a temporary key pair")
+	}
+	key, cert, err := samlauth.LoadKeyPair(cfg.SAMLKeyFile, cfg.SAMLCertFile)
+	if err != nil {
+		log.Fatalf("Failed to load SAML key pair: %v", err)
+	}
+
+	for _, p := range cfg.SAMLProviders {
+		if p.IDPMetadataFile == "" || p.RedirectURL == "" {
+			log.Fatalf("SAML provider %q needs IdP metadata and a redirect URL", p.Name)
+		}
+		if p.Binding != samlauth.BindingRedirect && p.Binding != samlauth.BindingPOST {
+			log.Fatalf("SAML provider %q has unknown binding %q", p.Name, p.Binding)
+		}
+		idpMetadata, err := os.ReadFile(p.IDPMetadataFile)
+		if err != nil {
+			log.Fatalf("Failed to read SAML provider %q metadata: %v", p.Name, err)
+		}
+		sp, err := samlauth.NewProvider(samlauth.Config{
+			Name:            p.Name,
+			BaseURL:         cfg.SAMLBaseURL,
+			IDPMetadata:     idpMetadata,
+			Key:             key,
+			Certificate:     cert,
+			Binding:         p.Binding,
+			EmailAttribute:  p.EmailAttribute,
+			NameAttribute:   p.NameAttribute,
+			LocaleAttribute: p.LocaleAttribute,
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up SAML provider %q: %v", p.Name, err)
+		}
+		providers[p.Name] = usecases.SAMLProvider{ServiceProvider: sp, RedirectURL: p.RedirectURL, TrustEmail: p.TrustEmail}
+	}
+	return providers
+}
+
+// newOIDCProvider loads the ID token signing key. Without
+// OIDC_SIGNING_KEY_FILE a key is generated, and tokens signed with it
+// stop verifying on restart.
+func newOIDCProvider(cfg *config.Config) *oauthUsecases.Provider {
+	if cfg.OIDCSigningKeyFile == "" {
+		log.Println("OIDC_SIGNING_KEY_FILE is not set, using a temporary signing key")
+	}
+	key, err := jwt.LoadSigningKey(cfg.OIDCSigningKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load OIDC signing key: %v", err)
+	}
+
+	return &oauthUsecases.Provider{
+		Issuer:   strings.TrimSuffix(cfg.OIDCIssuer, "/"),
+		LoginURL: cfg.OIDCLoginURL,
+		Key:      key,
+	}
+}
+
+// rateLimitedRoutes are the route names RATE_LIMITS can configure.
+var rateLimitedRoutes = map[string]bool{"auth.login": true, "auth.register": true, "auth.magic_link": true, "users": true, "oauth.token": true}
+
+// newRateLimiter parses RATE_LIMITS and returns a function giving the
+// middleware for a route name, which passes everything if unconfigured.
+func newRateLimiter(cfg *config.Config) func(route string) fiber.Handler {
+	routes, err := ratelimit.ParsePolicies(cfg.RateLimits)
+	if err != nil {
+		log.Fatalf("Failed to parse RATE_LIMITS: %v", err)
+	}
+	for route := range routes {
+		if !rateLimitedRoutes[route] {
+			log.Fatalf("Failed to parse RATE_LIMITS: unknown route %q", route)
+		}
+	}
 
-	authProtect := app.Group("/auth", middlewares.JWTAuthMiddleware())
-	authProtect.Get("/me", authController.GetProfile)
-	authProtect.Post("/refresh", authController.RefreshToken)
-	authProtect.Post("/logout", authController.Logout)
-	authProtect.Post("/logout/all", authController.LogoutAll)
+	var store ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		store = ratelimit.NewRedisStore(client, "ratelimit:")
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_STORE %q", cfg.RateLimitStore)
+	}
 
+	return func(route string) fiber.Handler {
+		return middlewares.RateLimitMiddleware(store, routes[route]...)
+	}
 }
@@ -0,0 +1,1476 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/natchaphonbw/usermanagement/config"
+	"github.com/natchaphonbw/usermanagement/modules/users/validator"
+	"github.com/natchaphonbw/usermanagement/pkg/databases"
+	"github.com/natchaphonbw/usermanagement/pkg/databases/migrations"
+	apptoken "github.com/natchaphonbw/usermanagement/pkg/jwt"
+	"github.com/natchaphonbw/usermanagement/pkg/ldapauth/ldaptest"
+	"github.com/natchaphonbw/usermanagement/pkg/mailer"
+	"github.com/natchaphonbw/usermanagement/pkg/passkey/passkeytest"
+	"github.com/natchaphonbw/usermanagement/pkg/samlauth/samltest"
+	"github.com/natchaphonbw/usermanagement/pkg/sso/ssotest"
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+	"github.com/natchaphonbw/usermanagement/server"
+)
+
+func TestMain(m *testing.M) {
+	validator.Init()
+
+	// cheap hashing keeps the suite fast
+	utils.DefaultArgon2Config.Memory = 1024
+	utils.DefaultArgon2Config.Time = 1
+
+	// one signing key for every app, generating RSA keys is slow
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("generate signing key: %v", err)
+	}
+	dir, err := os.MkdirTemp("", "server-test")
+	if err != nil {
+		log.Fatalf("create temp dir: %v", err)
+	}
+	signingKeyFile = filepath.Join(dir, "oidc.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(signingKeyFile, keyPEM, 0o600); err != nil {
+		log.Fatalf("write signing key: %v", err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var signingKeyFile string
+
+func newTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	return newTestAppWith(t, nil)
+}
+
+// newTestAppWith lets configure change the test config before set up.
+func newTestAppWith(t *testing.T, configure func(*config.Config)) *fiber.App {
+	t.Helper()
+
+	cfg := &config.Config{
+		DBDriver:     databases.DriverMemory,
+		AdminAPIKey:  "admin-key",
+		MetricsToken: "metrics-token",
+
+		OIDCIssuer:   "https://id.example.com",
+		OIDCLoginURL: "https://login.example.com/authorize",
+
+		OIDCSigningKeyFile: signingKeyFile,
+
+		WebAuthnRPID:    "app.example.com",
+		WebAuthnRPName:  "Example",
+		WebAuthnOrigins: []string{"https://app.example.com"},
+
+		ReauthMaxAge: 10 * time.Minute,
+
+		PasswordMinLength:    10,
+		PasswordRequireUpper: true,
+		PasswordRequireLower: true,
+		PasswordMinScore:     3,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	db := databases.Connect(cfg)
+	migrations.Migrate(db)
+
+	app := server.NewFiberApp()
+	server.SetupRoutes(app, db, cfg, mailer.NewMemoryMailer(), nil)
+	return app
+}
+
+type request struct {
+	method  string
+	path    string
+	body    any
+	token   string
+	headers map[string]string
+}
+
+func do(t *testing.T, app *fiber.App, r request) (int, map[string]any) {
+	t.Helper()
+
+	var out map[string]any
+	status := doInto(t, app, r, &out)
+	return status, out
+}
+
+// doInto sends r and decodes a JSON response body into out.
+func doInto(t *testing.T, app *fiber.App, r request, out any) int {
+	t.Helper()
+
+	var body io.Reader
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(r.method, r.path, body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Device-ID", "device-1")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", r.method, r.path, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(data, out)
+	return resp.StatusCode
+}
+
+func register(t *testing.T, app *fiber.App, email string) {
+	t.Helper()
+
+	status, body := do(t, app, request{method: http.MethodPost, path: "/auth/register", body: map[string]any{
+		"name": "Bob", "email": email, "password": "Correct-Orbit-42", "age": 25,
+	}})
+	if status != http.StatusCreated {
+		t.Fatalf("register: status %d, body %v", status, body)
+	}
+}
+
+func login(t *testing.T, app *fiber.App, email string) (string, string) {
+	t.Helper()
+
+	status, body := do(t, app, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{
+		"email": email, "password": "Correct-Orbit-42",
+	}})
+	if status != http.StatusOK {
+		t.Fatalf("login: status %d, body %v", status, body)
+	}
+	return body["access_token"].(string), body["refresh_token"].(string)
+}
+
+func TestRegister(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+	}{
+		{name: "valid", body: map[string]any{"name": "Bob", "email": "bob@example.com", "password": "Correct-Orbit-42", "age": 25}, status: http.StatusCreated},
+		{name: "duplicate email in other case", body: map[string]any{"name": "Bob", "email": "BOB@example.com", "password": "Correct-Orbit-42", "age": 25}, status: http.StatusConflict},
+		{name: "invalid email", body: map[string]any{"name": "Bob", "email": "bob", "password": "Correct-Orbit-42", "age": 25}, status: http.StatusBadRequest},
+		{name: "too young", body: map[string]any{"name": "Bob", "email": "young@example.com", "password": "Correct-Orbit-42", "age": 10}, status: http.StatusBadRequest},
+		{name: "weak password", body: map[string]any{"name": "Bob", "email": "weak@example.com", "password": "correct-orbit-42", "age": 25}, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, app, request{method: http.MethodPost, path: "/auth/register", body: tt.body})
+			if status != tt.status {
+				t.Errorf("status = %d, want %d (body %v)", status, tt.status, body)
+			}
+		})
+	}
+}
+
+func TestLoginAndProfile(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+
+	status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{
+		"email": "bob@example.com", "password": "Wrong-Orbit-42",
+	}})
+	if status != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d, want 401", status)
+	}
+
+	accessToken, _ := login(t, app, "Bob@Example.com")
+
+	status, body := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: accessToken})
+	if status != http.StatusOK || body["email"] != "bob@example.com" {
+		t.Errorf("me: status %d, body %v", status, body)
+	}
+
+	for _, token := range []string{"", "garbage", "a.b.c"} {
+		if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: token}); status != http.StatusUnauthorized {
+			t.Errorf("me with token %q: status = %d, want 401", token, status)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	_, refreshToken := login(t, app, "bob@example.com")
+
+	status, body := do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: refreshToken})
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %v", status, body)
+	}
+	if body["refresh_token"] == refreshToken {
+		t.Error("refresh token was not rotated")
+	}
+
+	// the rotated-out token cannot be used again
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: refreshToken}); status != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: status = %d, want 401", status)
+	}
+
+	// other devices cannot use the token
+	newRefresh := body["refresh_token"].(string)
+	status, _ = do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: newRefresh, headers: map[string]string{"X-Device-ID": "device-2"}})
+	if status != http.StatusUnauthorized {
+		t.Errorf("other device: status = %d, want 401", status)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	accessToken, refreshToken := login(t, app, "bob@example.com")
+
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/logout", token: accessToken}); status != http.StatusNoContent {
+		t.Fatalf("logout: status = %d, want 204", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/logout", token: accessToken}); status != http.StatusUnauthorized {
+		t.Errorf("second logout: status = %d, want 401", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: accessToken}); status != http.StatusUnauthorized {
+		t.Errorf("profile after logout: status = %d, want 401", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: refreshToken}); status != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: status = %d, want 401", status)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	accessToken, _ := login(t, app, "bob@example.com")
+	_, otherRefresh := login(t, app, "bob@example.com")
+
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/logout/all", token: accessToken}); status != http.StatusNoContent {
+		t.Fatalf("logout all: status = %d, want 204", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: otherRefresh}); status != http.StatusUnauthorized {
+		t.Errorf("refresh after logout all: status = %d, want 401", status)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	accessToken, refreshToken := login(t, app, "bob@example.com")
+
+	// deleting needs a recent login, as does unlinking an identity
+	claims, err := apptoken.VerifyAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	stale, err := apptoken.GenerateAccessToken(claims.UserID, claims.SessionID, time.Now().Add(-time.Hour), claims.AMR)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	for _, path := range []string{"/auth/me", "/auth/identities/6f1c2a8e-4b7d-4c1e-9a3f-2d5e8b9c0a17"} {
+		if status, body := do(t, app, request{method: http.MethodDelete, path: path, token: stale}); status != http.StatusUnauthorized || body["code"] != "reauthentication_required" {
+			t.Errorf("DELETE %s with stale token: status = %d, body %v, want 401 reauthentication_required", path, status, body)
+		}
+	}
+
+	if status, body := do(t, app, request{method: http.MethodDelete, path: "/auth/me", token: accessToken}); status != http.StatusNoContent {
+		t.Fatalf("delete account: status = %d, body %v", status, body)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: refreshToken}); status != http.StatusUnauthorized {
+		t.Errorf("refresh after delete: status = %d, want 401", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{"email": "bob@example.com", "password": "Correct-Orbit-42"}}); status != http.StatusUnauthorized {
+		t.Errorf("login after delete: status = %d, want 401", status)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	register(t, app, "alice@example.com")
+	bobToken, _ := login(t, app, "bob@example.com")
+	aliceToken, _ := login(t, app, "alice@example.com")
+	_, bob := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: bobToken})
+	bobPath := "/users/" + bob["id"].(string)
+	rename := map[string]any{"name": "Robert"}
+
+	if status, _ := do(t, app, request{method: http.MethodPut, path: bobPath, body: rename}); status != http.StatusUnauthorized {
+		t.Errorf("update without a token: status = %d, want 401", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPut, path: bobPath, token: aliceToken, body: rename}); status != http.StatusForbidden {
+		t.Errorf("update another user: status = %d, want 403", status)
+	}
+	if status, body := do(t, app, request{method: http.MethodPut, path: bobPath, token: bobToken, body: rename}); status != http.StatusCreated || body["name"] != "Robert" {
+		t.Errorf("update self: status = %d, body %v", status, body)
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodDelete, path: bobPath, token: aliceToken}); status != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE %s: status = %d, want 405", bobPath, status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: bobPath}); status != http.StatusOK {
+		t.Errorf("bob after DELETE: status = %d, want 200", status)
+	}
+}
+
+func TestLockUser(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"X-Admin-Key": "admin-key"}
+	register(t, app, "bob@example.com")
+	accessToken, refreshToken := login(t, app, "bob@example.com")
+	_, me := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: accessToken})
+	lockPath := "/admin/users/" + me["id"].(string) + "/lock"
+
+	if status, body := do(t, app, request{method: http.MethodPost, path: lockPath, headers: admin}); status != http.StatusOK || body["locked_at"] == nil {
+		t.Fatalf("lock: status %d, body %v", status, body)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/refresh", token: refreshToken}); status != http.StatusUnauthorized {
+		t.Errorf("refresh while locked: status = %d, want 401", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: accessToken}); status != http.StatusUnauthorized {
+		t.Errorf("profile while locked: status = %d, want 401", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{"email": "bob@example.com", "password": "Correct-Orbit-42"}}); status != http.StatusForbidden {
+		t.Errorf("login while locked: status = %d, want 403", status)
+	}
+
+	if status, body := do(t, app, request{method: http.MethodDelete, path: lockPath, headers: admin}); status != http.StatusOK || body["locked_at"] != nil {
+		t.Fatalf("unlock: status %d, body %v", status, body)
+	}
+	login(t, app, "bob@example.com")
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	accessToken, _ := login(t, app, "bob@example.com")
+
+	status, created := do(t, app, request{method: http.MethodPost, path: "/auth/tokens", token: accessToken, body: map[string]any{
+		"name": "ci", "scopes": []string{"profile:read"}, "expires_in_days": 30,
+	}})
+	if status != http.StatusCreated {
+		t.Fatalf("create token: status = %d, body %v", status, created)
+	}
+	pat, _ := created["token"].(string)
+	if !strings.HasPrefix(pat, "pat_") {
+		t.Fatalf("token = %q, want a pat_ token", pat)
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/tokens", token: accessToken, body: map[string]any{
+		"name": "ci", "scopes": []string{"admin"},
+	}}); status != http.StatusBadRequest {
+		t.Errorf("create token with unknown scope: status = %d, want 400", status)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{name: "in scope", method: http.MethodGet, path: "/auth/me", status: http.StatusOK},
+		{name: "out of scope", method: http.MethodGet, path: "/auth/tokens", status: http.StatusForbidden},
+		{name: "create token", method: http.MethodPost, path: "/auth/tokens", status: http.StatusForbidden},
+		{name: "logout", method: http.MethodPost, path: "/auth/logout", status: http.StatusForbidden},
+		{name: "logout all", method: http.MethodPost, path: "/auth/logout/all", status: http.StatusForbidden},
+		{name: "refresh", method: http.MethodPost, path: "/auth/refresh", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := do(t, app, request{method: tt.method, path: tt.path, token: pat}); status != tt.status {
+				t.Errorf("status = %d, want %d (body %v)", status, tt.status, body)
+			}
+		})
+	}
+
+	var tokens []map[string]any
+	if status := doInto(t, app, request{method: http.MethodGet, path: "/auth/tokens", token: accessToken}, &tokens); status != http.StatusOK {
+		t.Fatalf("list tokens: status = %d", status)
+	}
+	if len(tokens) != 1 || tokens[0]["token"] != nil || tokens[0]["prefix"] != pat[:12] || tokens[0]["last_used_at"] == nil {
+		t.Errorf("tokens = %v", tokens)
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodDelete, path: "/auth/tokens/" + created["id"].(string), token: accessToken}); status != http.StatusNoContent {
+		t.Fatalf("revoke token: status = %d, want 204", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: pat}); status != http.StatusUnauthorized {
+		t.Errorf("revoked token: status = %d, want 401", status)
+	}
+}
+
+func TestStepUp(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+	accessToken, _ := login(t, app, "bob@example.com")
+
+	// the same session, signed in an hour ago
+	claims, err := apptoken.VerifyAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	stale, err := apptoken.GenerateAccessToken(claims.UserID, claims.SessionID, time.Now().Add(-time.Hour), claims.AMR)
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+
+	createToken := request{method: http.MethodPost, path: "/auth/tokens", token: stale, body: map[string]any{"name": "ci", "scopes": []string{"profile:read"}}}
+	status, body := do(t, app, createToken)
+	if status != http.StatusUnauthorized || body["code"] != "reauthentication_required" {
+		t.Fatalf("stale token: status = %d, body %v, want 401 reauthentication_required", status, body)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: stale}); status != http.StatusOK {
+		t.Errorf("profile with stale token: status = %d, want 200", status)
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/reauthenticate", token: stale, body: map[string]any{"password": "Wrong-Orbit-42"}}); status != http.StatusUnauthorized {
+		t.Errorf("reauthenticate with wrong password: status = %d, want 401", status)
+	}
+	status, body = do(t, app, request{method: http.MethodPost, path: "/auth/reauthenticate", token: stale, body: map[string]any{"password": "Correct-Orbit-42"}})
+	if status != http.StatusOK {
+		t.Fatalf("reauthenticate: status = %d, body %v", status, body)
+	}
+	fresh, _ := body["access_token"].(string)
+
+	createToken.token = fresh
+	if status, body := do(t, app, createToken); status != http.StatusCreated {
+		t.Errorf("fresh token: status = %d, body %v, want 201", status, body)
+	}
+	// reauthenticating replaced the session
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/logout", token: accessToken}); status != http.StatusUnauthorized {
+		t.Errorf("logout of the old session: status = %d, want 401", status)
+	}
+}
+
+func TestHashingSaturated(t *testing.T) {
+	app := newTestApp(t)
+	register(t, app, "bob@example.com")
+
+	saved := utils.PasswordHashLimiter
+	utils.PasswordHashLimiter = utils.NewHashLimiter(1, 0, 0)
+	t.Cleanup(func() { utils.PasswordHashLimiter = saved })
+
+	release, err := utils.PasswordHashLimiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"bob@example.com","password":"Correct-Orbit-42"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST /auth/login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("login while saturated: status = %d, Retry-After %q, want 503 and 1", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/metrics"}); status != http.StatusUnauthorized {
+		t.Errorf("metrics without token: status = %d, want 401", status)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer metrics-token")
+	resp, err = app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{"password_hash_in_flight 1\n", "password_hash_queue_depth 0\n", "password_hash_rejected_total 1\n"} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("metrics missing %q:\n%s", want, metrics)
+		}
+	}
+
+	release()
+	login(t, app, "bob@example.com")
+}
+
+func TestRateLimit(t *testing.T) {
+	app := newTestAppWith(t, func(cfg *config.Config) {
+		cfg.RateLimits = "auth.login=ip:5/1m,email:2/1m"
+	})
+	register(t, app, "bob@example.com")
+
+	attempt := func(email string) *http.Response {
+		data, _ := json.Marshal(map[string]any{"email": email, "password": "Wrong-Orbit-42"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// the email's limit is closest, and hit first
+	for i, wantRemaining := range []string{"1", "0"} {
+		resp := attempt("bob@example.com")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d, want 401", i, resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("attempt %d: RateLimit-Remaining = %q, want %q", i, got, wantRemaining)
+		}
+		if got := resp.Header.Get("RateLimit-Policy"); got != "5;w=60, 2;w=60" {
+			t.Errorf("attempt %d: RateLimit-Policy = %q", i, got)
+		}
+	}
+
+	resp := attempt(" BOB@example.com")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("third attempt for the email: status = %d, want 429", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "30" || resp.Header.Get("RateLimit-Reset") != "60" {
+		t.Errorf("Retry-After = %q, RateLimit-Reset = %q, want 30 and 60", resp.Header.Get("Retry-After"), resp.Header.Get("RateLimit-Reset"))
+	}
+
+	// another email still gets through, until the IP has had 5 attempts
+	for _, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if resp := attempt("alice@example.com"); resp.StatusCode != want {
+			t.Errorf("status = %d, want %d", resp.StatusCode, want)
+		}
+	}
+
+	// routes without policies are not limited
+	if resp := attempt(""); resp.Header.Get("RateLimit-Limit") == "" {
+		t.Error("login without an email is not counted by IP")
+	}
+	status, _ := do(t, app, request{method: http.MethodGet, path: "/users/"})
+	if status != http.StatusOK {
+		t.Errorf("GET /users: status = %d, want 200", status)
+	}
+}
+
+func TestMagicLinkRequest(t *testing.T) {
+	app := newTestAppWith(t, func(cfg *config.Config) {
+		cfg.RateLimits = "auth.magic_link=email:3/15m"
+	})
+	register(t, app, "bob@example.com")
+	requestLink := func(email, method string) int {
+		status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/magic-link", body: map[string]any{"email": email, "method": method}})
+		return status
+	}
+
+	// an account or not, the answer is the same
+	for _, email := range []string{"bob@example.com", "nobody@example.com"} {
+		if status := requestLink(email, "link"); status != http.StatusAccepted {
+			t.Errorf("%s: status = %d, want 202", email, status)
+		}
+	}
+	if status := requestLink("bob@example.com", "sms"); status != http.StatusBadRequest {
+		t.Errorf("unknown method: status = %d, want 400", status)
+	}
+	if status := requestLink("Bob@example.com", "code"); status != http.StatusAccepted {
+		t.Errorf("code: status = %d, want 202", status)
+	}
+	if status := requestLink("bob@example.com", "link"); status != http.StatusTooManyRequests {
+		t.Errorf("fourth request for the email: status = %d, want 429", status)
+	}
+
+	status, _ := do(t, app, request{method: http.MethodPost, path: "/auth/magic-link/verify", body: map[string]any{"token": "not-a-token"}})
+	if status != http.StatusBadRequest {
+		t.Errorf("verify unknown token: status = %d, want 400", status)
+	}
+}
+
+// passkeyCeremony is a started passkey registration or login.
+type passkeyCeremony struct {
+	CeremonyID string          `json:"ceremony_id"`
+	Options    json.RawMessage `json:"options"`
+}
+
+// registerPasskey registers authn as a passkey named Laptop for the
+// user of access.
+func registerPasskey(t *testing.T, app *fiber.App, authn *passkeytest.Authenticator, access string) map[string]any {
+	t.Helper()
+
+	var ceremony passkeyCeremony
+	if status := doInto(t, app, request{method: http.MethodPost, path: "/auth/passkeys/register/begin", token: access}, &ceremony); status != http.StatusOK {
+		t.Fatalf("begin registration: status %d", status)
+	}
+	status, created := do(t, app, request{method: http.MethodPost, path: "/auth/passkeys/register/finish", token: access, body: map[string]any{
+		"ceremony_id": ceremony.CeremonyID, "nickname": "Laptop", "credential": json.RawMessage(authn.Register(t, ceremony.Options)),
+	}})
+	if status != http.StatusCreated || created["nickname"] != "Laptop" {
+		t.Fatalf("finish registration: status %d, body %v", status, created)
+	}
+	return created
+}
+
+func TestPasskeys(t *testing.T) {
+	app := newTestApp(t)
+	authn := passkeytest.NewAuthenticator("https://app.example.com")
+	register(t, app, "bob@example.com")
+	access, _ := login(t, app, "bob@example.com")
+
+	created := registerPasskey(t, app, authn, access)
+	passkeyPath := "/auth/passkeys/" + created["id"].(string)
+
+	finishLogin := func(ceremony passkeyCeremony) (int, map[string]any) {
+		return do(t, app, request{method: http.MethodPost, path: "/auth/passkeys/login/finish", body: map[string]any{
+			"ceremony_id": ceremony.CeremonyID, "credential": json.RawMessage(authn.Login(t, ceremony.Options)),
+		}})
+	}
+
+	t.Run("second factor", func(t *testing.T) {
+		var resp struct {
+			AccessToken     string          `json:"access_token"`
+			PasskeyRequired passkeyCeremony `json:"passkey_required"`
+		}
+		status := doInto(t, app, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{
+			"email": "bob@example.com", "password": "Correct-Orbit-42",
+		}}, &resp)
+		if status != http.StatusOK || resp.AccessToken != "" || resp.PasskeyRequired.CeremonyID == "" {
+			t.Fatalf("login: status %d, body %+v", status, resp)
+		}
+		if status, body := finishLogin(resp.PasskeyRequired); status != http.StatusOK || body["access_token"] == nil {
+			t.Errorf("finish login: status %d, body %v", status, body)
+		}
+	})
+
+	t.Run("passwordless", func(t *testing.T) {
+		var ceremony passkeyCeremony
+		if status := doInto(t, app, request{method: http.MethodPost, path: "/auth/passkeys/login/begin"}, &ceremony); status != http.StatusOK {
+			t.Fatalf("begin login: status %d", status)
+		}
+		if status, body := finishLogin(ceremony); status != http.StatusOK || body["access_token"] == nil {
+			t.Errorf("finish login: status %d, body %v", status, body)
+		}
+	})
+
+	t.Run("manage", func(t *testing.T) {
+		status, renamed := do(t, app, request{method: http.MethodPut, path: passkeyPath, token: access, body: map[string]any{"nickname": "Work laptop"}})
+		if status != http.StatusOK || renamed["nickname"] != "Work laptop" {
+			t.Errorf("rename: status %d, body %v", status, renamed)
+		}
+
+		var passkeys []map[string]any
+		if status := doInto(t, app, request{method: http.MethodGet, path: "/auth/passkeys", token: access}, &passkeys); status != http.StatusOK || len(passkeys) != 1 || passkeys[0]["last_used_at"] == nil {
+			t.Errorf("list: status %d, body %v", status, passkeys)
+		}
+
+		if status, _ := do(t, app, request{method: http.MethodDelete, path: passkeyPath, token: access}); status != http.StatusNoContent {
+			t.Errorf("delete: status = %d, want 204", status)
+		}
+		if status, _ := do(t, app, request{method: http.MethodDelete, path: passkeyPath, token: access}); status != http.StatusNotFound {
+			t.Errorf("delete again: status = %d, want 404", status)
+		}
+		login(t, app, "bob@example.com")
+	})
+}
+
+// requestToken posts form to the token endpoint, authenticating with
+// HTTP Basic if clientID is set.
+func requestToken(t *testing.T, app *fiber.App, form url.Values, clientID, clientSecret string) (int, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST /oauth/token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var out map[string]any
+	data, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(data, &out)
+	return resp.StatusCode, out
+}
+
+func TestServiceAccounts(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"X-Admin-Key": "admin-key"}
+
+	status, account := do(t, app, request{method: http.MethodPost, path: "/admin/service-accounts", headers: admin, body: map[string]any{
+		"name": "exporter", "scopes": []string{"webhooks:read", "users:import"},
+	}})
+	if status != http.StatusCreated {
+		t.Fatalf("create service account: status = %d, body %v", status, account)
+	}
+	accountPath := "/admin/service-accounts/" + account["id"].(string)
+	clientID, _ := account["client_id"].(string)
+	secret, _ := account["client_secret"].(string)
+	if !strings.HasPrefix(clientID, "svc_") || !strings.HasPrefix(secret, "sas_") {
+		t.Fatalf("client_id = %q, client_secret = %q", clientID, secret)
+	}
+
+	if status, body := requestToken(t, app, url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "client_secret": {secret}}, "", ""); status != http.StatusOK || body["scope"] != "webhooks:read users:import" {
+		t.Errorf("token with body credentials: status = %d, body %v", status, body)
+	}
+	status, body := requestToken(t, app, url.Values{"grant_type": {"client_credentials"}, "scope": {"webhooks:read"}}, clientID, secret)
+	if status != http.StatusOK || body["token_type"] != "Bearer" || body["scope"] != "webhooks:read" {
+		t.Fatalf("token with basic auth: status = %d, body %v", status, body)
+	}
+	token, _ := body["access_token"].(string)
+
+	errs := []struct {
+		name   string
+		form   url.Values
+		secret string
+		status int
+		code   string
+	}{
+		{name: "wrong secret", form: url.Values{"grant_type": {"client_credentials"}}, secret: "sas_wrong", status: http.StatusUnauthorized, code: "invalid_client"},
+		{name: "scope not granted", form: url.Values{"grant_type": {"client_credentials"}, "scope": {"webhooks:write"}}, secret: secret, status: http.StatusBadRequest, code: "invalid_scope"},
+		{name: "unsupported grant", form: url.Values{"grant_type": {"password"}}, secret: secret, status: http.StatusBadRequest, code: "unsupported_grant_type"},
+	}
+	for _, tt := range errs {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := requestToken(t, app, tt.form, clientID, tt.secret); status != tt.status || body["error"] != tt.code {
+				t.Errorf("status = %d, body %v, want %d %s", status, body, tt.status, tt.code)
+			}
+		})
+	}
+
+	routes := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{name: "in scope", method: http.MethodGet, path: "/admin/webhooks", status: http.StatusOK},
+		{name: "out of scope", method: http.MethodPost, path: "/admin/webhooks", status: http.StatusForbidden},
+		{name: "not granted in token", method: http.MethodPost, path: "/admin/users/import", status: http.StatusForbidden},
+		{name: "service accounts", method: http.MethodGet, path: "/admin/service-accounts", status: http.StatusForbidden},
+	}
+	for _, tt := range routes {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := do(t, app, request{method: tt.method, path: tt.path, token: token}); status != tt.status {
+				t.Errorf("status = %d, want %d (body %v)", status, tt.status, body)
+			}
+		})
+	}
+
+	// the old secret keeps working through the overlap
+	status, rotated := do(t, app, request{method: http.MethodPost, path: accountPath + "/secrets", headers: admin})
+	if status != http.StatusCreated {
+		t.Fatalf("rotate secret: status = %d, body %v", status, rotated)
+	}
+	for _, s := range []string{secret, rotated["client_secret"].(string)} {
+		if status, _ := requestToken(t, app, url.Values{"grant_type": {"client_credentials"}}, clientID, s); status != http.StatusOK {
+			t.Errorf("token after rotation: status = %d, want 200", status)
+		}
+	}
+
+	status, latest := do(t, app, request{method: http.MethodPost, path: accountPath + "/secrets", headers: admin, body: map[string]any{"overlap_seconds": 0}})
+	if status != http.StatusCreated {
+		t.Fatalf("rotate secret without overlap: status = %d", status)
+	}
+	if status, _ := requestToken(t, app, url.Values{"grant_type": {"client_credentials"}}, clientID, secret); status != http.StatusUnauthorized {
+		t.Errorf("token with expired secret: status = %d, want 401", status)
+	}
+	// tokens issued for an expired secret stop working with it
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/admin/webhooks", token: token}); status != http.StatusUnauthorized {
+		t.Errorf("token of expired secret: status = %d, want 401", status)
+	}
+	_, body = requestToken(t, app, url.Values{"grant_type": {"client_credentials"}}, clientID, latest["client_secret"].(string))
+	token, _ = body["access_token"].(string)
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/admin/webhooks", token: token}); status != http.StatusOK {
+		t.Errorf("token of latest secret: status = %d, want 200", status)
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodPut, path: accountPath, headers: admin, body: map[string]any{"disabled": true}}); status != http.StatusOK {
+		t.Fatalf("disable service account: status = %d", status)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/admin/webhooks", token: token}); status != http.StatusUnauthorized {
+		t.Errorf("token of disabled account: status = %d, want 401", status)
+	}
+	if status, body := requestToken(t, app, url.Values{"grant_type": {"client_credentials"}}, clientID, rotated["client_secret"].(string)); status != http.StatusUnauthorized || body["error"] != "invalid_client" {
+		t.Errorf("token for disabled account: status = %d, body %v", status, body)
+	}
+}
+
+// authorize sends the browser's request to the authorization endpoint and
+// returns where it was redirected.
+func authorize(t *testing.T, app *fiber.App, query url.Values) (int, *url.URL) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil), -1)
+	if err != nil {
+		t.Fatalf("GET /oauth/authorize: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse Location: %v", err)
+	}
+	return resp.StatusCode, location
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"X-Admin-Key": "admin-key"}
+	register(t, app, "bob@example.com")
+	accessToken, _ := login(t, app, "bob@example.com")
+
+	if status, body := do(t, app, request{method: http.MethodPost, path: "/admin/oauth-clients", headers: admin, body: map[string]any{
+		"name": "wiki", "redirect_uris": []string{"http://wiki.example.com/callback"},
+	}}); status != http.StatusBadRequest {
+		t.Errorf("register client with http redirect: status = %d, body %v", status, body)
+	}
+	status, client := do(t, app, request{method: http.MethodPost, path: "/admin/oauth-clients", headers: admin, body: map[string]any{
+		"name": "wiki", "public": true, "redirect_uris": []string{"https://wiki.example.com/callback"},
+	}})
+	if status != http.StatusCreated || client["client_secret"] != nil {
+		t.Fatalf("register client: status = %d, body %v", status, client)
+	}
+	clientID := client["client_id"].(string)
+
+	var discovery map[string]any
+	if status := doInto(t, app, request{method: http.MethodGet, path: "/.well-known/openid-configuration"}, &discovery); status != http.StatusOK ||
+		discovery["issuer"] != "https://id.example.com" || discovery["token_endpoint"] != "https://id.example.com/oauth/token" {
+		t.Fatalf("discovery: status = %d, body %v", status, discovery)
+	}
+
+	verifier := "a-long-random-code-verifier-of-at-least-43-characters"
+	sum := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {"https://wiki.example.com/callback"},
+		"scope":                 {"openid email"},
+		"state":                 {"s-1"},
+		"nonce":                 {"n-1"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	// the authorization code flow, as the browser and login page drive it
+	flow := func(t *testing.T, consentRequired bool) url.Values {
+		t.Helper()
+
+		status, location := authorize(t, app, query)
+		if status != http.StatusFound || location.Host != "login.example.com" {
+			t.Fatalf("authorize: status = %d, location %s", status, location)
+		}
+		requestPath := "/oauth/authorize/requests/" + location.Query().Get("request_id")
+
+		status, pending := do(t, app, request{method: http.MethodGet, path: requestPath, token: accessToken})
+		if status != http.StatusOK || pending["client_name"] != "wiki" || pending["consent_required"] != consentRequired {
+			t.Fatalf("get authorization request: status = %d, body %v", status, pending)
+		}
+
+		status, decision := do(t, app, request{method: http.MethodPost, path: requestPath, token: accessToken, body: map[string]any{"approve": true}})
+		if status != http.StatusOK {
+			t.Fatalf("approve: status = %d, body %v", status, decision)
+		}
+		if status, _ := do(t, app, request{method: http.MethodPost, path: requestPath, token: accessToken, body: map[string]any{"approve": true}}); status != http.StatusNotFound {
+			t.Errorf("approve twice: status = %d, want 404", status)
+		}
+
+		redirect, _ := url.Parse(decision["redirect_to"].(string))
+		if redirect.Host != "wiki.example.com" || redirect.Query().Get("state") != "s-1" || redirect.Query().Get("iss") != "https://id.example.com" {
+			t.Fatalf("redirect_to = %s", redirect)
+		}
+		return redirect.Query()
+	}
+
+	code := flow(t, true).Get("code")
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {"https://wiki.example.com/callback"},
+		"code_verifier": {verifier},
+	}
+	status, tokens := requestToken(t, app, exchange, "", "")
+	if status != http.StatusOK || tokens["scope"] != "openid email" {
+		t.Fatalf("exchange code: status = %d, body %v", status, tokens)
+	}
+	if status, body := requestToken(t, app, exchange, "", ""); status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("exchange code twice: status = %d, body %v", status, body)
+	}
+
+	var jwks struct {
+		Keys []struct{ Kid, N, E string }
+	}
+	doInto(t, app, request{method: http.MethodGet, path: "/.well-known/jwks.json"}, &jwks)
+	var claims struct {
+		Nonce string `json:"nonce"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+		jwt.RegisteredClaims
+	}
+	_, err := jwt.ParseWithClaims(tokens["id_token"].(string), &claims, func(token *jwt.Token) (interface{}, error) {
+		n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+		e, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}, jwt.WithIssuer("https://id.example.com"), jwt.WithAudience(clientID), jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil {
+		t.Fatalf("verify ID token: %v", err)
+	}
+	if claims.Nonce != "n-1" || claims.Email != "bob@example.com" || claims.Name != "" {
+		t.Errorf("ID token claims = %+v", claims)
+	}
+
+	oauthToken := tokens["access_token"].(string)
+	if status, info := do(t, app, request{method: http.MethodGet, path: "/oauth/userinfo", token: oauthToken}); status != http.StatusOK || info["email"] != "bob@example.com" || info["sub"] != claims.Subject {
+		t.Errorf("userinfo: status = %d, body %v", status, info)
+	}
+	// the client acts for the user only through userinfo
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: oauthToken}); status != http.StatusUnauthorized {
+		t.Errorf("/auth/me with OAuth token: status = %d, want 401", status)
+	}
+
+	// consent is remembered, and the code is bound to the verifier
+	exchange.Set("code", flow(t, false).Get("code"))
+	exchange.Set("code_verifier", "another-long-random-code-verifier-of-43-characters")
+	if status, body := requestToken(t, app, exchange, "", ""); status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("exchange with wrong verifier: status = %d, body %v", status, body)
+	}
+
+	// locking the user voids their codes and OAuth tokens
+	exchange.Set("code", flow(t, false).Get("code"))
+	exchange.Set("code_verifier", verifier)
+	_, me := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: accessToken})
+	if status, body := do(t, app, request{method: http.MethodPost, path: "/admin/users/" + me["id"].(string) + "/lock", headers: admin}); status != http.StatusOK {
+		t.Fatalf("lock: status = %d, body %v", status, body)
+	}
+	if status, body := requestToken(t, app, exchange, "", ""); status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("exchange code of a locked user: status = %d, body %v", status, body)
+	}
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/oauth/userinfo", token: oauthToken}); status != http.StatusUnauthorized {
+		t.Errorf("userinfo of a locked user: status = %d, want 401", status)
+	}
+
+	query.Set("redirect_uri", "https://evil.example.com/callback")
+	if status, location := authorize(t, app, query); status != http.StatusBadRequest || location.String() != "" {
+		t.Errorf("unregistered redirect_uri: status = %d, location %s", status, location)
+	}
+	query.Set("redirect_uri", "https://wiki.example.com/callback")
+	query.Del("code_challenge")
+	if status, location := authorize(t, app, query); status != http.StatusFound || location.Query().Get("error") != "invalid_request" {
+		t.Errorf("without PKCE: status = %d, location %s", status, location)
+	}
+}
+
+// ssoSignIn starts a login with the provider, signs in there as user and
+// returns the code and state the provider sent back to the login page.
+func ssoSignIn(t *testing.T, app *fiber.App, issuer *ssotest.Issuer, user ssotest.User) map[string]any {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/sso/corp/login", nil), -1)
+	if err != nil {
+		t.Fatalf("GET /auth/sso/corp/login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("start login: status = %d", resp.StatusCode)
+	}
+
+	return ssoAuthorize(t, issuer, user, resp.Header.Get("Location"))
+}
+
+// ssoAuthorize signs user in at the provider's authURL and returns the
+// callback parameters it redirects back with.
+func ssoAuthorize(t *testing.T, issuer *ssotest.Issuer, user ssotest.User, authURL string) map[string]any {
+	t.Helper()
+
+	issuer.SignIn(user)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	idpResp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", authURL, err)
+	}
+	idpResp.Body.Close()
+
+	location, err := url.Parse(idpResp.Header.Get("Location"))
+	if err != nil || location.Host != "app.example.com" {
+		t.Fatalf("provider redirected to %q", idpResp.Header.Get("Location"))
+	}
+	return map[string]any{"code": location.Query().Get("code"), "state": location.Query().Get("state")}
+}
+
+func TestSSOLogin(t *testing.T) {
+	issuer := ssotest.NewIssuer(t, "client-1", "secret-1")
+	app := newTestAppWith(t, func(cfg *config.Config) {
+		cfg.SSOProviders = []config.SSOProvider{{
+			Name:         "corp",
+			Issuer:       issuer.URL,
+			ClientID:     "client-1",
+			ClientSecret: "secret-1",
+			RedirectURL:  "https://app.example.com/sso/callback",
+			Scopes:       []string{"openid", "email", "profile"},
+		}}
+	})
+	callback := func(body map[string]any) (int, map[string]any) {
+		return do(t, app, request{method: http.MethodPost, path: "/auth/sso/corp/callback", body: body})
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/sso/other/login"}); status != http.StatusNotFound {
+		t.Errorf("unknown provider: status = %d, want 404", status)
+	}
+
+	t.Run("new user is created", func(t *testing.T) {
+		params := ssoSignIn(t, app, issuer, ssotest.User{Subject: "u-1", Email: "Alice@corp.example", EmailVerified: true, Name: "Alice"})
+		status, tokens := callback(params)
+		if status != http.StatusOK {
+			t.Fatalf("callback: status = %d, body %v", status, tokens)
+		}
+		if status, _ := callback(params); status != http.StatusBadRequest {
+			t.Errorf("reused state: status = %d, want 400", status)
+		}
+
+		status, me := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: tokens["access_token"].(string)})
+		if status != http.StatusOK || me["email"] != "alice@corp.example" || me["name"] != "Alice" {
+			t.Errorf("me: status = %d, body %v", status, me)
+		}
+
+		// signing in again finds the same user
+		status, again := callback(ssoSignIn(t, app, issuer, ssotest.User{Subject: "u-1", Email: "alice@corp.example", EmailVerified: true}))
+		if status != http.StatusOK {
+			t.Fatalf("second callback: status = %d, body %v", status, again)
+		}
+		var identities []map[string]any
+		if status := doInto(t, app, request{method: http.MethodGet, path: "/auth/identities", token: again["access_token"].(string)}, &identities); status != http.StatusOK || len(identities) != 1 || identities[0]["provider"] != "corp" {
+			t.Fatalf("identities: status = %d, body %v", status, identities)
+		}
+		if status, body := do(t, app, request{method: http.MethodDelete, path: "/auth/identities/" + identities[0]["id"].(string), token: again["access_token"].(string)}); status != http.StatusConflict {
+			t.Errorf("unlink only identity: status = %d, body %v", status, body)
+		}
+	})
+
+	t.Run("verified email links existing user", func(t *testing.T) {
+		// alice's email was verified by the provider that created her
+		status, tokens := callback(ssoSignIn(t, app, issuer, ssotest.User{Subject: "u-5", Email: "alice@corp.example", EmailVerified: true}))
+		if status != http.StatusOK {
+			t.Fatalf("callback: status = %d, body %v", status, tokens)
+		}
+		var identities []map[string]any
+		doInto(t, app, request{method: http.MethodGet, path: "/auth/identities", token: tokens["access_token"].(string)}, &identities)
+		if len(identities) != 2 {
+			t.Errorf("identities = %v", identities)
+		}
+	})
+
+	t.Run("unverified user links the identity themselves", func(t *testing.T) {
+		register(t, app, "bob@example.com")
+		bob := ssotest.User{Subject: "u-2", Email: "bob@example.com", EmailVerified: true, Name: "Robert"}
+
+		// whoever registered bob@example.com may not own it
+		if status, body := callback(ssoSignIn(t, app, issuer, bob)); status != http.StatusConflict {
+			t.Fatalf("callback: status = %d, body %v, want 409", status, body)
+		}
+
+		access, _ := login(t, app, "bob@example.com")
+		status, link := do(t, app, request{method: http.MethodPost, path: "/auth/sso/corp/link", token: access})
+		if status != http.StatusOK {
+			t.Fatalf("start link: status = %d, body %v", status, link)
+		}
+		status, tokens := callback(ssoAuthorize(t, issuer, bob, link["url"].(string)))
+		if status != http.StatusOK {
+			t.Fatalf("link callback: status = %d, body %v", status, tokens)
+		}
+
+		// from now on the identity signs in as bob
+		status, tokens = callback(ssoSignIn(t, app, issuer, bob))
+		if status != http.StatusOK {
+			t.Fatalf("callback: status = %d, body %v", status, tokens)
+		}
+		status, me := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: tokens["access_token"].(string)})
+		if status != http.StatusOK || me["email"] != "bob@example.com" || me["name"] != "Bob" {
+			t.Errorf("me: status = %d, body %v", status, me)
+		}
+
+		var identities []map[string]any
+		doInto(t, app, request{method: http.MethodGet, path: "/auth/identities", token: tokens["access_token"].(string)}, &identities)
+		if len(identities) != 1 {
+			t.Fatalf("identities = %v", identities)
+		}
+		if status, body := do(t, app, request{method: http.MethodDelete, path: "/auth/identities/" + identities[0]["id"].(string), token: tokens["access_token"].(string)}); status != http.StatusNoContent {
+			t.Errorf("unlink: status = %d, body %v", status, body)
+		}
+		// the password still works
+		login(t, app, "bob@example.com")
+	})
+
+	t.Run("identity of another user is not linked", func(t *testing.T) {
+		register(t, app, "erin@example.com")
+		access, _ := login(t, app, "erin@example.com")
+		_, link := do(t, app, request{method: http.MethodPost, path: "/auth/sso/corp/link", token: access})
+		params := ssoAuthorize(t, issuer, ssotest.User{Subject: "u-1", Email: "alice@corp.example", EmailVerified: true}, link["url"].(string))
+		if status, body := callback(params); status != http.StatusConflict {
+			t.Errorf("callback: status = %d, body %v, want 409", status, body)
+		}
+	})
+
+	t.Run("passkey is required after the provider", func(t *testing.T) {
+		status, tokens := callback(ssoSignIn(t, app, issuer, ssotest.User{Subject: "u-4", Email: "dave@corp.example", EmailVerified: true}))
+		if status != http.StatusOK {
+			t.Fatalf("callback: status = %d, body %v", status, tokens)
+		}
+		authn := passkeytest.NewAuthenticator("https://app.example.com")
+		registerPasskey(t, app, authn, tokens["access_token"].(string))
+
+		var resp struct {
+			AccessToken     string          `json:"access_token"`
+			PasskeyRequired passkeyCeremony `json:"passkey_required"`
+		}
+		status = doInto(t, app, request{method: http.MethodPost, path: "/auth/sso/corp/callback", body: ssoSignIn(t, app, issuer, ssotest.User{Subject: "u-4", Email: "dave@corp.example", EmailVerified: true})}, &resp)
+		if status != http.StatusOK || resp.AccessToken != "" || resp.PasskeyRequired.CeremonyID == "" {
+			t.Fatalf("callback: status = %d, body %+v, want a passkey ceremony", status, resp)
+		}
+		status, body := do(t, app, request{method: http.MethodPost, path: "/auth/passkeys/login/finish", body: map[string]any{
+			"ceremony_id": resp.PasskeyRequired.CeremonyID, "credential": json.RawMessage(authn.Login(t, resp.PasskeyRequired.Options)),
+		}})
+		if status != http.StatusOK || body["access_token"] == nil {
+			t.Errorf("finish login: status = %d, body %v", status, body)
+		}
+	})
+
+	t.Run("unverified email is refused", func(t *testing.T) {
+		register(t, app, "carol@example.com")
+
+		status, body := callback(ssoSignIn(t, app, issuer, ssotest.User{Subject: "u-3", Email: "carol@example.com", Name: "Mallory"}))
+		if status != http.StatusForbidden {
+			t.Errorf("callback: status = %d, body %v", status, body)
+		}
+	})
+}
+
+func TestLDAPLogin(t *testing.T) {
+	directory := ldaptest.NewDirectory(t, "dc=corp,dc=example", "cn=svc,dc=corp,dc=example", "svc-secret")
+	directory.AddUser("cn=alice,ou=people,dc=corp,dc=example", "Alice-Directory-1", map[string][]string{
+		"mail":        {"alice@corp.example"},
+		"displayName": {"Alice Liddell"},
+		"memberOf":    {"cn=staff,ou=groups,dc=corp,dc=example", "cn=admins,ou=groups,dc=corp,dc=example"},
+	})
+	directory.AddUser("cn=carol,ou=people,dc=corp,dc=example", "Carol-Directory-1", map[string][]string{
+		"mail":        {"carol@example.com"},
+		"displayName": {"Carol Danvers"},
+	})
+	app := newTestAppWith(t, func(cfg *config.Config) {
+		cfg.LDAPURL = directory.URL
+		cfg.LDAPBindDN = directory.BindDN
+		cfg.LDAPBindPassword = directory.BindPassword
+		cfg.LDAPBaseDN = directory.BaseDN
+		cfg.LDAPGroupRoles = "admin=CN=admins,ou=groups,dc=corp,dc=example;staff=cn=staff,ou=groups,dc=corp,dc=example"
+	})
+	ldapLogin := func(email, password string) (int, map[string]any) {
+		return do(t, app, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{"email": email, "password": password}})
+	}
+	me := func(tokens map[string]any) map[string]any {
+		t.Helper()
+		status, body := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: tokens["access_token"].(string)})
+		if status != http.StatusOK {
+			t.Fatalf("me: status = %d, body %v", status, body)
+		}
+		return body
+	}
+
+	// the first login creates the user, with the role of the first mapping
+	status, tokens := ldapLogin("alice@corp.example", "Alice-Directory-1")
+	if status != http.StatusOK {
+		t.Fatalf("directory login: status = %d, body %v", status, tokens)
+	}
+	if body := me(tokens); body["name"] != "Alice Liddell" || body["role"] != "admin" {
+		t.Errorf("me = %v", body)
+	}
+	if status, body := ldapLogin("alice@corp.example", "Alice-Directory-1"); status != http.StatusOK {
+		t.Errorf("second directory login: status = %d, body %v", status, body)
+	}
+	if status, body := ldapLogin("alice@corp.example", "Wrong-Password-1"); status != http.StatusUnauthorized {
+		t.Errorf("wrong directory password: status = %d, body %v", status, body)
+	}
+
+	if body := me(tokens); body["source"] != "ldap" {
+		t.Errorf("me = %v, want source ldap", body)
+	}
+
+	// a local user with the entry's email is not taken over
+	register(t, app, "carol@example.com")
+	if status, body := ldapLogin("carol@example.com", "Carol-Directory-1"); status != http.StatusConflict {
+		t.Errorf("directory login for local user: status = %d, body %v, want 409", status, body)
+	}
+	access, _ := login(t, app, "carol@example.com")
+	if body := me(map[string]any{"access_token": access}); body["name"] == "Carol Danvers" || body["source"] != "local" {
+		t.Errorf("me = %v", body)
+	}
+
+	t.Run("entry without email", func(t *testing.T) {
+		directory.AddUser("cn=dan,ou=people,dc=corp,dc=example", "Dan-Directory-1", map[string][]string{
+			"uid": {"dan@corp.example"},
+		})
+		byUID := newTestAppWith(t, func(cfg *config.Config) {
+			cfg.LDAPURL = directory.URL
+			cfg.LDAPBindDN = directory.BindDN
+			cfg.LDAPBindPassword = directory.BindPassword
+			cfg.LDAPBaseDN = directory.BaseDN
+			cfg.LDAPUserFilter = "(|(mail=%[1]s)(uid=%[1]s))"
+		})
+		status, body := do(t, byUID, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{"email": "dan@corp.example", "password": "Dan-Directory-1"}})
+		if status != http.StatusForbidden {
+			t.Errorf("status = %d, body %v, want 403", status, body)
+		}
+	})
+
+	t.Run("directory unavailable", func(t *testing.T) {
+		down := newTestAppWith(t, func(cfg *config.Config) {
+			cfg.LDAPURL = "ldap://127.0.0.1:1"
+			cfg.LDAPBaseDN = directory.BaseDN
+		})
+		status, body := do(t, down, request{method: http.MethodPost, path: "/auth/login", body: map[string]any{"email": "alice@corp.example", "password": "Alice-Directory-1"}})
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, body %v", status, body)
+		}
+	})
+}
+
+// samlSignIn starts a login with a SAML provider and signs user in at the
+// identity provider, returning the form it posts to the assertion
+// consumer service.
+func samlSignIn(t *testing.T, app *fiber.App, idp *samltest.IdP, provider string, user samltest.User) url.Values {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/saml/"+provider+"/login", nil), -1)
+	if err != nil {
+		t.Fatalf("GET /auth/saml/%s/login: %v", provider, err)
+	}
+	defer resp.Body.Close()
+	page, _ := io.ReadAll(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusFound:
+		return samlAuthenticate(t, idp, provider, user, resp.Header.Get("Location"), nil)
+	case http.StatusOK:
+		return samlAuthenticate(t, idp, provider, user, "", page)
+	}
+	t.Fatalf("start login: status = %d, body %s", resp.StatusCode, page)
+	return nil
+}
+
+// samlAuthenticate signs user in at the identity provider the browser is
+// sent to, by redirectURL or the page's form, and returns the form the
+// provider posts back.
+func samlAuthenticate(t *testing.T, idp *samltest.IdP, provider string, user samltest.User, redirectURL string, page []byte) url.Values {
+	t.Helper()
+
+	idp.SignIn(user)
+	acsURL, form := samltest.Send(t, redirectURL, page)
+	if want := "https://api.example.com/auth/saml/" + provider + "/acs"; acsURL != want {
+		t.Fatalf("response posts to %q, want %q", acsURL, want)
+	}
+	return form
+}
+
+// samlACS posts form to the assertion consumer service and returns the
+// status and where the browser is sent.
+func samlACS(t *testing.T, app *fiber.App, provider string, form url.Values) (int, *url.URL) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/saml/"+provider+"/acs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST /auth/saml/%s/acs: %v", provider, err)
+	}
+	resp.Body.Close()
+
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	return resp.StatusCode, location
+}
+
+func TestSAMLLogin(t *testing.T) {
+	idp := samltest.NewIdP(t)
+	metadataFile := filepath.Join(t.TempDir(), "idp.xml")
+	if err := os.WriteFile(metadataFile, idp.Metadata(t), 0o600); err != nil {
+		t.Fatalf("write IdP metadata: %v", err)
+	}
+	app := newTestAppWith(t, func(cfg *config.Config) {
+		cfg.SAMLBaseURL = "https://api.example.com"
+		cfg.SAMLProviders = []config.SAMLProvider{
+			{Name: "corp", IDPMetadataFile: metadataFile, RedirectURL: "https://app.example.com/saml/callback", Binding: "redirect", TrustEmail: true},
+			{Name: "corp-post", IDPMetadataFile: metadataFile, RedirectURL: "https://app.example.com/saml/callback", Binding: "post", TrustEmail: true},
+			{Name: "untrusted", IDPMetadataFile: metadataFile, RedirectURL: "https://app.example.com/saml/callback", Binding: "redirect"},
+		}
+	})
+	for _, provider := range []string{"corp", "corp-post", "untrusted"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/saml/"+provider+"/metadata", nil), -1)
+		if err != nil {
+			t.Fatalf("GET metadata: %v", err)
+		}
+		metadata, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/samlmetadata+xml" {
+			t.Fatalf("metadata: status = %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		idp.RegisterSP(t, metadata)
+	}
+	exchange := func(provider string, location *url.URL) (int, map[string]any) {
+		return do(t, app, request{method: http.MethodPost, path: "/auth/saml/" + provider + "/token", body: map[string]any{"code": location.Query().Get("code")}})
+	}
+
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/auth/saml/other/login"}); status != http.StatusNotFound {
+		t.Errorf("unknown provider: status = %d, want 404", status)
+	}
+
+	for _, provider := range []string{"corp", "corp-post"} {
+		t.Run(provider, func(t *testing.T) {
+			email := provider + "@corp.example"
+			form := samlSignIn(t, app, idp, provider, samltest.User{NameID: "u-" + provider, Email: email, Name: "Alice", Locale: "th-TH"})
+			status, location := samlACS(t, app, provider, form)
+			if status != http.StatusSeeOther || location.Host != "app.example.com" || location.Query().Get("code") == "" {
+				t.Fatalf("acs: status = %d, location %v", status, location)
+			}
+			if status, _ := samlACS(t, app, provider, form); status != http.StatusBadRequest {
+				t.Errorf("replayed response: status = %d, want 400", status)
+			}
+
+			status, tokens := exchange(provider, location)
+			if status != http.StatusOK {
+				t.Fatalf("token: status = %d, body %v", status, tokens)
+			}
+			if status, _ := exchange(provider, location); status != http.StatusBadRequest {
+				t.Errorf("reused code: status = %d, want 400", status)
+			}
+
+			status, me := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: tokens["access_token"].(string)})
+			if status != http.StatusOK || me["email"] != email || me["name"] != "Alice" || me["locale"] != "th" {
+				t.Errorf("me: status = %d, body %v", status, me)
+			}
+		})
+	}
+
+	t.Run("untrusted provider's email is not used", func(t *testing.T) {
+		form := samlSignIn(t, app, idp, "untrusted", samltest.User{NameID: "u-mallory", Email: "corp@corp.example", Name: "Mallory"})
+		if status, _ := samlACS(t, app, "untrusted", form); status != http.StatusForbidden {
+			t.Errorf("acs: status = %d, want 403", status)
+		}
+	})
+
+	t.Run("profile is copied on every login", func(t *testing.T) {
+		register(t, app, "bob@example.com")
+		bob := samltest.User{NameID: "u-bob", Email: "Bob@example.com", Name: "Robert"}
+
+		// bob has to sign in to link an identity with his unverified email
+		if status, _ := samlACS(t, app, "corp", samlSignIn(t, app, idp, "corp", bob)); status != http.StatusConflict {
+			t.Fatalf("acs: status = %d, want 409", status)
+		}
+		access, _ := login(t, app, "bob@example.com")
+		status, link := do(t, app, request{method: http.MethodPost, path: "/auth/saml/corp/link", token: access})
+		if status != http.StatusOK || link["url"] == nil {
+			t.Fatalf("start link: status = %d, body %v", status, link)
+		}
+		if status, _ := samlACS(t, app, "corp", samlAuthenticate(t, idp, "corp", bob, link["url"].(string), nil)); status != http.StatusSeeOther {
+			t.Fatalf("link acs: status = %d", status)
+		}
+
+		_, location := samlACS(t, app, "corp", samlSignIn(t, app, idp, "corp", bob))
+		status, tokens := exchange("corp", location)
+		if status != http.StatusOK {
+			t.Fatalf("token: status = %d, body %v", status, tokens)
+		}
+		status, me := do(t, app, request{method: http.MethodGet, path: "/auth/me", token: tokens["access_token"].(string)})
+		if status != http.StatusOK || me["name"] != "Robert" || me["locale"] != "en" {
+			t.Errorf("me: status = %d, body %v", status, me)
+		}
+
+		var identities []map[string]any
+		doInto(t, app, request{method: http.MethodGet, path: "/auth/identities", token: tokens["access_token"].(string)}, &identities)
+		if len(identities) != 1 || identities[0]["provider"] != "corp" {
+			t.Errorf("identities = %v", identities)
+		}
+	})
+
+	t.Run("tampered response is refused", func(t *testing.T) {
+		form := samlSignIn(t, app, idp, "corp", samltest.User{NameID: "u-eve", Email: "eve@corp.example"})
+		raw, _ := base64.StdEncoding.DecodeString(form.Get("SAMLResponse"))
+		// the assertion is encrypted, but the response around it is signed
+		issueInstant := regexp.MustCompile(`IssueInstant="[^"]*"`).Find(raw)
+		earlier := time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+		form.Set("SAMLResponse", base64.StdEncoding.EncodeToString(bytes.Replace(raw, issueInstant, []byte(`IssueInstant="`+earlier+`"`), 1)))
+
+		if status, _ := samlACS(t, app, "corp", form); status != http.StatusUnauthorized {
+			t.Errorf("acs: status = %d, want 401", status)
+		}
+	})
+
+	t.Run("response to another provider is refused", func(t *testing.T) {
+		form := samlSignIn(t, app, idp, "corp", samltest.User{NameID: "u-1", Email: "alice@corp.example"})
+		if status, _ := samlACS(t, app, "corp-post", form); status != http.StatusBadRequest {
+			t.Errorf("acs: status = %d, want 400", status)
+		}
+	})
+}
+
+func TestAdminRequiresKey(t *testing.T) {
+	app := newTestApp(t)
+
+	if status, _ := do(t, app, request{method: http.MethodGet, path: "/admin/webhooks"}); status != http.StatusUnauthorized {
+		t.Errorf("without key: status = %d, want 401", status)
+	}
+
+	status, _ := do(t, app, request{method: http.MethodGet, path: "/admin/webhooks", headers: map[string]string{"X-Admin-Key": "admin-key"}})
+	if status != http.StatusOK {
+		t.Errorf("with key: status = %d, want 200", status)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"X-Admin-Key": "admin-key"}
+
+	status, body := do(t, app, request{method: http.MethodPost, path: "/admin/users/import", headers: admin, body: map[string]any{
+		"users": []map[string]any{{
+			"name": "Dave", "email": "dave@example.com", "age": 40,
+			"password_hash": "pbkdf2_sha256$1000$seasalt2024$2hbxnK/8Tuey3RX6zyz5Jh0+6lq2xrmBz1ClO1X6+PQ=",
+		}},
+	}})
+	if status != http.StatusOK || len(body["imported"].([]any)) != 1 {
+		t.Fatalf("import: status %d, body %v", status, body)
+	}
+
+	login(t, app, "dave@example.com")
+
+	if status, _ := do(t, app, request{method: http.MethodPost, path: "/admin/users/import", headers: admin, body: map[string]any{"users": []any{}}}); status != http.StatusBadRequest {
+		t.Errorf("empty import: status = %d, want 400", status)
+	}
+}
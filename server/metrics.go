@@ -0,0 +1,28 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/natchaphonbw/usermanagement/pkg/utils"
+)
+
+// Metrics serves the password hashing limiter's state in the Prometheus
+// text exposition format.
+func Metrics(c *fiber.Ctx) error {
+	stats := utils.PasswordHashLimiter.Stats()
+
+	var b strings.Builder
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("password_hash_capacity", "gauge", "Password hashes allowed to run at once.", stats.Capacity)
+	metric("password_hash_in_flight", "gauge", "Password hashes currently running.", stats.InFlight)
+	metric("password_hash_queue_depth", "gauge", "Requests waiting to hash a password.", stats.Waiting)
+	metric("password_hash_rejected_total", "counter", "Requests rejected because hashing was saturated.", stats.Rejected)
+
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.SendString(b.String())
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	webhookRepositories "github.com/natchaphonbw/usermanagement/modules/webhooks/repositories"
+	webhookUsecases "github.com/natchaphonbw/usermanagement/modules/webhooks/usecases"
+	"github.com/natchaphonbw/usermanagement/pkg/outbox"
+)
+
+// StartWorkers runs the background workers until ctx is cancelled.
+func StartWorkers(ctx context.Context, db *gorm.DB) {
+	subRepo := webhookRepositories.NewSubscriptionPostgresRepository(db)
+	deliveryRepo := webhookRepositories.NewDeliveryPostgresRepository(db)
+
+	dispatcher := outbox.NewDispatcher(db, outbox.DefaultDispatcherConfig,
+		outbox.NewLogSink(),
+		webhookUsecases.NewWebhookSink(subRepo, deliveryRepo),
+	)
+	go dispatcher.Run(ctx)
+
+	deliveryWorker := webhookUsecases.NewDeliveryWorker(subRepo, deliveryRepo, webhookUsecases.DefaultDeliveryWorkerConfig)
+	go deliveryWorker.Run(ctx)
+}